@@ -48,9 +48,19 @@ func App() *buffalo.App {
 			SessionName: "_library_session",
 		})
 
+		// Background jobs, kept in the database and run on schedules.
+		if worker, workerOff, err := newWorker(app.Logger); err != nil {
+			app.Stop(err)
+		} else {
+			app.Worker, app.WorkerOff = worker, workerOff
+		}
+
 		// Automatically redirect to SSL
 		app.Use(forceSSL())
 
+		// Count and time the requests for the metrics.
+		app.Use(RecordRequests)
+
 		// Log request parameters (filters apply).
 		app.Use(paramlogger.ParameterLogger)
 
@@ -58,6 +68,9 @@ func App() *buffalo.App {
 		// Remove to disable this.
 		app.Use(csrf.New)
 
+		// Removes uploads left unused once the transaction is over.
+		app.Use(CleanUploads)
+
 		// Wraps each request in a transaction.
 		//   c.Value("tx").(*pop.Connection)
 		// Remove to disable this.
@@ -66,6 +79,7 @@ func App() *buffalo.App {
 		app.Use(translations())
 
 		app.GET("/", AuthLanding)
+		app.GET("/metrics", Metrics)
 		// app.Resource("/users", UsersResource{})
 
 		// NOTE: this block should go before any resources
@@ -73,10 +87,12 @@ func App() *buffalo.App {
 		//AuthMiddlewares
 		app.Use(SetCurrentUser)
 		app.Use(Authorize)
+		app.Middleware.Skip(Authorize, Metrics)
 
 		//Routes for Auth
 		auth := app.Group("/auth")
 		auth.GET("/", AuthLanding)
+		auth.GET("/dashboard/metrics", DashboardMetrics)
 		auth.GET("/new", AuthNew)
 		auth.POST("/", AuthCreate)
 		auth.DELETE("/", AuthDestroy)
@@ -95,33 +111,124 @@ func App() *buffalo.App {
 
 		// book resource route
 		auth.GET("/books/index", BooksResource{}.BooksIndex)
+		auth.GET("/books/export", BooksResource{}.BooksExport)
+		auth.GET("/books/shelf_list", BooksResource{}.BooksShelfList)
+		auth.GET("/books/labels", BooksResource{}.BooksLabels)
+		auth.POST("/books/labels", BooksResource{}.BooksLabels)
+		auth.GET("/books/{book_id}/shelf", BooksResource{}.BooksShelf)
+		auth.GET("/books/lookup", BookLookup)
+		auth.GET("/books/import", BookImportNew)
+		auth.POST("/books/import/preview", BookImportPreview)
+		auth.POST("/books/import", BookImportCreate)
 		auth.Resource("/books", BooksResource{})
 
 		// Categories resource route
 		auth.GET("/categories/index", CategoriesResource{}.CategoriesIndex)
+		auth.GET("/categories/tree", CategoriesResource{}.CategoriesTree)
+		auth.PUT("/categories/{category_id}/move", CategoriesResource{}.Move)
 		auth.Resource("/categories", CategoriesResource{})
 
 		// Categories resource route
 		auth.GET("/inventories/index", InventoriesResource{}.InventoriesIndex)
+		auth.GET("/inventories/export", InventoriesResource{}.InventoriesExport)
+		auth.GET("/inventories/valuation", InventoriesResource{}.InventoriesValuation)
 		auth.Resource("/inventories", InventoriesResource{})
+		auth.GET("/stocktakes", StocktakesIndex)
+		auth.POST("/stocktakes", StocktakesCreate)
+		auth.GET("/stocktakes/{stocktake_id}", StocktakesShow)
+		auth.POST("/stocktakes/{stocktake_id}/counts", StocktakesCount)
+		auth.GET("/stocktakes/{stocktake_id}/variance", StocktakesVariance)
+		auth.POST("/stocktakes/{stocktake_id}/close", StocktakesClose)
 		// Categories resource route
 		auth.GET("/customers/index", CustomersResource{}.CustomersIndex)
+		auth.GET("/customers/export", CustomersResource{}.CustomersExport)
+		auth.GET("/customers/cards", CustomersResource{}.CustomersCards)
+		auth.POST("/customers/cards", CustomersResource{}.CustomersCards)
+		auth.POST("/customers/{customer_id}/renew", CustomersResource{}.Renew)
+		auth.POST("/customers/{customer_id}/settle_fines", CustomersResource{}.SettleFines)
 		auth.Resource("/customers", CustomersResource{})
+		auth.Resource("/membership_plans", MembershipPlansResource{})
 
 		// Assign Books resource route
 		// auth.GET("/customers/index", CustomersResource{}.CustomersIndex)
 		auth.GET("/assign_books/getBooks", AssignBooksResource{}.GetBooksData)
 		auth.GET("/assign_books/getCustomers", AssignBooksResource{}.GetCustomersData)
+		auth.GET("/assign_books/export", AssignBooksResource{}.AssignBooksExport)
+		auth.POST("/assign_books/{assign_book_id}/renew", AssignBooksResource{}.Renew)
+		auth.POST("/assign_books/{assign_book_id}/return", AssignBooksResource{}.Return)
 
 		auth.Resource("/assign_books", AssignBooksResource{})
 
+		// circulation desk
+		auth.GET("/circulation", CirculationDesk)
+		auth.GET("/circulation/patron", CirculationPatron)
+		auth.GET("/circulation/item", CirculationItem)
+		auth.POST("/circulation/checkout", CirculationCheckout)
+		auth.POST("/circulation/checkin", CirculationCheckin)
+
+		// circulation reports
+		auth.GET("/reports", ReportsIndex)
+		auth.GET("/reports/{report}", ReportsShow)
+
+		// background jobs' runs and failures
+		auth.GET("/jobs", JobsIndex)
+		auth.POST("/jobs/{job_id}/retry", JobsRetry)
+
+		// authors and publishers resource routes
+		auth.GET("/authors/index", AuthorsResource{}.AuthorsIndex)
+		auth.Resource("/authors", AuthorsResource{})
+		auth.GET("/publishers/index", PublishersResource{}.PublishersIndex)
+		auth.Resource("/publishers", PublishersResource{})
+		auth.GET("/subjects", SubjectsList)
+
+		// bulk import routes
+		imports := auth.Group("/imports")
+		imports.GET("/new", ImportNew)
+		imports.POST("/upload", ImportUpload)
+		imports.POST("/{token}/check", ImportCheck)
+		imports.GET("/{token}/errors", ImportErrors)
+		imports.POST("/{token}", ImportCreate)
+
+		// patron portal, signed in to as a customer rather than a user
+		portal := app.Group("/portal")
+		portal.Middleware.Remove(SetCurrentUser, Authorize)
+		portal.Use(SetCurrentPatron)
+		portal.Use(AuthorizePatron)
+		portal.Middleware.Skip(AuthorizePatron, PortalNew, PortalCreate)
+		portal.GET("/", PortalLanding)
+		portal.GET("/signin", PortalNew)
+		portal.POST("/signin", PortalCreate)
+		portal.DELETE("/signin", PortalDestroy)
+		portal.GET("/history", PortalHistory)
+		portal.POST("/loans/{loan_id}/renew", PortalRenew)
+		portal.GET("/holds", PortalHolds)
+		portal.POST("/holds", PortalHoldCreate)
+		portal.DELETE("/holds/{hold_id}", PortalHoldDestroy)
+		portal.GET("/account", PortalAccount)
+		portal.PUT("/account", PortalAccountUpdate)
+
+		// public catalog, read only and open to all
+		catalog := app.Group("/catalog")
+		catalog.Middleware.Remove(SetCurrentUser, Authorize)
+		catalog.GET("/", CatalogIndex)
+		catalog.GET("/categories/{category_id}", CatalogIndex)
+		catalog.GET("/books/{book_id}", CatalogBook)
+		catalog.GET("/books/{book_id}/{slug}", CatalogBook)
+
+		// replies texted to the library, posted by the SMS gateway
+		smsReplies := app.Group("/sms")
+		smsReplies.Middleware.Remove(SetCurrentUser, Authorize, csrf.New)
+		smsReplies.POST("/inbound", SMSInbound)
+
 		//Routes for User registration
 		users := app.Group("/users")
 		users.GET("/new", UsersNew)
 		users.POST("/", UsersCreate)
 		users.Middleware.Remove(Authorize)
-		// app.Use(FileServerMiddleware)
-		app.GET("/uploads/{path:.+}", FileServerMiddleware)
+		// Public uploads need no login; ServeUploads checks private ones.
+		app.GET("/uploads/{path:.+}", ServeUploads)
+		app.GET("/images/{path:.+}", ServeImage)
+		app.Middleware.Skip(Authorize, ServeUploads, ServeImage)
 
 		app.ServeFiles("/", http.FS(public.FS())) // serve files from the public directory
 	})
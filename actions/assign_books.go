@@ -3,6 +3,7 @@ package actions
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop/v6"
@@ -41,7 +42,7 @@ func (v AssignBooksResource) List(c buffalo.Context) error {
 
 	// Paginate results. Params "page" and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
-	q := tx.PaginateFromParams(c.Params())
+	q := assignBooksQuery(tx, listQuery{Search: c.Param("search")}).PaginateFromParams(c.Params())
 
 	// Retrieve all AssignBooks from the DB
 	if err := q.All(assignBooks); err != nil {
@@ -53,6 +54,7 @@ func (v AssignBooksResource) List(c buffalo.Context) error {
 		c.Set("pagination", q.Paginator)
 		c.Set("PageTitle", "Assign Books List")
 		c.Set("assignBooks", assignBooks)
+		c.Set("search", c.Param("search"))
 		return c.Render(http.StatusOK, r2.HTML("backend/assign_books/index.plush.html"))
 	}).Wants("json", func(c buffalo.Context) error {
 		return c.Render(200, r2.JSON(assignBooks))
@@ -79,7 +81,17 @@ func (v AssignBooksResource) Show(c buffalo.Context) error {
 	}
 
 	return responder.Wants("html", func(c buffalo.Context) error {
+		customer, book := &models.Customer{}, &models.Book{}
+		if err := tx.Find(customer, assignBook.CustomerID); err != nil {
+			return err
+		}
+		if err := tx.Find(book, assignBook.BookID); err != nil {
+			return err
+		}
 		c.Set("assignBook", assignBook)
+		c.Set("customer", customer)
+		c.Set("book", book)
+		c.Set("daysLate", assignBook.DaysLate(time.Now()))
 		c.Set("PageTitle", "Show Assign Book")
 		return c.Render(http.StatusOK, r2.HTML("backend/assign_books/show.plush.html"))
 	}).Wants("json", func(c buffalo.Context) error {
@@ -132,13 +144,13 @@ func (v AssignBooksResource) GetCustomersData(c buffalo.Context) error {
 
 	if c.Param("q") != "" {
 		searchValue := c.Param("q")
-		if err := tx.Select("name, email, id").
-			RawQuery("SELECT name, email, id FROM customers WHERE name LIKE ? OR email LIKE ?", "%"+searchValue+"%", "%"+searchValue+"%").
+		if err := tx.Select("name, email, id, card_number").
+			RawQuery("SELECT name, email, id, card_number FROM customers WHERE name LIKE ? OR email LIKE ? OR card_number = ?", "%"+searchValue+"%", "%"+searchValue+"%", searchValue).
 			All(customers); err != nil {
 			return err
 		}
 	} else {
-		if err := tx.Select("name, email, id").All(customers); err != nil {
+		if err := tx.Select("name, email, id, card_number").All(customers); err != nil {
 			return err
 		}
 	}
@@ -309,3 +321,151 @@ func (v AssignBooksResource) Destroy(c buffalo.Context) error {
 		return c.Render(http.StatusOK, r2.XML(assignBook))
 	}).Respond(c)
 }
+
+// Renew extends a loan by the loan period of the customer's plan, as
+// long as the plan allows another renewal. This function is mapped to
+// the path POST /auth/assign_books/{assign_book_id}/renew
+func (v AssignBooksResource) Renew(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	assignBook := &models.AssignBook{}
+	if err := tx.Find(assignBook, c.Param("assign_book_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	verrs, err := assignBook.Renew(tx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Flash().Add("danger", verrs.Error())
+			return c.Redirect(http.StatusSeeOther, "/auth/assign_books/%v", assignBook.ID)
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Wants("xml", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.XML(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "assign_book.renewed.success", assignBook))
+		return c.Redirect(http.StatusSeeOther, "/auth/assign_books/%v", assignBook.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(assignBook))
+	}).Wants("xml", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.XML(assignBook))
+	}).Respond(c)
+}
+
+// Return checks a book back in, fining the customer by their plan for
+// each day it is late, and puts it aside for the next hold on it. This
+// function is mapped to the path
+// POST /auth/assign_books/{assign_book_id}/return
+func (v AssignBooksResource) Return(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	assignBook := &models.AssignBook{}
+	if err := tx.Find(assignBook, c.Param("assign_book_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	now := time.Now()
+	hold, err := assignBook.CheckIn(tx, now)
+	if err != nil {
+		return err
+	}
+	holder := &models.Customer{}
+	if hold != nil {
+		if err := tx.Find(holder, hold.CustomerID); err != nil {
+			return err
+		}
+		if _, err := notifyHolder(c, tx, *hold, now); err != nil {
+			return err
+		}
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		msg := T.Translate(c, "assign_book.returned.success")
+		if !assignBook.Fine.IsZero() {
+			msg = T.Translate(c, "assign_book.returned.fine", map[string]interface{}{
+				"Days": assignBook.DaysLate(now),
+				"Fine": assignBook.Fine.Format(languages(c)...),
+			})
+		}
+		c.Flash().Add("success", msg)
+		if hold != nil {
+			c.Flash().Add("info", T.Translate(c, "assign_book.returned.hold", map[string]interface{}{
+				"Name":       holder.Name,
+				"ReadyUntil": formatDate(hold.ReadyUntil),
+			}))
+		}
+		return c.Redirect(http.StatusSeeOther, "/auth/assign_books/%v", assignBook.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(assignBook))
+	}).Wants("xml", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.XML(assignBook))
+	}).Respond(c)
+}
+
+// assignBookRow is one line of the assign books export.
+type assignBookRow struct {
+	CustomerName  string `db:"customer_name"`
+	CustomerEmail string `db:"customer_email"`
+	BookNo        string `db:"book_no"`
+	Title         string `db:"title"`
+	AssignDate    string `db:"assign_date"`
+	ReturnDate    string `db:"return_date"`
+}
+
+func (assignBookRow) TableName() string {
+	return "assign_books"
+}
+
+// assignBooksQuery filters assign books by customer or book, newest
+// first.
+func assignBooksQuery(tx *pop.Connection, lq listQuery) *pop.Query {
+	q := tx.Q().
+		LeftJoin("customers", "customers.id = assign_books.customer_id").
+		LeftJoin("books", "books.id = assign_books.book_id")
+	if lq.Search != "" {
+		like := lq.like()
+		q = q.Where("customers.name LIKE ? OR customers.email LIKE ? OR books.title LIKE ? OR books.book_no LIKE ?", like, like, like, like)
+	}
+	return q.Order(lq.orderBy("assign_books.assign_date desc", "assign_books.id"))
+}
+
+// AssignBooksExport downloads the assign books list, filtered by the
+// "search" param like the list, as CSV, XLSX or PDF.
+// This function is mapped to the path GET /auth/assign_books/export
+func (v AssignBooksResource) AssignBooksExport(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	lq := listQuery{Search: c.Param("search")}
+
+	header := []string{"Customer", "Email", "Book No", "Title", "Assign Date", "Return Date"}
+	return streamExport(c, "Assign Books", header, func(page int) ([][]string, error) {
+		var list []assignBookRow
+		err := assignBooksQuery(tx, lq).
+			Select("customers.name AS customer_name", "customers.email AS customer_email", "books.book_no", "books.title",
+				"assign_books.assign_date", "assign_books.return_date").
+			Paginate(page, exportBatch).All(&list)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([][]string, 0, len(list))
+		for _, a := range list {
+			rows = append(rows, []string{a.CustomerName, a.CustomerEmail, a.BookNo, a.Title, a.AssignDate, a.ReturnDate})
+		}
+		return rows, nil
+	})
+}
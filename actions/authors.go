@@ -0,0 +1,269 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/x/responder"
+
+	"library/models"
+)
+
+// AuthorsResource is the resource for the Author model
+type AuthorsResource struct {
+	buffalo.Resource
+}
+
+// authorsSortable maps the authors table columns to the SQL they order
+// by.
+var authorsSortable = map[string]string{
+	"name":       "authors.name",
+	"updated_at": "authors.updated_at",
+}
+
+// AuthorsIndex serves the authors table.
+// This function is mapped to the path GET /auth/authors/index
+func (v AuthorsResource) AuthorsIndex(c buffalo.Context) error {
+	draw := c.Param("draw")
+	start, _ := strconv.Atoi(c.Param("start"))
+	length, _ := strconv.Atoi(c.Param("length"))
+	lq := listQueryFromParams(c, authorsSortable)
+
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	q := tx.Q()
+	if lq.Search != "" {
+		q = q.Where("authors.name LIKE ?", lq.like())
+	}
+	q = q.Order(lq.orderBy("authors.name asc", "authors.id")).Paginate((start/length)+1, length)
+
+	var authors models.Authors
+	if err := q.All(&authors); err != nil {
+		return err
+	}
+	ids := make([]interface{}, len(authors))
+	for i, a := range authors {
+		ids[i] = a.ID
+	}
+	counts, err := creditedBookCounts(tx, "book_authors", "author_id", ids)
+	if err != nil {
+		return err
+	}
+
+	count, err := tx.Count(&models.Authors{})
+	if err != nil {
+		return err
+	}
+
+	data := []interface{}{}
+	for _, author := range authors {
+		data = append(data, formatCreditData("authors", author.ID.String(), author.Name, counts[author.ID.String()], author.UpdatedAt))
+	}
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"draw":            draw,
+		"recordsTotal":    count,
+		"recordsFiltered": q.Paginator.TotalEntriesSize,
+		"data":            data,
+	}))
+}
+
+// List renders the authors table. JSON requests get the authors whose
+// name matches the "q" param, as used by the book form.
+// This function is mapped to the path GET /auth/authors
+func (v AuthorsResource) List(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Set("PageTitle", "Authors List")
+		return c.Render(http.StatusOK, r2.HTML("backend/authors/index.plush.html"))
+	}).Wants("json", func(c buffalo.Context) error {
+		authors := models.Authors{}
+		if err := tx.Where("name LIKE ?", "%"+c.Param("q")+"%").Order("name").Limit(20).All(&authors); err != nil {
+			return err
+		}
+		return c.Render(200, r2.JSON(authors))
+	}).Respond(c)
+}
+
+// Show lists the books of an Author with their loan statistics. This
+// function is mapped to the path GET /auth/authors/{author_id}
+func (v AuthorsResource) Show(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	author := &models.Author{}
+	if err := tx.Find(author, c.Param("author_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	books, stats, err := models.CreditedBooks(tx, author)
+	if err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Set("author", author)
+		c.Set("rows", creditedBookRows(books, stats))
+		c.Set("totals", loanTotals(stats))
+		c.Set("PageTitle", "Show Author")
+		return c.Render(http.StatusOK, r2.HTML("backend/authors/show.plush.html"))
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(200, r2.JSON(map[string]interface{}{
+			"author": author,
+			"books":  books,
+			"stats":  stats,
+		}))
+	}).Respond(c)
+}
+
+// New renders the form for creating a new Author.
+// This function is mapped to the path GET /auth/authors/new
+func (v AuthorsResource) New(c buffalo.Context) error {
+	c.Set("author", &models.Author{})
+	c.Set("PageTitle", "Create Author")
+	return c.Render(http.StatusOK, r2.HTML("backend/authors/new.plush.html"))
+}
+
+// Create adds an Author to the DB. This function is mapped to the
+// path POST /auth/authors
+func (v AuthorsResource) Create(c buffalo.Context) error {
+	author := &models.Author{}
+	if err := c.Bind(author); err != nil {
+		return err
+	}
+
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	verrs, err := tx.ValidateAndCreate(author)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Set("errors", verrs)
+			c.Set("author", author)
+			c.Set("PageTitle", "Create Author")
+			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/authors/new.plush.html"))
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "author.created.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/authors/%v", author.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusCreated, r2.JSON(author))
+	}).Respond(c)
+}
+
+// Edit renders a edit form for an Author. This function is
+// mapped to the path GET /auth/authors/{author_id}/edit
+func (v AuthorsResource) Edit(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	author := &models.Author{}
+	if err := tx.Find(author, c.Param("author_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	c.Set("author", author)
+	c.Set("PageTitle", "Edit Author")
+	return c.Render(http.StatusOK, r2.HTML("backend/authors/edit.plush.html"))
+}
+
+// Update renames an Author; the credit line of its books follows.
+// This function is mapped to the path PUT /auth/authors/{author_id}
+func (v AuthorsResource) Update(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	author := &models.Author{}
+	if err := tx.Find(author, c.Param("author_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	if err := c.Bind(author); err != nil {
+		return err
+	}
+
+	verrs, err := tx.ValidateAndUpdate(author)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Set("errors", verrs)
+			c.Set("author", author)
+			c.Set("PageTitle", "Edit Author")
+			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/authors/edit.plush.html"))
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "author.updated.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/authors/%v", author.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(author))
+	}).Respond(c)
+}
+
+// Destroy deletes an Author that is not credited on any book. This
+// function is mapped to the path DELETE /auth/authors/{author_id}
+func (v AuthorsResource) Destroy(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	author := &models.Author{}
+	if err := tx.Find(author, c.Param("author_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	counts, err := creditedBookCounts(tx, "book_authors", "author_id", []interface{}{author.ID})
+	if err != nil {
+		return err
+	}
+	if n := counts[author.ID.String()]; n > 0 {
+		msg := T.Translate(c, "author.destroyed.in_use", map[string]interface{}{"Count": n})
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Flash().Add("danger", msg)
+			return c.Redirect(http.StatusSeeOther, "/auth/authors/%v", author.ID)
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusConflict, r2.JSON(map[string]string{"error": msg}))
+		}).Respond(c)
+	}
+
+	if err := tx.Destroy(author); err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "author.destroyed.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/authors")
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(author))
+	}).Respond(c)
+}
@@ -0,0 +1,47 @@
+package actions
+
+import (
+	"net/http"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_AuthorsResource_Show() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	book := &models.Book{CategoryID: category.ID.String(), Title: "Good Omens", BookNo: "B-1", Price: money.New(1000, "USD"), Status: 1, Author: "Neil Gaiman"}
+	as.NoError(as.DB.Create(book))
+
+	author := &models.Author{}
+	as.NoError(as.DB.Where("name = ?", "Neil Gaiman").First(author))
+
+	res := as.HTML("/auth/authors/%s", author.ID).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Good Omens")
+
+	// an author with books can't be removed
+	res = as.HTML("/auth/authors/%s", author.ID).Delete()
+	as.Equal(http.StatusSeeOther, res.Code)
+	exists, err := as.DB.Where("id = ?", author.ID).Exists(&models.Author{})
+	as.NoError(err)
+	as.True(exists)
+}
+
+func (as *ActionSuite) Test_AuthorsResource_List_JSON() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	as.NoError(as.DB.Create(&models.Author{Name: "Ann Lee"}))
+	as.NoError(as.DB.Create(&models.Author{Name: "Bob Ray"}))
+
+	res := as.JSON("/auth/authors?q=ann").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Ann Lee")
+	as.NotContains(res.Body.String(), "Bob Ray")
+}
@@ -0,0 +1,144 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"library/marc"
+	"library/models"
+)
+
+// importDir holds uploaded import files between the preview and the
+// commit step. It is outside ./uploads so pending files are never
+// served to the public.
+var importDir = filepath.Join(os.TempDir(), "library-imports")
+
+// BookImportNew renders the MARC21/MARCXML upload form.
+// This function is mapped to the path GET /auth/books/import
+func BookImportNew(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	categories, err := models.CategoryTree(tx)
+	if err != nil {
+		return err
+	}
+	c.Set("categories", categories)
+	c.Set("PageTitle", "Import Books")
+	return c.Render(http.StatusOK, r2.HTML("backend/book_imports/new.plush.html"))
+}
+
+// BookImportPreview stores the uploaded file, maps every record and
+// shows what would be imported without writing anything.
+// This function is mapped to the path POST /auth/books/import/preview
+func BookImportPreview(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	f, err := c.File("File")
+	if err != nil || !f.Valid() {
+		c.Flash().Add("danger", T.Translate(c, "bookImport.file.missing"))
+		return c.Redirect(http.StatusSeeOther, "/auth/books/import")
+	}
+
+	if err := os.MkdirAll(importDir, 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	token, err := uuid.NewV4()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dst, err := os.Create(importFile(token.String()) + ".marc")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, f); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+
+	categoryID := c.Param("CategoryID")
+	rows, err := readBookImport(tx, dst, categoryID)
+	if err != nil {
+		os.Remove(importFile(token.String()) + ".marc")
+		c.Flash().Add("danger", T.Translate(c, "bookImport.file.invalid")+" "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/auth/books/import")
+	}
+
+	c.Set("rows", rows)
+	c.Set("token", token.String())
+	c.Set("fileName", f.Filename)
+	c.Set("categoryID", categoryID)
+	c.Set("PageTitle", "Import Books - Preview")
+	return c.Render(http.StatusOK, r2.HTML("backend/book_imports/preview.plush.html"))
+}
+
+// BookImportCreate commits a previewed import. Every book is created in
+// the request transaction, so a database failure leaves the catalogue
+// untouched.
+// This function is mapped to the path POST /auth/books/import
+func BookImportCreate(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	token, err := uuid.FromString(c.Param("Token"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	path := importFile(token.String()) + ".marc"
+	file, err := os.Open(path)
+	if err != nil {
+		c.Flash().Add("danger", T.Translate(c, "bookImport.file.expired"))
+		return c.Redirect(http.StatusSeeOther, "/auth/books/import")
+	}
+	defer os.Remove(path)
+	defer file.Close()
+
+	rows, err := readBookImport(tx, file, c.Param("CategoryID"))
+	if err != nil {
+		return c.Error(http.StatusUnprocessableEntity, err)
+	}
+	if err := models.CommitBookImport(tx, rows); err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.Flash().Add("success", T.Translate(c, "bookImport.created.success", map[string]interface{}{
+		"Count": rows.Count(models.BookImportCreated),
+	}))
+	c.Set("rows", rows)
+	c.Set("PageTitle", "Import Books - Report")
+	return c.Render(http.StatusOK, r2.HTML("backend/book_imports/report.plush.html"))
+}
+
+func readBookImport(tx *pop.Connection, r io.Reader, categoryID string) (models.BookImportRows, error) {
+	records, err := marc.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("the file contains no records")
+	}
+	return models.PrepareBookImport(tx, records, categoryID)
+}
+
+// importFile returns the path, without extension, under which the
+// files of a pending import are kept.
+func importFile(token string) string {
+	return filepath.Join(importDir, token)
+}
@@ -0,0 +1,27 @@
+package actions
+
+import (
+	"net/http"
+)
+
+func (as *ActionSuite) Test_BookImportNew() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/auth/books/import").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Import Books from MARC")
+}
+
+func (as *ActionSuite) Test_BookImportCreate_Expired() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/auth/books/import").Post(map[string]string{
+		"Token": "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	})
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.Equal("/auth/books/import", res.Location())
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 
 	"library/models"
+	"library/money"
 )
 
 // This file is generated by Buffalo. It offers a basic structure for
@@ -35,10 +36,7 @@ func (v BooksResource) BooksIndex(c buffalo.Context) error {
 	draw := c.Param("draw")
 	start, _ := strconv.Atoi(c.Param("start"))
 	length, _ := strconv.Atoi(c.Param("length"))
-	searchValue := c.Param("search[value]")
-	orderColumnIndex, _ := strconv.Atoi(c.Param("order[0][column]"))
-	orderColumnName := c.Param("columns[" + strconv.Itoa(orderColumnIndex) + "][data]")
-	orderDir := c.Param("order[0][dir]")
+	lq := listQueryFromParams(c, booksSortable)
 
 	// Create a DB connection
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -51,13 +49,7 @@ func (v BooksResource) BooksIndex(c buffalo.Context) error {
 	perPage := length
 
 	// Prepare the query
-	q := tx.Paginate(currentPage, perPage)
-	q = q.Join("categories", "categories.id = books.category_id").Order(orderColumnName + " " + orderDir)
-
-	// Apply search filter
-	if searchValue != "" {
-		q = q.Where("books.title LIKE ? OR books.book_no LIKE ? OR books.author LIKE ? OR books.price LIKE ? OR categories.category_name LIKE ?	", "%"+searchValue+"%", "%"+searchValue+"%", "%"+searchValue+"%", "%"+searchValue+"%", "%"+searchValue+"%")
-	}
+	q := booksQuery(tx, lq, c.Param("category_id")).Paginate(currentPage, perPage)
 
 	// Fetch the data
 	var books models.Books
@@ -75,14 +67,14 @@ func (v BooksResource) BooksIndex(c buffalo.Context) error {
 	response := map[string]interface{}{
 		"draw":            draw,
 		"recordsTotal":    count,
-		"recordsFiltered": len(books),
-		"data":            formatBooksData(books),
+		"recordsFiltered": q.Paginator.TotalEntriesSize,
+		"data":            formatBooksData(books, languages(c)),
 	}
 
 	return c.Render(200, r.JSON(response))
 }
 
-func formatBooksData(books models.Books) []interface{} {
+func formatBooksData(books models.Books, langs []string) []interface{} {
 	var formattedData []interface{}
 
 	for _, book := range books {
@@ -92,12 +84,13 @@ func formatBooksData(books models.Books) []interface{} {
 		// Add the existing category data
 		formattedBook["id"] = book.ID
 		bookID := book.ID.String()
-		formattedBook["picture_path"] = "<img src='" + book.PicturePath + "' style='width: 80px; height: 100px'>"
+		formattedBook["picture_path"] = "<img src='" + imageURL(book.PicturePath, 80, 100) + "' style='width: 80px; height: 100px'>"
 		formattedBook["category_name"] = book.Category.CategoryName
 		formattedBook["title"] = book.Title
 		formattedBook["book_no"] = book.BookNo
 		formattedBook["author"] = book.Author
-		formattedBook["price"] = book.Price
+		formattedBook["call_number"] = book.CallNumber
+		formattedBook["price"] = book.Price.Format(langs...)
 		if book.Status == 1 {
 			formattedBook["status"] = "<label class='label label-success'>Active</label>"
 		} else {
@@ -138,8 +131,16 @@ func (v BooksResource) List(c buffalo.Context) error {
 	}
 
 	return responder.Wants("html", func(c buffalo.Context) error {
+		categories, err := models.CategoryTree(tx)
+		if err != nil {
+			return err
+		}
 		// Add the paginator to the context so it can be used in the template.
 		c.Set("pagination", q.Paginator)
+		c.Set("labelSheets", labelSheets(c))
+		c.Set("labelSheet", defaultLabelSheet)
+		c.Set("categories", categories)
+		c.Set("categoryID", c.Param("category_id"))
 		c.Set("PageTitle", "Books List")
 		return c.Render(http.StatusOK, r2.HTML("backend/books/index.plush.html"))
 	}).Wants("json", func(c buffalo.Context) error {
@@ -179,9 +180,20 @@ func (v BooksResource) Show(c buffalo.Context) error {
 	if err := tx.Eager().Find(book, c.Param("book_id")); err != nil {
 		return c.Error(http.StatusNotFound, err)
 	}
+	if err := book.LoadCredits(tx); err != nil {
+		return err
+	}
 
 	return responder.Wants("html", func(c buffalo.Context) error {
+		crumbs := models.Categories{}
+		if book.Category != nil {
+			var err error
+			if crumbs, err = book.Category.Breadcrumbs(tx); err != nil {
+				return err
+			}
+		}
 		c.Set("book", book)
+		c.Set("categoryCrumbs", crumbs)
 		c.Set("PageTitle", "Show Book")
 		return c.Render(http.StatusOK, r2.HTML("backend/books/show.plush.html"))
 	}).Wants("json", func(c buffalo.Context) error {
@@ -194,23 +206,18 @@ func (v BooksResource) Show(c buffalo.Context) error {
 // New renders the form for creating a new Book.
 // This function is mapped to the path GET /books/new
 func (v BooksResource) New(c buffalo.Context) error {
-	c.Set("book", &models.Book{})
+	c.Set("book", &models.Book{Currency: money.DefaultCurrency})
 
 	tx, ok := c.Value("tx").(*pop.Connection)
 	if !ok {
 		return fmt.Errorf("no transaction found")
 	}
-	categories := &models.Categories{}
-
-	// Paginate results. Params "page" and "per_page" control pagination.
-	// Default values are "page=1" and "per_page=20".
-	q := tx.PaginateFromParams(c.Params())
-	c.Set("categories", categories)
-	c.Set("PageTitle", "Create Category")
-	// Retrieve all Books from the DB
-	if err := q.All(categories); err != nil {
+	categories, err := models.CategoryTree(tx)
+	if err != nil {
 		return err
 	}
+	c.Set("categories", categories)
+	c.Set("PageTitle", "Create Category")
 	return c.Render(http.StatusOK, r2.HTML("backend/books/new.plush.html"))
 }
 
@@ -225,15 +232,18 @@ func (v BooksResource) Create(c buffalo.Context) error {
 		return err
 	}
 
+	attachLookupCover(c, book)
+
 	// Get the DB connection from the context
 	tx := c.Value("tx").(*pop.Connection)
 
-	var categories []*models.Category
-	if err := tx.All(&categories); err != nil {
-		return errors.WithStack(err)
+	categories, err := models.CategoryTree(tx)
+	if err != nil {
+		return err
 	}
 
 	verrs, err := book.Create(tx)
+	trackUpload(c, "", book.PicturePath)
 	// verrs, err := book.Create(tx)
 	if err != nil {
 		return errors.WithStack(err)
@@ -283,13 +293,16 @@ func (v BooksResource) Edit(c buffalo.Context) error {
 
 	// Allocate an empty Book
 	book := &models.Book{}
-	categories := models.Categories{}
-	if err := tx.Eager().All(&categories); err != nil {
-		return errors.WithStack(err)
+	categories, err := models.CategoryTree(tx)
+	if err != nil {
+		return err
 	}
 	if err := tx.Eager().Find(book, c.Param("book_id")); err != nil {
 		return c.Error(http.StatusNotFound, err)
 	}
+	if err := book.LoadCredits(tx); err != nil {
+		return err
+	}
 	c.Set("categories", categories)
 	c.Set("book", book)
 	c.Set("PageTitle", "Edit Category")
@@ -311,16 +324,19 @@ func (v BooksResource) Update(c buffalo.Context) error {
 	if err := tx.Find(book, c.Param("book_id")); err != nil {
 		return c.Error(http.StatusNotFound, err)
 	}
+	oldPicture := book.PicturePath
 
 	// Bind Book to the html form elements
 	if err := c.Bind(book); err != nil {
 		return err
 	}
-	var categories []*models.Category
-	if err := tx.All(&categories); err != nil {
-		return errors.WithStack(err)
+	attachLookupCover(c, book)
+	categories, err := models.CategoryTree(tx)
+	if err != nil {
+		return err
 	}
 	verrs, err := book.Update(tx)
+	trackUpload(c, oldPicture, book.PicturePath)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -389,3 +405,66 @@ func (v BooksResource) Destroy(c buffalo.Context) error {
 		return c.Render(http.StatusOK, r2.XML(book))
 	}).Respond(c)
 }
+
+// booksSortable maps the books table columns to the SQL they order by.
+var booksSortable = map[string]string{
+	"title":         "books.title",
+	"category_name": "categories.category_name",
+	"book_no":       "books.book_no",
+	"author":        "books.author",
+	"call_number":   "books.call_number_sort",
+	"price":         "books.price",
+	"status":        "books.status",
+	"updated_at":    "books.updated_at",
+}
+
+// booksQuery filters and orders books the way the books list does.
+// When categoryID is set only the books in that category and its
+// subcategories are listed.
+func booksQuery(tx *pop.Connection, lq listQuery, categoryID string) *pop.Query {
+	q := tx.Q().Join("categories", "categories.id = books.category_id")
+	if categoryID != "" {
+		q = models.InCategory(q, "books.category_id", categoryID)
+	}
+	if lq.Search != "" {
+		like := lq.like()
+		q = q.Where("books.title LIKE ? OR books.book_no LIKE ? OR books.author LIKE ? OR books.call_number LIKE ? OR books.price LIKE ? OR categories.category_name LIKE ?", like, like, like, like, like, like)
+	}
+	return q.Order(lq.orderBy("books.created_at desc", "books.id"))
+}
+
+// BooksExport downloads the books list, with the same search and order
+// as on screen, as CSV, XLSX or PDF.
+// This function is mapped to the path GET /auth/books/export
+func (v BooksResource) BooksExport(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	lq := listQueryFromParams(c, booksSortable)
+	categoryID := c.Param("category_id")
+
+	header := []string{"Book No", "Title", "Category", "ISBN", "Author", "Publisher", "Call Number", "Year", "Price", "Replacement Cost", "Currency", "Status", "Updated At"}
+	return streamExport(c, "Books", header, func(page int) ([][]string, error) {
+		var books models.Books
+		if err := booksQuery(tx, lq, categoryID).Paginate(page, exportBatch).Eager("Category").All(&books); err != nil {
+			return nil, err
+		}
+		rows := make([][]string, 0, len(books))
+		for _, book := range books {
+			category, year, status := "", "", "De-Active"
+			if book.Category != nil {
+				category = book.Category.CategoryName
+			}
+			if book.Year > 0 {
+				year = strconv.Itoa(book.Year)
+			}
+			if book.Status == 1 {
+				status = "Active"
+			}
+			rows = append(rows, []string{book.BookNo, book.Title, category, book.ISBN, book.Author, book.Publisher,
+				book.CallNumber, year, book.Price.Decimal(), book.ReplacementCost.Decimal(), book.Currency, status, book.UpdatedAt.Format("2006-01-02 15:04")})
+		}
+		return rows, nil
+	})
+}
@@ -0,0 +1,197 @@
+package actions
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/models"
+)
+
+// The public catalog lets anyone search and browse the books in
+// circulation, with their covers and how many copies are on the shelf.
+// It needs no sign in and shows nothing about who has borrowed what.
+
+// started tells the catalog pages of this run of the app from those of
+// an earlier one, whose templates may differ.
+var started = time.Now()
+
+// catalogNotModified sets the validators and cache headers of a catalog
+// page that shows what was there at modified, with books books in the
+// library, and reports whether the browser's copy is still current.
+// Pages are cached privately: they carry the session cookie.
+func catalogNotModified(c buffalo.Context, modified time.Time, books int) bool {
+	etag := fmt.Sprintf(`W/"%x-%x-%x"`, started.Unix(), modified.UnixNano(), books)
+	h := c.Response().Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	h.Set("Cache-Control", "private, max-age=60")
+
+	req := c.Request()
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			if tag = strings.TrimSpace(tag); tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.Truncate(time.Second).After(since)
+}
+
+// catalogFresh answers a conditional request for a catalog page with
+// 304 Not Modified when nothing it shows has changed since. It returns
+// true when it did.
+func catalogFresh(c buffalo.Context, tx *pop.Connection) (bool, error) {
+	modified, books, err := models.CatalogVersion(tx)
+	if err != nil {
+		return false, err
+	}
+	if catalogNotModified(c, modified, books) {
+		c.Response().WriteHeader(http.StatusNotModified)
+		return true, nil
+	}
+	return false, nil
+}
+
+// bookCatalogPath is the address of a book in the catalog, with its
+// title in it for readers and search engines, as in
+// /catalog/books/<id>/the-left-hand-of-darkness/.
+func bookCatalogPath(book models.Book) string {
+	return fmt.Sprintf("/catalog/books/%s/%s/", book.ID, book.Slug())
+}
+
+// CatalogIndex searches the books in circulation by title, author,
+// ISBN, book or call number and subject, within a category and its
+// subcategories when one is chosen. This function is mapped to the
+// paths GET /catalog and GET /catalog/categories/{category_id}
+func CatalogIndex(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	if fresh, err := catalogFresh(c, tx); fresh || err != nil {
+		return err
+	}
+
+	tree, err := models.CategoryTree(tx)
+	if err != nil {
+		return err
+	}
+	var category *models.Category
+	categoryID := c.Param("category_id")
+	for i := range tree {
+		if tree[i].ID.String() == categoryID {
+			category = &tree[i]
+		}
+	}
+	if categoryID != "" && category == nil {
+		return c.Error(http.StatusNotFound, errors.Errorf("no category %s", categoryID))
+	}
+
+	var counts []struct {
+		CategoryID string `db:"category_id"`
+		Books      int    `db:"books"`
+	}
+	if err := tx.RawQuery("SELECT category_id, COUNT(*) AS books FROM books WHERE status = 1 GROUP BY category_id").All(&counts); err != nil {
+		return err
+	}
+	perCategory := map[string]int{}
+	for _, n := range counts {
+		perCategory[n.CategoryID] = n.Books
+	}
+
+	search := strings.TrimSpace(c.Param("q"))
+	q := tx.Where("books.status = 1")
+	if category != nil {
+		q = models.InCategory(q, "books.category_id", category.ID.String())
+	}
+	if search != "" {
+		like := "%" + search + "%"
+		q = q.Where("(books.title LIKE ? OR books.author LIKE ? OR books.isbn = ? OR books.book_no = ? OR books.call_number LIKE ? OR EXISTS (SELECT 1 FROM book_subjects JOIN subjects ON subjects.id = book_subjects.subject_id WHERE book_subjects.book_id = books.id AND subjects.name LIKE ?))",
+			like, like, models.NormalizeISBN(search), search, search+"%", like)
+	}
+	books := models.Books{}
+	q = q.Order("books.title, books.id").PaginateFromParams(c.Params())
+	if err := q.All(&books); err != nil {
+		return err
+	}
+	availability, err := models.BookAvailability(tx, books)
+	if err != nil {
+		return err
+	}
+
+	title := "Catalog"
+	if category != nil {
+		title = category.Trail
+	}
+	c.Set("PageTitle", title)
+	c.Set("libraryName", libraryName)
+	c.Set("categories", tree)
+	c.Set("bookCounts", tree.SubtreeTotals(perCategory))
+	c.Set("category", category)
+	c.Set("categoryID", categoryID)
+	c.Set("search", search)
+	c.Set("books", books)
+	c.Set("availability", availability)
+	c.Set("pagination", q.Paginator)
+	return c.Render(http.StatusOK, r.HTML("catalog/index.plush.html"))
+}
+
+// CatalogBook shows a book in circulation: its cover, credits and
+// description, and how many copies are on the shelf. Addresses without
+// the book's current title are sent on to the one with it.
+// This function is mapped to the paths GET /catalog/books/{book_id}
+// and GET /catalog/books/{book_id}/{slug}
+func CatalogBook(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	book := &models.Book{}
+	if err := tx.Where("status = 1").Find(book, c.Param("book_id")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Error(http.StatusNotFound, err)
+		}
+		return err
+	}
+	if c.Param("slug") != book.Slug() {
+		return c.Redirect(http.StatusMovedPermanently, bookCatalogPath(*book))
+	}
+	if fresh, err := catalogFresh(c, tx); fresh || err != nil {
+		return err
+	}
+
+	if err := book.LoadCredits(tx); err != nil {
+		return err
+	}
+	category := &models.Category{}
+	if err := tx.Find(category, book.CategoryID); err != nil {
+		return err
+	}
+	crumbs, err := category.Breadcrumbs(tx)
+	if err != nil {
+		return err
+	}
+	availability, err := models.BookAvailability(tx, models.Books{*book})
+	if err != nil {
+		return err
+	}
+
+	c.Set("PageTitle", book.Title)
+	c.Set("book", book)
+	c.Set("category", category)
+	c.Set("breadcrumbs", crumbs)
+	c.Set("availability", availability[book.ID.String()])
+	c.Set("canonical", strings.TrimSuffix(App().Options.Host, "/")+bookCatalogPath(*book))
+	c.Set("libraryName", libraryName)
+	return c.Render(http.StatusOK, r.HTML("catalog/show.plush.html"))
+}
@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_Catalog() {
+	as.createPlan()
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	book := &models.Book{CategoryID: category.ID.String(), Title: "Pride and Prejudice", BookNo: "P-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(book))
+	withdrawn := &models.Book{CategoryID: category.ID.String(), Title: "Persuasion", BookNo: "P-2", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 0}
+	as.NoError(as.DB.Create(withdrawn))
+	customer := &models.Customer{Name: "Ann Reader", Email: "ann@example.com", Mobile: "1"}
+	as.NoError(as.DB.Create(customer))
+	verrs, err := as.DB.ValidateAndCreate(&models.AssignBook{CustomerID: customer.ID.String(), BookID: book.ID.String()})
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+
+	// no sign in needed
+	res := as.HTML("/catalog?q=austen").Get()
+	as.Equal(http.StatusOK, res.Code)
+	body := res.Body.String()
+	as.Contains(body, "Pride and Prejudice")
+	as.NotContains(body, "Persuasion")
+	as.Contains(body, "All copies out")
+	as.NotContains(body, "Ann Reader")
+	as.Equal("private, max-age=60", res.Header().Get("Cache-Control"))
+	etag := res.Header().Get("ETag")
+	as.NotEmpty(etag)
+
+	req := as.HTML("/catalog?q=austen")
+	req.Headers["If-None-Match"] = etag
+	res = req.Get()
+	as.Equal(http.StatusNotModified, res.Code)
+
+	res = as.HTML("/catalog/categories/%s", category.ID).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Pride and Prejudice")
+
+	res = as.HTML("/catalog/books/%s", book.ID).Get()
+	as.Equal(http.StatusMovedPermanently, res.Code)
+	as.Equal("/catalog/books/"+book.ID.String()+"/pride-and-prejudice/", res.Location())
+	res = as.HTML("/catalog/books/%s/pride-and-prejudice/", book.ID).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), `rel="canonical"`)
+	as.NotContains(res.Body.String(), "Ann Reader")
+
+	res = as.HTML("/catalog/books/%s/persuasion/", withdrawn.ID).Get()
+	as.Equal(http.StatusNotFound, res.Code)
+}
+
+func (as *ActionSuite) Test_Catalog_covers() {
+	as.useTempUploads()
+	file := as.saveTestImage("books")
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	book := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1, PicturePath: file.Path}
+	as.NoError(as.DB.Create(book))
+
+	res := as.HTML("/catalog?q=emma").Get()
+	as.Equal(http.StatusOK, res.Code)
+	src := regexp.MustCompile(`<img class="media-object" src="([^"]+)"`).FindStringSubmatch(res.Body.String())
+	as.Len(src, 2)
+
+	// the cover the page asks for is one that is served
+	res = as.HTML(html.UnescapeString(src[1])).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("image/jpeg", res.Header().Get("Content-Type"))
+}
@@ -2,12 +2,16 @@ package actions
 
 import (
 	"fmt"
+	"html/template"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/x/responder"
+	"github.com/gofrs/uuid"
 
 	"library/models"
 )
@@ -29,17 +33,23 @@ type CategoriesResource struct {
 	buffalo.Resource
 }
 
-// List gets all Categories. This function is mapped to the path
-// GET /categories
+// categoriesSortable maps the categories table columns to the SQL they
+// order by.
+var categoriesSortable = map[string]string{
+	"category_name": "categories.category_name",
+	"status":        "categories.status",
+	"updated_at":    "categories.updated_at",
+}
+
+// CategoriesIndex serves the categories table. Each category is shown
+// with its trail and the number of books in it and its subcategories.
+// This function is mapped to the path GET /auth/categories/index
 func (v CategoriesResource) CategoriesIndex(c buffalo.Context) error {
 	// Define DataTables request parameters
 	draw := c.Param("draw")
 	start, _ := strconv.Atoi(c.Param("start"))
 	length, _ := strconv.Atoi(c.Param("length"))
-	searchValue := c.Param("search[value]")
-	orderColumnIndex, _ := strconv.Atoi(c.Param("order[0][column]"))
-	orderColumnName := c.Param("columns[" + strconv.Itoa(orderColumnIndex) + "][data]")
-	orderDir := c.Param("order[0][dir]")
+	lq := listQueryFromParams(c, categoriesSortable)
 
 	// Create a DB connection
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -52,13 +62,11 @@ func (v CategoriesResource) CategoriesIndex(c buffalo.Context) error {
 	perPage := length
 
 	// Prepare the query
-	q := tx.Paginate(currentPage, perPage)
-	q = q.Order(orderColumnName + " " + orderDir)
-
-	// Apply search filter
-	if searchValue != "" {
-		q = q.Where("category_name LIKE ?", "%"+searchValue+"%")
+	q := tx.Q()
+	if lq.Search != "" {
+		q = q.Where("categories.category_name LIKE ?", lq.like())
 	}
+	q = q.Order(lq.orderBy("categories.path asc", "categories.id")).Paginate(currentPage, perPage)
 
 	// Fetch the data
 	var categories models.Categories
@@ -66,6 +74,18 @@ func (v CategoriesResource) CategoriesIndex(c buffalo.Context) error {
 		return err
 	}
 
+	tree, totals, err := categoryTreeTotals(tx)
+	if err != nil {
+		return err
+	}
+	trails := map[string]string{}
+	for _, category := range tree {
+		trails[category.ID.String()] = category.Trail
+	}
+	for i := range categories {
+		categories[i].Trail = trails[categories[i].ID.String()]
+	}
+
 	// Get the total count
 	count, err := tx.Count(&models.Categories{})
 	if err != nil {
@@ -76,14 +96,14 @@ func (v CategoriesResource) CategoriesIndex(c buffalo.Context) error {
 	response := map[string]interface{}{
 		"draw":            draw,
 		"recordsTotal":    count,
-		"recordsFiltered": len(categories),
-		"data":            formatCategoriesData(categories),
+		"recordsFiltered": q.Paginator.TotalEntriesSize,
+		"data":            formatCategoriesData(categories, totals),
 	}
 
 	return c.Render(200, r.JSON(response))
 }
 
-func formatCategoriesData(categories models.Categories) []interface{} {
+func formatCategoriesData(categories models.Categories, totals map[string]int) []interface{} {
 	var formattedData []interface{}
 
 	for _, category := range categories {
@@ -93,7 +113,8 @@ func formatCategoriesData(categories models.Categories) []interface{} {
 		// Add the existing category data
 		formattedCategory["id"] = category.ID
 		categoryID := category.ID.String()
-		formattedCategory["category_name"] = category.CategoryName
+		formattedCategory["category_name"] = template.HTMLEscapeString(category.SelectLabel())
+		formattedCategory["books"] = totals[categoryID]
 		if category.Status == 1 {
 			formattedCategory["status"] = "<label class='label label-success'>Active</label>"
 		} else {
@@ -137,17 +158,20 @@ func (v CategoriesResource) List(c buffalo.Context) error {
 		c.Set("categories", categories)
 		return c.Render(http.StatusOK, r2.HTML("backend/categories/index.plush.html"))
 	}).Wants("json", func(c buffalo.Context) error {
-		if c.Param("q") != "" {
-			if err := tx.Where("category_name LIKE ?", "%"+c.Param("q")+"%").PaginateFromParams(c.Params()).All(categories); err != nil {
-				return err
-			}
-		} else {
-			if err := q.All(categories); err != nil {
-				return err
+		// The category selects list the whole tree, matching "q"
+		// against the trail so "sci" also finds Science / Physics.
+		tree, err := models.CategoryTree(tx)
+		if err != nil {
+			return err
+		}
+		term := strings.ToLower(strings.TrimSpace(c.Param("q")))
+		matches := models.Categories{}
+		for _, category := range tree {
+			if strings.Contains(strings.ToLower(category.Trail), term) {
+				matches = append(matches, category)
 			}
 		}
-
-		return c.Render(200, r2.JSON(categories))
+		return c.Render(200, r2.JSON(matches))
 	}).Wants("xml", func(c buffalo.Context) error {
 		return c.Render(200, r2.XML(categories))
 	}).Respond(c)
@@ -170,12 +194,38 @@ func (v CategoriesResource) Show(c buffalo.Context) error {
 		return c.Error(http.StatusNotFound, err)
 	}
 
+	breadcrumbs, err := category.Breadcrumbs(tx)
+	if err != nil {
+		return err
+	}
+	children, err := category.Children(tx)
+	if err != nil {
+		return err
+	}
+	books, err := models.InCategory(tx.Q(), "books.category_id", category.ID.String()).Count(&models.Books{})
+	if err != nil {
+		return err
+	}
+
 	return responder.Wants("html", func(c buffalo.Context) error {
+		parents, err := parentOptions(tx, category)
+		if err != nil {
+			return err
+		}
 		c.Set("category", category)
+		c.Set("breadcrumbs", breadcrumbs)
+		c.Set("children", children)
+		c.Set("books", books)
+		c.Set("parents", parents)
 		c.Set("PageTitle", "Show Category")
 		return c.Render(http.StatusOK, r2.HTML("backend/categories/show.plush.html"))
 	}).Wants("json", func(c buffalo.Context) error {
-		return c.Render(200, r2.JSON(category))
+		return c.Render(200, r2.JSON(map[string]interface{}{
+			"category":    category,
+			"breadcrumbs": breadcrumbs,
+			"children":    children,
+			"books":       books,
+		}))
 	}).Wants("xml", func(c buffalo.Context) error {
 		return c.Render(200, r2.XML(category))
 	}).Respond(c)
@@ -184,7 +234,23 @@ func (v CategoriesResource) Show(c buffalo.Context) error {
 // New renders the form for creating a new Category.
 // This function is mapped to the path GET /categories/new
 func (v CategoriesResource) New(c buffalo.Context) error {
-	c.Set("category", &models.Category{})
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	// A parent can be picked in advance, as the "Add subcategory" link
+	// of a category does.
+	category := &models.Category{}
+	if parentID, err := uuid.FromString(c.Param("parent_id")); err == nil {
+		category.ParentID = nulls.NewUUID(parentID)
+	}
+	parents, err := parentOptions(tx, category)
+	if err != nil {
+		return err
+	}
+	c.Set("category", category)
+	c.Set("parents", parents)
 	c.Set("PageTitle", "Create Category")
 	return c.Render(http.StatusOK, r2.HTML("backend/categories/new.plush.html"))
 }
@@ -219,8 +285,13 @@ func (v CategoriesResource) Create(c buffalo.Context) error {
 
 			// Render again the new.html template that the user can
 			// correct the input.
+			parents, err := parentOptions(tx, category)
+			if err != nil {
+				return err
+			}
 			c.Set("PageTitle", "Create Category")
 			c.Set("category", category)
+			c.Set("parents", parents)
 
 			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/categories/new.plush.html"))
 		}).Wants("json", func(c buffalo.Context) error {
@@ -259,7 +330,12 @@ func (v CategoriesResource) Edit(c buffalo.Context) error {
 		return c.Error(http.StatusNotFound, err)
 	}
 
+	parents, err := parentOptions(tx, category)
+	if err != nil {
+		return err
+	}
 	c.Set("category", category)
+	c.Set("parents", parents)
 	c.Set("PageTitle", "Edit Category")
 	return c.Render(http.StatusOK, r2.HTML("backend/categories/edit.plush.html"))
 }
@@ -284,6 +360,11 @@ func (v CategoriesResource) Update(c buffalo.Context) error {
 	if err := c.Bind(category); err != nil {
 		return err
 	}
+	// Bind skips blank values, so a parent cleared on the form would
+	// otherwise be kept.
+	if _, ok := c.Request().Form["ParentID"]; ok && c.Param("ParentID") == "" {
+		category.ParentID = nulls.UUID{}
+	}
 
 	verrs, err := tx.ValidateAndUpdate(category)
 	if err != nil {
@@ -297,7 +378,12 @@ func (v CategoriesResource) Update(c buffalo.Context) error {
 
 			// Render again the edit.html template that the user can
 			// correct the input.
+			parents, err := parentOptions(tx, category)
+			if err != nil {
+				return err
+			}
 			c.Set("category", category)
+			c.Set("parents", parents)
 			c.Set("PageTitle", "Edit Category")
 			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/categories/edit.plush.html"))
 		}).Wants("json", func(c buffalo.Context) error {
@@ -337,6 +423,24 @@ func (v CategoriesResource) Destroy(c buffalo.Context) error {
 		return c.Error(http.StatusNotFound, err)
 	}
 
+	// Subcategories have to be moved or removed first, so whole trees of
+	// books aren't deleted by accident.
+	children, err := tx.Where("parent_id = ?", category.ID).Count(&models.Categories{})
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		msg := T.Translate(c, "category.destroyed.has_children", map[string]interface{}{"Count": children})
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Flash().Add("danger", msg)
+			return c.Redirect(http.StatusSeeOther, "/auth/categories/%v", category.ID)
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusConflict, r2.JSON(map[string]string{"error": msg}))
+		}).Wants("xml", func(c buffalo.Context) error {
+			return c.Render(http.StatusConflict, r2.XML(map[string]string{"error": msg}))
+		}).Respond(c)
+	}
+
 	if err := tx.Destroy(category); err != nil {
 		return err
 	}
@@ -353,3 +457,121 @@ func (v CategoriesResource) Destroy(c buffalo.Context) error {
 		return c.Render(http.StatusOK, r2.XML(category))
 	}).Respond(c)
 }
+
+// CategoriesTree shows every category nested under its parent, with
+// the number of books in it and its subcategories.
+// This function is mapped to the path GET /auth/categories/tree
+func (v CategoriesResource) CategoriesTree(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	tree, totals, err := categoryTreeTotals(tx)
+	if err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Set("tree", tree)
+		c.Set("totals", totals)
+		c.Set("PageTitle", "Category Tree")
+		return c.Render(http.StatusOK, r2.HTML("backend/categories/tree.plush.html"))
+	}).Wants("json", func(c buffalo.Context) error {
+		nodes := make([]map[string]interface{}, len(tree))
+		for i, category := range tree {
+			nodes[i] = map[string]interface{}{
+				"id":            category.ID,
+				"parent_id":     category.ParentID,
+				"category_name": category.CategoryName,
+				"trail":         category.Trail,
+				"depth":         category.Depth,
+				"status":        category.Status,
+				"books":         totals[category.ID.String()],
+			}
+		}
+		return c.Render(http.StatusOK, r2.JSON(nodes))
+	}).Respond(c)
+}
+
+// Move puts a Category, with all its subcategories, under another
+// parent, or at the top level when "parent_id" is blank.
+// This function is mapped to the path PUT /auth/categories/{category_id}/move
+func (v CategoriesResource) Move(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	category := &models.Category{}
+	if err := tx.Find(category, c.Param("category_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	category.ParentID = nulls.UUID{}
+	if p := c.Param("parent_id"); p != "" {
+		parentID, err := uuid.FromString(p)
+		if err != nil {
+			return c.Error(http.StatusBadRequest, err)
+		}
+		category.ParentID = nulls.NewUUID(parentID)
+	}
+
+	verrs, err := tx.ValidateAndUpdate(category)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Flash().Add("danger", verrs.Error())
+			return c.Redirect(http.StatusSeeOther, "/auth/categories/%v", category.ID)
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "category.moved.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/categories/%v", category.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(category))
+	}).Respond(c)
+}
+
+// categoryTreeTotals loads the category tree and the number of books in
+// each category and its subcategories.
+func categoryTreeTotals(tx *pop.Connection) (models.Categories, map[string]int, error) {
+	tree, err := models.CategoryTree(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]interface{}, len(tree))
+	for i, category := range tree {
+		ids[i] = category.ID
+	}
+	counts, err := creditedBookCounts(tx, "books", "category_id", ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tree, tree.SubtreeTotals(counts), nil
+}
+
+// parentOptions lists the categories the given one can be put under:
+// all of them but itself and its own subcategories.
+func parentOptions(tx *pop.Connection, category *models.Category) (models.Categories, error) {
+	tree, err := models.CategoryTree(tx)
+	if err != nil {
+		return nil, err
+	}
+	if category.Path == "" {
+		return tree, nil
+	}
+	options := models.Categories{}
+	for _, option := range tree {
+		if !strings.HasPrefix(option.Path, category.Path) {
+			options = append(options, option)
+		}
+	}
+	return options, nil
+}
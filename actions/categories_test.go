@@ -1,5 +1,11 @@
 package actions
 
+import (
+	"net/http"
+
+	"library/models"
+)
+
 func (as *ActionSuite) Test_CategoriesResource_List() {
 	as.Fail("Not Implemented!")
 }
@@ -27,3 +33,27 @@ func (as *ActionSuite) Test_CategoriesResource_New() {
 func (as *ActionSuite) Test_CategoriesResource_Edit() {
 	as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_CategoriesResource_Move() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	science := &models.Category{CategoryName: "Science", Status: 1}
+	as.NoError(as.DB.Create(science))
+	physics := &models.Category{CategoryName: "Physics", Status: 1}
+	as.NoError(as.DB.Create(physics))
+
+	res := as.HTML("/auth/categories/%s/move", physics.ID).Put(map[string]string{"parent_id": science.ID.String()})
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.NoError(as.DB.Reload(physics))
+	as.Equal(science.ID, physics.ParentID.UUID)
+	as.Equal(1, physics.Depth)
+
+	// science has a subcategory now, so it can't be removed
+	res = as.HTML("/auth/categories/%s", science.ID).Delete()
+	as.Equal(http.StatusSeeOther, res.Code)
+	exists, err := as.DB.Where("id = ?", science.ID).Exists(&models.Category{})
+	as.NoError(err)
+	as.True(exists)
+}
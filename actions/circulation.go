@@ -0,0 +1,328 @@
+package actions
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/models"
+)
+
+// The circulation desk lends and takes back books by scanning barcodes:
+// a customer's library card, then the book numbers on the labels. The
+// desk page calls the JSON endpoints below as each barcode is scanned.
+
+// CirculationDesk renders the desk, with a mode for lending and one for
+// taking books back. This function is mapped to the path
+// GET /auth/circulation
+func CirculationDesk(c buffalo.Context) error {
+	c.Set("PageTitle", "Circulation Desk")
+	return c.Render(http.StatusOK, r2.HTML("backend/circulation/desk.plush.html"))
+}
+
+// deskError answers a scan the desk can't act on.
+func deskError(c buffalo.Context, status int, format string, args ...interface{}) error {
+	return c.Render(status, r2.JSON(map[string]string{"error": fmt.Sprintf(format, args...)}))
+}
+
+// scannedCard finds the customer whose card was scanned. It returns nil
+// after answering the request when there is none.
+func scannedCard(c buffalo.Context, tx *pop.Connection, card string) (*models.Customer, error) {
+	card = strings.TrimSpace(card)
+	if !models.ValidCardNumber(card) {
+		return nil, deskError(c, http.StatusUnprocessableEntity, "%q is not a library card number; scan the card again.", card)
+	}
+	customer := &models.Customer{}
+	if err := tx.Where("card_number = ?", card).First(customer); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, deskError(c, http.StatusNotFound, "No customer has the card %s.", card)
+		}
+		return nil, err
+	}
+	return customer, nil
+}
+
+// scannedBook finds the book whose label was scanned. It returns nil
+// after answering the request when there is none.
+func scannedBook(c buffalo.Context, tx *pop.Connection, barcode string) (*models.Book, error) {
+	barcode = strings.TrimSpace(barcode)
+	book := &models.Book{}
+	if err := tx.Where("book_no = ?", barcode).First(book); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, deskError(c, http.StatusNotFound, "No book has the number %q.", barcode)
+		}
+		return nil, err
+	}
+	return book, nil
+}
+
+// titledLoan is a loan with the title and number of the book lent.
+type titledLoan struct {
+	models.AssignBook
+	Title  string `db:"title"`
+	BookNo string `db:"book_no"`
+}
+
+// titledLoans selects the titledLoans of a customer.
+const titledLoans = "SELECT assign_books.*, books.title, books.book_no FROM assign_books JOIN books ON books.id = assign_books.book_id WHERE assign_books.customer_id = ?"
+
+// deskLoan is a loan as the desk shows it.
+type deskLoan struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	BookNo   string `json:"book_no"`
+	Due      string `json:"due"`
+	DaysLate int    `json:"days_late"`
+}
+
+// CirculationPatron answers the scan of a library card with the
+// customer, whether they may borrow and why not, the books they have
+// out, what they owe and the holds put aside for them.
+// This function is mapped to the path GET /auth/circulation/patron
+func CirculationPatron(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	customer, err := scannedCard(c, tx, c.Param("card"))
+	if customer == nil {
+		return err
+	}
+	now := time.Now()
+	refusal, err := customer.BorrowingRefusal(tx, now)
+	if err != nil {
+		return err
+	}
+	plan, err := customer.MembershipPlan(tx)
+	if err != nil {
+		return err
+	}
+	owed, err := customer.FinesOwed(tx, now)
+	if err != nil {
+		return err
+	}
+
+	rows := []titledLoan{}
+	if err := tx.RawQuery(titledLoans+" AND assign_books.returned_on IS NULL ORDER BY assign_books.return_date", customer.ID).All(&rows); err != nil {
+		return err
+	}
+	loans := []deskLoan{}
+	for _, row := range rows {
+		loans = append(loans, deskLoan{ID: row.ID.String(), Title: row.Title, BookNo: row.BookNo, Due: formatDate(row.ReturnDate), DaysLate: row.DaysLate(now)})
+	}
+
+	var ready []struct {
+		Title      string `db:"title" json:"title"`
+		BookNo     string `db:"book_no" json:"book_no"`
+		ReadyUntil string `db:"ready_until" json:"ready_until"`
+	}
+	if err := tx.RawQuery("SELECT books.title, books.book_no, holds.ready_until FROM holds JOIN books ON books.id = holds.book_id WHERE holds.customer_id = ? AND holds.status = ? AND holds.ready_until >= ? ORDER BY holds.ready_until", customer.ID, models.HoldReady, now.Format("2006-01-02")).All(&ready); err != nil {
+		return err
+	}
+	for i := range ready {
+		ready[i].ReadyUntil = formatDate(ready[i].ReadyUntil)
+	}
+
+	return c.Render(http.StatusOK, r2.JSON(map[string]interface{}{
+		"customer": map[string]interface{}{
+			"id":          customer.ID,
+			"name":        customer.Name,
+			"card_number": customer.CardNumber,
+			"status":      customer.Standing(now),
+			"expires_on":  formatDate(customer.ExpiresOn),
+			"plan":        plan.Name,
+		},
+		"allowed":     refusal == "",
+		"refusal":     refusal,
+		"loans":       loans,
+		"max_loans":   plan.MaxLoans,
+		"loan_days":   plan.LoanDays,
+		"fines":       owed.Format(languages(c)...),
+		"holds_ready": ready,
+	}))
+}
+
+// CirculationItem answers the scan of a book with whether it can be
+// lent to the customer whose card was scanned first, and until when.
+// This function is mapped to the path GET /auth/circulation/item
+func CirculationItem(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	customer, err := scannedCard(c, tx, c.Param("card"))
+	if customer == nil {
+		return err
+	}
+	book, err := scannedBook(c, tx, c.Param("barcode"))
+	if book == nil {
+		return err
+	}
+	now := time.Now()
+	refusal, err := book.LendingRefusal(tx, *customer, now)
+	if err != nil {
+		return err
+	}
+	available, err := book.Available(tx)
+	if err != nil {
+		return err
+	}
+	plan, err := customer.MembershipPlan(tx)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, r2.JSON(map[string]interface{}{
+		"book": map[string]interface{}{
+			"id":          book.ID,
+			"title":       book.Title,
+			"book_no":     book.BookNo,
+			"call_number": book.CallNumber,
+		},
+		"available": available,
+		"allowed":   refusal == "",
+		"refusal":   refusal,
+		"due":       now.AddDate(0, 0, plan.LoanDays).Format("2006-01-02"),
+	}))
+}
+
+// checkoutRequest is a customer's card and the books scanned for them.
+type checkoutRequest struct {
+	Card     string   `json:"card" form:"card"`
+	Barcodes []string `json:"barcodes" form:"barcodes"`
+}
+
+// checkinRequest is a book scanned as it comes back.
+type checkinRequest struct {
+	Barcode string `json:"barcode" form:"barcode"`
+}
+
+// CirculationCheckout lends the scanned books to the customer, all of
+// them or, when any can't be lent, none: the loans are made in the
+// request's transaction, which an error response rolls back.
+// This function is mapped to the path POST /auth/circulation/checkout
+func CirculationCheckout(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	req := checkoutRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	if len(req.Barcodes) == 0 {
+		return deskError(c, http.StatusUnprocessableEntity, "Scan the books to lend first.")
+	}
+	customer, err := scannedCard(c, tx, req.Card)
+	if customer == nil {
+		return err
+	}
+
+	type refusal struct {
+		Barcode string `json:"barcode"`
+		Error   string `json:"error"`
+	}
+	refusals := []refusal{}
+	loans := []deskLoan{}
+	for _, barcode := range req.Barcodes {
+		book := &models.Book{}
+		if err := tx.Where("book_no = ?", strings.TrimSpace(barcode)).First(book); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			refusals = append(refusals, refusal{barcode, fmt.Sprintf("No book has the number %q.", barcode)})
+			continue
+		}
+		loan := &models.AssignBook{CustomerID: customer.ID.String(), BookID: book.ID.String()}
+		verrs, err := tx.ValidateAndCreate(loan)
+		if err != nil {
+			return err
+		}
+		if verrs.HasAny() {
+			refusals = append(refusals, refusal{barcode, verrs.Error()})
+			continue
+		}
+		loans = append(loans, deskLoan{ID: loan.ID.String(), Title: book.Title, BookNo: book.BookNo, Due: formatDate(loan.ReturnDate)})
+	}
+
+	if len(refusals) > 0 {
+		return c.Render(http.StatusUnprocessableEntity, r2.JSON(map[string]interface{}{"errors": refusals}))
+	}
+	return c.Render(http.StatusCreated, r2.JSON(map[string]interface{}{"loans": loans}))
+}
+
+// CirculationCheckin takes back the scanned book, reporting the fine
+// for any days late and the customer to put it aside for when it is on
+// hold. This function is mapped to the path POST /auth/circulation/checkin
+func CirculationCheckin(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	req := checkinRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	book, err := scannedBook(c, tx, req.Barcode)
+	if book == nil {
+		return err
+	}
+	now := time.Now()
+	loan, hold, err := book.CheckIn(tx, now)
+	if err != nil {
+		return err
+	}
+	if loan == nil {
+		return deskError(c, http.StatusUnprocessableEntity, "%s is not out on loan.", book.Title)
+	}
+	customer := &models.Customer{}
+	if err := tx.Find(customer, loan.CustomerID); err != nil {
+		return err
+	}
+
+	res := map[string]interface{}{
+		"book":      map[string]interface{}{"id": book.ID, "title": book.Title, "book_no": book.BookNo},
+		"customer":  map[string]interface{}{"id": customer.ID, "name": customer.Name, "card_number": customer.CardNumber},
+		"days_late": loan.DaysLate(now),
+		"fine":      loan.Fine.Format(languages(c)...),
+		"hold":      nil,
+	}
+	if hold != nil {
+		holder := &models.Customer{}
+		if err := tx.Find(holder, hold.CustomerID); err != nil {
+			return err
+		}
+		notified, err := notifyHolder(c, tx, *hold, now)
+		if err != nil {
+			return err
+		}
+		res["hold"] = map[string]interface{}{
+			"name":        holder.Name,
+			"card_number": holder.CardNumber,
+			"ready_until": formatDate(hold.ReadyUntil),
+			"notified":    notified,
+		}
+	}
+	return c.Render(http.StatusOK, r2.JSON(res))
+}
+
+// notifyHolder tells the customer of a hold just readied that the book
+// waits for them. It reports whether any channel reached them; the
+// notices:send task tries again for those it did not.
+func notifyHolder(c buffalo.Context, tx *pop.Connection, hold models.Hold, now time.Time) (bool, error) {
+	notice, err := models.HoldNotice(tx, hold)
+	if err != nil {
+		return false, err
+	}
+	return notify(c, tx, notice, now)
+}
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_Circulation_Desk() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+	as.createPlan()
+
+	customer := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	verrs, err := as.DB.ValidateAndCreate(customer)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	emma := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(emma))
+	gone := &models.Book{CategoryID: category.ID.String(), Title: "Gone", BookNo: "G-1", Author: "Someone", Price: money.New(100, "USD"), Status: 0}
+	as.NoError(as.DB.Create(gone))
+
+	page := as.HTML("/auth/circulation").Get()
+	as.Equal(http.StatusOK, page.Code)
+
+	res := as.JSON("/auth/circulation/patron?card=%s", customer.CardNumber).Get()
+	as.Equal(http.StatusOK, res.Code)
+	patron := struct {
+		Allowed bool `json:"allowed"`
+	}{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), &patron))
+	as.True(patron.Allowed)
+	res = as.JSON("/auth/circulation/patron?card=nope").Get()
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+
+	item := struct {
+		Allowed bool   `json:"allowed"`
+		Refusal string `json:"refusal"`
+	}{}
+	res = as.JSON("/auth/circulation/item?card=%s&barcode=G-1", customer.CardNumber).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.NoError(json.Unmarshal(res.Body.Bytes(), &item))
+	as.False(item.Allowed)
+	as.Equal("Gone has been withdrawn from lending.", item.Refusal)
+	res = as.JSON("/auth/circulation/item?card=%s&barcode=X-9", customer.CardNumber).Get()
+	as.Equal(http.StatusNotFound, res.Code)
+
+	// one refusal lends nothing
+	res = as.JSON("/auth/circulation/checkout").Post(checkoutRequest{Card: customer.CardNumber, Barcodes: []string{"E-1", "G-1"}})
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+	on, err := emma.OnLoan(as.DB)
+	as.NoError(err)
+	as.Equal(0, on)
+
+	res = as.JSON("/auth/circulation/checkout").Post(checkoutRequest{Card: customer.CardNumber, Barcodes: []string{"E-1"}})
+	as.Equal(http.StatusCreated, res.Code)
+	on, err = emma.OnLoan(as.DB)
+	as.NoError(err)
+	as.Equal(1, on)
+
+	res = as.JSON("/auth/circulation/checkin").Post(checkinRequest{Barcode: "E-1"})
+	as.Equal(http.StatusOK, res.Code)
+	res = as.JSON("/auth/circulation/checkin").Post(checkinRequest{Barcode: "E-1"})
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+}
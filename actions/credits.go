@@ -0,0 +1,93 @@
+package actions
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+
+	"library/models"
+)
+
+// creditCount is the number of books linked to an author, publisher or
+// subject.
+type creditCount struct {
+	ID    string `db:"id"`
+	Books int    `db:"books"`
+}
+
+// creditedBookCounts returns how many books are linked to each of the
+// ids through joinTable, keyed by id.
+func creditedBookCounts(tx *pop.Connection, joinTable, column string, ids []interface{}) (map[string]int, error) {
+	counts := map[string]int{}
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	var rows []creditCount
+	err := tx.RawQuery("SELECT "+column+" AS id, COUNT(*) AS books FROM "+joinTable+" WHERE "+column+" IN (?) GROUP BY "+column, ids...).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.ID] = row.Books
+	}
+	return counts, nil
+}
+
+// formatCreditData formats an author or publisher for its table.
+func formatCreditData(module, id, name string, books int, updatedAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"name":       template.HTMLEscapeString(name),
+		"books":      books,
+		"updated_at": updatedAt.Format("01-02-2006 (03:04 PM)"),
+		"actions": "<button class='btn btn-default showData' data-id='" + id + "' data-modulename='" + module + "'><i class='fa fa-eye'></i></button> " +
+			"<button class='btn btn-default editData' data-id='" + id + "' data-modulename='" + module + "'><i class='fa fa-edit'></i></button>",
+	}
+}
+
+// loanTotals adds up the loans of several books and finds the latest.
+// Borrowers can't be added up as one customer may borrow several books.
+func loanTotals(stats map[string]models.LoanStats) models.LoanStats {
+	var total models.LoanStats
+	for _, s := range stats {
+		total.Loans += s.Loans
+		if s.LastLoan > total.LastLoan {
+			total.LastLoan = s.LastLoan
+		}
+	}
+	return total
+}
+
+// SubjectsList returns the subjects whose name matches the "q" param,
+// for the book form.
+// This function is mapped to the path GET /auth/subjects
+func SubjectsList(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	subjects := models.Subjects{}
+	if err := tx.Where("name LIKE ?", "%"+c.Param("q")+"%").Order("name").Limit(20).All(&subjects); err != nil {
+		return err
+	}
+	return c.Render(200, r2.JSON(subjects))
+}
+
+// creditedBook is a line of the books table on an author or publisher
+// page.
+type creditedBook struct {
+	Book  models.Book
+	Loans models.LoanStats
+}
+
+// creditedBookRows pairs books with their loan statistics.
+func creditedBookRows(books models.Books, stats map[string]models.LoanStats) []creditedBook {
+	rows := make([]creditedBook, len(books))
+	for i, book := range books {
+		rows[i] = creditedBook{Book: book, Loans: stats[book.ID.String()]}
+	}
+	return rows
+}
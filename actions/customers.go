@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/x/responder"
 
@@ -34,10 +36,7 @@ func (v CustomersResource) CustomersIndex(c buffalo.Context) error {
 	draw := c.Param("draw")
 	start, _ := strconv.Atoi(c.Param("start"))
 	length, _ := strconv.Atoi(c.Param("length"))
-	searchValue := c.Param("search[value]")
-	orderColumnIndex, _ := strconv.Atoi(c.Param("order[0][column]"))
-	orderColumnName := c.Param("columns[" + strconv.Itoa(orderColumnIndex) + "][data]")
-	orderDir := c.Param("order[0][dir]")
+	lq := listQueryFromParams(c, customersSortable)
 
 	// Create a DB connection
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -50,13 +49,7 @@ func (v CustomersResource) CustomersIndex(c buffalo.Context) error {
 	perPage := length
 
 	// Prepare the query
-	q := tx.Paginate(currentPage, perPage)
-	q = q.Order(orderColumnName + " " + orderDir)
-
-	// Apply search filter
-	if searchValue != "" {
-		q = q.Where("name LIKE ? OR email LIKE ? OR mobile LIKE ? OR address LIKE ?", "%"+searchValue+"%", "%"+searchValue+"%", "%"+searchValue+"%", "%"+searchValue+"%")
-	}
+	q := customersQuery(tx, lq).Paginate(currentPage, perPage)
 
 	// Fetch the data
 	var customers models.Customers
@@ -83,6 +76,7 @@ func (v CustomersResource) CustomersIndex(c buffalo.Context) error {
 
 func formatCustomersData(customers models.Customers) []interface{} {
 	var formattedData []interface{}
+	now := time.Now()
 
 	for _, customer := range customers {
 		// Create a new map to hold the formatted category data
@@ -95,6 +89,9 @@ func formatCustomersData(customers models.Customers) []interface{} {
 		formattedCustomer["email"] = customer.Email
 		formattedCustomer["mobile"] = customer.Mobile
 		formattedCustomer["address"] = customer.Address
+		formattedCustomer["card_number"] = customer.CardNumber
+		formattedCustomer["status"] = customer.Standing(now)
+		formattedCustomer["expires_on"] = formatDate(customer.ExpiresOn)
 
 		formattedCustomer["updated_at"] = customer.UpdatedAt.Format("01-02-2006 (03:04 PM)")
 		// Add the custom action column with edit and delete buttons
@@ -133,6 +130,8 @@ func (v CustomersResource) List(c buffalo.Context) error {
 	return responder.Wants("html", func(c buffalo.Context) error {
 		// Add the paginator to the context so it can be used in the template.
 		c.Set("pagination", q.Paginator)
+		c.Set("labelSheets", labelSheets(c))
+		c.Set("labelSheet", defaultCardSheet)
 
 		c.Set("customers", customers)
 		c.Set("PageTitle", "All Customers")
@@ -161,8 +160,30 @@ func (v CustomersResource) Show(c buffalo.Context) error {
 		return c.Error(http.StatusNotFound, err)
 	}
 
+	plan, err := customer.MembershipPlan(tx)
+	if err != nil {
+		return err
+	}
+	openLoans, err := customer.OpenLoans(tx)
+	if err != nil {
+		return err
+	}
+	finesOwed, err := customer.FinesOwed(tx, time.Now())
+	if err != nil {
+		return err
+	}
+	notifications := models.Notifications{}
+	if err := tx.Where("customer_id = ?", customer.ID).Order("created_at DESC").Limit(10).All(&notifications); err != nil {
+		return err
+	}
+
 	return responder.Wants("html", func(c buffalo.Context) error {
 		c.Set("customer", customer)
+		c.Set("plan", plan)
+		c.Set("openLoans", openLoans)
+		c.Set("finesOwed", finesOwed)
+		c.Set("notifications", notifications)
+		c.Set("standing", customer.Standing(time.Now()))
 		c.Set("PageTitle", "Show Customer")
 		return c.Render(http.StatusOK, r2.HTML("backend/customers/show.plush.html"))
 	}).Wants("json", func(c buffalo.Context) error {
@@ -175,7 +196,18 @@ func (v CustomersResource) Show(c buffalo.Context) error {
 // New renders the form for creating a new Customer.
 // This function is mapped to the path GET /customers/new
 func (v CustomersResource) New(c buffalo.Context) error {
-	c.Set("customer", &models.Customer{})
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	plan, err := models.DefaultMembershipPlan(tx)
+	if err != nil {
+		return err
+	}
+	if err := setMembershipPlans(c, tx); err != nil {
+		return err
+	}
+	c.Set("customer", &models.Customer{MembershipPlanID: plan.ID.String()})
 	c.Set("PageTitle", "Create Customer")
 	return c.Render(http.StatusOK, r2.HTML("backend/customers/new.plush.html"))
 }
@@ -198,7 +230,8 @@ func (v CustomersResource) Create(c buffalo.Context) error {
 	}
 
 	// Validate the data from the html form
-	verrs, err := tx.ValidateAndCreate(customer)
+	verrs, err := customer.Create(tx)
+	trackUpload(c, "", customer.PhotoPath)
 	if err != nil {
 		return err
 	}
@@ -212,6 +245,9 @@ func (v CustomersResource) Create(c buffalo.Context) error {
 			// correct the input.
 			c.Set("PageTitle", "Create Customer")
 			c.Set("customer", customer)
+			if err := setMembershipPlans(c, tx); err != nil {
+				return err
+			}
 
 			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/customers/new.plush.html"))
 		}).Wants("json", func(c buffalo.Context) error {
@@ -249,6 +285,9 @@ func (v CustomersResource) Edit(c buffalo.Context) error {
 	if err := tx.Find(customer, c.Param("customer_id")); err != nil {
 		return c.Error(http.StatusNotFound, err)
 	}
+	if err := setMembershipPlans(c, tx); err != nil {
+		return err
+	}
 	c.Set("PageTitle", "Edit Customer")
 	c.Set("customer", customer)
 	return c.Render(http.StatusOK, r2.HTML("backend/customers/edit.plush.html"))
@@ -269,13 +308,15 @@ func (v CustomersResource) Update(c buffalo.Context) error {
 	if err := tx.Find(customer, c.Param("customer_id")); err != nil {
 		return c.Error(http.StatusNotFound, err)
 	}
+	oldPhoto := customer.PhotoPath
 
 	// Bind Customer to the html form elements
 	if err := c.Bind(customer); err != nil {
 		return err
 	}
 
-	verrs, err := tx.ValidateAndUpdate(customer)
+	verrs, err := customer.Update(tx)
+	trackUpload(c, oldPhoto, customer.PhotoPath)
 	if err != nil {
 		return err
 	}
@@ -289,6 +330,9 @@ func (v CustomersResource) Update(c buffalo.Context) error {
 			// correct the input.
 			c.Set("PageTitle", "Edit Customer")
 			c.Set("customer", customer)
+			if err := setMembershipPlans(c, tx); err != nil {
+				return err
+			}
 
 			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/customers/edit.plush.html"))
 		}).Wants("json", func(c buffalo.Context) error {
@@ -331,6 +375,7 @@ func (v CustomersResource) Destroy(c buffalo.Context) error {
 	if err := tx.Destroy(customer); err != nil {
 		return err
 	}
+	trackUpload(c, customer.PhotoPath, "")
 
 	return responder.Wants("html", func(c buffalo.Context) error {
 		// If there are no errors set a flash message
@@ -344,3 +389,172 @@ func (v CustomersResource) Destroy(c buffalo.Context) error {
 		return c.Render(http.StatusOK, r2.XML(customer))
 	}).Respond(c)
 }
+
+// Renew extends a customer's membership by a term, reactivating an
+// expired card. This function is mapped to the path
+// POST /customers/{customer_id}/renew
+func (v CustomersResource) Renew(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	customer := &models.Customer{}
+	if err := tx.Find(customer, c.Param("customer_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	customer.Renew(time.Now())
+	verrs, err := tx.ValidateAndUpdate(customer)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Flash().Add("danger", verrs.Error())
+			return c.Redirect(http.StatusSeeOther, "/auth/customers/%v", customer.ID)
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Wants("xml", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.XML(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "customer.renewed.success", map[string]string{"Name": customer.Name, "ExpiresOn": formatDate(customer.ExpiresOn)}))
+		return c.Redirect(http.StatusSeeOther, "/auth/customers/%v", customer.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(customer))
+	}).Wants("xml", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.XML(customer))
+	}).Respond(c)
+}
+
+// SettleFines records the fines of the books a customer has returned as
+// paid. This function is mapped to the path
+// POST /auth/customers/{customer_id}/settle_fines
+func (v CustomersResource) SettleFines(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	customer := &models.Customer{}
+	if err := tx.Find(customer, c.Param("customer_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	if err := customer.SettleFines(tx); err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "customer.fines_settled.success", map[string]string{"Name": customer.Name}))
+		return c.Redirect(http.StatusSeeOther, "/auth/customers/%v", customer.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(customer))
+	}).Wants("xml", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.XML(customer))
+	}).Respond(c)
+}
+
+// membershipPlanNames maps the id of every plan to its name.
+func membershipPlanNames(tx *pop.Connection) (map[string]string, error) {
+	plans := models.MembershipPlans{}
+	if err := tx.All(&plans); err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for _, plan := range plans {
+		names[plan.ID.String()] = plan.Name
+	}
+	return names, nil
+}
+
+// setMembershipPlans offers the plans to the customer form.
+func setMembershipPlans(c buffalo.Context, tx *pop.Connection) error {
+	plans := models.MembershipPlans{}
+	if err := tx.Order("name").All(&plans); err != nil {
+		return err
+	}
+	c.Set("membershipPlans", plans)
+	return nil
+}
+
+// formatDate writes a date column's value as in 2006-01-02, or "" when
+// there is none. Dates read into strings come as timestamps.
+func formatDate(v interface{}) string {
+	switch d := v.(type) {
+	case string:
+		if len(d) > 10 {
+			return d[:10]
+		}
+		return d
+	case time.Time:
+		if !d.IsZero() {
+			return d.Format("2006-01-02")
+		}
+	case nulls.Time:
+		if d.Valid {
+			return d.Time.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// customersSortable maps the customers table columns to the SQL they
+// order by.
+var customersSortable = map[string]string{
+	"name":       "customers.name",
+	"email":      "customers.email",
+	"mobile":     "customers.mobile",
+	"address":    "customers.address",
+	"updated_at": "customers.updated_at",
+
+	"card_number": "customers.card_number",
+	"status":      "customers.status",
+	"expires_on":  "customers.expires_on",
+}
+
+// customersQuery filters and orders customers the way the customers
+// list does.
+func customersQuery(tx *pop.Connection, lq listQuery) *pop.Query {
+	q := tx.Q()
+	if lq.Search != "" {
+		like := lq.like()
+		q = q.Where("customers.name LIKE ? OR customers.email LIKE ? OR customers.mobile LIKE ? OR customers.address LIKE ? OR customers.card_number = ?", like, like, like, like, lq.Search)
+	}
+	return q.Order(lq.orderBy("customers.created_at desc", "customers.id"))
+}
+
+// CustomersExport downloads the customers list, with the same search
+// and order as on screen, as CSV, XLSX or PDF.
+// This function is mapped to the path GET /auth/customers/export
+func (v CustomersResource) CustomersExport(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	lq := listQueryFromParams(c, customersSortable)
+
+	header := []string{"Name", "Email", "Mobile", "Address", "Updated At", "Card Number", "Membership", "Joined", "Expires", "Status"}
+	now := time.Now()
+	plans, err := membershipPlanNames(tx)
+	if err != nil {
+		return err
+	}
+	return streamExport(c, "Customers", header, func(page int) ([][]string, error) {
+		var customers models.Customers
+		if err := customersQuery(tx, lq).Paginate(page, exportBatch).All(&customers); err != nil {
+			return nil, err
+		}
+		rows := make([][]string, 0, len(customers))
+		for _, customer := range customers {
+			rows = append(rows, []string{customer.Name, customer.Email, customer.Mobile, customer.Address.String,
+				customer.UpdatedAt.Format("2006-01-02 15:04"), customer.CardNumber, plans[customer.MembershipPlanID],
+				formatDate(customer.JoinedOn), formatDate(customer.ExpiresOn), customer.Standing(now)})
+		}
+		return rows, nil
+	})
+}
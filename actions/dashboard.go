@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v6"
+
+	"library/models"
+)
+
+// metricsCache keeps the library's metrics for a short while, so the
+// dashboard doesn't count the whole library each time it is loaded.
+type metricsCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	metrics *models.LibraryMetrics
+}
+
+// dashboardMetrics caches the metrics for DASHBOARD_CACHE, a minute by
+// default.
+var dashboardMetrics = &metricsCache{TTL: dashboardCacheTTL()}
+
+func dashboardCacheTTL() time.Duration {
+	if d, err := time.ParseDuration(envy.Get("DASHBOARD_CACHE", "")); err == nil && d >= 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// Get returns the metrics worked out less than TTL before now, or works
+// them out afresh.
+func (mc *metricsCache) Get(tx *pop.Connection, now time.Time) (*models.LibraryMetrics, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.metrics != nil && now.Sub(mc.metrics.At) < mc.TTL && !now.Before(mc.metrics.At) {
+		return mc.metrics, nil
+	}
+	metrics, err := models.CurrentMetrics(tx, now)
+	if err != nil {
+		return nil, err
+	}
+	mc.metrics = metrics
+	return metrics, nil
+}
+
+// Reset drops the cached metrics.
+func (mc *metricsCache) Reset() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.metrics = nil
+}
+
+// DashboardMetrics serves the library's metrics to the dashboard as
+// JSON, with the fines written the way the user's language does.
+func DashboardMetrics(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	m, err := dashboardMetrics.Get(tx, time.Now())
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("Cache-Control", "private, no-cache")
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"metrics":         m,
+		"fines_formatted": m.FinesOutstanding.Format(languages(c)...),
+		"refresh_seconds": int(dashboardMetrics.TTL.Seconds()),
+	}))
+}
@@ -0,0 +1,45 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_DashboardMetrics() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+	dashboardMetrics.Reset()
+	defer dashboardMetrics.Reset()
+
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	as.NoError(as.DB.Create(&models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}))
+
+	res := as.HTML("/auth").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "/auth/dashboard/metrics")
+
+	metrics := func() map[string]interface{} {
+		res := as.JSON("/auth/dashboard/metrics").Get()
+		as.Equal(http.StatusOK, res.Code)
+		body := struct {
+			Metrics map[string]interface{} `json:"metrics"`
+		}{}
+		as.NoError(json.Unmarshal(res.Body.Bytes(), &body))
+		return body.Metrics
+	}
+	as.Equal(float64(1), metrics()["titles"])
+
+	// the figures are kept for a while
+	as.NoError(as.DB.Create(&models.Book{CategoryID: category.ID.String(), Title: "Dune", BookNo: "D-1", Author: "Frank Herbert", Price: money.New(100, "USD"), Status: 1}))
+	as.Equal(float64(1), metrics()["titles"])
+	dashboardMetrics.Reset()
+	as.Equal(float64(2), metrics()["titles"])
+
+	as.Session.Clear()
+	as.NotEqual(http.StatusOK, as.JSON("/auth/dashboard/metrics").Get().Code)
+}
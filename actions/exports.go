@@ -0,0 +1,103 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/pkg/errors"
+
+	"library/spreadsheet"
+)
+
+// exportBatch is the number of records fetched per query while an
+// export is streamed to the client.
+const exportBatch = 500
+
+// listQuery is the search and ordering of a list, as sent by DataTables
+// to the index endpoints and by the export buttons to the exports.
+type listQuery struct {
+	Search string
+	Order  string
+}
+
+// listQueryFromParams reads the DataTables "search[value]" and
+// "order[0]" params. Only the columns named in sortable can be ordered
+// by; they map the DataTables column name to an SQL expression.
+func listQueryFromParams(c buffalo.Context, sortable map[string]string) listQuery {
+	lq := listQuery{Search: strings.TrimSpace(c.Param("search[value]"))}
+	index, _ := strconv.Atoi(c.Param("order[0][column]"))
+	column, ok := sortable[c.Param("columns["+strconv.Itoa(index)+"][data]")]
+	if !ok {
+		return lq
+	}
+	dir := "asc"
+	if strings.EqualFold(c.Param("order[0][dir]"), "desc") {
+		dir = "desc"
+	}
+	lq.Order = column + " " + dir
+	return lq
+}
+
+// orderBy returns the requested order, or fallback when none was
+// requested, followed by tiebreak so pages never overlap.
+func (lq listQuery) orderBy(fallback, tiebreak string) string {
+	if lq.Order == "" {
+		return fallback + ", " + tiebreak
+	}
+	return lq.Order + ", " + tiebreak
+}
+
+// like returns the search term as a LIKE pattern.
+func (lq listQuery) like() string {
+	return "%" + lq.Search + "%"
+}
+
+// streamExport writes a list as CSV, XLSX or PDF, chosen by the
+// "format" param. next is called with page 1, 2, ... and returns up to
+// exportBatch rows; each batch is flushed to the client as it is
+// written so large lists don't have to fit in memory.
+func streamExport(c buffalo.Context, title string, header []string, next func(page int) ([][]string, error)) error {
+	format := c.Param("format")
+	if format == "" {
+		format = spreadsheet.CSV
+	}
+
+	w := c.Response()
+	sw, err := spreadsheet.NewWriter(format, w, title)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	filename := fmt.Sprintf("%s-%s.%s", strings.ToLower(strings.ReplaceAll(title, " ", "-")), time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Type", spreadsheet.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := sw.Write(header); err != nil {
+		return err
+	}
+	for page := 1; ; page++ {
+		rows, err := next(page)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, row := range rows {
+			if err := sw.Write(row); err != nil {
+				return err
+			}
+		}
+		if err := sw.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if len(rows) < exportBatch {
+			break
+		}
+	}
+	return sw.Close()
+}
@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"net/http"
+	"strings"
+
+	"library/models"
+)
+
+func (as *ActionSuite) Test_CustomersExport() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	as.createPlan()
+	as.NoError(as.DB.Create(&models.Customer{Name: "Ann Reader", Email: "ann@example.com", Mobile: "0123456789"}))
+	as.NoError(as.DB.Create(&models.Customer{Name: "Bob Borrower", Email: "bob@example.com", Mobile: "0123456789"}))
+
+	res := as.HTML("/auth/customers/export?format=csv&search[value]=ann").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("text/csv; charset=utf-8", res.Header().Get("Content-Type"))
+	as.Contains(res.Header().Get("Content-Disposition"), ".csv")
+	body := res.Body.String()
+	as.Contains(body, "Name,Email,Mobile,Address,Updated At")
+	as.Contains(body, "Ann Reader")
+	as.NotContains(body, "Bob Borrower")
+
+	res = as.HTML("/auth/customers/export?format=pdf").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("application/pdf", res.Header().Get("Content-Type"))
+	as.True(strings.HasPrefix(res.Body.String(), "%PDF-"))
+}
+
+func (as *ActionSuite) Test_CustomersExport_IgnoresUnknownOrder() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/auth/customers/export?format=xlsx&order[0][column]=0&columns[0][data]=id;DROP+TABLE+customers").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", res.Header().Get("Content-Type"))
+}
+
+func (as *ActionSuite) Test_BooksExport_BadFormat() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/auth/books/export?format=doc").Get()
+	as.Equal(http.StatusBadRequest, res.Code)
+}
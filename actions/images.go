@@ -0,0 +1,102 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/pkg/errors"
+
+	"library/models"
+	"library/upload"
+)
+
+// ServeImage serves a resized copy of an uploaded image, as set by the
+// params "w" and "h" (from models.ImageSizes), "fit" ("contain" or
+// "cover") and "format" ("jpeg", "png" or "webp"). Copies are made once
+// and kept in models.ImageCache. Who may see an image is decided as in
+// ServeUploads.
+// This function is mapped to the path GET /images/{path}
+func ServeImage(c buffalo.Context) error {
+	filePath := "/uploads/" + c.Param("path")
+	key, err := uploadKey(c, filePath)
+	if err != nil {
+		return err
+	}
+	v, err := upload.ParseVariant(c.Param("w"), c.Param("h"), c.Param("fit"), c.Param("format"), models.ImageSizes)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	// images named after their content never change, so their copies
+	// are good for ever; others are copied again when they change
+	version := "0"
+	if !upload.Immutable(key) {
+		info, err := models.Uploads.Storage.Stat(c, key)
+		if errors.Is(err, upload.ErrNotExist) {
+			return c.Error(http.StatusNotFound, err)
+		}
+		if err != nil {
+			return err
+		}
+		version = strconv.FormatInt(info.ModTime.Unix(), 36)
+	}
+	cacheKey := strings.TrimSuffix(key, path.Ext(key)) + "/" + version + "-" + v.Name()
+	etag := fmt.Sprintf(`"%s-%s"`, strings.TrimSuffix(path.Base(key), path.Ext(key)), strings.TrimSuffix(path.Base(cacheKey), path.Ext(cacheKey)))
+
+	cached, info, err := models.ImageCache.Get(c, cacheKey)
+	if err == nil {
+		defer cached.Close()
+		return serveUpload(c, key, cached, v.ContentType(), etag, info.ModTime)
+	}
+	if !errors.Is(err, upload.ErrNotExist) {
+		return err
+	}
+
+	src, info, err := models.Uploads.Open(c, filePath)
+	if errors.Is(err, upload.ErrNotExist) {
+		return c.Error(http.StatusNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	data, err := v.Make(src)
+	if upload.IsRejected(err) {
+		return c.Error(http.StatusUnprocessableEntity, err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := models.ImageCache.Put(c, cacheKey, bytes.NewReader(data), int64(len(data)), v.ContentType()); err != nil {
+		c.Logger().Errorf("caching %s: %v", cacheKey, err)
+	}
+	return serveUpload(c, key, bytes.NewReader(data), v.ContentType(), etag, info.ModTime)
+}
+
+// imageURL is the path of a resized copy of the upload at p, as in
+// <img src="<%= imageURL(book.PicturePath, 80, 100) %>">. Covers fill
+// the box. Anything that isn't an upload, such as an empty path, is
+// returned as it is.
+func imageURL(p string, width, height int) string {
+	key, ok := models.Uploads.Key(p)
+	if !ok {
+		return p
+	}
+	q := url.Values{"fit": {upload.FitCover}}
+	if width > 0 {
+		q.Set("w", strconv.Itoa(width))
+	}
+	if height > 0 {
+		q.Set("h", strconv.Itoa(height))
+	}
+	if width == 0 || height == 0 {
+		q.Set("fit", upload.FitContain)
+	}
+	return "/images/" + key + "?" + q.Encode()
+}
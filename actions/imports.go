@@ -0,0 +1,296 @@
+package actions
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"library/models"
+	"library/spreadsheet"
+)
+
+// maxImportSize is the largest spreadsheet accepted for a bulk import.
+const maxImportSize = 20 << 20
+
+// pendingImport is saved next to an uploaded spreadsheet so the mapping,
+// dry run and commit steps all work on the same file.
+type pendingImport struct {
+	Kind     string               `json:"kind"`
+	Filename string               `json:"filename"`
+	Format   string               `json:"format"`
+	Options  models.ImportOptions `json:"options"`
+}
+
+// ImportNew renders the upload form for a bulk import. The "kind" param
+// preselects books, customers or inventories.
+// This function is mapped to the path GET /auth/imports/new
+func ImportNew(c buffalo.Context) error {
+	c.Set("kind", c.Param("kind"))
+	c.Set("PageTitle", "Bulk Import")
+	return c.Render(http.StatusOK, r2.HTML("backend/imports/new.plush.html"))
+}
+
+// ImportUpload stores the uploaded file and renders the column mapping
+// form, preselecting columns whose headings match a field.
+// This function is mapped to the path POST /auth/imports/upload
+func ImportUpload(c buffalo.Context) error {
+	imp, ok := models.Importers[c.Param("Kind")]
+	if !ok {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("unknown import %q", c.Param("Kind")))
+	}
+
+	f, err := c.File("File")
+	if err != nil || !f.Valid() {
+		c.Flash().Add("danger", T.Translate(c, "import.file.missing"))
+		return c.Redirect(http.StatusSeeOther, "/auth/imports/new?kind=%s", imp.Kind)
+	}
+	format := spreadsheet.FormatOf(f.Filename)
+	if format == "" || f.Size > maxImportSize {
+		c.Flash().Add("danger", T.Translate(c, "import.file.invalid"))
+		return c.Redirect(http.StatusSeeOther, "/auth/imports/new?kind=%s", imp.Kind)
+	}
+
+	token, err := uuid.NewV4()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p := &pendingImport{Kind: imp.Kind, Filename: f.Filename, Format: format}
+	if err := os.MkdirAll(importDir, 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	dst, err := os.Create(importFile(token.String()) + "." + format)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = io.Copy(dst, f)
+	dst.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	rows, err := readPendingImport(token.String(), p)
+	if err != nil || len(rows) < 2 {
+		removePendingImport(token.String(), p)
+		c.Flash().Add("danger", T.Translate(c, "import.file.invalid"))
+		return c.Redirect(http.StatusSeeOther, "/auth/imports/new?kind=%s", imp.Kind)
+	}
+	p.Options = models.ImportOptions{Mapping: imp.AutoMap(rows[0]), Upsert: c.Param("Upsert") == "true"}
+	if err := savePendingImport(token.String(), p); err != nil {
+		return err
+	}
+
+	c.Set("token", token.String())
+	c.Set("pending", p)
+	c.Set("importer", imp)
+	c.Set("mapping", importMapping(imp, p))
+	c.Set("header", rows[0])
+	c.Set("sample", importSample(rows))
+	c.Set("PageTitle", "Bulk Import - Columns")
+	return c.Render(http.StatusOK, r2.HTML("backend/imports/mapping.plush.html"))
+}
+
+// ImportCheck saves the column mapping and validates every row without
+// writing anything.
+// This function is mapped to the path POST /auth/imports/{token}/check
+func ImportCheck(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	token := c.Param("token")
+	p, rows, err := loadPendingImport(token)
+	if err != nil {
+		c.Flash().Add("danger", T.Translate(c, "import.file.expired"))
+		return c.Redirect(http.StatusSeeOther, "/auth/imports/new")
+	}
+	imp := models.Importers[p.Kind]
+
+	p.Options = models.ImportOptions{Mapping: map[string]int{}, Upsert: c.Param("Upsert") == "true"}
+	for _, field := range imp.Fields {
+		if col, err := strconv.Atoi(c.Param("map[" + field + "]")); err == nil && col >= 0 {
+			p.Options.Mapping[field] = col
+		}
+	}
+	if err := savePendingImport(token, p); err != nil {
+		return err
+	}
+
+	report, err := imp.Run(tx, rows[1:], p.Options, false)
+	if err != nil {
+		c.Flash().Add("danger", err.Error())
+		c.Set("token", token)
+		c.Set("pending", p)
+		c.Set("importer", imp)
+		c.Set("mapping", importMapping(imp, p))
+		c.Set("header", rows[0])
+		c.Set("sample", importSample(rows))
+		c.Set("PageTitle", "Bulk Import - Columns")
+		return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/imports/mapping.plush.html"))
+	}
+	return renderImportReport(c, token, p, rows[0], report, http.StatusOK)
+}
+
+// ImportCreate runs the import for real. Nothing is written unless every
+// row is valid, and all rows are written in the request transaction.
+// This function is mapped to the path POST /auth/imports/{token}
+func ImportCreate(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	token := c.Param("token")
+	p, rows, err := loadPendingImport(token)
+	if err != nil {
+		c.Flash().Add("danger", T.Translate(c, "import.file.expired"))
+		return c.Redirect(http.StatusSeeOther, "/auth/imports/new")
+	}
+	imp := models.Importers[p.Kind]
+
+	report, err := imp.Run(tx, rows[1:], p.Options, true)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if report.HasErrors() {
+		return renderImportReport(c, token, p, rows[0], report, http.StatusUnprocessableEntity)
+	}
+	removePendingImport(token, p)
+
+	c.Flash().Add("success", T.Translate(c, "import.created.success", map[string]interface{}{
+		"Created": report.Count(models.ImportCreate),
+		"Updated": report.Count(models.ImportUpdate),
+	}))
+	return c.Redirect(http.StatusSeeOther, "/auth/%s", p.Kind)
+}
+
+// ImportErrors downloads the rows that failed the dry run as CSV, with
+// the problems in an extra "Errors" column, so they can be fixed and
+// uploaded again.
+// This function is mapped to the path GET /auth/imports/{token}/errors
+func ImportErrors(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	token := c.Param("token")
+	p, rows, err := loadPendingImport(token)
+	if err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	report, err := models.Importers[p.Kind].Run(tx, rows[1:], p.Options, false)
+	if err != nil {
+		return c.Error(http.StatusUnprocessableEntity, err)
+	}
+
+	w := c.Response()
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", p.Kind+"-import-errors.csv"))
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, rows[0]...), "Errors")); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, row := range report.Rows {
+		if row.Action != models.ImportError {
+			continue
+		}
+		if err := cw.Write(append(append([]string{}, row.Cells...), strings.Join(row.Errors, "; "))); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cw.Flush()
+	return errors.WithStack(cw.Error())
+}
+
+// importMapping returns the column chosen for every field, -1 meaning
+// the field is not imported.
+func importMapping(imp *models.Importer, p *pendingImport) map[string]int {
+	mapping := map[string]int{}
+	for _, field := range imp.Fields {
+		mapping[field] = -1
+		if col, ok := p.Options.Mapping[field]; ok {
+			mapping[field] = col
+		}
+	}
+	return mapping
+}
+
+// importSample returns the first few data rows to help staff recognise
+// the columns while mapping them.
+func importSample(rows [][]string) [][]string {
+	if len(rows) > 6 {
+		return rows[1:6]
+	}
+	return rows[1:]
+}
+
+func renderImportReport(c buffalo.Context, token string, p *pendingImport, header []string, report models.ImportReport, status int) error {
+	c.Set("token", token)
+	c.Set("pending", p)
+	c.Set("header", header)
+	c.Set("report", report)
+	c.Set("PageTitle", "Bulk Import - Check")
+	return c.Render(status, r2.HTML("backend/imports/report.plush.html"))
+}
+
+func savePendingImport(token string, p *pendingImport) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(importFile(token)+".json", b, 0600))
+}
+
+func loadPendingImport(token string) (*pendingImport, [][]string, error) {
+	if _, err := uuid.FromString(token); err != nil {
+		return nil, nil, err
+	}
+	b, err := os.ReadFile(importFile(token) + ".json")
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	p := &pendingImport{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if _, ok := models.Importers[p.Kind]; !ok {
+		return nil, nil, fmt.Errorf("unknown import %q", p.Kind)
+	}
+	rows, err := readPendingImport(token, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, errors.New("the file is empty")
+	}
+	return p, rows, nil
+}
+
+func readPendingImport(token string, p *pendingImport) ([][]string, error) {
+	f, err := os.Open(importFile(token) + "." + p.Format)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return spreadsheet.Read(p.Format, f, info.Size())
+}
+
+func removePendingImport(token string, p *pendingImport) {
+	os.Remove(importFile(token) + "." + p.Format)
+	os.Remove(importFile(token) + ".json")
+}
@@ -0,0 +1,25 @@
+package actions
+
+import (
+	"net/http"
+)
+
+func (as *ActionSuite) Test_ImportNew() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/auth/imports/new?kind=customers").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), `<option value="customers" selected>`)
+}
+
+func (as *ActionSuite) Test_ImportCheck_Expired() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.HTML("/auth/imports/6ba7b810-9dad-11d1-80b4-00c04fd430c8/check").Post(map[string]string{})
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.Equal("/auth/imports/new", res.Location())
+}
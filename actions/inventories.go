@@ -3,13 +3,16 @@ package actions
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/x/responder"
 
 	"library/models"
+	"library/money"
 )
 
 // This file is generated by Buffalo. It offers a basic structure for
@@ -34,10 +37,7 @@ func (v InventoriesResource) InventoriesIndex(c buffalo.Context) error {
 	draw := c.Param("draw")
 	start, _ := strconv.Atoi(c.Param("start"))
 	length, _ := strconv.Atoi(c.Param("length"))
-	searchValue := c.Param("search[value]")
-	orderColumnIndex, _ := strconv.Atoi(c.Param("order[0][column]"))
-	orderColumnName := c.Param("columns[" + strconv.Itoa(orderColumnIndex) + "][data]")
-	orderDir := c.Param("order[0][dir]")
+	lq := listQueryFromParams(c, inventoriesSortable)
 
 	// Create a DB connection
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -50,13 +50,7 @@ func (v InventoriesResource) InventoriesIndex(c buffalo.Context) error {
 	perPage := length
 
 	// Prepare the query
-	q := tx.Paginate(currentPage, perPage)
-	q = q.Join("books", "books.id = inventories.book_id").Order(orderColumnName + " " + orderDir)
-
-	// Apply search filter
-	if searchValue != "" {
-		q = q.Where("books.title LIKE ? OR inventories.qty LIKE ? ", "%"+searchValue+"%", "%"+searchValue+"%")
-	}
+	q := inventoriesQuery(tx, lq).Paginate(currentPage, perPage)
 
 	// Fetch the data
 	var inventories models.Inventories
@@ -93,6 +87,7 @@ func formatInventoriesData(inventories models.Inventories) []interface{} {
 		inventoryID := inventory.ID.String()
 		formattedInventory["title"] = inventory.Book.Title
 		formattedInventory["qty"] = inventory.Qty
+		formattedInventory["branch"] = inventory.Branch
 
 		formattedInventory["updated_at"] = inventory.UpdatedAt.Format("01-02-2006 (03:04 PM)")
 		// Add the custom action column with edit and delete buttons
@@ -340,3 +335,138 @@ func (v InventoriesResource) Destroy(c buffalo.Context) error {
 		return c.Render(http.StatusOK, r2.XML(inventory))
 	}).Respond(c)
 }
+
+// inventoriesSortable maps the inventories table columns to the SQL
+// they order by.
+var inventoriesSortable = map[string]string{
+	"title":      "books.title",
+	"qty":        "inventories.qty",
+	"branch":     "inventories.branch",
+	"updated_at": "inventories.updated_at",
+}
+
+// inventoriesQuery filters and orders inventories the way the
+// inventories list does.
+func inventoriesQuery(tx *pop.Connection, lq listQuery) *pop.Query {
+	q := tx.Q().Join("books", "books.id = inventories.book_id")
+	if lq.Search != "" {
+		like := lq.like()
+		q = q.Where("books.title LIKE ? OR inventories.qty LIKE ? OR inventories.branch LIKE ?", like, like, like)
+	}
+	return q.Order(lq.orderBy("inventories.created_at desc", "inventories.id"))
+}
+
+// InventoriesExport downloads the inventories list, with the same
+// search and order as on screen, as CSV, XLSX or PDF.
+// This function is mapped to the path GET /auth/inventories/export
+func (v InventoriesResource) InventoriesExport(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	lq := listQueryFromParams(c, inventoriesSortable)
+
+	header := []string{"Book No", "Title", "Branch", "Qty", "Updated At"}
+	return streamExport(c, "Inventories", header, func(page int) ([][]string, error) {
+		var inventories models.Inventories
+		if err := inventoriesQuery(tx, lq).Paginate(page, exportBatch).Eager("Book").All(&inventories); err != nil {
+			return nil, err
+		}
+		rows := make([][]string, 0, len(inventories))
+		for _, inventory := range inventories {
+			bookNo, title := "", ""
+			if inventory.Book != nil {
+				bookNo, title = inventory.Book.BookNo, inventory.Book.Title
+			}
+			rows = append(rows, []string{bookNo, title, inventory.Branch, strconv.Itoa(inventory.Qty),
+				inventory.UpdatedAt.Format("2006-01-02 15:04")})
+		}
+		return rows, nil
+	})
+}
+
+// valuationTotals adds up the valuation of each branch and of all of
+// them, in each currency. Titles are left out of the totals, as a book
+// may be kept at more than one branch.
+func valuationTotals(valuation []models.StockValuation) ([]models.StockValuation, error) {
+	totals := []models.StockValuation{}
+	index := map[[2]string]int{}
+	add := func(branch string, v models.StockValuation) error {
+		key := [2]string{branch, v.Currency}
+		i, ok := index[key]
+		if !ok {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, models.StockValuation{Branch: branch, Currency: v.Currency, Value: money.New(0, v.Currency)})
+		}
+		t := &totals[i]
+		t.Copies += v.Copies
+		var err error
+		t.Value, err = t.Value.Add(v.Value)
+		return err
+	}
+	for _, v := range valuation {
+		if err := add(v.Branch, v); err != nil {
+			return nil, err
+		}
+		if err := add("", v); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		if (totals[i].Branch == "") != (totals[j].Branch == "") {
+			return totals[j].Branch == ""
+		}
+		if totals[i].Branch != totals[j].Branch {
+			return totals[i].Branch < totals[j].Branch
+		}
+		return totals[i].Currency < totals[j].Currency
+	})
+	return totals, nil
+}
+
+// InventoriesValuation values the stock at the books' prices by
+// category and branch, or exports the valuation as CSV, XLSX or PDF
+// when a "format" is asked for.
+// This function is mapped to the path GET /auth/inventories/valuation
+func (v InventoriesResource) InventoriesValuation(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	valuation, err := models.InventoryValuation(tx)
+	if err != nil {
+		return err
+	}
+	totals, err := valuationTotals(valuation)
+	if err != nil {
+		return err
+	}
+
+	if c.Param("format") != "" {
+		header := []string{"Category", "Branch", "Currency", "Titles", "Copies", "Value"}
+		return streamExport(c, "Inventory Valuation "+time.Now().Format("2006-01-02"), header, func(page int) ([][]string, error) {
+			if page > 1 {
+				return nil, nil
+			}
+			rows := [][]string{}
+			for _, s := range valuation {
+				rows = append(rows, []string{s.Category, s.Branch, s.Currency, strconv.Itoa(s.Titles), strconv.Itoa(s.Copies), s.Value.Decimal()})
+			}
+			for _, t := range totals {
+				branch := t.Branch
+				if branch == "" {
+					branch = "All branches"
+				}
+				rows = append(rows, []string{"Total", branch, t.Currency, "", strconv.Itoa(t.Copies), t.Value.Decimal()})
+			}
+			return rows, nil
+		})
+	}
+
+	c.Set("valuation", valuation)
+	c.Set("totals", totals)
+	c.Set("PageTitle", "Inventory Valuation")
+	return c.Render(http.StatusOK, r2.HTML("backend/inventories/valuation.plush.html"))
+}
@@ -0,0 +1,171 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/worker"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/jobs"
+	"library/models"
+)
+
+// scheduledTask is a job the app runs on a schedule. Its schedule can
+// be changed by setting SCHEDULE_<NAME>, as in SCHEDULE_SEND_NOTICES, to
+// another cron-style one, or to "off" to not run it at all.
+type scheduledTask struct {
+	Name     string
+	Schedule string
+	Handler  worker.Handler
+}
+
+// scheduledTasks are the app's nightly and other regular work.
+func scheduledTasks() []scheduledTask {
+	return []scheduledTask{
+		// reminders, overdue notices and ready holds; sent hourly so
+		// that notices that failed are soon tried again
+		{Name: "send_notices", Schedule: "5 * * * *", Handler: sendNoticesJob},
+		{Name: "expire_holds", Schedule: "10 0 * * *", Handler: expireHoldsJob},
+	}
+}
+
+// newWorker returns the worker running the app's background jobs, kept
+// in the jobs table, with the scheduled tasks registered and scheduled.
+// JOBS_WORKER=off has an instance of the app serve requests only,
+// leaving the jobs it queues to other instances.
+func newWorker(logger worker.SimpleLogger) (*jobs.Queue, bool, error) {
+	q := jobs.New(models.DB, logger)
+	q.Observe = observeJob
+	for _, task := range scheduledTasks() {
+		if err := q.Register(task.Name, task.Handler); err != nil {
+			return nil, false, err
+		}
+		spec := envy.Get("SCHEDULE_"+strings.ToUpper(task.Name), task.Schedule)
+		if strings.EqualFold(spec, "off") {
+			continue
+		}
+		schedule, err := jobs.ParseSchedule(spec)
+		if err != nil {
+			return nil, false, err
+		}
+		q.Schedules = append(q.Schedules, jobs.Scheduled{
+			Name:     task.Name,
+			Schedule: schedule,
+			Job:      worker.Job{Handler: task.Name},
+		})
+	}
+	return q, strings.EqualFold(envy.Get("JOBS_WORKER", "on"), "off"), nil
+}
+
+// sendNoticesJob sends the notices due, as the notices:send task does.
+// Notices that failed to send make the job fail, so the failure shows on
+// the jobs page; only they are sent when it is tried again.
+func sendNoticesJob(worker.Args) error {
+	sent, failed, err := SendNotices(context.Background(), models.DB, time.Now())
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errors.Errorf("%d notices sent, %d failed", sent, failed)
+	}
+	return nil
+}
+
+// expireHoldsJob gives up the holds no longer waiting for their
+// customers, putting each book aside for the next customer in line, who
+// is told at once.
+func expireHoldsJob(worker.Args) error {
+	now := time.Now()
+	return models.DB.Transaction(func(tx *pop.Connection) error {
+		_, readied, err := models.ExpireHolds(tx, now)
+		if err != nil {
+			return err
+		}
+		for _, hold := range readied {
+			notice, err := models.HoldNotice(tx, hold)
+			if err != nil {
+				return err
+			}
+			if _, err := notify(context.Background(), tx, notice, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// JobsIndex lists the background jobs' runs, latest first, or those with
+// the status asked for.
+func JobsIndex(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	status := c.Param("status")
+	q := tx.PaginateFromParams(c.Params())
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	list := models.Jobs{}
+	if err := q.Order("updated_at desc").All(&list); err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, s := range models.JobStatuses {
+		n, err := tx.Where("status = ?", s).Count(&models.Job{})
+		if err != nil {
+			return err
+		}
+		counts[s] = n
+	}
+
+	c.Set("jobs", list)
+	c.Set("status", status)
+	c.Set("statuses", models.JobStatuses)
+	c.Set("counts", counts)
+	c.Set("schedules", scheduledTasksList())
+	c.Set("pagination", q.Paginator)
+	c.Set("PageTitle", "Background Jobs")
+	return c.Render(http.StatusOK, r2.HTML("backend/jobs/index.plush.html"))
+}
+
+// scheduledTasksList describes the scheduled tasks for the jobs page.
+func scheduledTasksList() []map[string]string {
+	list := []map[string]string{}
+	for _, task := range scheduledTasks() {
+		spec := envy.Get("SCHEDULE_"+strings.ToUpper(task.Name), task.Schedule)
+		list = append(list, map[string]string{"name": task.Name, "schedule": spec})
+	}
+	return list
+}
+
+// JobsRetry queues a job that failed for good to run again.
+func JobsRetry(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	job := &models.Job{}
+	if err := tx.Find(job, c.Param("job_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	if job.Status != models.JobFailed {
+		c.Flash().Add("danger", T.Translate(c, "job.retried.not_failed", map[string]string{"Handler": job.Handler, "Status": job.Status}))
+		return c.Redirect(http.StatusSeeOther, "/auth/jobs")
+	}
+	if err := job.Retry(tx, time.Now()); err != nil {
+		return err
+	}
+	c.Flash().Add("success", T.Translate(c, "job.retried.success", map[string]string{"Handler": job.Handler}))
+	return c.Redirect(http.StatusSeeOther, "/auth/jobs")
+}
@@ -0,0 +1,62 @@
+package actions
+
+import (
+	"net/http"
+
+	"github.com/gobuffalo/buffalo/worker"
+	"github.com/pkg/errors"
+
+	"library/jobs"
+	"library/models"
+)
+
+func (as *ActionSuite) Test_Jobs() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	q := jobs.New(as.DB, as.App.Logger)
+	q.MaxAttempts = 2
+	runs := 0
+	as.NoError(q.Register("flaky", func(worker.Args) error {
+		runs++
+		return errors.New("the printer is on fire")
+	}))
+	as.NoError(q.Perform(worker.Job{Handler: "flaky", Args: worker.Args{"report": "loans"}}))
+
+	// the first failure waits its turn to be tried again
+	n, err := q.WorkAll(as.App.Context)
+	as.NoError(err)
+	as.Equal(1, n)
+	job := &models.Job{}
+	as.NoError(as.DB.First(job))
+	as.Equal(models.JobQueued, job.Status)
+	as.Equal(1, job.Attempts)
+	as.Equal("the printer is on fire", job.LastError.String)
+	as.True(job.RunAt.After(job.UpdatedAt))
+
+	// the last fails it for good
+	job.RunAt = job.CreatedAt
+	as.NoError(as.DB.Update(job))
+	_, err = q.WorkAll(as.App.Context)
+	as.NoError(err)
+	as.NoError(as.DB.Reload(job))
+	as.Equal(models.JobFailed, job.Status)
+	as.Equal(2, runs)
+
+	res := as.HTML("/auth/jobs?status=failed").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "<title>Library | Background Jobs</title>")
+	as.Contains(res.Body.String(), "flaky")
+	as.Contains(res.Body.String(), "the printer is on fire")
+
+	res = as.HTML("/auth/jobs/%s/retry", job.ID).Post(nil)
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.NoError(as.DB.Reload(job))
+	as.Equal(models.JobQueued, job.Status)
+	as.Equal(0, job.Attempts)
+
+	// only failed jobs are retried
+	res = as.HTML("/auth/jobs/%s/retry", job.ID).Post(nil)
+	as.Equal(http.StatusSeeOther, res.Code)
+}
@@ -0,0 +1,322 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/barcode"
+	"library/callnumber"
+	"library/labels"
+	"library/models"
+	"library/pdf"
+	"library/upload"
+)
+
+// libraryName heads the library cards.
+var libraryName = envy.Get("LIBRARY_NAME", "Library")
+
+const (
+	defaultCardSheet  = "avery-5371"
+	defaultLabelSheet = "avery-5160"
+)
+
+// labelSheets returns the built in label sheets and those in the JSON
+// file LABEL_SHEETS names. A file that can't be read is logged and
+// left out.
+func labelSheets(c buffalo.Context) []labels.Sheet {
+	path := envy.Get("LABEL_SHEETS", "")
+	if path == "" {
+		return labels.Builtin
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		c.Logger().Errorf("label sheets: %v", err)
+		return labels.Builtin
+	}
+	defer f.Close()
+	sheets, err := labels.Load(f)
+	if err != nil {
+		c.Logger().Errorf("label sheets in %s: %v", path, err)
+		return labels.Builtin
+	}
+	return sheets
+}
+
+// labelRequest is what the print forms on the index pages send: the
+// selected records, the sheet and the label to start at.
+type labelRequest struct {
+	ids   []interface{}
+	sheet labels.Sheet
+	skip  int
+}
+
+// parseLabelRequest reads a print form, sent as a GET for a single
+// record or a POST for a selection too long for a URL.
+func parseLabelRequest(c buffalo.Context, defaultSheet string) (labelRequest, error) {
+	req := labelRequest{}
+	if err := c.Request().ParseForm(); err != nil {
+		return req, err
+	}
+	for _, id := range c.Request().Form["ids"] {
+		req.ids = append(req.ids, id)
+	}
+
+	sheets := labelSheets(c)
+	name := c.Param("sheet")
+	if name == "" {
+		name = defaultSheet
+	}
+	i := labels.Find(sheets, name)
+	if i < 0 {
+		return req, fmt.Errorf("there is no label sheet %q", name)
+	}
+	req.sheet = sheets[i]
+
+	if start := c.Param("start"); start != "" {
+		n, err := strconv.Atoi(start)
+		if err != nil || n < 1 || n > req.sheet.PerSheet() {
+			return req, fmt.Errorf("a %s sheet has labels 1 to %d", req.sheet.Name, req.sheet.PerSheet())
+		}
+		req.skip = n - 1
+	}
+	return req, nil
+}
+
+// streamLabels sends the labels as a PDF to open in the browser.
+func streamLabels(c buffalo.Context, name string, req labelRequest, n int, draw func(d *pdf.Document, p *pdf.Page, i int, box labels.Box) error) error {
+	w := c.Response()
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fmt.Sprintf("%s-%s.pdf", name, time.Now().Format("20060102"))))
+	w.Header().Set("Cache-Control", "no-store")
+	return labels.Print(w, req.sheet, req.skip, n, draw)
+}
+
+// CustomersCards prints the library cards of the selected customers.
+// This function is mapped to the path GET and POST /auth/customers/cards
+func (v CustomersResource) CustomersCards(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	req, err := parseLabelRequest(c, defaultCardSheet)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	if len(req.ids) == 0 {
+		c.Flash().Add("danger", T.Translate(c, "labels.none_selected"))
+		return c.Redirect(http.StatusSeeOther, "/auth/customers")
+	}
+
+	customers := models.Customers{}
+	if err := tx.Where("id IN (?)", req.ids...).Order("name").All(&customers); err != nil {
+		return err
+	}
+	plans, err := membershipPlanNames(tx)
+	if err != nil {
+		return err
+	}
+
+	return streamLabels(c, "library-cards", req, len(customers), func(d *pdf.Document, p *pdf.Page, i int, box labels.Box) error {
+		customer := customers[i]
+		photo, err := cardPhoto(c, d, customer.PhotoPath)
+		if err != nil {
+			c.Logger().Errorf("photo of customer %s: %v", customer.ID, err)
+		}
+		drawCard(p, box, customer, plans[customer.MembershipPlanID], photo)
+		return nil
+	})
+}
+
+// cardPhoto adds a customer's photo to the document, cropped to the
+// shape of the space on the card. Customers without a photo get none.
+func cardPhoto(c buffalo.Context, d *pdf.Document, path string) (*pdf.Image, error) {
+	if path == "" {
+		return nil, nil
+	}
+	r, _, err := models.Uploads.Open(c, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.AddImage(upload.Fill(img, 240, 300))
+}
+
+// drawCard lays a library card out in box: the library's name across
+// the top, the photo on the left, the customer's name, plan and expiry
+// beside it and the card number's barcode along the bottom.
+func drawCard(p *pdf.Page, box labels.Box, customer models.Customer, plan string, photo *pdf.Image) {
+	pad := box.Height * 0.06
+	x, y := box.X+pad, box.Y+pad
+	width := box.Width - 2*pad
+
+	size := box.Height * 0.09
+	p.Text(x, y+size, size, true, pdf.Fit(libraryName+" Card", width, size, true))
+	y += size + pad/2
+	p.Line(x, y, x+width, y, 0.5)
+	y += pad / 2
+
+	photoHeight := box.Height * 0.5
+	photoWidth := photoHeight * 0.8
+	if photo != nil {
+		p.Image(photo, x, y, photoWidth, photoHeight)
+	} else {
+		p.Gray(0.6)
+		p.Rect(x, y, photoWidth, photoHeight, false)
+		p.Gray(0)
+	}
+
+	textX := x + photoWidth + pad
+	textWidth := box.X + box.Width - pad - textX
+	size = box.Height * 0.085
+	p.Text(textX, y+size, size, true, pdf.Fit(customer.Name, textWidth, size, true))
+	small := box.Height * 0.065
+	line := y + size + small*1.6
+	if plan != "" {
+		p.Text(textX, line, small, false, pdf.Fit(strings.ToUpper(plan[:1])+plan[1:]+" member", textWidth, small, false))
+		line += small * 1.4
+	}
+	if customer.ExpiresOn.Valid {
+		p.Text(textX, line, small, false, pdf.Fit("Expires "+formatDate(customer.ExpiresOn), textWidth, small, false))
+	}
+
+	drawBarcode(p, textX, y+box.Height*0.31, textWidth, box.Height*0.12, small, customer.CardNumber)
+}
+
+// drawBarcode draws the Code 128 barcode of s as wide as fits in width,
+// with s written underneath in the given size. Text that can't be put
+// in a barcode is only written.
+func drawBarcode(p *pdf.Page, x, y, width, height, size float64, s string) {
+	if modules, err := barcode.Code128(s); err == nil {
+		// bars wider than three quarters of a millimetre don't scan any
+		// better
+		module := math.Min(width/float64(len(modules)+2*barcode.QuietZone), 0.75*pdf.MM)
+		p.Bars(x+(width-module*float64(len(modules)))/2, y, module, height, modules)
+	}
+	p.TextCenter(x+width/2, y+height+size*1.1, size, false, s)
+}
+
+// BooksLabels prints spine or barcode labels for the selected books,
+// one for each book or one for each copy in stock.
+// This function is mapped to the path GET and POST /auth/books/labels
+func (v BooksResource) BooksLabels(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	req, err := parseLabelRequest(c, defaultLabelSheet)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	if len(req.ids) == 0 {
+		c.Flash().Add("danger", T.Translate(c, "labels.none_selected"))
+		return c.Redirect(http.StatusSeeOther, "/auth/books")
+	}
+
+	books := models.Books{}
+	if err := tx.Where("id IN (?)", req.ids...).Order("call_number_sort, title").All(&books); err != nil {
+		return err
+	}
+
+	// each label is a book and which of its copies it is for
+	type bookLabel struct {
+		book         models.Book
+		copy, copies int
+	}
+	var todo []bookLabel
+	if c.Param("copies") == "stock" {
+		stock := []struct {
+			BookID string `db:"book_id"`
+			Qty    int    `db:"qty"`
+		}{}
+		if err := tx.RawQuery("SELECT book_id, SUM(qty) AS qty FROM inventories WHERE book_id IN (?) GROUP BY book_id", req.ids...).All(&stock); err != nil {
+			return err
+		}
+		qty := map[string]int{}
+		for _, s := range stock {
+			qty[s.BookID] = s.Qty
+		}
+		for _, book := range books {
+			n := qty[book.ID.String()]
+			for i := 1; i <= n; i++ {
+				todo = append(todo, bookLabel{book, i, n})
+			}
+		}
+	} else {
+		for _, book := range books {
+			todo = append(todo, bookLabel{book, 1, 1})
+		}
+	}
+
+	spine := c.Param("kind") == "spine"
+	return streamLabels(c, "book-labels", req, len(todo), func(d *pdf.Document, p *pdf.Page, i int, box labels.Box) error {
+		label := todo[i]
+		if spine {
+			drawSpineLabel(p, box, label.book)
+		} else {
+			drawBookLabel(p, box, label.book, label.copy, label.copies)
+		}
+		return nil
+	})
+}
+
+// drawSpineLabel writes the call number one part to a line, as large
+// as fits. Books without a call number get their book number.
+func drawSpineLabel(p *pdf.Page, box labels.Box, book models.Book) {
+	lines := []string{book.BookNo}
+	if cn, err := callnumber.Parse(book.CallNumber); err == nil {
+		lines = cn.SpineLines()
+	} else if book.CallNumber != "" {
+		lines = strings.Fields(book.CallNumber)
+	}
+
+	pad := math.Min(box.Width, box.Height) * 0.08
+	size := math.Min((box.Height-2*pad)/float64(len(lines))/1.15, 14)
+	for _, line := range lines {
+		if w := pdf.TextWidth(line, size, true); w > box.Width-2*pad {
+			size *= (box.Width - 2*pad) / w
+		}
+	}
+	y := box.Y + (box.Height-size*1.15*float64(len(lines)))/2
+	for _, line := range lines {
+		y += size * 1.15
+		p.TextCenter(box.X+box.Width/2, y-size*0.15, size, true, line)
+	}
+}
+
+// drawBookLabel puts the book's title over the barcode of its book
+// number, with the copy when a book has several.
+func drawBookLabel(p *pdf.Page, box labels.Box, book models.Book, copy, copies int) {
+	pad := math.Min(box.Width, box.Height) * 0.08
+	x, width := box.X+pad, box.Width-2*pad
+	size := box.Height * 0.12
+	p.Text(x, box.Y+pad+size, size, true, pdf.Fit(book.Title, width, size, true))
+	if copies > 1 {
+		p.TextRight(x+width, box.Y+box.Height-pad, size*0.8, false, fmt.Sprintf("copy %d of %d", copy, copies))
+	}
+	drawBarcode(p, x, box.Y+pad+size*1.5, width, box.Height*0.4, size*0.9, book.BookNo)
+}
@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"net/http"
+	"strings"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_CustomersResource_CustomersCards() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+	as.createPlan()
+
+	customer := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	as.NoError(as.DB.Create(customer))
+
+	res := as.HTML("/auth/customers/cards?ids=%s&sheet=avery-c32011&start=3", customer.ID).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("application/pdf", res.Header().Get("Content-Type"))
+	as.True(strings.HasPrefix(res.Body.String(), "%PDF-"))
+
+	res = as.HTML("/auth/customers/cards?ids=%s&start=11", customer.ID).Get()
+	as.Equal(http.StatusBadRequest, res.Code)
+	res = as.HTML("/auth/customers/cards?sheet=avery-9999").Get()
+	as.Equal(http.StatusBadRequest, res.Code)
+
+	// nothing selected
+	res = as.HTML("/auth/customers/cards").Post(map[string]string{"sheet": "avery-5371"})
+	as.Equal(http.StatusSeeOther, res.Code)
+}
+
+func (as *ActionSuite) Test_BooksResource_BooksLabels() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	book := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", CallNumber: "823.7 AUS", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(book))
+	as.NoError(as.DB.Create(&models.Inventory{BookID: book.ID.String(), Qty: 3}))
+
+	for _, query := range []string{"kind=spine", "kind=barcode&copies=stock&sheet=avery-l7651"} {
+		res := as.HTML("/auth/books/labels?ids=%s&%s", book.ID, query).Get()
+		as.Equal(http.StatusOK, res.Code, query)
+		as.True(strings.HasPrefix(res.Body.String(), "%PDF-"), query)
+	}
+}
@@ -0,0 +1,234 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/x/responder"
+
+	"library/models"
+)
+
+// MembershipPlansResource is the resource for the MembershipPlan model
+type MembershipPlansResource struct {
+	buffalo.Resource
+}
+
+// membershipPlanRow is a plan with the number of customers on it.
+type membershipPlanRow struct {
+	models.MembershipPlan
+	Customers int `json:"customers"`
+}
+
+// membershipPlanCustomers counts the customers on each plan, by plan id.
+func membershipPlanCustomers(tx *pop.Connection) (map[string]int, error) {
+	var counts []struct {
+		PlanID string `db:"membership_plan_id"`
+		Count  int    `db:"customers"`
+	}
+	if err := tx.RawQuery("SELECT membership_plan_id, COUNT(*) AS customers FROM customers GROUP BY membership_plan_id").All(&counts); err != nil {
+		return nil, err
+	}
+	byPlan := map[string]int{}
+	for _, c := range counts {
+		byPlan[c.PlanID] = c.Count
+	}
+	return byPlan, nil
+}
+
+// List shows every plan; there are only ever a handful. JSON requests
+// get them for the customer form.
+// This function is mapped to the path GET /auth/membership_plans
+func (v MembershipPlansResource) List(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	plans := models.MembershipPlans{}
+	if err := tx.Order("name").All(&plans); err != nil {
+		return err
+	}
+	counts, err := membershipPlanCustomers(tx)
+	if err != nil {
+		return err
+	}
+	rows := make([]membershipPlanRow, len(plans))
+	for i, plan := range plans {
+		rows[i] = membershipPlanRow{MembershipPlan: plan, Customers: counts[plan.ID.String()]}
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Set("plans", rows)
+		c.Set("PageTitle", "Membership Plans")
+		return c.Render(http.StatusOK, r2.HTML("backend/membership_plans/index.plush.html"))
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(rows))
+	}).Respond(c)
+}
+
+// Show sends a plan as JSON; the list shows everything there is to see
+// of a plan, so HTML requests go there.
+// This function is mapped to the path GET /auth/membership_plans/{membership_plan_id}
+func (v MembershipPlansResource) Show(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	plan := &models.MembershipPlan{}
+	if err := tx.Find(plan, c.Param("membership_plan_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		return c.Redirect(http.StatusSeeOther, "/auth/membership_plans")
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(plan))
+	}).Respond(c)
+}
+
+// New renders the form for creating a new MembershipPlan.
+// This function is mapped to the path GET /auth/membership_plans/new
+func (v MembershipPlansResource) New(c buffalo.Context) error {
+	c.Set("plan", &models.MembershipPlan{MaxLoans: 5, LoanDays: 14, MaxRenewals: 2})
+	c.Set("PageTitle", "Create Membership Plan")
+	return c.Render(http.StatusOK, r2.HTML("backend/membership_plans/new.plush.html"))
+}
+
+// Create adds a MembershipPlan to the DB. This function is mapped to the
+// path POST /auth/membership_plans
+func (v MembershipPlansResource) Create(c buffalo.Context) error {
+	plan := &models.MembershipPlan{}
+	if err := c.Bind(plan); err != nil {
+		return err
+	}
+
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	verrs, err := tx.ValidateAndCreate(plan)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Set("errors", verrs)
+			c.Set("plan", plan)
+			c.Set("PageTitle", "Create Membership Plan")
+			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/membership_plans/new.plush.html"))
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "membership_plan.created.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/membership_plans")
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusCreated, r2.JSON(plan))
+	}).Respond(c)
+}
+
+// Edit renders a edit form for a MembershipPlan. This function is
+// mapped to the path GET /auth/membership_plans/{membership_plan_id}/edit
+func (v MembershipPlansResource) Edit(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	plan := &models.MembershipPlan{}
+	if err := tx.Find(plan, c.Param("membership_plan_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	c.Set("plan", plan)
+	c.Set("PageTitle", "Edit Membership Plan")
+	return c.Render(http.StatusOK, r2.HTML("backend/membership_plans/edit.plush.html"))
+}
+
+// Update changes a MembershipPlan. New limits apply to loans made or
+// renewed from then on. This function is mapped to the path
+// PUT /auth/membership_plans/{membership_plan_id}
+func (v MembershipPlansResource) Update(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	plan := &models.MembershipPlan{}
+	if err := tx.Find(plan, c.Param("membership_plan_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	if err := c.Bind(plan); err != nil {
+		return err
+	}
+
+	verrs, err := tx.ValidateAndUpdate(plan)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Set("errors", verrs)
+			c.Set("plan", plan)
+			c.Set("PageTitle", "Edit Membership Plan")
+			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/membership_plans/edit.plush.html"))
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "membership_plan.updated.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/membership_plans")
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(plan))
+	}).Respond(c)
+}
+
+// Destroy deletes a MembershipPlan no customer is on. This function is
+// mapped to the path DELETE /auth/membership_plans/{membership_plan_id}
+func (v MembershipPlansResource) Destroy(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	plan := &models.MembershipPlan{}
+	if err := tx.Find(plan, c.Param("membership_plan_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	counts, err := membershipPlanCustomers(tx)
+	if err != nil {
+		return err
+	}
+	if n := counts[plan.ID.String()]; n > 0 || plan.IsDefault {
+		msg := T.Translate(c, "membership_plan.destroyed.in_use", map[string]interface{}{"Count": n})
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Flash().Add("danger", msg)
+			return c.Redirect(http.StatusSeeOther, "/auth/membership_plans")
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusConflict, r2.JSON(map[string]string{"error": msg}))
+		}).Respond(c)
+	}
+
+	if err := tx.Destroy(plan); err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "membership_plan.destroyed.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/membership_plans")
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(plan))
+	}).Respond(c)
+}
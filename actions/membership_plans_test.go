@@ -0,0 +1,125 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"library/mailer"
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) createPlan() *models.MembershipPlan {
+	plan := &models.MembershipPlan{Name: "adult", MaxLoans: 1, LoanDays: 14, MaxRenewals: 1, FinePerDay: money.New(25, "USD"), IsDefault: true}
+	as.NoError(as.DB.Create(plan))
+	return plan
+}
+
+func (as *ActionSuite) Test_MembershipPlansResource() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+	plan := as.createPlan()
+
+	res := as.HTML("/auth/membership_plans").Post(map[string]string{"Name": "staff", "MaxLoans": "20", "LoanDays": "42", "MaxRenewals": "5", "FinePerDay": "0"})
+	as.Equal(http.StatusSeeOther, res.Code)
+	staff := &models.MembershipPlan{}
+	as.NoError(as.DB.Where("name = ?", "staff").First(staff))
+	as.Equal(20, staff.MaxLoans)
+
+	res = as.HTML("/auth/membership_plans").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "staff")
+
+	customer := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	as.NoError(as.DB.Create(customer))
+	as.Equal(plan.ID.String(), customer.MembershipPlanID)
+
+	// plans customers are on stay
+	res = as.HTML("/auth/membership_plans/%s", plan.ID).Delete()
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.NoError(as.DB.Find(&models.MembershipPlan{}, plan.ID))
+
+	res = as.HTML("/auth/membership_plans/%s", staff.ID).Delete()
+	as.Equal(http.StatusSeeOther, res.Code)
+	exists, err := as.DB.Where("id = ?", staff.ID).Exists(&models.MembershipPlan{})
+	as.NoError(err)
+	as.False(exists)
+}
+
+func (as *ActionSuite) Test_AssignBooksResource_Renew() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+	as.createPlan()
+
+	customer := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	as.NoError(as.DB.Create(customer))
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	book := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(book))
+	loan := &models.AssignBook{CustomerID: customer.ID.String(), BookID: book.ID.String()}
+	verrs, err := as.DB.ValidateAndCreate(loan)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+
+	res := as.JSON("/auth/assign_books/%s/renew", loan.ID).Post(nil)
+	as.Equal(http.StatusOK, res.Code)
+	res = as.JSON("/auth/assign_books/%s/renew", loan.ID).Post(nil)
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+	as.Contains(res.Body.String(), "the most the adult plan allows")
+
+	res = as.JSON("/auth/assign_books/%s/return", loan.ID).Post(nil)
+	as.Equal(http.StatusOK, res.Code)
+	as.NoError(as.DB.Reload(loan))
+	as.True(loan.ReturnedOn.Valid)
+}
+
+func (as *ActionSuite) Test_AssignBooksResource_Return_hold() {
+	dir, err := os.MkdirTemp("", "mail")
+	as.NoError(err)
+	defer os.RemoveAll(dir)
+	defer func(m mailer.Sender) { models.Mailer = m }(models.Mailer)
+	models.Mailer = mailer.File{Dir: dir}
+
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+	as.createPlan()
+
+	ann := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	as.NoError(as.DB.Create(ann))
+	bo := &models.Customer{Name: "Bo", Email: "bo@example.com", Mobile: "2"}
+	as.NoError(as.DB.Create(bo))
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	book := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(book))
+	loan := &models.AssignBook{CustomerID: ann.ID.String(), BookID: book.ID.String()}
+	verrs, err := as.DB.ValidateAndCreate(loan)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+	hold := &models.Hold{CustomerID: bo.ID.String(), BookID: book.ID.String(), Status: models.HoldWaiting}
+	as.NoError(as.DB.Create(hold))
+
+	res := as.HTML("/auth/assign_books/%s/return", loan.ID).Post(nil)
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.NoError(as.DB.Reload(hold))
+	as.Equal(models.HoldReady, hold.Status)
+	as.True(hold.ReadyUntil.Valid)
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	as.NoError(err)
+	as.Len(files, 1)
+	content, err := os.ReadFile(files[0])
+	as.NoError(err)
+	as.Contains(string(content), "To: \"Bo\" <bo@example.com>")
+
+	// returning it twice readies nothing more
+	res = as.HTML("/auth/assign_books/%s/return", loan.ID).Post(nil)
+	as.Equal(http.StatusSeeOther, res.Code)
+	files, err = filepath.Glob(filepath.Join(dir, "*.eml"))
+	as.NoError(err)
+	as.Len(files, 1)
+}
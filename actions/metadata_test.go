@@ -0,0 +1,26 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+
+	"library/metadata"
+)
+
+func (as *ActionSuite) Test_BookLookup() {
+	metadataProvider = metadata.NewFixture(os.DirFS("../fixtures/metadata"))
+
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	res := as.JSON("/auth/books/lookup?isbn=978-0-14-044913-6").Get()
+	as.Equal(http.StatusOK, res.Code)
+	m := &metadata.Metadata{}
+	res.Bind(m)
+	as.Equal("Crime and Punishment", m.Title)
+	as.Equal("Penguin Books", m.Publisher)
+
+	res = as.JSON("/auth/books/lookup?isbn=0000000000").Get()
+	as.Equal(http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,198 @@
+package actions
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/nulls"
+	"github.com/pkg/errors"
+
+	"library/metrics"
+	"library/models"
+)
+
+var (
+	// MetricsAddr is an internal address, as in 127.0.0.1:9091, the
+	// metrics are served on by themselves when METRICS_ADDR sets it;
+	// see ServeMetrics.
+	MetricsAddr = envy.Get("METRICS_ADDR", "")
+	// metricsToken has the metrics served at /metrics too, to requests
+	// bearing it, when METRICS_TOKEN sets it.
+	metricsToken = envy.Get("METRICS_TOKEN", "")
+)
+
+var (
+	httpRequests = metrics.NewCounterVec("library_http_requests_total",
+		"HTTP requests served, by method, route and status.", "method", "route", "status")
+	httpDuration = metrics.NewHistogramVec("library_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by method and route.", metrics.DefaultBuckets, "method", "route")
+	jobRuns = metrics.NewCounterVec("library_job_runs_total",
+		"Background job runs on this instance, by handler and how they ended: done, retry or failed.", "handler", "result")
+	jobDuration = metrics.NewHistogramVec("library_job_run_duration_seconds",
+		"Time taken by background job runs on this instance, by handler.", []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600}, "handler")
+)
+
+// appMetrics are the metrics the app serves.
+var appMetrics = newAppMetrics()
+
+func newAppMetrics() *metrics.Registry {
+	r := metrics.NewRegistry()
+	r.Register(httpRequests, httpDuration, jobRuns, jobDuration,
+		metrics.CollectorFunc(dbPoolMetrics),
+		metrics.CollectorFunc(libraryMetrics),
+		metrics.CollectorFunc(jobQueueMetrics),
+	)
+	return r
+}
+
+// RecordRequests counts and times the requests served, by the route
+// they matched rather than their path, so that there aren't metrics for
+// every book and customer.
+func RecordRequests(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		route, _ := c.Value("current_route").(buffalo.RouteInfo)
+		status := http.StatusOK
+		if res, ok := c.Response().(*buffalo.Response); ok && res.Status != 0 {
+			status = res.Status
+		}
+		if err != nil {
+			status = http.StatusInternalServerError
+			var herr buffalo.HTTPError
+			if errors.As(err, &herr) {
+				status = herr.Status
+			}
+		}
+		httpRequests.Inc(route.Method, route.Path, strconv.Itoa(status))
+		httpDuration.Observe(time.Since(start).Seconds(), route.Method, route.Path)
+		return err
+	}
+}
+
+// observeJob records how a background job run ended.
+func observeJob(handler, result string, took time.Duration) {
+	jobRuns.Inc(handler, result)
+	jobDuration.Observe(took.Seconds(), handler)
+}
+
+// dbPoolMetrics are the statistics of the database connection pool.
+func dbPoolMetrics() ([]metrics.Family, error) {
+	if models.DB == nil {
+		return nil, nil
+	}
+	db, ok := models.DB.Store.(interface{ Stats() sql.DBStats })
+	if !ok {
+		return nil, nil
+	}
+	s := db.Stats()
+	return []metrics.Family{
+		metrics.Gauge("library_db_max_open_connections", "The most connections to the database the pool opens.", float64(s.MaxOpenConnections)),
+		metrics.Gauge("library_db_open_connections", "Connections to the database open, in use and idle.", float64(s.OpenConnections)),
+		metrics.Gauge("library_db_in_use_connections", "Connections to the database in use.", float64(s.InUse)),
+		metrics.Gauge("library_db_idle_connections", "Connections to the database idle.", float64(s.Idle)),
+		metrics.Counter("library_db_wait_count_total", "Times a connection to the database was waited for.", float64(s.WaitCount)),
+		metrics.Counter("library_db_wait_duration_seconds_total", "Time spent waiting for connections to the database.", s.WaitDuration.Seconds()),
+		metrics.Counter("library_db_max_idle_closed_total", "Connections closed for the pool having too many idle.", float64(s.MaxIdleClosed)),
+		metrics.Counter("library_db_max_lifetime_closed_total", "Connections closed for having been open too long.", float64(s.MaxLifetimeClosed)),
+	}, nil
+}
+
+// libraryMetrics are the library's figures, as on the dashboard and
+// cached for as long.
+func libraryMetrics() ([]metrics.Family, error) {
+	m, err := dashboardMetrics.Get(models.DB, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return []metrics.Family{
+		metrics.Gauge("library_titles", "Books in the catalog.", float64(m.Titles)),
+		metrics.Gauge("library_copies", "Copies of the books in stock.", float64(m.Copies)),
+		metrics.Gauge("library_loans_active", "Copies lent and not yet returned.", float64(m.OnLoan)),
+		metrics.Gauge("library_loans_overdue", "Copies out past their due date.", float64(m.Overdue)),
+		{
+			Name: "library_holds",
+			Help: "Holds waiting for a copy, and ready for the customer to collect.",
+			Type: metrics.TypeGauge,
+			Samples: []metrics.Sample{
+				{Labels: []string{"status", models.HoldWaiting}, Value: float64(m.HoldsWaiting)},
+				{Labels: []string{"status", models.HoldReady}, Value: float64(m.HoldsReady)},
+			},
+		},
+		{
+			Name:    "library_fines_outstanding",
+			Help:    "Fines not yet paid, those books still out are running up included.",
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Labels: []string{"currency", m.FinesOutstanding.Currency}, Value: float64(m.FinesOutstanding.Cents) / 100}},
+		},
+		metrics.Gauge("library_metrics_timestamp_seconds", "When the library's figures were worked out.", float64(m.At.Unix())),
+	}, nil
+}
+
+// jobQueueMetrics count the background jobs in the jobs table, shared
+// by every instance of the app.
+func jobQueueMetrics() ([]metrics.Family, error) {
+	var counts []struct {
+		Status string `db:"status"`
+		N      int    `db:"n"`
+	}
+	if err := models.DB.RawQuery("SELECT status, COUNT(*) AS n FROM jobs GROUP BY status").All(&counts); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	byStatus := map[string]int{}
+	for _, n := range counts {
+		byStatus[n.Status] = n.N
+	}
+	jobs := metrics.Family{Name: "library_jobs", Help: "Background jobs kept, by status.", Type: metrics.TypeGauge}
+	for _, s := range models.JobStatuses {
+		jobs.Samples = append(jobs.Samples, metrics.Sample{Labels: []string{"status", s}, Value: float64(byStatus[s])})
+	}
+
+	now := time.Now()
+	oldest := struct {
+		RunAt nulls.Time `db:"run_at"`
+	}{}
+	if err := models.DB.RawQuery("SELECT MIN(run_at) AS run_at FROM jobs WHERE status = ? AND run_at <= ?", models.JobQueued, now).First(&oldest); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	waiting := 0.0
+	if oldest.RunAt.Valid {
+		waiting = now.Sub(oldest.RunAt.Time).Seconds()
+	}
+	return []metrics.Family{
+		jobs,
+		metrics.Gauge("library_jobs_waiting_seconds", "How long the job due to run longest ago has waited to be taken.", waiting),
+	}, nil
+}
+
+// Metrics serves the app's metrics, in the Prometheus text format, to
+// requests bearing METRICS_TOKEN, as in "Authorization: Bearer token".
+// Without a token they are served on MetricsAddr only.
+func Metrics(c buffalo.Context) error {
+	if metricsToken == "" {
+		return c.Error(http.StatusNotFound, fmt.Errorf("metrics are not served here"))
+	}
+	given := c.Request().Header.Get("Authorization")
+	if subtle.ConstantTimeCompare([]byte(given), []byte("Bearer "+metricsToken)) != 1 {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("the metrics token is needed"))
+	}
+	appMetrics.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// ServeMetrics serves the app's metrics at /metrics on addr, for an
+// internal port that only the monitoring can reach.
+func ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", appMetrics)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
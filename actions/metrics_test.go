@@ -0,0 +1,41 @@
+package actions
+
+import (
+	"net/http"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_Metrics() {
+	dashboardMetrics.Reset()
+	defer dashboardMetrics.Reset()
+	token := metricsToken
+	defer func() { metricsToken = token }()
+
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	as.NoError(as.DB.Create(&models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}))
+
+	metricsToken = ""
+	res := as.HTML("/metrics").Get()
+	as.Equal(http.StatusNotFound, res.Code, "the metrics are only served on the internal port without a token")
+
+	metricsToken = "secret"
+	res = as.HTML("/metrics").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+
+	as.HTML("/auth/new").Get()
+	req := as.HTML("/metrics")
+	req.Headers["Authorization"] = "Bearer secret"
+	res = req.Get()
+	as.Equal(http.StatusOK, res.Code)
+	body := res.Body.String()
+	as.Contains(body, `library_http_requests_total{method="GET",route="/auth/new/",status="200"}`)
+	as.Contains(body, `library_http_request_duration_seconds_count{method="GET",route="/auth/new/"}`)
+	as.Contains(body, "library_db_open_connections ")
+	as.Contains(body, "library_titles 1\n")
+	as.Contains(body, "library_loans_active 0\n")
+	as.Contains(body, `library_holds{status="waiting"} 0`)
+	as.Contains(body, `library_jobs{status="queued"} 0`)
+}
@@ -1,33 +1,100 @@
 package actions
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"mime"
-	"os"
-	"path/filepath"
+	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
+	"github.com/pkg/errors"
+
+	"library/models"
+	"library/upload"
 )
 
-// FileServerMiddleware serves uploaded files
-func FileServerMiddleware(c buffalo.Context) error {
-	filePath := c.Param("path")
+// ServeUploads serves the files in models.Uploads, wherever they are
+// stored, answering conditional and range requests. Files named after
+// their content never change, so browsers may cache them for good.
+// Private uploads are only served to signed-in users or at a signed
+// URL; everything else, such as book covers, is public.
+// This function is mapped to the path GET /uploads/{path}
+func ServeUploads(c buffalo.Context) error {
+	filePath := "/uploads/" + c.Param("path")
+	key, err := uploadKey(c, filePath)
+	if err != nil {
+		return err
+	}
 
-	file, err := os.Open(filepath.Join("./uploads", filePath))
+	file, info, err := models.Uploads.Open(c, filePath)
+	if errors.Is(err, upload.ErrNotExist) {
+		return c.Error(http.StatusNotFound, err)
+	}
 	if err != nil {
-		return c.Error(404, err)
+		return err
 	}
 	defer file.Close()
 
-	// Set appropriate Content-Type header based on the file extension
-	contentType := mime.TypeByExtension(filepath.Ext(filePath))
-	c.Response().Header().Set("Content-Type", contentType)
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	etag := fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size)
+	if upload.Immutable(key) {
+		etag = `"` + strings.TrimSuffix(path.Base(key), path.Ext(key)) + `"`
+	}
+	return serveUpload(c, key, file, contentType, etag, info.ModTime)
+}
+
+// uploadKey returns the storage key of the upload served at filePath,
+// or the error to respond with when there is none or the user may not
+// see it.
+func uploadKey(c buffalo.Context, filePath string) (string, error) {
+	key, ok := models.Uploads.Key(filePath)
+	// paths that had to be cleaned, such as ones with "..", are refused
+	// rather than followed
+	if !ok || models.Uploads.Path(key) != filePath || strings.HasPrefix(path.Base(key), ".") {
+		return "", c.Error(http.StatusNotFound, errors.Errorf("no upload at %s", filePath))
+	}
+	if models.Uploads.IsPrivate(filePath) && c.Session().Get("current_user_id") == nil && !models.Uploads.Verify(filePath, c.Request().URL.Query()) {
+		return "", c.Error(http.StatusForbidden, errors.New("sign in or use a signed URL to see this file"))
+	}
+	return key, nil
+}
 
-	// Serve the file as the response
-	_, err = io.Copy(c.Response(), file)
-	if err != nil {
-		return c.Error(500, err)
+// serveUpload writes the upload stored under key, or a variant of it,
+// with headers that keep it from running as a page of this site and
+// let browsers cache it as long as it can't change.
+func serveUpload(c buffalo.Context, key string, file io.Reader, contentType, etag string, modTime time.Time) error {
+	// S3 objects can't seek, which ranges need; uploads are small
+	// enough to read whole
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		content = bytes.NewReader(data)
+	}
+
+	h := c.Response().Header()
+	h.Set("Content-Type", contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; sandbox")
+	h.Set("ETag", etag)
+	switch {
+	case models.Uploads.IsPrivate(models.Uploads.Path(key)):
+		h.Set("Cache-Control", "private, no-cache")
+	case upload.Immutable(key):
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		h.Set("Cache-Control", "public, max-age=3600")
 	}
 
+	http.ServeContent(c.Response(), c.Request(), path.Base(key), modTime, content)
 	return nil
 }
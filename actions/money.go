@@ -0,0 +1,22 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/plush/v4"
+
+	"library/money"
+)
+
+// languages returns the languages the i18n middleware found the user
+// prefers, best first.
+func languages(c buffalo.Context) []string {
+	langs, _ := c.Value("languages").([]string)
+	return langs
+}
+
+// formatMoney is the template helper that writes an amount the way the
+// user's language does, as in <%= formatMoney(book.Price) %>.
+func formatMoney(m money.Money, help plush.HelperContext) string {
+	langs, _ := help.Value("languages").([]string)
+	return m.Format(langs...)
+}
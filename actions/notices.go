@@ -0,0 +1,137 @@
+package actions
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/mailer"
+	"library/models"
+	"library/sms"
+)
+
+// Customers are sent reminders before their books are due, notices
+// when they are late and word when a book they held is put aside for
+// them, by email and, when they ask for it, text message. The text of
+// each comes from the notice.<kind>.subject, .body and .sms
+// translations.
+
+// noticeLanguage is the language notices are written in, as set by
+// NOTICE_LANGUAGE. Customers don't choose one.
+var noticeLanguage = envy.Get("NOTICE_LANGUAGE", "en-US")
+
+// noticeData is what the notice translations are filled in with.
+func noticeData(n models.Notice) map[string]interface{} {
+	data := map[string]interface{}{
+		"Name":      n.Customer.Name,
+		"Title":     n.Title,
+		"BookNo":    n.BookNo,
+		"Due":       n.Due,
+		"DaysLate":  n.DaysLate,
+		"Library":   libraryName,
+		"PortalURL": strings.TrimSuffix(App().Options.Host, "/") + "/portal/",
+	}
+	if n.Kind == models.NoticeOverdue {
+		data["Fine"] = n.Fine.Format(noticeLanguage)
+	}
+	return data
+}
+
+// noticeMessage writes the email telling the customer of n.
+func noticeMessage(n models.Notice) (mailer.Message, error) {
+	data := noticeData(n)
+	subject, err := T.TranslateWithLang(noticeLanguage, "notice."+n.Kind+".subject", data)
+	if err != nil {
+		return mailer.Message{}, errors.WithStack(err)
+	}
+	text, err := T.TranslateWithLang(noticeLanguage, "notice."+n.Kind+".body", data)
+	if err != nil {
+		return mailer.Message{}, errors.WithStack(err)
+	}
+	to := &mail.Address{Name: n.Customer.Name, Address: strings.TrimSpace(n.Customer.Email)}
+	return mailer.Message{
+		From:    models.MailFrom,
+		To:      []string{to.String()},
+		Subject: subject,
+		Text:    text,
+	}, nil
+}
+
+// noticeText writes the text message telling the customer of n.
+func noticeText(n models.Notice) (sms.Message, error) {
+	text, err := T.TranslateWithLang(noticeLanguage, "notice."+n.Kind+".sms", noticeData(n))
+	if err != nil {
+		return sms.Message{}, errors.WithStack(err)
+	}
+	return sms.Message{To: n.Customer.SMSNumber, Text: strings.TrimSpace(text)}, nil
+}
+
+// sendNotice sends the customer n on the channel at now and records
+// that it was sent, or why it wasn't. A notice that fails to send isn't
+// an error; the notification returned says so.
+func sendNotice(ctx context.Context, tx *pop.Connection, n models.Notice, channel string, now time.Time) (*models.Notification, error) {
+	switch channel {
+	case models.ChannelEmail:
+		m, err := noticeMessage(n)
+		if err != nil {
+			return nil, err
+		}
+		return n.Record(tx, channel, m.To[0], m.Subject, now, models.Mailer.Send(ctx, m))
+	case models.ChannelSMS:
+		m, err := noticeText(n)
+		if err != nil {
+			return nil, err
+		}
+		return n.Record(tx, channel, m.To, m.Text, now, models.SMS.Send(ctx, m))
+	}
+	return nil, errors.Errorf("no channel %q to send notices on", channel)
+}
+
+// notify sends the customer n on every channel they want notices on,
+// and reports whether it reached them on any.
+func notify(ctx context.Context, tx *pop.Connection, n models.Notice, now time.Time) (bool, error) {
+	reached := false
+	for _, channel := range n.Customer.Channels() {
+		note, err := sendNotice(ctx, tx, n, channel, now)
+		if err != nil {
+			return reached, err
+		}
+		reached = reached || note.Status == models.NotificationSent
+	}
+	return reached, nil
+}
+
+// SendNotices sends customers what they should be told on the day of
+// now and haven't been, on each channel they want notices on. It
+// returns how many were sent and how many failed to be; those are tried
+// again the next time. Run it at least daily, as the notices:send task
+// does.
+func SendNotices(ctx context.Context, tx *pop.Connection, now time.Time) (int, int, error) {
+	sent, failed := 0, 0
+	for _, channel := range models.NoticeChannels {
+		notices, err := models.PendingNotices(tx, now, channel)
+		if err != nil {
+			return sent, failed, err
+		}
+		for _, n := range notices {
+			if err := ctx.Err(); err != nil {
+				return sent, failed, err
+			}
+			note, err := sendNotice(ctx, tx, n, channel, now)
+			if err != nil {
+				return sent, failed, err
+			}
+			if note.Status == models.NotificationSent {
+				sent++
+			} else {
+				failed++
+			}
+		}
+	}
+	return sent, failed, nil
+}
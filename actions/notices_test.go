@@ -0,0 +1,78 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+
+	"library/mailer"
+	"library/models"
+	"library/money"
+	"library/sms"
+)
+
+func (as *ActionSuite) Test_SendNotices() {
+	dir, err := os.MkdirTemp("", "mail")
+	as.NoError(err)
+	defer os.RemoveAll(dir)
+	defer func(m mailer.Sender, s sms.Sender) { models.Mailer, models.SMS = m, s }(models.Mailer, models.SMS)
+	models.Mailer = mailer.File{Dir: dir}
+	texts := &sms.Fake{}
+	models.SMS = texts
+
+	as.createPlan()
+	customer := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	verrs, err := as.DB.ValidateAndCreate(customer)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	emma := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(emma))
+	now := time.Now()
+	loan := &models.AssignBook{CustomerID: customer.ID.String(), BookID: emma.ID.String(), AssignDate: now.AddDate(0, 0, -13).Format("2006-01-02"), ReturnDate: now.AddDate(0, 0, 1).Format("2006-01-02")}
+	verrs, err = as.DB.ValidateAndCreate(loan)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+	// Bo only wants text messages
+	bo := &models.Customer{Name: "Bo", Email: "bo@example.com", Mobile: "555 123 4567", NoEmailNotices: true, SMSNotices: true}
+	verrs, err = as.DB.ValidateAndCreate(bo)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+	hold := &models.Hold{CustomerID: bo.ID.String(), BookID: emma.ID.String(), Status: models.HoldReady, ReadyUntil: nulls.NewTime(now.AddDate(0, 0, 7))}
+	as.NoError(as.DB.Create(hold))
+
+	sent, failed, err := SendNotices(context.Background(), as.DB, now)
+	as.NoError(err)
+	as.Equal(2, sent)
+	as.Equal(0, failed)
+	as.Len(texts.Sent(), 1)
+	as.Equal("+15551234567", texts.Sent()[0].To)
+	as.Contains(texts.Sent()[0].Text, "Emma is waiting for you")
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	as.NoError(err)
+	as.Len(files, 1)
+	content, err := os.ReadFile(files[0])
+	as.NoError(err)
+	as.Contains(string(content), "To: \"Ann\" <ann@example.com>")
+	as.Contains(string(content), "Emma (E-1) is due back")
+
+	// sent once only
+	sent, _, err = SendNotices(context.Background(), as.DB, now)
+	as.NoError(err)
+	as.Equal(0, sent)
+
+	// Bo texts STOP and hears no more
+	defer func(token string) { smsInboundToken = token }(smsInboundToken)
+	smsInboundToken = "gateway-secret"
+	res := as.JSON("/sms/inbound?token=%s", smsInboundToken).Post(smsReply{From: "+15551234567", Text: "STOP"})
+	as.Equal(http.StatusOK, res.Code)
+	as.NoError(as.DB.Reload(bo))
+	as.True(bo.SMSOptedOutAt.Valid)
+	res = as.JSON("/sms/inbound?token=wrong").Post(smsReply{From: "+15551234567", Text: "START"})
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
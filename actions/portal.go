@@ -0,0 +1,425 @@
+package actions
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"library/models"
+)
+
+// The patron portal lets customers sign in with their library card
+// number or email address to see their loans, fines and holds. It keeps
+// its own session key, current_customer_id, apart from the staff's
+// current_user_id, so signing in to one never opens the other.
+
+// SetCurrentPatron puts the customer signed in to the portal in the
+// context as current_patron.
+func SetCurrentPatron(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		if cid := c.Session().Get("current_customer_id"); cid != nil {
+			customer := &models.Customer{}
+			tx := c.Value("tx").(*pop.Connection)
+			if err := tx.Find(customer, cid); err != nil {
+				c.Logger().Warnf("customer attempted to access the portal with current_customer_id '%v' that is not found: %v", cid, err)
+
+				c.Session().Delete("current_customer_id")
+				c.Flash().Add("danger", T.Translate(c, "portal.signin.required"))
+				return c.Redirect(http.StatusFound, "/portal/signin")
+			}
+			c.Set("current_patron", customer)
+		}
+		return next(c)
+	}
+}
+
+// AuthorizePatron requires a customer be signed in to the portal.
+func AuthorizePatron(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		if cid := c.Session().Get("current_customer_id"); cid == nil {
+			c.Session().Set("portalRedirectURL", c.Request().URL.String())
+
+			err := c.Session().Save()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			c.Flash().Add("danger", T.Translate(c, "portal.signin.required"))
+			return c.Redirect(http.StatusFound, "/portal/signin")
+		}
+		return next(c)
+	}
+}
+
+// currentPatron returns the customer signed in to the portal.
+func currentPatron(c buffalo.Context) *models.Customer {
+	customer, _ := c.Value("current_patron").(*models.Customer)
+	return customer
+}
+
+// patronLogin is what customers sign in to the portal with.
+type patronLogin struct {
+	Login    string `form:"Login"`
+	Password string `form:"Password"`
+}
+
+// PortalNew renders the sign in page of the portal.
+// This function is mapped to the path GET /portal/signin
+func PortalNew(c buffalo.Context) error {
+	c.Set("login", patronLogin{})
+	return c.Render(http.StatusOK, r.HTML("portal/new.plush.html"))
+}
+
+// PortalCreate signs a customer in to the portal.
+// This function is mapped to the path POST /portal/signin
+func PortalCreate(c buffalo.Context) error {
+	login := patronLogin{}
+	if err := c.Bind(&login); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	customer, err := models.FindPatron(tx, login.Login, login.Password)
+	if err != nil {
+		return err
+	}
+	if customer == nil {
+		verrs := validate.NewErrors()
+		verrs.Add("login", "invalid card number, email or password")
+
+		c.Set("errors", verrs)
+		c.Set("login", patronLogin{Login: login.Login})
+
+		return c.Render(http.StatusUnauthorized, r.HTML("portal/new.plush.html"))
+	}
+
+	c.Session().Set("current_customer_id", customer.ID)
+	c.Flash().Add("success", T.Translate(c, "portal.signin.success", map[string]string{"Name": customer.Name}))
+
+	redirectURL := "/portal"
+	if redir, ok := c.Session().Get("portalRedirectURL").(string); ok && redir != "" {
+		redirectURL = redir
+		c.Session().Delete("portalRedirectURL")
+	}
+
+	return c.Redirect(http.StatusFound, redirectURL)
+}
+
+// PortalDestroy signs the customer out of the portal, leaving any staff
+// session alone.
+// This function is mapped to the path DELETE /portal/signin
+func PortalDestroy(c buffalo.Context) error {
+	c.Session().Delete("current_customer_id")
+	c.Flash().Add("success", T.Translate(c, "portal.signout.success"))
+	return c.Redirect(http.StatusFound, "/portal/signin")
+}
+
+// patronLoan is a loan as the portal shows it.
+type patronLoan struct {
+	titledLoan
+	Late int `db:"-"`
+}
+
+// patronHold is a hold as the portal shows it, with the customer's
+// place in the queue for the book.
+type patronHold struct {
+	models.Hold
+	Title  string `db:"title"`
+	BookNo string `db:"book_no"`
+	Place  int    `db:"-"`
+}
+
+// patronHolds lists the customer's holds still to be served on the day
+// of now.
+func patronHolds(tx *pop.Connection, customer models.Customer, now time.Time) ([]patronHold, error) {
+	holds := []patronHold{}
+	err := tx.RawQuery("SELECT holds.*, books.title, books.book_no FROM holds JOIN books ON books.id = holds.book_id WHERE holds.customer_id = ? AND (holds.status = ? OR (holds.status = ? AND holds.ready_until >= ?)) ORDER BY holds.created_at",
+		customer.ID, models.HoldWaiting, models.HoldReady, now.Format("2006-01-02")).All(&holds)
+	if err != nil {
+		return nil, err
+	}
+	for i, hold := range holds {
+		if hold.Status != models.HoldWaiting {
+			continue
+		}
+		book := models.Book{ID: uuid.FromStringOrNil(hold.BookID)}
+		ahead, err := book.HeldFor(tx, customer, now)
+		if err != nil {
+			return nil, err
+		}
+		holds[i].Place = ahead + 1
+	}
+	return holds, nil
+}
+
+// PortalLanding shows the signed in customer their loans, fines and
+// holds. This function is mapped to the path GET /portal
+func PortalLanding(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	customer := currentPatron(c)
+	now := time.Now()
+
+	loans := []patronLoan{}
+	if err := tx.RawQuery(titledLoans+" AND assign_books.returned_on IS NULL ORDER BY assign_books.return_date", customer.ID).All(&loans); err != nil {
+		return err
+	}
+	for i := range loans {
+		loans[i].Late = loans[i].DaysLate(now)
+	}
+	holds, err := patronHolds(tx, *customer, now)
+	if err != nil {
+		return err
+	}
+	plan, err := customer.MembershipPlan(tx)
+	if err != nil {
+		return err
+	}
+	owed, err := customer.FinesOwed(tx, now)
+	if err != nil {
+		return err
+	}
+	refusal, err := customer.BorrowingRefusal(tx, now)
+	if err != nil {
+		return err
+	}
+
+	c.Set("customer", customer)
+	c.Set("plan", plan)
+	c.Set("standing", customer.Standing(now))
+	c.Set("refusal", refusal)
+	c.Set("finesOwed", owed)
+	c.Set("loans", loans)
+	c.Set("holds", holds)
+	return c.Render(http.StatusOK, r.HTML("portal/index.plush.html"))
+}
+
+// PortalHistory lists the books the signed in customer has returned,
+// latest first. This function is mapped to the path GET /portal/history
+func PortalHistory(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	customer := currentPatron(c)
+
+	loans := []titledLoan{}
+	q := tx.RawQuery(titledLoans+" AND assign_books.returned_on IS NOT NULL ORDER BY assign_books.returned_on DESC, assign_books.created_at DESC", customer.ID).PaginateFromParams(c.Params())
+	if err := q.All(&loans); err != nil {
+		return err
+	}
+
+	c.Set("customer", customer)
+	c.Set("loans", loans)
+	c.Set("pagination", q.Paginator)
+	return c.Render(http.StatusOK, r.HTML("portal/history.plush.html"))
+}
+
+// PortalRenew renews a loan of the signed in customer.
+// This function is mapped to the path
+// POST /portal/loans/{loan_id}/renew
+func PortalRenew(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	customer := currentPatron(c)
+
+	loan := &models.AssignBook{}
+	if err := tx.Where("customer_id = ?", customer.ID).Find(loan, c.Param("loan_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	verrs, err := loan.Renew(tx, time.Now())
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+		return c.Redirect(http.StatusSeeOther, "/portal")
+	}
+
+	c.Flash().Add("success", T.Translate(c, "portal.renewed.success", map[string]string{"ReturnDate": formatDate(loan.ReturnDate)}))
+	return c.Redirect(http.StatusSeeOther, "/portal")
+}
+
+// PortalHolds lists the signed in customer's holds and finds books to
+// place new ones on by title, author or book number.
+// This function is mapped to the path GET /portal/holds
+func PortalHolds(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	customer := currentPatron(c)
+	now := time.Now()
+
+	holds, err := patronHolds(tx, *customer, now)
+	if err != nil {
+		return err
+	}
+	books := models.Books{}
+	search := c.Param("q")
+	if search != "" {
+		like := "%" + search + "%"
+		if err := tx.Where("status = 1 AND (title LIKE ? OR author LIKE ? OR book_no = ?)", like, like, search).Order("title").Limit(25).All(&books); err != nil {
+			return err
+		}
+	}
+
+	c.Set("customer", customer)
+	c.Set("holds", holds)
+	c.Set("search", search)
+	c.Set("books", books)
+	return c.Render(http.StatusOK, r.HTML("portal/holds.plush.html"))
+}
+
+// PortalHoldCreate places a hold for the signed in customer on a book
+// that is out. This function is mapped to the path POST /portal/holds
+func PortalHoldCreate(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	customer := currentPatron(c)
+
+	book := &models.Book{}
+	if err := tx.Find(book, c.Param("book_id")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Error(http.StatusNotFound, err)
+		}
+		return err
+	}
+	refusal, err := book.HoldRefusal(tx, *customer, time.Now())
+	if err != nil {
+		return err
+	}
+	if refusal != "" {
+		c.Flash().Add("danger", refusal)
+		return c.Redirect(http.StatusSeeOther, "/portal/holds")
+	}
+	verrs, err := tx.ValidateAndCreate(&models.Hold{CustomerID: customer.ID.String(), BookID: book.ID.String()})
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+		return c.Redirect(http.StatusSeeOther, "/portal/holds")
+	}
+
+	c.Flash().Add("success", T.Translate(c, "portal.hold.placed", map[string]string{"Title": book.Title}))
+	return c.Redirect(http.StatusSeeOther, "/portal/holds")
+}
+
+// PortalHoldDestroy cancels a hold of the signed in customer.
+// This function is mapped to the path DELETE /portal/holds/{hold_id}
+func PortalHoldDestroy(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	customer := currentPatron(c)
+
+	hold := &models.Hold{}
+	if err := tx.Where("customer_id = ?", customer.ID).Find(hold, c.Param("hold_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	if err := hold.Cancel(tx); err != nil {
+		return err
+	}
+
+	c.Flash().Add("success", T.Translate(c, "portal.hold.cancelled"))
+	return c.Redirect(http.StatusSeeOther, "/portal/holds")
+}
+
+// patronAccount holds the contact details, notice preferences and
+// password customers may change themselves; the rest of their record
+// is the library's.
+type patronAccount struct {
+	Email                string `form:"Email"`
+	Mobile               string `form:"Mobile"`
+	Address              string `form:"Address"`
+	EmailNotices         bool   `form:"EmailNotices"`
+	SMSNotices           bool   `form:"SMSNotices"`
+	CurrentPassword      string `form:"CurrentPassword"`
+	Password             string `form:"Password"`
+	PasswordConfirmation string `form:"PasswordConfirmation"`
+}
+
+// PortalAccount renders the form for the signed in customer's contact
+// details and how they want notices. This function is mapped to the path GET /portal/account
+func PortalAccount(c buffalo.Context) error {
+	customer := currentPatron(c)
+	c.Set("customer", customer)
+	c.Set("account", patronAccount{
+		Email:        customer.Email,
+		Mobile:       customer.Mobile,
+		Address:      customer.Address.String,
+		EmailNotices: !customer.NoEmailNotices,
+		SMSNotices:   customer.SMSNotices && !customer.SMSOptedOutAt.Valid,
+	})
+	return c.Render(http.StatusOK, r.HTML("portal/account.plush.html"))
+}
+
+// PortalAccountUpdate changes the signed in customer's contact details
+// and how they want notices, and, given their current password, their
+// password.
+// This function is mapped to the path PUT /portal/account
+func PortalAccountUpdate(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	customer := currentPatron(c)
+
+	account := patronAccount{}
+	if err := c.Bind(&account); err != nil {
+		return err
+	}
+	customer.Email = account.Email
+	customer.Mobile = account.Mobile
+	customer.Address = nulls.NewString(account.Address)
+	customer.NoEmailNotices = !account.EmailNotices
+	if !account.SMSNotices {
+		customer.SMSNotices = false
+	} else if !customer.SMSNotices || customer.SMSOptedOutAt.Valid {
+		// asking again here undoes a STOP
+		customer.OptInSMS()
+	}
+
+	verrs := validate.NewErrors()
+	if account.Password != "" {
+		if customer.CheckPassword(account.CurrentPassword) {
+			customer.Password = account.Password
+			customer.PasswordConfirmation = account.PasswordConfirmation
+		} else {
+			verrs.Add("current_password", "Current password is wrong.")
+		}
+	}
+	if !verrs.HasAny() {
+		var err error
+		if verrs, err = customer.Update(tx); err != nil {
+			return err
+		}
+	}
+
+	if verrs.HasAny() {
+		account.CurrentPassword, account.Password, account.PasswordConfirmation = "", "", ""
+		c.Set("errors", verrs)
+		c.Set("customer", customer)
+		c.Set("account", account)
+		return c.Render(http.StatusUnprocessableEntity, r.HTML("portal/account.plush.html"))
+	}
+
+	c.Flash().Add("success", T.Translate(c, "portal.account.updated"))
+	return c.Redirect(http.StatusSeeOther, "/portal/account")
+}
@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"net/http"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_Portal() {
+	as.createPlan()
+	customer := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1", Password: "correct horse", PasswordConfirmation: "correct horse"}
+	verrs, err := customer.Create(as.DB)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	book := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(book))
+
+	res := as.HTML("/portal").Get()
+	as.Equal(http.StatusFound, res.Code)
+	as.Equal("/portal/signin", res.Location())
+
+	res = as.HTML("/portal/signin").Post(map[string]string{"Login": customer.CardNumber, "Password": "wrong"})
+	as.Equal(http.StatusUnauthorized, res.Code)
+	res = as.HTML("/portal/signin").Post(map[string]string{"Login": customer.CardNumber, "Password": "correct horse"})
+	as.Equal(http.StatusFound, res.Code)
+	as.Equal(customer.ID, as.Session.Get("current_customer_id"))
+
+	// a customer is no staff user
+	res = as.HTML("/auth/customers").Get()
+	as.Equal(http.StatusFound, res.Code)
+	as.Equal("/auth/new", res.Location())
+
+	res = as.HTML("/portal").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), customer.CardNumber)
+
+	// the only copy is on the shelf
+	res = as.HTML("/portal/holds?book_id=%s", book.ID).Post(nil)
+	as.Equal(http.StatusSeeOther, res.Code)
+	count, err := as.DB.Count(&models.Hold{})
+	as.NoError(err)
+	as.Equal(0, count)
+
+	res = as.HTML("/portal/holds?q=emma").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "E-1")
+
+	res = as.HTML("/portal/account").Put(map[string]string{"Email": "ann@example.org", "Mobile": "2", "Address": "1 High St", "Password": "new password", "PasswordConfirmation": "new password", "CurrentPassword": "wrong"})
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+	res = as.HTML("/portal/account").Put(map[string]string{"Email": "ann@example.org", "Mobile": "2", "Address": "1 High St", "Status": "suspended"})
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.NoError(as.DB.Reload(customer))
+	as.Equal("ann@example.org", customer.Email)
+	as.Equal(models.CustomerActive, customer.Status)
+
+	res = as.HTML("/portal/history").Get()
+	as.Equal(http.StatusOK, res.Code)
+
+	res = as.HTML("/portal/signin").Delete()
+	as.Equal(http.StatusFound, res.Code)
+	as.Nil(as.Session.Get("current_customer_id"))
+}
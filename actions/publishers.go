@@ -0,0 +1,269 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/x/responder"
+
+	"library/models"
+)
+
+// PublishersResource is the resource for the Publisher model
+type PublishersResource struct {
+	buffalo.Resource
+}
+
+// publishersSortable maps the publishers table columns to the SQL they order
+// by.
+var publishersSortable = map[string]string{
+	"name":       "publishers.name",
+	"updated_at": "publishers.updated_at",
+}
+
+// PublishersIndex serves the publishers table.
+// This function is mapped to the path GET /auth/publishers/index
+func (v PublishersResource) PublishersIndex(c buffalo.Context) error {
+	draw := c.Param("draw")
+	start, _ := strconv.Atoi(c.Param("start"))
+	length, _ := strconv.Atoi(c.Param("length"))
+	lq := listQueryFromParams(c, publishersSortable)
+
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	q := tx.Q()
+	if lq.Search != "" {
+		q = q.Where("publishers.name LIKE ?", lq.like())
+	}
+	q = q.Order(lq.orderBy("publishers.name asc", "publishers.id")).Paginate((start/length)+1, length)
+
+	var publishers models.Publishers
+	if err := q.All(&publishers); err != nil {
+		return err
+	}
+	ids := make([]interface{}, len(publishers))
+	for i, a := range publishers {
+		ids[i] = a.ID
+	}
+	counts, err := creditedBookCounts(tx, "book_publishers", "publisher_id", ids)
+	if err != nil {
+		return err
+	}
+
+	count, err := tx.Count(&models.Publishers{})
+	if err != nil {
+		return err
+	}
+
+	data := []interface{}{}
+	for _, publisher := range publishers {
+		data = append(data, formatCreditData("publishers", publisher.ID.String(), publisher.Name, counts[publisher.ID.String()], publisher.UpdatedAt))
+	}
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"draw":            draw,
+		"recordsTotal":    count,
+		"recordsFiltered": q.Paginator.TotalEntriesSize,
+		"data":            data,
+	}))
+}
+
+// List renders the publishers table. JSON requests get the publishers whose
+// name matches the "q" param, as used by the book form.
+// This function is mapped to the path GET /auth/publishers
+func (v PublishersResource) List(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Set("PageTitle", "Publishers List")
+		return c.Render(http.StatusOK, r2.HTML("backend/publishers/index.plush.html"))
+	}).Wants("json", func(c buffalo.Context) error {
+		publishers := models.Publishers{}
+		if err := tx.Where("name LIKE ?", "%"+c.Param("q")+"%").Order("name").Limit(20).All(&publishers); err != nil {
+			return err
+		}
+		return c.Render(200, r2.JSON(publishers))
+	}).Respond(c)
+}
+
+// Show lists the books of a Publisher with their loan statistics. This
+// function is mapped to the path GET /auth/publishers/{publisher_id}
+func (v PublishersResource) Show(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	publisher := &models.Publisher{}
+	if err := tx.Find(publisher, c.Param("publisher_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	books, stats, err := models.CreditedBooks(tx, publisher)
+	if err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Set("publisher", publisher)
+		c.Set("rows", creditedBookRows(books, stats))
+		c.Set("totals", loanTotals(stats))
+		c.Set("PageTitle", "Show Publisher")
+		return c.Render(http.StatusOK, r2.HTML("backend/publishers/show.plush.html"))
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(200, r2.JSON(map[string]interface{}{
+			"publisher": publisher,
+			"books":     books,
+			"stats":     stats,
+		}))
+	}).Respond(c)
+}
+
+// New renders the form for creating a new Publisher.
+// This function is mapped to the path GET /auth/publishers/new
+func (v PublishersResource) New(c buffalo.Context) error {
+	c.Set("publisher", &models.Publisher{})
+	c.Set("PageTitle", "Create Publisher")
+	return c.Render(http.StatusOK, r2.HTML("backend/publishers/new.plush.html"))
+}
+
+// Create adds a Publisher to the DB. This function is mapped to the
+// path POST /auth/publishers
+func (v PublishersResource) Create(c buffalo.Context) error {
+	publisher := &models.Publisher{}
+	if err := c.Bind(publisher); err != nil {
+		return err
+	}
+
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	verrs, err := tx.ValidateAndCreate(publisher)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Set("errors", verrs)
+			c.Set("publisher", publisher)
+			c.Set("PageTitle", "Create Publisher")
+			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/publishers/new.plush.html"))
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "publisher.created.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/publishers/%v", publisher.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusCreated, r2.JSON(publisher))
+	}).Respond(c)
+}
+
+// Edit renders a edit form for a Publisher. This function is
+// mapped to the path GET /auth/publishers/{publisher_id}/edit
+func (v PublishersResource) Edit(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	publisher := &models.Publisher{}
+	if err := tx.Find(publisher, c.Param("publisher_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	c.Set("publisher", publisher)
+	c.Set("PageTitle", "Edit Publisher")
+	return c.Render(http.StatusOK, r2.HTML("backend/publishers/edit.plush.html"))
+}
+
+// Update renames a Publisher; the credit line of its books follows.
+// This function is mapped to the path PUT /auth/publishers/{publisher_id}
+func (v PublishersResource) Update(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	publisher := &models.Publisher{}
+	if err := tx.Find(publisher, c.Param("publisher_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+	if err := c.Bind(publisher); err != nil {
+		return err
+	}
+
+	verrs, err := tx.ValidateAndUpdate(publisher)
+	if err != nil {
+		return err
+	}
+
+	if verrs.HasAny() {
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Set("errors", verrs)
+			c.Set("publisher", publisher)
+			c.Set("PageTitle", "Edit Publisher")
+			return c.Render(http.StatusUnprocessableEntity, r2.HTML("backend/publishers/edit.plush.html"))
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusUnprocessableEntity, r2.JSON(verrs))
+		}).Respond(c)
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "publisher.updated.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/publishers/%v", publisher.ID)
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(publisher))
+	}).Respond(c)
+}
+
+// Destroy deletes a Publisher that is not credited on any book. This
+// function is mapped to the path DELETE /auth/publishers/{publisher_id}
+func (v PublishersResource) Destroy(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	publisher := &models.Publisher{}
+	if err := tx.Find(publisher, c.Param("publisher_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	counts, err := creditedBookCounts(tx, "book_publishers", "publisher_id", []interface{}{publisher.ID})
+	if err != nil {
+		return err
+	}
+	if n := counts[publisher.ID.String()]; n > 0 {
+		msg := T.Translate(c, "publisher.destroyed.in_use", map[string]interface{}{"Count": n})
+		return responder.Wants("html", func(c buffalo.Context) error {
+			c.Flash().Add("danger", msg)
+			return c.Redirect(http.StatusSeeOther, "/auth/publishers/%v", publisher.ID)
+		}).Wants("json", func(c buffalo.Context) error {
+			return c.Render(http.StatusConflict, r2.JSON(map[string]string{"error": msg}))
+		}).Respond(c)
+	}
+
+	if err := tx.Destroy(publisher); err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Flash().Add("success", T.Translate(c, "publisher.destroyed.success"))
+		return c.Redirect(http.StatusSeeOther, "/auth/publishers")
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(publisher))
+	}).Respond(c)
+}
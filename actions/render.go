@@ -1,6 +1,8 @@
 package actions
 
 import (
+	"library/models"
+	"library/money"
 	"library/public"
 	"library/templates"
 
@@ -27,6 +29,11 @@ func init() {
 			// below and import "github.com/gobuffalo/helpers/forms"
 			// forms.FormKey:     forms.Form,
 			// forms.FormForKey:  forms.FormFor,
+			"formatMoney": formatMoney,
+			"formatDate":  formatDate,
+			"imageURL":    imageURL,
+
+			"bookCatalogPath": bookCatalogPath,
 		},
 	})
 
@@ -40,6 +47,13 @@ func init() {
 		AssetsFS: public.FS(),
 
 		// Add template helpers here:
-		Helpers: render.Helpers{},
+		Helpers: render.Helpers{
+			"formatMoney": formatMoney,
+			"formatDate":  formatDate,
+			"currencies":  money.Currencies,
+			"imageURL":    imageURL,
+
+			"customerStatuses": func() []string { return models.CustomerStatuses },
+		},
 	})
 }
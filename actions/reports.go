@@ -0,0 +1,282 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+
+	"library/models"
+)
+
+// circulationReports are the reports on the reports page, in order.
+var circulationReports = []struct {
+	Name  string
+	Title string
+	About string
+}{
+	{"loans", "Loans and returns", "Books lent and returned in each day, week or month of the range."},
+	{"overdue", "Overdue loans", "Books out past their due date today, with the fines they are running up."},
+	{"top_books", "Most borrowed books", "The books lent most often in the range."},
+	{"top_categories", "Most borrowed categories", "The categories whose books were lent most often in the range."},
+	{"top_customers", "Most active customers", "The customers who borrowed most books in the range."},
+	{"loan_length", "Loan length", "How long the books returned in the range were out for."},
+}
+
+// reportTable is a report as shown on the page and exported: a table,
+// with a bar for each row drawn to scale when the report has a chart.
+type reportTable struct {
+	Name   string
+	Title  string
+	Header []string
+	Rows   [][]string
+	// Bars are the charted value of each row, as a percentage of the
+	// largest; none when the report has no chart.
+	Bars []int
+}
+
+// reportParams are the range and settings a report is run for.
+type reportParams struct {
+	From   time.Time
+	To     time.Time
+	Period string
+	Limit  int
+}
+
+// Query is the params as a query string, to link to other reports and
+// exports for the same range.
+func (p reportParams) Query() string {
+	return url.Values{
+		"from":   {p.From.Format("2006-01-02")},
+		"to":     {p.To.Format("2006-01-02")},
+		"period": {p.Period},
+		"limit":  {strconv.Itoa(p.Limit)},
+	}.Encode()
+}
+
+// reportParamsFrom reads the "from", "to", "period" and "limit" params.
+// The range is the month so far when not given. Loans are counted by
+// day over a month or so, by week over half a year and by month over
+// longer ranges, unless asked otherwise.
+func reportParamsFrom(c buffalo.Context, now time.Time) reportParams {
+	p := reportParams{
+		From:   time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()),
+		To:     time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		Period: c.Param("period"),
+		Limit:  10,
+	}
+	if t, err := time.ParseInLocation("2006-01-02", c.Param("from"), now.Location()); err == nil {
+		p.From = t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", c.Param("to"), now.Location()); err == nil {
+		p.To = t
+	}
+	if p.To.Before(p.From) {
+		p.From, p.To = p.To, p.From
+	}
+	if !included(models.ReportPeriods, p.Period) {
+		switch days := p.To.Sub(p.From).Hours() / 24; {
+		case days <= 35:
+			p.Period = models.ReportDay
+		case days <= 190:
+			p.Period = models.ReportWeek
+		default:
+			p.Period = models.ReportMonth
+		}
+	}
+	if n, err := strconv.Atoi(c.Param("limit")); err == nil && n > 0 && n <= 100 {
+		p.Limit = n
+	}
+	return p
+}
+
+// included reports whether s is one of list.
+func included(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// bars scales values to percentages of the largest.
+func bars(values []int) []int {
+	largest := 0
+	for _, v := range values {
+		if v > largest {
+			largest = v
+		}
+	}
+	scaled := make([]int, len(values))
+	if largest == 0 {
+		return scaled
+	}
+	for i, v := range values {
+		scaled[i] = v * 100 / largest
+	}
+	return scaled
+}
+
+// loansTable fills in the loans report from the counts of each period.
+func loansTable(report *reportTable, counts []models.PeriodCount) {
+	report.Header = []string{"Period", "Loans", "Returns"}
+	values := []int{}
+	for _, n := range counts {
+		report.Rows = append(report.Rows, []string{n.Label, strconv.Itoa(n.Loans), strconv.Itoa(n.Returns)})
+		values = append(values, n.Loans)
+	}
+	report.Bars = bars(values)
+}
+
+// runReport runs the report named for p on the day of now, or returns
+// nil when there is no such report.
+func runReport(tx *pop.Connection, name string, p reportParams, now time.Time, langs []string) (*reportTable, error) {
+	report := &reportTable{Name: name}
+	for _, r := range circulationReports {
+		if r.Name == name {
+			report.Title = r.Title
+		}
+	}
+	if report.Title == "" {
+		return nil, nil
+	}
+
+	ranked := func(list []models.RankedCount, err error, header ...string) (*reportTable, error) {
+		if err != nil {
+			return nil, err
+		}
+		report.Header = header
+		values := []int{}
+		for i, item := range list {
+			row := []string{strconv.Itoa(i + 1), item.Name}
+			if len(header) == 4 {
+				row = append(row, item.Code)
+			}
+			report.Rows = append(report.Rows, append(row, strconv.Itoa(item.Loans)))
+			values = append(values, item.Loans)
+		}
+		report.Bars = bars(values)
+		return report, nil
+	}
+
+	switch name {
+	case "loans":
+		counts, err := models.LoansByPeriod(tx, p.From, p.To, p.Period)
+		if err != nil {
+			return nil, err
+		}
+		loansTable(report, counts)
+	case "overdue":
+		loans, err := models.OverdueLoans(tx, now)
+		if err != nil {
+			return nil, err
+		}
+		report.Header = []string{"Book No", "Title", "Customer", "Card Number", "Lent", "Due", "Days Late", "Fine"}
+		for _, loan := range loans {
+			report.Rows = append(report.Rows, []string{loan.BookNo, loan.Title, loan.CustomerName, loan.CardNumber, formatDate(loan.AssignDate), formatDate(loan.ReturnDate), strconv.Itoa(loan.Late), loan.Fine.Format(langs...)})
+		}
+	case "top_books":
+		list, err := models.MostBorrowedBooks(tx, p.From, p.To, p.Limit)
+		return ranked(list, err, "Rank", "Title", "Book No", "Loans")
+	case "top_categories":
+		list, err := models.MostBorrowedCategories(tx, p.From, p.To, p.Limit)
+		return ranked(list, err, "Rank", "Category", "Loans")
+	case "top_customers":
+		list, err := models.MostActiveCustomers(tx, p.From, p.To, p.Limit)
+		return ranked(list, err, "Rank", "Customer", "Card Number", "Loans")
+	case "loan_length":
+		length, err := models.AverageLoanLength(tx, p.From, p.To)
+		if err != nil {
+			return nil, err
+		}
+		report.Header = []string{"Books Returned", "Average Days Out", "Longest Days Out"}
+		report.Rows = [][]string{{strconv.Itoa(length.Loans), strconv.FormatFloat(length.Average, 'f', 1, 64), strconv.Itoa(length.Longest)}}
+	}
+	return report, nil
+}
+
+// ReportsIndex lists the circulation reports, with the loans and returns
+// of the range and a summary of the rest.
+func ReportsIndex(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	now := time.Now()
+	p := reportParamsFrom(c, now)
+	counts, err := models.LoansByPeriod(tx, p.From, p.To, p.Period)
+	if err != nil {
+		return err
+	}
+	loans := &reportTable{Name: circulationReports[0].Name, Title: circulationReports[0].Title}
+	loansTable(loans, counts)
+	lent, returned := 0, 0
+	for _, n := range counts {
+		lent, returned = lent+n.Loans, returned+n.Returns
+	}
+	overdue, err := models.OverdueLoans(tx, now)
+	if err != nil {
+		return err
+	}
+	length, err := models.AverageLoanLength(tx, p.From, p.To)
+	if err != nil {
+		return err
+	}
+
+	c.Set("reports", circulationReports)
+	c.Set("report", loans)
+	c.Set("params", p)
+	c.Set("periods", models.ReportPeriods)
+	c.Set("lent", lent)
+	c.Set("returned", returned)
+	c.Set("overdue", len(overdue))
+	c.Set("length", length)
+	c.Set("averageDays", strconv.FormatFloat(length.Average, 'f', 1, 64))
+	c.Set("PageTitle", "Circulation Reports")
+	return c.Render(http.StatusOK, r2.HTML("backend/reports/index.plush.html"))
+}
+
+// ReportsShow shows a circulation report for the range asked for, or
+// exports it as CSV, XLSX or PDF when a "format" is asked for.
+func ReportsShow(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	now := time.Now()
+	p := reportParamsFrom(c, now)
+	report, err := runReport(tx, c.Param("report"), p, now, languages(c))
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("no report named %q", c.Param("report")))
+	}
+
+	if c.Param("format") != "" {
+		title := fmt.Sprintf("%s %s to %s", report.Title, p.From.Format("2006-01-02"), p.To.Format("2006-01-02"))
+		if report.Name == "overdue" {
+			title = fmt.Sprintf("%s %s", report.Title, now.Format("2006-01-02"))
+		}
+		return streamExport(c, title, report.Header, func(page int) ([][]string, error) {
+			if page > 1 {
+				return nil, nil
+			}
+			return report.Rows, nil
+		})
+	}
+
+	c.Set("reports", circulationReports)
+	c.Set("report", report)
+	c.Set("params", p)
+	c.Set("periods", models.ReportPeriods)
+	c.Set("PageTitle", report.Title)
+	return c.Render(http.StatusOK, r2.HTML("backend/reports/show.plush.html"))
+}
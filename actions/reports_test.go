@@ -0,0 +1,48 @@
+package actions
+
+import (
+	"net/http"
+	"time"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_Reports() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	as.createPlan()
+	customer := &models.Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	verrs, err := as.DB.ValidateAndCreate(customer)
+	as.NoError(err)
+	as.False(verrs.HasAny(), verrs.Error())
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	emma := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(100, "USD"), Status: 1}
+	as.NoError(as.DB.Create(emma))
+	now := time.Now()
+	loan := &models.AssignBook{CustomerID: customer.ID.String(), BookID: emma.ID.String(), AssignDate: now.AddDate(0, 0, -20).Format("2006-01-02"), ReturnDate: now.AddDate(0, 0, -6).Format("2006-01-02")}
+	as.NoError(as.DB.Create(loan))
+
+	res := as.HTML("/auth/reports").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Most borrowed books")
+	as.Contains(res.Body.String(), "<title>Library | Circulation Reports</title>")
+
+	res = as.HTML("/auth/reports/overdue").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Emma")
+	as.Contains(res.Body.String(), customer.CardNumber)
+
+	from := now.AddDate(0, 0, -30).Format("2006-01-02")
+	res = as.HTML("/auth/reports/top_books?from=%s&format=csv", from).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Header().Get("Content-Disposition"), "most-borrowed-books")
+	as.Contains(res.Body.String(), "Rank,Title,Book No,Loans")
+	as.Contains(res.Body.String(), "1,Emma,E-1,1")
+
+	res = as.HTML("/auth/reports/nonsense").Get()
+	as.Equal(http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,103 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/x/responder"
+
+	"library/models"
+)
+
+// shelfNeighbours is how many books the shelf view shows on either side
+// of the one browsed from.
+const shelfNeighbours = 10
+
+// BooksShelf shows the books shelved around a Book, as if standing in
+// front of the shelf. The "n" param changes how many books are shown on
+// either side.
+// This function is mapped to the path GET /auth/books/{book_id}/shelf
+func (v BooksResource) BooksShelf(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	book := &models.Book{}
+	if err := tx.Find(book, c.Param("book_id")); err != nil {
+		return c.Error(http.StatusNotFound, err)
+	}
+
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil || n < 1 || n > 50 {
+		n = shelfNeighbours
+	}
+	before, after, err := book.ShelfNeighbours(tx, n)
+	if err != nil {
+		return err
+	}
+
+	return responder.Wants("html", func(c buffalo.Context) error {
+		c.Set("book", book)
+		c.Set("before", before)
+		c.Set("after", after)
+		c.Set("PageTitle", "Browse the Shelf")
+		return c.Render(http.StatusOK, r2.HTML("backend/books/shelf.plush.html"))
+	}).Wants("json", func(c buffalo.Context) error {
+		return c.Render(http.StatusOK, r2.JSON(map[string]interface{}{
+			"book":   book,
+			"before": before,
+			"after":  after,
+		}))
+	}).Respond(c)
+}
+
+// BooksShelfList prints the books between two call numbers in shelf
+// order, for shelf reading and stocktaking. Without a "format" param it
+// renders the form asking for the range.
+// This function is mapped to the path GET /auth/books/shelf_list
+func (v BooksResource) BooksShelfList(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	if c.Param("format") == "" {
+		categories, err := models.CategoryTree(tx)
+		if err != nil {
+			return err
+		}
+		c.Set("categories", categories)
+		c.Set("PageTitle", "Shelf List")
+		return c.Render(http.StatusOK, r2.HTML("backend/books/shelf_list.plush.html"))
+	}
+
+	q := tx.Q()
+	if categoryID := c.Param("category_id"); categoryID != "" {
+		q = models.InCategory(q, "books.category_id", categoryID)
+	}
+	q, err := models.ShelfRange(q, c.Param("from"), c.Param("to"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	header := []string{"Call Number", "Title", "Author", "Book No", "ISBN", "Status"}
+	return streamExport(c, "Shelf List", header, func(page int) ([][]string, error) {
+		var books models.Books
+		if err := q.Paginate(page, exportBatch).All(&books); err != nil {
+			return nil, err
+		}
+		rows := make([][]string, 0, len(books))
+		for _, book := range books {
+			status := "De-Active"
+			if book.Status == 1 {
+				status = "Active"
+			}
+			rows = append(rows, []string{book.CallNumber, book.Title, book.Author, book.BookNo, book.ISBN, status})
+		}
+		return rows, nil
+	})
+}
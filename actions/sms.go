@@ -0,0 +1,60 @@
+package actions
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/models"
+)
+
+// smsInboundToken lets the SMS gateway pass on the replies customers
+// text to the library, as set by SMS_INBOUND_TOKEN. Replies aren't
+// taken without one.
+var smsInboundToken = envy.Get("SMS_INBOUND_TOKEN", "")
+
+// smsReply is a text message a customer sent the library, as the
+// gateway posts it, in JSON or as a form.
+type smsReply struct {
+	From string `json:"from" form:"from"`
+	Text string `json:"text" form:"text"`
+}
+
+// SMSInbound takes the replies customers text to the library, which the
+// gateway posts with the token as ?token= or a bearer token. Replying
+// STOP opts a customer out of text messages and START opts them back
+// in; other replies are ignored.
+// This function is mapped to the path POST /sms/inbound
+func SMSInbound(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+	if smsInboundToken == "" {
+		return c.Error(http.StatusNotFound, errors.New("SMS replies are not taken"))
+	}
+	token := c.Param("token")
+	if bearer := c.Request().Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		token = strings.TrimPrefix(bearer, "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(smsInboundToken)) != 1 {
+		return c.Error(http.StatusUnauthorized, errors.New("wrong SMS gateway token"))
+	}
+
+	reply := smsReply{}
+	if err := c.Bind(&reply); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	changed, err := models.SMSReply(tx, reply.From, reply.Text, time.Now())
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]int{"changed": len(changed)}))
+}
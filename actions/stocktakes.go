@@ -0,0 +1,313 @@
+package actions
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/models"
+	"library/money"
+)
+
+// currentUserID is the id of the user signed in, if any.
+func currentUserID(c buffalo.Context) nulls.UUID {
+	if u, ok := c.Value("current_user").(*models.User); ok {
+		return nulls.NewUUID(u.ID)
+	}
+	return nulls.UUID{}
+}
+
+// findStocktake loads the stocktake named by the stocktake_id param.
+func findStocktake(c buffalo.Context, tx *pop.Connection) (*models.Stocktake, error) {
+	stocktake := &models.Stocktake{}
+	if err := tx.Find(stocktake, c.Param("stocktake_id")); err != nil {
+		return nil, c.Error(http.StatusNotFound, err)
+	}
+	return stocktake, nil
+}
+
+// StocktakesIndex lists the stocktakes, the latest first, with a form
+// to start one at a branch.
+func StocktakesIndex(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	q := tx.PaginateFromParams(c.Params())
+	list := models.Stocktakes{}
+	if err := q.Order("created_at desc").All(&list); err != nil {
+		return err
+	}
+	branches, err := models.Branches(tx)
+	if err != nil {
+		return err
+	}
+
+	c.Set("stocktakes", list)
+	c.Set("branches", branches)
+	c.Set("pagination", q.Paginator)
+	c.Set("PageTitle", "Stocktakes")
+	return c.Render(http.StatusOK, r2.HTML("backend/stocktakes/index.plush.html"))
+}
+
+// StocktakesCreate starts a stocktake at a branch, or goes back to the
+// one already open there.
+func StocktakesCreate(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	stocktake := &models.Stocktake{
+		Branch: c.Param("Branch"),
+		UserID: currentUserID(c),
+	}
+	if notes := strings.TrimSpace(c.Param("Notes")); notes != "" {
+		stocktake.Notes = nulls.NewString(notes)
+	}
+	if err := stocktake.BeforeValidate(tx); err != nil {
+		return err
+	}
+
+	open := &models.Stocktake{}
+	err := tx.Where("branch = ? AND status = ?", stocktake.Branch, models.StocktakeOpen).First(open)
+	if err == nil {
+		c.Flash().Add("warning", T.Translate(c, "stocktake.created.open", map[string]string{"Branch": open.Branch}))
+		return c.Redirect(http.StatusSeeOther, "/auth/stocktakes/%v", open.ID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	verrs, err := tx.ValidateAndCreate(stocktake)
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+		return c.Redirect(http.StatusSeeOther, "/auth/stocktakes")
+	}
+	c.Flash().Add("success", T.Translate(c, "stocktake.created.success", map[string]string{"Branch": stocktake.Branch}))
+	return c.Redirect(http.StatusSeeOther, "/auth/stocktakes/%v", stocktake.ID)
+}
+
+// StocktakesShow is where staff scan or count the books of an open
+// stocktake, with the counts so far.
+func StocktakesShow(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	stocktake, err := findStocktake(c, tx)
+	if err != nil {
+		return err
+	}
+	counts, err := stocktake.Counts(tx)
+	if err != nil {
+		return err
+	}
+	copies := 0
+	for _, n := range counts {
+		copies += n.Counted
+	}
+	movements := models.StockMovements{}
+	if err := tx.Where("stocktake_id = ?", stocktake.ID).Order("created_at").All(&movements); err != nil {
+		return err
+	}
+
+	c.Set("stocktake", stocktake)
+	c.Set("counts", counts)
+	c.Set("copies", copies)
+	c.Set("adjustments", len(movements))
+	c.Set("PageTitle", "Stocktake at "+stocktake.Branch)
+	return c.Render(http.StatusOK, r2.HTML("backend/stocktakes/show.plush.html"))
+}
+
+// StocktakesCount records a scanned or counted book. Each scan counts
+// one copy, or "Qty" copies; with "Set" the count is replaced instead.
+func StocktakesCount(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	stocktake, err := findStocktake(c, tx)
+	if err != nil {
+		return err
+	}
+	back := fmt.Sprintf("/auth/stocktakes/%v", stocktake.ID)
+
+	bookNo := strings.TrimSpace(c.Param("BookNo"))
+	book := &models.Book{}
+	if err := tx.Where("book_no = ?", bookNo).First(book); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		c.Flash().Add("danger", T.Translate(c, "stocktake.counted.unknown", map[string]string{"BookNo": bookNo}))
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+	n := 1
+	if qty := strings.TrimSpace(c.Param("Qty")); qty != "" {
+		if n, err = strconv.Atoi(qty); err != nil {
+			c.Flash().Add("danger", T.Translate(c, "stocktake.counted.invalid", map[string]string{"Qty": qty}))
+			return c.Redirect(http.StatusSeeOther, back)
+		}
+	}
+
+	counted, verrs, err := stocktake.Record(tx, book.ID.String(), n, c.Param("Set") == "")
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+	c.Flash().Add("success", T.Translate(c, "stocktake.counted.success", map[string]interface{}{"Title": book.Title, "Counted": counted}))
+	return c.Redirect(http.StatusSeeOther, back)
+}
+
+// stocktakeVariances picks the variances the report shows: those whose
+// count differs from the stock expected, or all of them.
+func stocktakeVariances(variances []models.StockVariance, all bool) []models.StockVariance {
+	if all {
+		return variances
+	}
+	differ := []models.StockVariance{}
+	for _, v := range variances {
+		if v.Variance != 0 {
+			differ = append(differ, v)
+		}
+	}
+	return differ
+}
+
+// varianceValues adds up what the copies found over those expected and
+// those missing are worth, in each currency.
+func varianceValues(variances []models.StockVariance) (map[string]money.Money, map[string]money.Money, error) {
+	over, short := map[string]money.Money{}, map[string]money.Money{}
+	for _, v := range variances {
+		if v.Variance == 0 {
+			continue
+		}
+		totals := over
+		if v.Variance < 0 {
+			totals = short
+		}
+		total, ok := totals[v.Currency]
+		if !ok {
+			total = money.New(0, v.Currency)
+		}
+		var err error
+		if totals[v.Currency], err = total.Add(v.Value); err != nil {
+			return nil, nil, err
+		}
+	}
+	return over, short, nil
+}
+
+// StocktakesVariance compares the counts to the stock expected, with a
+// form to close the stocktake and adjust the stock of the books chosen,
+// or exports the report as CSV, XLSX or PDF when a "format" is asked
+// for. Only the books whose count differs are listed unless "all" is
+// given.
+func StocktakesVariance(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	stocktake, err := findStocktake(c, tx)
+	if err != nil {
+		return err
+	}
+	variances, err := stocktake.Variances(tx)
+	if err != nil {
+		return err
+	}
+	all := c.Param("all") != ""
+	shown := stocktakeVariances(variances, all)
+
+	if c.Param("format") != "" {
+		title := fmt.Sprintf("Stocktake %s %s", stocktake.Branch, stocktake.CreatedAt.Format("2006-01-02"))
+		header := []string{"Book No", "Title", "Expected", "On Loan", "Counted", "Variance", "Value"}
+		return streamExport(c, title, header, func(page int) ([][]string, error) {
+			if page > 1 {
+				return nil, nil
+			}
+			rows := [][]string{}
+			for _, v := range shown {
+				rows = append(rows, []string{v.BookNo, v.Title, strconv.Itoa(v.Expected), strconv.Itoa(v.OnLoan), strconv.Itoa(v.Counted), strconv.Itoa(v.Variance), v.Value.Decimal() + " " + v.Currency})
+			}
+			return rows, nil
+		})
+	}
+
+	over, short, err := varianceValues(variances)
+	if err != nil {
+		return err
+	}
+	found, missing := 0, 0
+	for _, v := range variances {
+		if v.Variance > 0 {
+			found += v.Variance
+		} else {
+			missing -= v.Variance
+		}
+	}
+
+	c.Set("stocktake", stocktake)
+	c.Set("variances", shown)
+	c.Set("all", all)
+	c.Set("books", len(variances))
+	c.Set("found", found)
+	c.Set("missing", missing)
+	c.Set("over", over)
+	c.Set("short", short)
+	c.Set("PageTitle", "Stocktake Variance at "+stocktake.Branch)
+	return c.Render(http.StatusOK, r2.HTML("backend/stocktakes/variance.plush.html"))
+}
+
+// StocktakesClose ends a stocktake. The stock of the books ticked in
+// "adjust" is set to what was counted, each change recorded as a stock
+// movement.
+func StocktakesClose(c buffalo.Context) error {
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return fmt.Errorf("no transaction found")
+	}
+
+	stocktake, err := findStocktake(c, tx)
+	if err != nil {
+		return err
+	}
+	if err := c.Request().ParseForm(); err != nil {
+		return err
+	}
+	if notes := strings.TrimSpace(c.Param("Notes")); notes != "" {
+		stocktake.Notes = nulls.NewString(notes)
+		if err := tx.UpdateColumns(stocktake, "notes"); err != nil {
+			return err
+		}
+	}
+
+	adjusted, verrs, err := stocktake.Close(tx, currentUserID(c), c.Request().Form["adjust"], time.Now())
+	if err != nil {
+		return err
+	}
+	if verrs.HasAny() {
+		c.Flash().Add("danger", verrs.Error())
+		return c.Redirect(http.StatusSeeOther, "/auth/stocktakes/%v", stocktake.ID)
+	}
+	c.Flash().Add("success", T.Translate(c, "stocktake.closed.success", map[string]interface{}{"Branch": stocktake.Branch, "Adjusted": adjusted}))
+	return c.Redirect(http.StatusSeeOther, "/auth/stocktakes/%v", stocktake.ID)
+}
@@ -0,0 +1,74 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"library/models"
+	"library/money"
+)
+
+func (as *ActionSuite) Test_Stocktakes() {
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+
+	category := &models.Category{CategoryName: "Fiction", Status: 1}
+	as.NoError(as.DB.Create(category))
+	emma := &models.Book{CategoryID: category.ID.String(), Title: "Emma", BookNo: "E-1", Author: "Jane Austen", Price: money.New(500, "USD"), Status: 1}
+	as.NoError(as.DB.Create(emma))
+	as.NoError(as.DB.Create(&models.Inventory{BookID: emma.ID.String(), Branch: models.DefaultBranch, Qty: 3}))
+
+	res := as.HTML("/auth/inventories/valuation").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Fiction")
+	res = as.HTML("/auth/inventories/valuation?format=csv").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Fiction,Main,USD,1,3,15.00")
+
+	res = as.HTML("/auth/stocktakes").Post(map[string]string{"Branch": ""})
+	as.Equal(http.StatusSeeOther, res.Code)
+	stocktake := &models.Stocktake{}
+	as.NoError(as.DB.First(stocktake))
+	as.Equal(models.DefaultBranch, stocktake.Branch)
+	as.Equal(fmt.Sprintf("/auth/stocktakes/%s", stocktake.ID), res.Location())
+
+	res = as.HTML("/auth/stocktakes").Post(map[string]string{"Branch": models.DefaultBranch})
+	as.Equal(fmt.Sprintf("/auth/stocktakes/%s", stocktake.ID), res.Location(), "one stocktake is open at a branch at a time")
+
+	path := fmt.Sprintf("/auth/stocktakes/%s", stocktake.ID)
+	as.HTML(path + "/counts").Post(map[string]string{"BookNo": "E-1"})
+	as.HTML(path + "/counts").Post(map[string]string{"BookNo": " E-1 "})
+	as.HTML(path + "/counts").Post(map[string]string{"BookNo": "X-404"})
+
+	res = as.HTML(path).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "1 books counted, 2 copies")
+
+	res = as.HTML(path + "/variance").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "Emma")
+	res = as.HTML(path + "/variance?format=csv").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "E-1,Emma,3,0,2,-1,-5.00 USD")
+
+	res = as.HTML(path + "/close").Post(url.Values{"adjust": {emma.ID.String()}, "Notes": {"Shelf 3"}})
+	as.Equal(http.StatusSeeOther, res.Code)
+	as.NoError(as.DB.Reload(stocktake))
+	as.Equal(models.StocktakeClosed, stocktake.Status)
+	as.True(stocktake.Adjusted)
+	as.Equal("Shelf 3", stocktake.Notes.String)
+
+	copies, err := emma.Copies(as.DB)
+	as.NoError(err)
+	as.Equal(2, copies)
+	movement := &models.StockMovement{}
+	as.NoError(as.DB.Where("book_id = ?", emma.ID).First(movement))
+	as.Equal(-1, movement.Quantity)
+	as.Equal(u.ID, movement.UserID.UUID)
+
+	res = as.HTML("/auth/stocktakes").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), "stock adjusted")
+}
@@ -0,0 +1,61 @@
+package actions
+
+import (
+	"github.com/gobuffalo/buffalo"
+
+	"library/models"
+)
+
+// uploadChanges are the uploads a request added and the ones they
+// replaced, kept until its transaction is over.
+type uploadChanges struct {
+	added, replaced []string
+}
+
+// CleanUploads removes the files a request replaced once its
+// transaction has committed, and the files it added when it was rolled
+// back. Files another record still points to are kept, since equal
+// uploads share a file. It has to wrap popmw.Transaction so it runs
+// after the commit.
+func CleanUploads(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		changes := &uploadChanges{}
+		c.Set("uploadChanges", changes)
+
+		err := next(c)
+
+		stale := changes.replaced
+		if res, ok := c.Response().(*buffalo.Response); err != nil || (ok && res.Status >= 400) {
+			stale = changes.added
+		}
+		for _, path := range stale {
+			inUse, ierr := models.UploadInUse(models.DB, path)
+			if ierr != nil {
+				c.Logger().Errorf("checking upload %s: %v", path, ierr)
+				continue
+			}
+			if inUse {
+				continue
+			}
+			if rerr := models.RemoveUpload(c, path); rerr != nil {
+				c.Logger().Errorf("removing upload %s: %v", path, rerr)
+			}
+		}
+		return err
+	}
+}
+
+// trackUpload tells CleanUploads a record's upload changed from old to
+// path, so whichever one ends up unused is removed.
+func trackUpload(c buffalo.Context, old, path string) {
+	changes, ok := c.Value("uploadChanges").(*uploadChanges)
+	if !ok || old == path {
+		return
+	}
+	if path != "" {
+		changes.added = append(changes.added, path)
+	}
+	if old != "" {
+		changes.replaced = append(changes.replaced, old)
+	}
+}
@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"time"
+
+	"library/models"
+	"library/upload"
+)
+
+// useTempUploads points models.Uploads and models.ImageCache at
+// temporary directories for the rest of the test.
+func (as *ActionSuite) useTempUploads() {
+	saved := models.Uploads
+	models.Uploads.Storage = upload.Local{Dir: as.T().TempDir()}
+	models.Uploads.Secret = []byte("test secret")
+	savedCache := models.ImageCache
+	models.ImageCache = upload.Local{Dir: as.T().TempDir()}
+	as.T().Cleanup(func() { models.Uploads, models.ImageCache = saved, savedCache })
+}
+
+func (as *ActionSuite) saveTestImage(folder string) *upload.File {
+	var b bytes.Buffer
+	as.NoError(png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 100, 100))))
+	file, err := models.Uploads.Save(as.DB.Context(), folder, &b, models.CoverUpload)
+	as.NoError(err)
+	return file
+}
+
+func (as *ActionSuite) Test_ServeUploads() {
+	as.useTempUploads()
+	file := as.saveTestImage("books")
+
+	res := as.HTML(file.Path).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("image/png", res.Header().Get("Content-Type"))
+	as.Equal("public, max-age=31536000, immutable", res.Header().Get("Cache-Control"))
+	etag := res.Header().Get("ETag")
+	as.NotEmpty(etag)
+
+	req := as.HTML(file.Path)
+	req.Headers["If-None-Match"] = etag
+	as.Equal(http.StatusNotModified, req.Get().Code)
+
+	req = as.HTML(file.Path)
+	req.Headers["Range"] = "bytes=0-3"
+	res = req.Get()
+	as.Equal(http.StatusPartialContent, res.Code)
+	as.Equal("\x89PNG", res.Body.String())
+
+	as.Equal(http.StatusNotFound, as.HTML("/uploads/books/missing.png").Get().Code)
+	as.NotEqual(http.StatusOK, as.HTML("/uploads/books/../../config/buffalo-app.toml").Get().Code)
+}
+
+func (as *ActionSuite) Test_ServeUploads_Private() {
+	as.useTempUploads()
+	file := as.saveTestImage("profiles")
+
+	as.Equal(http.StatusForbidden, as.HTML(file.Path).Get().Code)
+
+	signed, err := models.Uploads.SignedURL(file.Path, time.Minute)
+	as.NoError(err)
+	res := as.HTML(signed).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("private, no-cache", res.Header().Get("Cache-Control"))
+
+	u, err := as.createUser()
+	as.NoError(err)
+	as.Session.Set("current_user_id", u.ID)
+	as.Equal(http.StatusOK, as.HTML(file.Path).Get().Code)
+}
+
+func (as *ActionSuite) Test_ServeImage() {
+	as.useTempUploads()
+	file := as.saveTestImage("books")
+
+	res := as.HTML(imageURL(file.Path, 32, 0)).Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("image/jpeg", res.Header().Get("Content-Type"))
+	config, _, err := image.DecodeConfig(res.Body)
+	as.NoError(err)
+	as.Equal(32, config.Width)
+
+	keys, err := models.ImageCache.List(as.DB.Context(), "")
+	as.NoError(err)
+	as.Len(keys, 1)
+
+	res = as.HTML(imageURL(file.Path, 32, 0) + "&format=webp").Get()
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("image/webp", res.Header().Get("Content-Type"))
+	as.True(bytes.HasPrefix(res.Body.Bytes(), []byte("RIFF")))
+
+	as.Equal(http.StatusBadRequest, as.HTML("/images"+file.Path[len("/uploads"):]+"?w=5").Get().Code)
+	as.Equal(http.StatusNotFound, as.HTML("/images/books/missing.png?w=80").Get().Code)
+}
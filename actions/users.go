@@ -91,9 +91,11 @@ func UserUpdate(c buffalo.Context) error {
 	if err := tx.Find(user, c.Param("ID")); err != nil {
 		return c.Error(http.StatusNotFound, err)
 	}
+	oldProfile := user.ProfilePath
 
 	// Bind User to the html form elements
 	verrs, err := user.Update(tx)
+	trackUpload(c, oldProfile, user.ProfilePath)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -0,0 +1,108 @@
+// Package barcode encodes text as Code 128 barcodes, the symbology
+// library scanners read from cards and book labels.
+package barcode
+
+import (
+	"github.com/pkg/errors"
+)
+
+// patterns are the bar and space widths of each Code 128 symbol, in
+// modules, starting with a bar. 106 is the stop symbol, which has a
+// final two module bar.
+var patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	codeC  = 99
+	codeB  = 100
+	startB = 104
+	startC = 105
+	stop   = 106
+)
+
+// QuietZone is the number of blank modules a scanner needs on either
+// side of a barcode.
+const QuietZone = 10
+
+// ErrUnencodable is returned for empty text and text with characters
+// outside printable ASCII.
+var ErrUnencodable = errors.New("barcodes hold printable ASCII text")
+
+// Code128 encodes s and returns its modules, true for a bar, without
+// the quiet zones. Runs of four or more digits use code set C, which
+// packs two digits into a symbol; everything else uses code set B.
+func Code128(s string) ([]bool, error) {
+	if s == "" {
+		return nil, ErrUnencodable
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '~' {
+			return nil, ErrUnencodable
+		}
+	}
+
+	var values []int
+	set := 0
+	for i := 0; i < len(s); {
+		// a run of digits goes in set C when that is shorter; an odd
+		// run puts its first digit in set B
+		if digits := digitRun(s[i:]); digits%2 == 0 && (digits >= 4 || digits == len(s)) {
+			if set == 0 {
+				values = append(values, startC)
+			} else if set != startC {
+				values = append(values, codeC)
+			}
+			set = startC
+			for end := i + digits; i < end; i += 2 {
+				values = append(values, int(s[i]-'0')*10+int(s[i+1]-'0'))
+			}
+			continue
+		}
+		if set == 0 {
+			values = append(values, startB)
+		} else if set != startB {
+			values = append(values, codeB)
+		}
+		set = startB
+		values = append(values, int(s[i]-' '))
+		i++
+	}
+
+	check := values[0]
+	for i, v := range values[1:] {
+		check += (i + 1) * v
+	}
+	values = append(values, check%103, stop)
+
+	var modules []bool
+	for _, v := range values {
+		bar := true
+		for _, w := range patterns[v] {
+			for n := rune(0); n < w-'0'; n++ {
+				modules = append(modules, bar)
+			}
+			bar = !bar
+		}
+	}
+	return modules, nil
+}
+
+// digitRun counts the digits s starts with.
+func digitRun(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package barcode
+
+import (
+	"strings"
+	"testing"
+)
+
+// decode reads modules back into text, checking the start, check and
+// stop symbols.
+func decode(t *testing.T, modules []bool) string {
+	t.Helper()
+	widths := map[string]int{}
+	for v, p := range patterns {
+		widths[p] = v
+	}
+	var runs []byte
+	for i := 0; i < len(modules); {
+		run := 1
+		for i+run < len(modules) && modules[i+run] == modules[i] {
+			run++
+		}
+		runs = append(runs, byte('0'+run))
+		i += run
+	}
+	var values []int
+	for len(runs) >= 6 {
+		n := 6
+		if string(runs[:6]) == patterns[stop][:6] {
+			n = 7
+		}
+		v, ok := widths[string(runs[:n])]
+		if !ok {
+			t.Fatalf("no symbol %q", runs[:n])
+		}
+		values = append(values, v)
+		runs = runs[n:]
+	}
+
+	n := len(values)
+	if values[n-1] != stop {
+		t.Fatalf("no stop symbol")
+	}
+	check := values[0]
+	for i, v := range values[1 : n-2] {
+		check += (i + 1) * v
+	}
+	if check%103 != values[n-2] {
+		t.Errorf("check symbol %d, want %d", values[n-2], check%103)
+	}
+
+	var s strings.Builder
+	set := values[0]
+	for _, v := range values[1 : n-2] {
+		switch {
+		case v == codeB && set == startC:
+			set = startB
+		case v == codeC && set == startB:
+			set = startC
+		case set == startC:
+			s.WriteString(string([]byte{byte('0' + v/10), byte('0' + v%10)}))
+		default:
+			s.WriteByte(byte(v + ' '))
+		}
+	}
+	return s.String()
+}
+
+func Test_patterns(t *testing.T) {
+	seen := map[string]bool{}
+	for v, p := range patterns {
+		if seen[p] {
+			t.Errorf("pattern %d is repeated", v)
+		}
+		seen[p] = true
+		sum, bars := 0, 0
+		for i, w := range p {
+			sum += int(w - '0')
+			if i%2 == 0 {
+				bars += int(w - '0')
+			}
+		}
+		if want := 11 + 2*(len(p)-6); sum != want || bars%2 != 0 {
+			t.Errorf("pattern %d is %q", v, p)
+		}
+	}
+}
+
+func Test_Code128(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		symbols int
+	}{
+		{"20001234567897", 7 + 3},
+		{"BK-0042", 3 + 1 + 2 + 3},
+		{"B12345", 2 + 1 + 2 + 3},
+		{"12", 1 + 3},
+		{"Qty 1", 5 + 3},
+	} {
+		modules, err := Code128(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(modules) != 11*tc.symbols+2 {
+			t.Errorf("%s: %d modules, want %d", tc.in, len(modules), 11*tc.symbols+2)
+		}
+		if got := decode(t, modules); got != tc.in {
+			t.Errorf("decoded %q, want %q", got, tc.in)
+		}
+	}
+
+	for _, s := range []string{"", "tab\t", "café"} {
+		if _, err := Code128(s); err != ErrUnencodable {
+			t.Errorf("%q: got %v", s, err)
+		}
+	}
+}
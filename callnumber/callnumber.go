@@ -0,0 +1,183 @@
+// Package callnumber parses Dewey Decimal and Library of Congress call
+// numbers, writes them out in a consistent form and builds sort keys
+// that put books in shelf order when compared as plain strings.
+package callnumber
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Scheme is a classification scheme.
+type Scheme string
+
+const (
+	Dewey Scheme = "dewey"
+	LC    Scheme = "lc"
+)
+
+// CallNumber is a parsed call number such as "REF 823.914 ROW 2001" or
+// "QA76.73.G63 D66 2016".
+type CallNumber struct {
+	Scheme Scheme
+	// Prefix is a collection marker in front of a Dewey number, such as
+	// "REF" or "J".
+	Prefix string
+	// Class is "823.914" for Dewey and "QA76.73" for LC.
+	Class string
+	// Cutters are the book numbers, dates, volumes and copies that
+	// follow the class, without LC's leading period.
+	Cutters []string
+}
+
+var (
+	deweyRe  = regexp.MustCompile(`^(?:([A-Z]{1,4})\s+)?(\d{3})(?:\.(\d+))?(?:\s+(.*))?$`)
+	lcRe     = regexp.MustCompile(`^([A-Z]{1,3})\s*(\d{1,4})(?:\.(\d+))?(.*)$`)
+	cutterRe = regexp.MustCompile(`^[A-Z]\d+[A-Z]*$`)
+	chunkRe  = regexp.MustCompile(`[A-Z]\d+`)
+	// a period starts a cutter when a letter follows it
+	cutterStartRe = regexp.MustCompile(`\.([A-Z])`)
+	volumeRe      = regexp.MustCompile(`^(V|VOL|C|COP|PT|NO|BK)\.?(\d+)$`)
+	digitsRe      = regexp.MustCompile(`^\d+$`)
+)
+
+// ErrInvalid is returned for call numbers that are neither Dewey nor LC.
+var ErrInvalid = errors.New("not a Dewey Decimal or Library of Congress call number")
+
+// Parse reads a Dewey or LC call number. Case, spacing, Dewey
+// segmentation marks ("823/.914") and LC's optional space after the
+// class letters don't matter. Dewey classes have three digits, which
+// is what tells "J 823" (Dewey) and "QA 76" (LC) apart.
+func Parse(s string) (CallNumber, error) {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), " "))
+	if s == "" {
+		return CallNumber{}, ErrInvalid
+	}
+
+	if m := deweyRe.FindStringSubmatch(strings.NewReplacer("/", "", "'", "").Replace(s)); m != nil {
+		cn := CallNumber{Scheme: Dewey, Prefix: m[1], Class: m[2]}
+		if fraction := strings.TrimRight(m[3], "0"); fraction != "" {
+			cn.Class += "." + fraction
+		}
+		cn.Cutters = strings.Fields(m[4])
+		return cn, nil
+	}
+
+	if m := lcRe.FindStringSubmatch(s); m != nil {
+		cn := CallNumber{Scheme: LC, Class: m[1] + m[2]}
+		if fraction := strings.TrimRight(m[3], "0"); fraction != "" {
+			cn.Class += "." + fraction
+		}
+		cutters, err := lcCutters(m[4])
+		if err != nil {
+			return CallNumber{}, err
+		}
+		cn.Cutters = cutters
+		return cn, nil
+	}
+
+	return CallNumber{}, ErrInvalid
+}
+
+// lcCutters splits what follows an LC class, such as ".G63D66 2016 V.2"
+// or " .G63 D66 2016 V.2", into "G63", "D66", "2016", "V.2".
+func lcCutters(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '.' && (s[0] < 'A' || s[0] > 'Z') && !digitsRe.MatchString(strings.Fields(s)[0]) {
+		return nil, ErrInvalid
+	}
+	var cutters []string
+	for _, token := range strings.Fields(cutterStartRe.ReplaceAllString(s, " .$1")) {
+		token = strings.TrimPrefix(token, ".")
+		if token == "" {
+			continue
+		}
+		// ".G63D66" holds two cutters
+		if chunks := chunkRe.FindAllString(token, -1); len(chunks) > 1 && strings.Join(chunks, "") == token {
+			cutters = append(cutters, chunks...)
+			continue
+		}
+		cutters = append(cutters, token)
+	}
+	return cutters, nil
+}
+
+// String writes the call number the way it is printed on spine labels:
+// "REF 823.914 ROW 2001" or "QA76.73.G63 D66 2016".
+func (c CallNumber) String() string {
+	parts := []string{}
+	if c.Prefix != "" {
+		parts = append(parts, c.Prefix)
+	}
+	class := c.Class
+	cutters := c.Cutters
+	if c.Scheme == LC && len(cutters) > 0 && cutterRe.MatchString(cutters[0]) {
+		class += "." + cutters[0]
+		cutters = cutters[1:]
+	}
+	parts = append(parts, class)
+	parts = append(parts, cutters...)
+	return strings.Join(parts, " ")
+}
+
+// SpineLines breaks the call number into the lines of a spine label:
+// "REF", "823.914", "ROW", "2001" or "QA", "76.73", ".G63", "D66",
+// "2016".
+func (c CallNumber) SpineLines() []string {
+	lines := []string{}
+	if c.Prefix != "" {
+		lines = append(lines, c.Prefix)
+	}
+	cutters := c.Cutters
+	switch c.Scheme {
+	case LC:
+		letters := strings.TrimRight(c.Class, "0123456789.")
+		lines = append(lines, letters, c.Class[len(letters):])
+		if len(cutters) > 0 && cutterRe.MatchString(cutters[0]) {
+			lines = append(lines, "."+cutters[0])
+			cutters = cutters[1:]
+		}
+	default:
+		lines = append(lines, c.Class)
+	}
+	return append(lines, cutters...)
+}
+
+// SortKey returns a string that sorts in shelf order. Dewey numbers
+// come before LC ones, unprefixed Dewey numbers before prefixed
+// collections, class numbers compare as decimals and volume and copy
+// numbers as integers.
+func (c CallNumber) SortKey() string {
+	parts := []string{}
+	switch c.Scheme {
+	case Dewey:
+		parts = append(parts, "D", c.Prefix, c.Class)
+	case LC:
+		letters := strings.TrimRight(c.Class, "0123456789.")
+		number := strings.SplitN(c.Class[len(letters):], ".", 2)
+		class := padLeft(number[0], 4)
+		if len(number) == 2 {
+			class += "." + number[1]
+		}
+		parts = append(parts, "L", letters+strings.Repeat(" ", 3-len(letters))+class)
+	}
+	for _, cutter := range c.Cutters {
+		if m := volumeRe.FindStringSubmatch(cutter); m != nil {
+			cutter = m[1] + padLeft(m[2], 4)
+		}
+		parts = append(parts, cutter)
+	}
+	return strings.Join(parts, " ")
+}
+
+func padLeft(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return strings.Repeat("0", n-len(s)) + s
+}
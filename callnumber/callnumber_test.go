@@ -0,0 +1,90 @@
+package callnumber
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		in, out string
+		scheme  Scheme
+	}{
+		{"823.914 ROW", "823.914 ROW", Dewey},
+		{" 823/.914  row 2001 ", "823.914 ROW 2001", Dewey},
+		{"ref 030.0 enc v.2", "REF 030 ENC V.2", Dewey},
+		{"j 599.75 r884h", "J 599.75 R884H", Dewey},
+		{"QA76.73.G63 D66 2016", "QA76.73.G63 D66 2016", LC},
+		{"qa 76.73 .g63d66 2016", "QA76.73.G63 D66 2016", LC},
+		{"PR6068.O93 H37 1997 v.2", "PR6068.O93 H37 1997 V.2", LC},
+	}
+	for _, tt := range tests {
+		cn, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if cn.String() != tt.out || cn.Scheme != tt.scheme {
+			t.Errorf("Parse(%q) = %q (%s), want %q (%s)", tt.in, cn.String(), cn.Scheme, tt.out, tt.scheme)
+		}
+	}
+
+	for _, in := range []string{"", "FIC ROW", "82.3", "12345"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
+
+func Test_SortKey(t *testing.T) {
+	// in shelf order
+	shelf := []string{
+		"005.133 PYT",
+		"005.2 ALP",
+		"599.75 R884",
+		"599.75 R89",
+		"823 AUS",
+		"823.8 DIC",
+		"823.914 ROW 2001 V.2",
+		"823.914 ROW 2001 V.10",
+		"J 599.75 ZOO",
+		"REF 030 ENC",
+		"Q335 .R86 2010",
+		"QA9 .B3",
+		"QA76 .K5",
+		"QA76.73.G63 D66 2016",
+		"QA76.9.D3 C67",
+		"QA764 .A1",
+	}
+	keys := make([]string, len(shelf))
+	for i, s := range shelf {
+		cn, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", s, err)
+		}
+		keys[i] = cn.SortKey()
+	}
+	if !sort.StringsAreSorted(keys) {
+		sorted := append([]string{}, keys...)
+		sort.Strings(sorted)
+		t.Errorf("keys out of shelf order:\n got %q\nwant %q", sorted, keys)
+	}
+}
+
+func Test_SpineLines(t *testing.T) {
+	tests := map[string]string{
+		"REF 823.914 ROW 2001": "REF|823.914|ROW|2001",
+		"QA76.73.G63 D66 2016": "QA|76.73|.G63|D66|2016",
+		"Q335 2010":            "Q|335|2010",
+	}
+	for in, want := range tests {
+		cn, err := Parse(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(cn.SpineLines(), "|"); got != want {
+			t.Errorf("SpineLines(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -14,6 +14,11 @@ import (
 // application that is. :)
 func main() {
 	app := actions.App()
+	if actions.MetricsAddr != "" {
+		go func() {
+			log.Fatal(actions.ServeMetrics(actions.MetricsAddr))
+		}()
+	}
 	if err := app.Serve(); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,22 @@
+package grifts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/grift/grift"
+
+	"library/actions"
+	"library/models"
+)
+
+var _ = grift.Namespace("notices", func() {
+
+	grift.Desc("send", "Emails customers due-date reminders, overdue notices and ready holds they haven't been sent; run it daily or more often")
+	grift.Add("send", func(c *grift.Context) error {
+		sent, failed, err := actions.SendNotices(c, models.DB, time.Now())
+		fmt.Printf("%d notices sent, %d failed\n", sent, failed)
+		return err
+	})
+
+})
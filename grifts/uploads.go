@@ -0,0 +1,36 @@
+package grifts
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/grift/grift"
+
+	"library/models"
+	"library/upload"
+)
+
+var _ = grift.Namespace("uploads", func() {
+
+	grift.Desc("migrate", "Copies uploads from one storage to another: uploads:migrate [from] [to], local to the configured UPLOADS_STORAGE by default")
+	grift.Add("migrate", func(c *grift.Context) error {
+		from, to := "local", models.UploadsStorageKind()
+		if len(c.Args) > 0 {
+			from = c.Args[0]
+		}
+		if len(c.Args) > 1 {
+			to = c.Args[1]
+		}
+		if from == to {
+			return fmt.Errorf("nothing to do: uploads already are in %s storage", to)
+		}
+
+		n := 0
+		err := upload.Copy(c, models.UploadStorage(from), models.UploadStorage(to), func(key string) {
+			n++
+			fmt.Println("copied", key)
+		})
+		fmt.Printf("%d uploads copied from %s to %s storage\n", n, from, to)
+		return err
+	})
+
+})
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseSchedule(t *testing.T) {
+	for _, spec := range []string{"* * * * *", "30 2 * * *", "*/15 8-18 * * 1-5", "0 0 1,15 * *", "5 4 * * 7", "@daily", "@HOURLY", "0 8-18/2 * * *"} {
+		if _, err := ParseSchedule(spec); err != nil {
+			t.Errorf("ParseSchedule(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@sometimes"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) gave no error", spec)
+		}
+	}
+}
+
+func Test_Schedule_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		spec, from, next string
+	}{
+		{"* * * * *", "2026-10-19 10:00", "2026-10-19 10:01"},
+		{"30 2 * * *", "2026-10-19 10:00", "2026-10-20 02:30"},
+		{"30 2 * * *", "2026-10-19 02:29", "2026-10-19 02:30"},
+		{"5 * * * *", "2026-10-19 10:05", "2026-10-19 11:05"},
+		{"*/15 8-18 * * 1-5", "2026-10-23 18:50", "2026-10-26 08:00"},
+		{"0 0 1,15 * *", "2026-10-19 10:00", "2026-11-01 00:00"},
+		{"@monthly", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"0 0 * * 0", "2026-10-19 10:00", "2026-10-25 00:00"},
+		{"0 0 * * 7", "2026-10-19 10:00", "2026-10-25 00:00"},
+		{"0 0 29 2 *", "2026-10-19 10:00", "2028-02-29 00:00"},
+		// either day will do when both are given
+		{"0 0 13 * 5", "2026-10-19 10:00", "2026-10-23 00:00"},
+		{"0 8-18/2 * * *", "2026-10-19 09:00", "2026-10-19 10:00"},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.spec, err)
+		}
+		if next := s.Next(at(tt.from)); !next.Equal(at(tt.next)) {
+			t.Errorf("%q after %s = %s, want %s", tt.spec, tt.from, next.Format("2006-01-02 15:04"), tt.next)
+		}
+	}
+
+	never, _ := ParseSchedule("0 0 31 2 *")
+	if next := never.Next(at("2026-10-19 10:00")); !next.IsZero() {
+		t.Errorf("the 31st of February came at %s", next)
+	}
+}
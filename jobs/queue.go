@@ -0,0 +1,388 @@
+// Package jobs runs the library's background work. Queue is a
+// buffalo worker that keeps its jobs in the database, so they outlive
+// restarts and every instance of the app shares them: a job is run
+// once, by whichever instance takes it first, and one that fails is
+// tried again later, waiting longer each time. Jobs can also be run on
+// cron-style schedules, such as nightly.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/buffalo/worker"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/models"
+)
+
+var _ worker.Worker = &Queue{}
+
+// Scheduled is a job queued on a schedule. Name tells its runs apart
+// from those of other schedules, in the jobs' unique keys.
+type Scheduled struct {
+	Name     string
+	Schedule Schedule
+	Job      worker.Job
+}
+
+// Queue is a worker.Worker keeping its jobs in the jobs table.
+type Queue struct {
+	DB     *pop.Connection
+	Logger worker.SimpleLogger
+	// Name identifies this worker in the jobs it takes; the host name
+	// and process id by default.
+	Name string
+	// Queues are the queues this worker takes jobs from; all of them
+	// when empty.
+	Queues []string
+	// Poll is how often the worker looks for jobs when it has none.
+	Poll time.Duration
+	// Concurrency is how many jobs the worker runs at once.
+	Concurrency int
+	// MaxAttempts is how many times a job is run before it is failed
+	// for good.
+	MaxAttempts int
+	// Timeout is how long a job may run before it is taken to have died
+	// with its worker and is queued again.
+	Timeout time.Duration
+	// Schedules are the jobs queued on a schedule while the worker runs.
+	Schedules []Scheduled
+	// Observe, when set, is told how each run of a job ended, "done",
+	// "retry" or "failed", and how long it took.
+	Observe func(handler, result string, took time.Duration)
+
+	mu       sync.Mutex
+	handlers map[string]worker.Handler
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	now      func() time.Time
+}
+
+// New returns a worker keeping its jobs in db, with the defaults: it
+// polls every 5 seconds, runs 2 jobs at once, 5 times at most, for an
+// hour at most.
+func New(db *pop.Connection, logger worker.SimpleLogger) *Queue {
+	host, _ := os.Hostname()
+	return &Queue{
+		DB:          db,
+		Logger:      logger,
+		Name:        fmt.Sprintf("%s:%d", host, os.Getpid()),
+		Poll:        5 * time.Second,
+		Concurrency: 2,
+		MaxAttempts: 5,
+		Timeout:     time.Hour,
+		handlers:    map[string]worker.Handler{},
+		now:         time.Now,
+	}
+}
+
+// Backoff is how long a job waits after failing its attempt-th run: 30
+// seconds after the first, doubling each time, and 6 hours at most.
+func Backoff(attempt int) time.Duration {
+	wait := 30 * time.Second
+	for i := 1; i < attempt && wait < 6*time.Hour; i++ {
+		wait *= 2
+	}
+	if wait > 6*time.Hour {
+		wait = 6 * time.Hour
+	}
+	return wait
+}
+
+// Register maps name to the handler that runs the jobs named so.
+func (q *Queue) Register(name string, h worker.Handler) error {
+	if name == "" || h == nil {
+		return errors.New("name or handler cannot be empty/nil")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.handlers[name]; ok {
+		return errors.Errorf("handler already mapped for name %s", name)
+	}
+	q.handlers[name] = h
+	return nil
+}
+
+func (q *Queue) handler(name string) (worker.Handler, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	h, ok := q.handlers[name]
+	return h, ok
+}
+
+// Perform queues the job to run as soon as possible.
+func (q *Queue) Perform(job worker.Job) error {
+	return q.PerformAt(job, q.now())
+}
+
+// PerformIn queues the job to run after d.
+func (q *Queue) PerformIn(job worker.Job, d time.Duration) error {
+	return q.PerformAt(job, q.now().Add(d))
+}
+
+// PerformAt queues the job to run at t.
+func (q *Queue) PerformAt(job worker.Job, t time.Time) error {
+	_, err := q.enqueue(job, t, "")
+	return err
+}
+
+// enqueue stores the job to run at t. A job with a unique key already
+// queued is not queued again; enqueue returns false for it.
+func (q *Queue) enqueue(job worker.Job, t time.Time, key string) (bool, error) {
+	if job.Handler == "" {
+		return false, errors.Errorf("no handler name given: %s", job)
+	}
+	args, err := json.Marshal(job.Args)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	queue := job.Queue
+	if queue == "" {
+		queue = "default"
+	}
+	j := &models.Job{
+		Queue:       queue,
+		Handler:     job.Handler,
+		Args:        string(args),
+		Status:      models.JobQueued,
+		MaxAttempts: q.MaxAttempts,
+		RunAt:       t,
+	}
+	if key != "" {
+		j.UniqueKey = nulls.NewString(key)
+		queued, err := q.DB.Where("unique_key = ?", key).Exists(&models.Job{})
+		if err != nil || queued {
+			return false, errors.WithStack(err)
+		}
+	}
+	if err := q.DB.Create(j); err != nil {
+		if key != "" {
+			// another instance got there first
+			if queued, _ := q.DB.Where("unique_key = ?", key).Exists(&models.Job{}); queued {
+				return false, nil
+			}
+		}
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// Start runs jobs and queues scheduled ones in the background until ctx
+// is done or the worker is stopped.
+func (q *Queue) Start(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cancel != nil {
+		return errors.New("worker already started")
+	}
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.Logger.Infof("starting the jobs worker %s", q.Name)
+
+	for i := 0; i < q.Concurrency; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for ctx.Err() == nil {
+				ran, err := q.Work()
+				if err != nil {
+					q.Logger.Errorf("jobs: %v", err)
+				}
+				if ran && err == nil {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(q.Poll):
+				}
+			}
+		}()
+	}
+	if len(q.Schedules) > 0 {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.schedule(ctx)
+		}()
+	}
+	return nil
+}
+
+// Stop stops taking jobs and waits for those running to finish.
+func (q *Queue) Stop() error {
+	q.mu.Lock()
+	cancel := q.cancel
+	q.cancel = nil
+	q.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	q.Logger.Infof("stopping the jobs worker %s", q.Name)
+	cancel()
+	q.wg.Wait()
+	return nil
+}
+
+// schedule queues each scheduled job when it is due, until ctx is done.
+// Runs missed while no worker was running are not made up.
+func (q *Queue) schedule(ctx context.Context) {
+	last := q.now()
+	for {
+		next := time.Time{}
+		for _, s := range q.Schedules {
+			if t := s.Schedule.Next(last); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+		if next.IsZero() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		if err := q.QueueDue(last, next); err != nil {
+			q.Logger.Errorf("jobs: %v", err)
+		}
+		last = next
+	}
+}
+
+// QueueDue queues the scheduled jobs due after from and up to to.
+func (q *Queue) QueueDue(from, to time.Time) error {
+	for _, s := range q.Schedules {
+		for t := s.Schedule.Next(from); !t.IsZero() && !t.After(to); t = s.Schedule.Next(t) {
+			key := fmt.Sprintf("%s@%s", s.Name, t.UTC().Format(time.RFC3339))
+			if _, err := q.enqueue(s.Job, t, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Work takes a job that is due and runs it, reporting whether there was
+// one. Jobs left running by a worker that died are queued again first.
+func (q *Queue) Work() (bool, error) {
+	job, err := q.claim()
+	if job == nil || err != nil {
+		return false, err
+	}
+	return true, q.run(job)
+}
+
+// WorkAll runs the jobs that are due until there are none left.
+func (q *Queue) WorkAll(ctx context.Context) (int, error) {
+	n := 0
+	for ctx.Err() == nil {
+		ran, err := q.Work()
+		if err != nil || !ran {
+			return n, err
+		}
+		n++
+	}
+	return n, ctx.Err()
+}
+
+// claim takes the job due the longest, marking it as running, or
+// returns nil when none is due. Another worker may take a job between
+// finding and marking it; it then tries the next.
+func (q *Queue) claim() (*models.Job, error) {
+	now := q.now()
+	err := q.DB.RawQuery("UPDATE jobs SET status = ?, locked_by = '', updated_at = ? WHERE status = ? AND locked_at < ?",
+		models.JobQueued, now, models.JobRunning, now.Add(-q.Timeout)).Exec()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	due := models.Jobs{}
+	query := q.DB.Where("status = ? AND run_at <= ?", models.JobQueued, now)
+	if len(q.Queues) > 0 {
+		queues := make([]interface{}, len(q.Queues))
+		for i, name := range q.Queues {
+			queues[i] = name
+		}
+		query = query.Where("queue IN (?)", queues...)
+	}
+	if err := query.Order("run_at, created_at").Limit(10).All(&due); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, job := range due {
+		n, err := q.DB.RawQuery("UPDATE jobs SET status = ?, locked_by = ?, locked_at = ?, started_at = ?, attempts = attempts + 1, updated_at = ? WHERE id = ? AND status = ?",
+			models.JobRunning, q.Name, now, now, now, job.ID, models.JobQueued).ExecWithCount()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if n == 1 {
+			claimed := &models.Job{}
+			if err := q.DB.Find(claimed, job.ID); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					continue
+				}
+				return nil, errors.WithStack(err)
+			}
+			return claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+// run runs the job's handler and records how it went: done, queued to
+// try again after Backoff, or failed for good.
+func (q *Queue) run(job *models.Job) error {
+	start := q.now()
+	args := worker.Args{}
+	err := json.Unmarshal([]byte(job.Args), &args)
+	if err == nil {
+		if h, ok := q.handler(job.Handler); ok {
+			q.Logger.Debugf("running job %s %s %s", job.ID, job.Handler, job.Args)
+			err = safeRun(func() error { return h(args) })
+		} else {
+			err = errors.Errorf("no handler mapped for name %s", job.Handler)
+		}
+	}
+
+	now := q.now()
+	job.FinishedAt = nulls.NewTime(now)
+	job.LockedBy, job.LockedAt = "", nulls.Time{}
+	result := "done"
+	switch {
+	case err == nil:
+		job.Status, job.LastError = models.JobDone, nulls.String{}
+	case job.Attempts >= job.MaxAttempts:
+		job.Status, job.LastError = models.JobFailed, nulls.NewString(err.Error())
+		result = "failed"
+		q.Logger.Errorf("job %s %s failed for good: %v", job.ID, job.Handler, err)
+	default:
+		job.Status, job.LastError = models.JobQueued, nulls.NewString(err.Error())
+		job.RunAt = now.Add(Backoff(job.Attempts))
+		result = "retry"
+		q.Logger.Errorf("job %s %s failed, trying again at %s: %v", job.ID, job.Handler, job.RunAt.Format(time.RFC3339), err)
+	}
+	if q.Observe != nil {
+		q.Observe(job.Handler, result, now.Sub(start))
+	}
+	return errors.WithStack(q.DB.Update(job))
+}
+
+// safeRun runs fn, turning a panic into an error.
+func safeRun(fn func() error) (err error) {
+	defer func() {
+		if ex := recover(); ex != nil {
+			if e, ok := ex.(error); ok {
+				err = e
+				return
+			}
+			err = errors.New(fmt.Sprint(ex))
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_Backoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		wait    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if wait := Backoff(tt.attempt); wait != tt.wait {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, wait, tt.wait)
+		}
+	}
+}
+
+func Test_safeRun(t *testing.T) {
+	if err := safeRun(func() error { panic("boom") }); err == nil || err.Error() != "boom" {
+		t.Errorf("safeRun of a panic = %v", err)
+	}
+	failed := errors.New("failed")
+	if err := safeRun(func() error { return failed }); err != failed {
+		t.Errorf("safeRun = %v, want %v", err, failed)
+	}
+}
@@ -0,0 +1,183 @@
+// Package labels lays out labels and cards on sheets of sticky labels,
+// such as the Avery range, and prints them as PDF.
+//
+// Sheets are measured in millimetres, the way label makers publish
+// them; boxes handed to the drawing function are in PDF points.
+package labels
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"library/pdf"
+)
+
+// Sheet is a sheet of labels laid out in a grid.
+type Sheet struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PageWidth   float64 `json:"page_width"`
+	PageHeight  float64 `json:"page_height"`
+	// Left and Top are the margins to the first label.
+	Left    float64 `json:"left"`
+	Top     float64 `json:"top"`
+	Width   float64 `json:"width"`
+	Height  float64 `json:"height"`
+	Columns int     `json:"columns"`
+	Rows    int     `json:"rows"`
+	// ColumnPitch and RowPitch are the distances from one label to the
+	// next, gap included. They are the label's size when left out.
+	ColumnPitch float64 `json:"column_pitch"`
+	RowPitch    float64 `json:"row_pitch"`
+}
+
+const (
+	letterWidth  = 215.9
+	letterHeight = 279.4
+	a4Width      = 210
+	a4Height     = 297
+)
+
+// Builtin are the sheets every library can print on.
+var Builtin = []Sheet{
+	{Name: "avery-5160", Description: "Avery 5160 address labels, 1\" x 2 5/8\", 30 per Letter sheet",
+		PageWidth: letterWidth, PageHeight: letterHeight, Left: 4.76, Top: 12.7, Width: 66.68, Height: 25.4, Columns: 3, Rows: 10, ColumnPitch: 69.85},
+	{Name: "avery-5163", Description: "Avery 5163 shipping labels, 2\" x 4\", 10 per Letter sheet",
+		PageWidth: letterWidth, PageHeight: letterHeight, Left: 3.97, Top: 12.7, Width: 101.6, Height: 50.8, Columns: 2, Rows: 5, ColumnPitch: 104.78},
+	{Name: "avery-5167", Description: "Avery 5167 return address labels, 1/2\" x 1 3/4\", 80 per Letter sheet",
+		PageWidth: letterWidth, PageHeight: letterHeight, Left: 7.54, Top: 12.7, Width: 44.45, Height: 12.7, Columns: 4, Rows: 20, ColumnPitch: 52.39},
+	{Name: "avery-5371", Description: "Avery 5371 business cards, 2\" x 3 1/2\", 10 per Letter sheet",
+		PageWidth: letterWidth, PageHeight: letterHeight, Left: 19.05, Top: 12.7, Width: 88.9, Height: 50.8, Columns: 2, Rows: 5},
+	{Name: "avery-l7160", Description: "Avery L7160 address labels, 63.5 x 38.1 mm, 21 per A4 sheet",
+		PageWidth: a4Width, PageHeight: a4Height, Left: 7.2, Top: 15.15, Width: 63.5, Height: 38.1, Columns: 3, Rows: 7, ColumnPitch: 66},
+	{Name: "avery-l7163", Description: "Avery L7163 address labels, 99.1 x 38.1 mm, 14 per A4 sheet",
+		PageWidth: a4Width, PageHeight: a4Height, Left: 4.65, Top: 15.15, Width: 99.1, Height: 38.1, Columns: 2, Rows: 7, ColumnPitch: 101.6},
+	{Name: "avery-l7651", Description: "Avery L7651 mini labels, 38.1 x 21.2 mm, 65 per A4 sheet",
+		PageWidth: a4Width, PageHeight: a4Height, Left: 4.75, Top: 10.7, Width: 38.1, Height: 21.2, Columns: 5, Rows: 13, ColumnPitch: 40.6},
+	{Name: "avery-c32011", Description: "Avery C32011 business cards, 85 x 54 mm, 10 per A4 sheet",
+		PageWidth: a4Width, PageHeight: a4Height, Left: 15, Top: 13.5, Width: 85, Height: 54, Columns: 2, Rows: 5, ColumnPitch: 95},
+}
+
+// SelectLabel names the sheet in forms.
+func (s Sheet) SelectLabel() string {
+	return s.Description
+}
+
+func (s Sheet) SelectValue() interface{} {
+	return s.Name
+}
+
+// PerSheet is the number of labels on a sheet.
+func (s Sheet) PerSheet() int {
+	return s.Columns * s.Rows
+}
+
+// Validate checks the labels fit on the page.
+func (s Sheet) Validate() error {
+	switch {
+	case s.Name == "":
+		return errors.New("label sheet has no name")
+	case s.PageWidth <= 0 || s.PageHeight <= 0 || s.Width <= 0 || s.Height <= 0 || s.Columns <= 0 || s.Rows <= 0:
+		return errors.Errorf("label sheet %s needs a page size, a label size, columns and rows", s.Name)
+	}
+	right := s.Left + float64(s.Columns-1)*s.columnPitch() + s.Width
+	bottom := s.Top + float64(s.Rows-1)*s.rowPitch() + s.Height
+	if s.Left < 0 || s.Top < 0 || right > s.PageWidth+0.5 || bottom > s.PageHeight+0.5 {
+		return errors.Errorf("the labels of sheet %s don't fit on the page", s.Name)
+	}
+	return nil
+}
+
+func (s Sheet) columnPitch() float64 {
+	if s.ColumnPitch > 0 {
+		return s.ColumnPitch
+	}
+	return s.Width
+}
+
+func (s Sheet) rowPitch() float64 {
+	if s.RowPitch > 0 {
+		return s.RowPitch
+	}
+	return s.Height
+}
+
+// Box is where a label is on the page, in points from the top left.
+type Box struct {
+	X, Y, Width, Height float64
+}
+
+// Label returns the box of the i-th label of a sheet, counting across
+// the rows from the top left.
+func (s Sheet) Label(i int) Box {
+	i %= s.PerSheet()
+	return Box{
+		X:      (s.Left + float64(i%s.Columns)*s.columnPitch()) * pdf.MM,
+		Y:      (s.Top + float64(i/s.Columns)*s.rowPitch()) * pdf.MM,
+		Width:  s.Width * pdf.MM,
+		Height: s.Height * pdf.MM,
+	}
+}
+
+// Load reads a JSON array of sheets, adding them to the built in ones.
+// A sheet with the name of a built in sheet replaces it.
+func Load(r io.Reader) ([]Sheet, error) {
+	var custom []Sheet
+	if err := json.NewDecoder(r).Decode(&custom); err != nil {
+		return nil, errors.Wrap(err, "reading label sheets")
+	}
+	sheets := append([]Sheet{}, Builtin...)
+	for _, s := range custom {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		if s.Description == "" {
+			s.Description = s.Name
+		}
+		if i := Find(sheets, s.Name); i >= 0 {
+			sheets[i] = s
+			continue
+		}
+		sheets = append(sheets, s)
+	}
+	return sheets, nil
+}
+
+// Find returns the index of the sheet called name, or -1.
+func Find(sheets []Sheet, name string) int {
+	for i, s := range sheets {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Print writes n labels to w as a PDF, starting skip labels into the
+// first sheet so a part used sheet can go back in the printer. draw
+// fills in the i-th label, adding the images it needs to d.
+func Print(w io.Writer, s Sheet, skip, n int, draw func(d *pdf.Document, p *pdf.Page, i int, box Box) error) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if skip < 0 || skip >= s.PerSheet() {
+		return errors.Errorf("a %s sheet has labels 1 to %d", s.Name, s.PerSheet())
+	}
+	d := pdf.New(w, s.PageWidth*pdf.MM, s.PageHeight*pdf.MM)
+	var page *pdf.Page
+	for i := 0; i < n; i++ {
+		at := skip + i
+		if page == nil || at%s.PerSheet() == 0 {
+			page = d.AddPage()
+		}
+		if err := draw(d, page, i, s.Label(at)); err != nil {
+			return err
+		}
+	}
+	if page == nil {
+		d.AddPage()
+	}
+	return d.Close()
+}
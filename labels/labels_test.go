@@ -0,0 +1,79 @@
+package labels
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"library/pdf"
+)
+
+func Test_Builtin(t *testing.T) {
+	for _, s := range Builtin {
+		if err := s.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func Test_Sheet_Label(t *testing.T) {
+	s := Builtin[Find(Builtin, "avery-5160")]
+	if s.PerSheet() != 30 {
+		t.Fatalf("got %d labels", s.PerSheet())
+	}
+	box := s.Label(4)
+	if math.Abs(box.X-(4.76+69.85)*pdf.MM) > 0.01 || math.Abs(box.Y-(12.7+25.4)*pdf.MM) > 0.01 {
+		t.Errorf("label 4 at %v", box)
+	}
+	if s.Label(34) != box {
+		t.Error("labels should repeat on every sheet")
+	}
+}
+
+func Test_Load(t *testing.T) {
+	sheets, err := Load(strings.NewReader(`[
+		{"name": "avery-5160", "page_width": 215.9, "page_height": 279.4, "left": 5, "top": 13, "width": 66, "height": 25, "columns": 3, "rows": 10, "column_pitch": 70},
+		{"name": "spine", "page_width": 210, "page_height": 297, "width": 25, "height": 25, "columns": 8, "rows": 11}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheets) != len(Builtin)+1 {
+		t.Errorf("got %d sheets", len(sheets))
+	}
+	if s := sheets[Find(sheets, "avery-5160")]; s.Left != 5 {
+		t.Error("the custom sheet should replace the built in one")
+	}
+	if s := sheets[Find(sheets, "spine")]; s.Description != "spine" || s.Label(9).X != 25*pdf.MM {
+		t.Errorf("got %+v", s)
+	}
+
+	_, err = Load(strings.NewReader(`[{"name": "big", "page_width": 210, "page_height": 297, "width": 100, "height": 25, "columns": 3, "rows": 1}]`))
+	if err == nil {
+		t.Error("labels wider than the page should be refused")
+	}
+}
+
+func Test_Print(t *testing.T) {
+	s := Builtin[Find(Builtin, "avery-5371")]
+	buf := &bytes.Buffer{}
+	var drawn []Box
+	err := Print(buf, s, 8, 3, func(d *pdf.Document, p *pdf.Page, i int, box Box) error {
+		drawn = append(drawn, box)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drawn) != 3 || drawn[0] != s.Label(8) || drawn[2] != s.Label(0) {
+		t.Errorf("got %v", drawn)
+	}
+	if !strings.Contains(buf.String(), "/Count 2") {
+		t.Error("expected two sheets")
+	}
+
+	if err := Print(buf, s, 10, 1, nil); err == nil {
+		t.Error("skipping a whole sheet should be refused")
+	}
+}
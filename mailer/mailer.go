@@ -0,0 +1,190 @@
+// Package mailer sends plain text email, such as the notices the
+// library sends its customers. Where messages go is up to a Sender: an
+// SMTP server, or, in development and tests, the log or a directory of
+// .eml files.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Message is a plain text email.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	// Date is when the message was written; Write uses the time it's
+	// called when it's zero.
+	Date time.Time
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// addresses checks the sender and recipients of m and returns their
+// bare addresses, as SMTP takes them.
+func addresses(m Message) (string, []string, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "invalid sender %q", m.From)
+	}
+	if len(m.To) == 0 {
+		return "", nil, errors.New("message has no recipients")
+	}
+	to := make([]string, len(m.To))
+	for i, addr := range m.To {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "invalid recipient %q", addr)
+		}
+		to[i] = a.Address
+	}
+	return from.Address, to, nil
+}
+
+// Write writes m to w in the Internet Message Format, its text as UTF-8
+// quoted-printable.
+func Write(w io.Writer, m Message) error {
+	if _, _, err := addresses(m); err != nil {
+		return err
+	}
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return errors.WithStack(err)
+	}
+	domain := "localhost"
+	if from, err := mail.ParseAddress(m.From); err == nil {
+		if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+			domain = from.Address[at+1:]
+		}
+	}
+
+	var b bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain))
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	text := strings.ReplaceAll(strings.ReplaceAll(m.Text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := qp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := w.Write(b.Bytes())
+	return errors.WithStack(err)
+}
+
+// SMTP sends messages through a mail server, upgrading the connection
+// with STARTTLS when the server offers it.
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+// Send delivers m to the server.
+func (s SMTP) Send(ctx context.Context, m Message) error {
+	from, to, err := addresses(m)
+	if err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	if err := Write(&msg, m); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	port := s.Port
+	if port == "" {
+		port = "25"
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(s.Host, port), auth, from, to, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return errors.Wrapf(err, "sending mail through %s", s.Host)
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// Log writes a summary of each message and its text to a logger instead
+// of sending it.
+type Log struct {
+	Logger *log.Logger
+}
+
+// Send logs m.
+func (l Log) Send(ctx context.Context, m Message) error {
+	if _, _, err := addresses(m); err != nil {
+		return err
+	}
+	logger := l.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("mail from %s to %s: %s\n%s", m.From, strings.Join(m.To, ", "), m.Subject, m.Text)
+	return nil
+}
+
+// File writes each message to a .eml file of its own in a directory
+// instead of sending it, for mail clients to open.
+type File struct {
+	Dir string
+}
+
+// Send writes m to a new file in the directory.
+func (f File) Send(ctx context.Context, m Message) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	var msg bytes.Buffer
+	if err := Write(&msg, m); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return errors.WithStack(err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return errors.WithStack(os.WriteFile(filepath.Join(f.Dir, name), msg.Bytes(), 0o644))
+}
@@ -0,0 +1,171 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var message = Message{
+	From:    "Library <library@example.com>",
+	To:      []string{"Ann Ng <ann@example.com>"},
+	Subject: "Émile is due back",
+	Text:    "Dear Ann,\n\nÉmile is due back on 2026-10-21.\n",
+	Date:    time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+}
+
+func readMessage(t *testing.T, r io.Reader) (*mail.Message, string) {
+	t.Helper()
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(m.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, string(body)
+}
+
+func Test_Write(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, message); err != nil {
+		t.Fatal(err)
+	}
+	m, body := readMessage(t, &b)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != message.Subject {
+		t.Errorf("Subject = %q, want %q", subject, message.Subject)
+	}
+	if got := m.Header.Get("Date"); got != "Mon, 19 Oct 2026 09:00:00 +0000" {
+		t.Errorf("Date = %q", got)
+	}
+	if got := m.Header.Get("Message-ID"); !strings.HasSuffix(got, "@example.com>") {
+		t.Errorf("Message-ID = %q", got)
+	}
+	if want := strings.ReplaceAll(message.Text, "\n", "\r\n"); body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+
+	if err := Write(io.Discard, Message{From: "library@example.com", Subject: "Hi"}); err == nil {
+		t.Error("wrote a message without recipients")
+	}
+	if err := Write(io.Discard, Message{From: "nobody", To: []string{"ann@example.com"}}); err == nil {
+		t.Error("wrote a message from an invalid address")
+	}
+}
+
+func Test_File(t *testing.T) {
+	dir := t.TempDir()
+	f := File{Dir: filepath.Join(dir, "mail")}
+	for i := 0; i < 2; i++ {
+		if err := f.Send(context.Background(), message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "mail", "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("wrote %d files, want 2", len(files))
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := readMessage(t, bytes.NewReader(content)); m.Header.Get("To") != message.To[0] {
+		t.Errorf("To = %q", m.Header.Get("To"))
+	}
+}
+
+// fakeSMTP accepts one message the way a mail server does, without
+// STARTTLS or authentication, and hands over the envelope and data.
+func fakeSMTP(t *testing.T) (string, string, <-chan []string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		var envelope []string
+		var data strings.Builder
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				envelope = append(envelope, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				got <- append(envelope, data.String())
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	return host, port, got
+}
+
+func Test_SMTP(t *testing.T) {
+	host, port, got := fakeSMTP(t)
+	s := SMTP{Host: host, Port: port}
+	if err := s.Send(context.Background(), message); err != nil {
+		t.Fatal(err)
+	}
+	session := <-got
+	if len(session) != 3 {
+		t.Fatalf("session = %q", session)
+	}
+	if session[0] != "MAIL FROM:<library@example.com>" || session[1] != "RCPT TO:<ann@example.com>" {
+		t.Errorf("envelope = %q", session[:2])
+	}
+	if _, body := readMessage(t, strings.NewReader(session[2])); !strings.Contains(body, "due back on 2026-10-21") {
+		t.Errorf("body = %q", body)
+	}
+}
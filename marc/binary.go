@@ -0,0 +1,108 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	recordTerminator  = 0x1D
+	fieldTerminator   = 0x1E
+	subfieldDelimiter = 0x1F
+	leaderLength      = 24
+	directoryEntryLen = 12
+)
+
+// ReadBinary parses MARC21 (ISO 2709) records until EOF.
+func ReadBinary(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+	var records []Record
+	for n := 1; ; n++ {
+		raw, err := br.ReadBytes(recordTerminator)
+		// files exported by some systems put a newline between records
+		raw = bytes.TrimLeft(raw, "\r\n")
+		if len(bytes.TrimSpace(raw)) == 0 && err == io.EOF {
+			return records, nil
+		}
+		if err != nil && err != io.EOF {
+			return records, errors.WithStack(err)
+		}
+		rec, perr := parseBinary(raw)
+		if perr != nil {
+			return records, errors.Wrapf(perr, "record %d", n)
+		}
+		records = append(records, rec)
+		if err == io.EOF {
+			return records, nil
+		}
+	}
+}
+
+func parseBinary(raw []byte) (Record, error) {
+	rec := Record{}
+	if len(raw) < leaderLength {
+		return rec, errors.New("record is shorter than its leader")
+	}
+	rec.Leader = string(raw[:leaderLength])
+
+	base, err := strconv.Atoi(strings.TrimSpace(rec.Leader[12:17]))
+	if err != nil || base <= leaderLength || base > len(raw) {
+		return rec, errors.Errorf("invalid base address of data %q", rec.Leader[12:17])
+	}
+
+	// the directory runs from the end of the leader up to the field
+	// terminator that precedes the base address
+	dir := raw[leaderLength : base-1]
+	if len(dir)%directoryEntryLen != 0 {
+		return rec, errors.New("malformed directory")
+	}
+	data := raw[base:]
+
+	for i := 0; i < len(dir); i += directoryEntryLen {
+		entry := string(dir[i : i+directoryEntryLen])
+		tag := entry[:3]
+		length, err := strconv.Atoi(entry[3:7])
+		if err != nil {
+			return rec, errors.Errorf("invalid field length for tag %s", tag)
+		}
+		start, err := strconv.Atoi(entry[7:12])
+		if err != nil {
+			return rec, errors.Errorf("invalid field start for tag %s", tag)
+		}
+		if start+length > len(data) {
+			return rec, errors.Errorf("field %s runs past the end of the record", tag)
+		}
+		value := strings.TrimRight(string(data[start:start+length]), string([]byte{fieldTerminator, recordTerminator}))
+
+		if isControlTag(tag) {
+			rec.ControlFields = append(rec.ControlFields, ControlField{Tag: tag, Value: value})
+			continue
+		}
+		rec.DataFields = append(rec.DataFields, parseDataField(tag, value))
+	}
+	return rec, nil
+}
+
+func parseDataField(tag, value string) DataField {
+	field := DataField{Tag: tag, Ind1: " ", Ind2: " "}
+	parts := strings.Split(value, string(rune(subfieldDelimiter)))
+	if ind := parts[0]; len(ind) >= 2 {
+		field.Ind1, field.Ind2 = ind[0:1], ind[1:2]
+	}
+	for _, p := range parts[1:] {
+		if p == "" {
+			continue
+		}
+		field.Subfields = append(field.Subfields, Subfield{Code: p[:1], Value: p[1:]})
+	}
+	return field
+}
+
+func isControlTag(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
@@ -0,0 +1,132 @@
+// Package marc reads bibliographic records in the MARC21 binary
+// (ISO 2709) and MARCXML formats.
+package marc
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Record is a single bibliographic record.
+type Record struct {
+	Leader        string
+	ControlFields []ControlField
+	DataFields    []DataField
+}
+
+// ControlField holds the value of a 00X field.
+type ControlField struct {
+	Tag   string
+	Value string
+}
+
+// DataField holds a variable data field with its indicators and subfields.
+type DataField struct {
+	Tag       string
+	Ind1      string
+	Ind2      string
+	Subfields []Subfield
+}
+
+// Subfield is a single coded value inside a DataField.
+type Subfield struct {
+	Code  string
+	Value string
+}
+
+// Control returns the value of the first control field with the given tag.
+func (r Record) Control(tag string) string {
+	for _, f := range r.ControlFields {
+		if f.Tag == tag {
+			return strings.TrimSpace(f.Value)
+		}
+	}
+	return ""
+}
+
+// Fields returns every data field with the given tag.
+func (r Record) Fields(tag string) []DataField {
+	var fields []DataField
+	for _, f := range r.DataFields {
+		if f.Tag == tag {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Value returns the first non empty value of subfield code in the
+// first field with the given tag.
+func (r Record) Value(tag, code string) string {
+	for _, f := range r.Fields(tag) {
+		if v := f.Value(code); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Values returns the first value of subfield code for every field with
+// the given tag.
+func (r Record) Values(tag, code string) []string {
+	var values []string
+	for _, f := range r.Fields(tag) {
+		if v := f.Value(code); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Value returns the first value of the given subfield code.
+func (f DataField) Value(code string) string {
+	for _, s := range f.Subfields {
+		if s.Code == code {
+			return strings.TrimSpace(s.Value)
+		}
+	}
+	return ""
+}
+
+// Join concatenates the values of the given subfield codes in the
+// order they appear in the field.
+func (f DataField) Join(codes string) string {
+	var parts []string
+	for _, s := range f.Subfields {
+		if strings.Contains(codes, s.Code) {
+			if v := strings.TrimSpace(s.Value); v != "" {
+				parts = append(parts, v)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Read parses every record in r. The format is detected from the first
+// non blank byte: "<" means MARCXML, anything else MARC21 binary.
+func Read(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, errors.WithStack(err)
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n', 0xEF, 0xBB, 0xBF:
+			// skip blank lines and a UTF-8 byte order mark
+			if _, err := br.ReadByte(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		case '<':
+			return ReadXML(br)
+		default:
+			return ReadBinary(br)
+		}
+	}
+}
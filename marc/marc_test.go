@@ -0,0 +1,97 @@
+package marc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// encode builds a minimal ISO 2709 record from tag/value pairs. Data
+// field values must already contain their indicators and subfield
+// delimiters.
+func encode(fields [][2]string) string {
+	var dir, data strings.Builder
+	for _, f := range fields {
+		value := f[1] + string(rune(fieldTerminator))
+		fmt.Fprintf(&dir, "%s%04d%05d", f[0], len(value), data.Len())
+		data.WriteString(value)
+	}
+	dir.WriteByte(fieldTerminator)
+	base := leaderLength + dir.Len()
+	total := base + data.Len() + 1
+	leader := fmt.Sprintf("%05dnam a22%05d   4500", total, base)
+	return leader + dir.String() + data.String() + string(rune(recordTerminator))
+}
+
+func sf(code, value string) string {
+	return string(rune(subfieldDelimiter)) + code + value
+}
+
+func Test_ReadBinary(t *testing.T) {
+	raw := encode([][2]string{
+		{"001", "ocm12345"},
+		{"020", "  " + sf("a", "9780140449136") + sf("c", "12.50")},
+		{"100", "1 " + sf("a", "Dostoyevsky, Fyodor,")},
+		{"245", "10" + sf("a", "Crime and punishment /") + sf("c", "translated by David McDuff.")},
+		{"650", " 0" + sf("a", "Murder") + sf("z", "Russia")},
+		{"650", " 0" + sf("a", "Psychological fiction")},
+	})
+
+	records, err := Read(strings.NewReader(raw + "\n" + raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	rec := records[0]
+	if got := rec.Control("001"); got != "ocm12345" {
+		t.Errorf("001 = %q", got)
+	}
+	if got := rec.Value("020", "a"); got != "9780140449136" {
+		t.Errorf("020$a = %q", got)
+	}
+	if got := rec.Fields("245")[0].Ind1; got != "1" {
+		t.Errorf("245 ind1 = %q", got)
+	}
+	if got := rec.Values("650", "a"); len(got) != 2 || got[1] != "Psychological fiction" {
+		t.Errorf("650$a = %v", got)
+	}
+}
+
+func Test_ReadBinary_Malformed(t *testing.T) {
+	if _, err := Read(strings.NewReader("00010nam a22")); err == nil {
+		t.Fatal("expected an error for a truncated record")
+	}
+}
+
+func Test_ReadXML(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000nam a2200000 a 4500</leader>
+    <controlfield tag="001">xml-1</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">0316769487 (pbk.)</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="4">
+      <subfield code="a">The catcher in the rye /</subfield>
+      <subfield code="c">J.D. Salinger.</subfield>
+    </datafield>
+  </record>
+</collection>`
+
+	records, err := Read(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	rec := records[0]
+	if got := rec.Control("001"); got != "xml-1" {
+		t.Errorf("001 = %q", got)
+	}
+	if got := rec.Fields("245")[0].Join("ac"); got != "The catcher in the rye / J.D. Salinger." {
+		t.Errorf("245 = %q", got)
+	}
+}
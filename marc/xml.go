@@ -0,0 +1,65 @@
+package marc
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+type xmlRecord struct {
+	Leader        string `xml:"leader"`
+	ControlFields []struct {
+		Tag   string `xml:"tag,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"controlfield"`
+	DataFields []struct {
+		Tag       string `xml:"tag,attr"`
+		Ind1      string `xml:"ind1,attr"`
+		Ind2      string `xml:"ind2,attr"`
+		Subfields []struct {
+			Code  string `xml:"code,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"subfield"`
+	} `xml:"datafield"`
+}
+
+// ReadXML parses every <record> element in a MARCXML document. Both a
+// single record and a <collection> of records are accepted.
+func ReadXML(r io.Reader) ([]Record, error) {
+	dec := xml.NewDecoder(r)
+	var records []Record
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, errors.WithStack(err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var xr xmlRecord
+		if err := dec.DecodeElement(&xr, &start); err != nil {
+			return records, errors.Wrapf(err, "record %d", len(records)+1)
+		}
+		records = append(records, xr.record())
+	}
+}
+
+func (xr xmlRecord) record() Record {
+	rec := Record{Leader: xr.Leader}
+	for _, cf := range xr.ControlFields {
+		rec.ControlFields = append(rec.ControlFields, ControlField{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range xr.DataFields {
+		field := DataField{Tag: df.Tag, Ind1: df.Ind1, Ind2: df.Ind2}
+		for _, sf := range df.Subfields {
+			field.Subfields = append(field.Subfields, Subfield{Code: sf.Code, Value: sf.Value})
+		}
+		rec.DataFields = append(rec.DataFields, field)
+	}
+	return rec
+}
@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache remembers lookups, including misses, for TTL so repeated form
+// lookups don't hit the upstream provider.
+type Cache struct {
+	Provider Provider
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	metadata *Metadata
+	err      error
+	expires  time.Time
+}
+
+// NewCache wraps p with a cache holding results for ttl.
+func NewCache(p Provider, ttl time.Duration) *Cache {
+	return &Cache{Provider: p, TTL: ttl, entries: map[string]cacheEntry{}, now: time.Now}
+}
+
+// Lookup implements Provider. Only successful results and ErrNotFound
+// are cached; transient errors are retried on the next call.
+func (c *Cache) Lookup(ctx context.Context, isbn string) (*Metadata, error) {
+	c.mu.Lock()
+	e, ok := c.entries[isbn]
+	if ok && c.now().Before(e.expires) {
+		c.mu.Unlock()
+		return copyMetadata(e.metadata), e.err
+	}
+	c.mu.Unlock()
+
+	m, err := c.Provider.Lookup(ctx, isbn)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[isbn] = cacheEntry{metadata: m, err: err, expires: c.now().Add(c.TTL)}
+	c.mu.Unlock()
+	return copyMetadata(m), err
+}
+
+func copyMetadata(m *Metadata) *Metadata {
+	if m == nil {
+		return nil
+	}
+	cp := *m
+	cp.Authors = append([]string(nil), m.Authors...)
+	return &cp
+}
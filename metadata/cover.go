@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// MaxCoverSize is the largest cover image DownloadCover accepts.
+const MaxCoverSize = 5 << 20
+
+var coverExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// DownloadCover fetches a cover image and returns its bytes with a file
+// extension matching the sniffed content type.
+func DownloadCover(ctx context.Context, client *http.Client, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("metadata: cover download returned %s", res.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, MaxCoverSize+1))
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	if len(b) > MaxCoverSize {
+		return nil, "", errors.New("metadata: cover image is too large")
+	}
+	ext, ok := coverExtensions[http.DetectContentType(b)]
+	if !ok {
+		return nil, "", errors.New("metadata: cover is not an image")
+	}
+	return b, ext, nil
+}
@@ -0,0 +1,35 @@
+// Package metadata looks up bibliographic details for a book by ISBN
+// so cataloguers don't have to type them in.
+package metadata
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by a Provider that has no record for an ISBN.
+var ErrNotFound = errors.New("metadata: isbn not found")
+
+// Metadata is what a provider knows about a book.
+type Metadata struct {
+	ISBN        string   `json:"isbn"`
+	Title       string   `json:"title"`
+	Authors     []string `json:"authors"`
+	Publisher   string   `json:"publisher"`
+	Year        int      `json:"year"`
+	Description string   `json:"description"`
+	CoverURL    string   `json:"cover_url"`
+}
+
+// Author returns the authors as a single display string.
+func (m Metadata) Author() string {
+	return strings.Join(m.Authors, "; ")
+}
+
+// Provider returns the metadata of the book with the given ISBN. The
+// ISBN is passed already normalised (digits and a trailing X only).
+type Provider interface {
+	Lookup(ctx context.Context, isbn string) (*Metadata, error)
+}
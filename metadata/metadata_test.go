@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func Test_OpenLibrary_Lookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("bibkeys") != "ISBN:9780140449136" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Write([]byte(`{"ISBN:9780140449136": {
+			"title": "Crime and Punishment",
+			"authors": [{"name": "Fyodor Dostoyevsky"}],
+			"publishers": [{"name": "Penguin Books"}],
+			"publish_date": "January 30, 2003",
+			"notes": "Translated by David McDuff.",
+			"cover": {"medium": "https://covers.example/m.jpg", "large": "https://covers.example/l.jpg"}
+		}}`))
+	}))
+	defer srv.Close()
+
+	ol := NewOpenLibrary()
+	ol.BaseURL = srv.URL
+
+	m, err := ol.Lookup(context.Background(), "9780140449136")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "Crime and Punishment" || m.Author() != "Fyodor Dostoyevsky" || m.Publisher != "Penguin Books" {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+	if m.Year != 2003 {
+		t.Errorf("year = %d", m.Year)
+	}
+	if m.CoverURL != "https://covers.example/l.jpg" {
+		t.Errorf("cover = %q", m.CoverURL)
+	}
+
+	if _, err := ol.Lookup(context.Background(), "0000000000"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func Test_Fixture_Lookup(t *testing.T) {
+	f := NewFixture(fstest.MapFS{
+		"9780316769488.json": {Data: []byte(`{"title": "The Catcher in the Rye", "authors": ["J. D. Salinger"], "year": 1951}`)},
+	})
+
+	m, err := f.Lookup(context.Background(), "9780316769488")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ISBN != "9780316769488" || m.Year != 1951 {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+	if _, err := f.Lookup(context.Background(), "123"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+type countingProvider struct {
+	calls int32
+}
+
+func (p *countingProvider) Lookup(ctx context.Context, isbn string) (*Metadata, error) {
+	atomic.AddInt32(&p.calls, 1)
+	if isbn == "missing" {
+		return nil, ErrNotFound
+	}
+	return &Metadata{ISBN: isbn, Authors: []string{"A"}}, nil
+}
+
+func Test_Cache(t *testing.T) {
+	p := &countingProvider{}
+	c := NewCache(p, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		m, err := c.Lookup(context.Background(), "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Authors[0] = "changed"
+		if _, err := c.Lookup(context.Background(), "missing"); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if p.calls != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", p.calls)
+	}
+	if m, _ := c.Lookup(context.Background(), "1"); m.Authors[0] != "A" {
+		t.Errorf("cached value was modified by a caller")
+	}
+
+	now = now.Add(2 * time.Minute)
+	c.Lookup(context.Background(), "1")
+	if p.calls != 3 {
+		t.Errorf("expected the entry to expire, got %d calls", p.calls)
+	}
+}
+
+func Test_RateLimited(t *testing.T) {
+	p := &countingProvider{}
+	r := NewRateLimited(p, 50*time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := r.Lookup(context.Background(), "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 calls took %s, expected at least 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.next = time.Now().Add(time.Hour)
+	if _, err := r.Lookup(ctx, "1"); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OpenLibrary looks books up with the Open Library "books" API.
+type OpenLibrary struct {
+	// BaseURL defaults to https://openlibrary.org and is overridden in
+	// tests or to point at a mirror.
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewOpenLibrary returns a provider talking to openlibrary.org.
+func NewOpenLibrary() *OpenLibrary {
+	return &OpenLibrary{
+		BaseURL: "https://openlibrary.org",
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type olName struct {
+	Name string `json:"name"`
+}
+
+type olBook struct {
+	Title       string   `json:"title"`
+	Subtitle    string   `json:"subtitle"`
+	Authors     []olName `json:"authors"`
+	Publishers  []olName `json:"publishers"`
+	PublishDate string   `json:"publish_date"`
+	Notes       string   `json:"notes"`
+	Excerpts    []struct {
+		Text string `json:"text"`
+	} `json:"excerpts"`
+	Cover struct {
+		Small  string `json:"small"`
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"cover"`
+}
+
+var yearPattern = regexp.MustCompile(`\b(1[5-9]|20)\d\d\b`)
+
+// Lookup implements Provider.
+func (o *OpenLibrary) Lookup(ctx context.Context, isbn string) (*Metadata, error) {
+	key := "ISBN:" + isbn
+	q := url.Values{}
+	q.Set("bibkeys", key)
+	q.Set("jscmd", "data")
+	q.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(o.BaseURL, "/")+"/api/books?"+q.Encode(), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := o.Client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata: open library returned %s", res.Status)
+	}
+
+	books := map[string]olBook{}
+	if err := json.NewDecoder(res.Body).Decode(&books); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b, ok := books[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	m := &Metadata{ISBN: isbn, Title: b.Title, Description: b.Notes}
+	if b.Subtitle != "" {
+		m.Title += ": " + b.Subtitle
+	}
+	for _, a := range b.Authors {
+		m.Authors = append(m.Authors, a.Name)
+	}
+	if len(b.Publishers) > 0 {
+		m.Publisher = b.Publishers[0].Name
+	}
+	if y := yearPattern.FindString(b.PublishDate); y != "" {
+		m.Year, _ = strconv.Atoi(y)
+	}
+	if m.Description == "" && len(b.Excerpts) > 0 {
+		m.Description = b.Excerpts[0].Text
+	}
+	switch {
+	case b.Cover.Large != "":
+		m.CoverURL = b.Cover.Large
+	case b.Cover.Medium != "":
+		m.CoverURL = b.Cover.Medium
+	default:
+		m.CoverURL = b.Cover.Small
+	}
+	return m, nil
+}
@@ -0,0 +1,46 @@
+package metadata
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimited spaces calls to the wrapped provider at least Interval
+// apart, which keeps us within the usage policy of public APIs.
+type RateLimited struct {
+	Provider Provider
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewRateLimited wraps p so it is called at most once per interval.
+func NewRateLimited(p Provider, interval time.Duration) *RateLimited {
+	return &RateLimited{Provider: p, Interval: interval}
+}
+
+// Lookup implements Provider. It waits for its slot, or returns early
+// with the context's error if ctx is done first.
+func (r *RateLimited) Lookup(ctx context.Context, isbn string) (*Metadata, error) {
+	r.mu.Lock()
+	now := time.Now()
+	slot := r.next
+	if slot.Before(now) {
+		slot = now
+	}
+	r.next = slot.Add(r.Interval)
+	r.mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+	return r.Provider.Lookup(ctx, isbn)
+}
@@ -0,0 +1,336 @@
+// Package metrics keeps counters, gauges and histograms and serves them
+// in the Prometheus text exposition format, for the app's /metrics
+// endpoint. Counters and histograms are kept as the app runs; figures
+// read from elsewhere, such as the database, are gathered by collector
+// functions each time the metrics are scraped.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The types of metric families.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds of the buckets of a histogram of
+// durations in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample is a value of a metric. Suffix is added to the family's name,
+// as in "_bucket" for the buckets of a histogram. Labels are name and
+// value pairs.
+type Sample struct {
+	Suffix string
+	Labels []string
+	Value  float64
+}
+
+// Family is a metric and its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector gathers metric families.
+type Collector interface {
+	Collect() ([]Family, error)
+}
+
+// CollectorFunc gathers metric families when the metrics are scraped.
+type CollectorFunc func() ([]Family, error)
+
+// Collect calls f.
+func (f CollectorFunc) Collect() ([]Family, error) {
+	return f()
+}
+
+// Gauge is a family of one gauge with the value v, for collector
+// functions.
+func Gauge(name, help string, v float64) Family {
+	return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Value: v}}}
+}
+
+// Counter is a family of one counter with the value v, for collector
+// functions reading a count kept elsewhere.
+func Counter(name, help string, v float64) Family {
+	return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Value: v}}}
+}
+
+// Registry is the collectors whose metrics are served together.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry returns a registry with no collectors.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds collectors to the registry.
+func (r *Registry) Register(cs ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, cs...)
+}
+
+// Gather collects the metric families of every collector, by name.
+func (r *Registry) Gather() ([]Family, error) {
+	r.mu.Lock()
+	collectors := append([]Collector{}, r.collectors...)
+	r.mu.Unlock()
+
+	families := []Family{}
+	for _, c := range collectors {
+		fs, err := c.Collect()
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, fs...)
+	}
+	sort.SliceStable(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families, nil
+}
+
+// WriteTo writes the registry's metrics to w in the text exposition
+// format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	families, err := r.Gather()
+	if err != nil {
+		return 0, err
+	}
+	return Write(w, families)
+}
+
+// ServeHTTP serves the registry's metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	families, err := r.Gather()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	Write(w, families)
+}
+
+// Write writes families to w in the text exposition format.
+func Write(w io.Writer, families []Family) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		if f.Help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escape(f.Help, false))
+		}
+		if f.Type != "" {
+			fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		}
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name + s.Suffix)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i := 0; i+1 < len(s.Labels); i += 2 {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, "%s=\"%s\"", s.Labels[i], escape(s.Labels[i+1], true))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// escape escapes backslashes and line feeds, and double quotes too in
+// label values.
+func escape(s string, quotes bool) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	if quotes {
+		r = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	}
+	return r.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// vec keeps a value for each combination of label values.
+type vec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu   sync.Mutex
+	keys []string
+	// label values by key, the values joined by a zero byte
+	values map[string][]string
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, values: map[string][]string{}}
+}
+
+// key returns the key of the label values, remembering them the first
+// time. It is called with mu held.
+func (v *vec) key(values []string) string {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, given %d values", v.name, v.labels, len(values)))
+	}
+	key := strings.Join(values, "\x00")
+	if _, ok := v.values[key]; !ok {
+		v.values[key] = append([]string{}, values...)
+		v.keys = append(v.keys, key)
+		sort.Strings(v.keys)
+	}
+	return key
+}
+
+// pairs are the labels with the values of key, and extra pairs after.
+func (v *vec) pairs(key string, extra ...string) []string {
+	pairs := make([]string, 0, 2*len(v.labels)+len(extra))
+	for i, value := range v.values[key] {
+		pairs = append(pairs, v.labels[i], value)
+	}
+	return append(pairs, extra...)
+}
+
+// CounterVec counts events by their labels.
+type CounterVec struct {
+	vec
+	counts map[string]float64
+}
+
+// NewCounterVec returns a counter with the label names given.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec: newVec(name, help, labels), counts: map[string]float64{}}
+}
+
+// Inc counts one event with the label values given.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add counts n events with the label values given. Counters only go
+// up, so a negative n is ignored.
+func (c *CounterVec) Add(n float64, values ...string) {
+	if n < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[c.key(values)] += n
+}
+
+// Collect returns the counts.
+func (c *CounterVec) Collect() ([]Family, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, key := range c.keys {
+		f.Samples = append(f.Samples, Sample{Labels: c.pairs(key), Value: c.counts[key]})
+	}
+	return []Family{f}, nil
+}
+
+// HistogramVec counts observations, such as durations, into buckets by
+// their labels.
+type HistogramVec struct {
+	vec
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// NewHistogramVec returns a histogram with the bucket upper bounds and
+// label names given. The +Inf bucket is added.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{
+		vec:     newVec(name, help, labels),
+		buckets: buckets,
+		counts:  map[string][]uint64{},
+		sums:    map[string]float64{},
+		totals:  map[string]uint64{},
+	}
+}
+
+// Observe adds v to the histogram with the label values given.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.key(values)
+	counts, ok := h.counts[key]
+	if !ok {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[key] = counts
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(counts) {
+		counts[i]++
+	}
+	h.sums[key] += v
+	h.totals[key]++
+}
+
+// Collect returns the buckets, counted cumulatively, and the sum and
+// count of the observations.
+func (h *HistogramVec) Collect() ([]Family, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, key := range h.keys {
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += h.counts[key][i]
+			f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: h.pairs(key, "le", formatValue(le)), Value: float64(cumulative)})
+		}
+		f.Samples = append(f.Samples,
+			Sample{Suffix: "_bucket", Labels: h.pairs(key, "le", "+Inf"), Value: float64(h.totals[key])},
+			Sample{Suffix: "_sum", Labels: h.pairs(key), Value: h.sums[key]},
+			Sample{Suffix: "_count", Labels: h.pairs(key), Value: float64(h.totals[key])},
+		)
+	}
+	return []Family{f}, nil
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Registry(t *testing.T) {
+	requests := NewCounterVec("app_requests_total", "Requests served.", "method", "route")
+	requests.Inc("GET", "/books/")
+	requests.Inc("GET", "/books/")
+	requests.Add(3, "POST", `/say/"hi"\`)
+	requests.Add(-1, "GET", "/books/")
+
+	took := NewHistogramVec("app_request_seconds", "Time taken.", []float64{1, 0.1}, "route")
+	took.Observe(0.05, "/books/")
+	took.Observe(0.1, "/books/")
+	took.Observe(0.5, "/books/")
+	took.Observe(7, "/books/")
+
+	r := NewRegistry()
+	r.Register(took, requests, CollectorFunc(func() ([]Family, error) {
+		return []Family{Gauge("app_loans", "Books out\non loan.", 12)}, nil
+	}))
+
+	sb := &strings.Builder{}
+	if _, err := r.WriteTo(sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP app_loans Books out\non loan.
+# TYPE app_loans gauge
+app_loans 12
+# HELP app_request_seconds Time taken.
+# TYPE app_request_seconds histogram
+app_request_seconds_bucket{route="/books/",le="0.1"} 2
+app_request_seconds_bucket{route="/books/",le="1"} 3
+app_request_seconds_bucket{route="/books/",le="+Inf"} 4
+app_request_seconds_sum{route="/books/"} 7.65
+app_request_seconds_count{route="/books/"} 4
+# HELP app_requests_total Requests served.
+# TYPE app_requests_total counter
+app_requests_total{method="GET",route="/books/"} 2
+app_requests_total{method="POST",route="/say/\"hi\"\\"} 3
+`
+	if got := sb.String(); got != want {
+		t.Errorf("metrics written as\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_Registry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Register(CollectorFunc(func() ([]Family, error) {
+		return []Family{Gauge("up", "", 1)}, nil
+	}))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != ContentType || w.Body.String() != "# TYPE up gauge\nup 1\n" {
+		t.Errorf("served %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	r.Register(CollectorFunc(func() ([]Family, error) {
+		return nil, errors.New("no database")
+	}))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("a collector failing served %d", w.Code)
+	}
+}
+
+func Test_CounterVec_labels(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("counting with the wrong number of label values didn't panic")
+		}
+	}()
+	NewCounterVec("app_total", "", "method").Inc("GET", "/")
+}
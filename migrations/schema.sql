@@ -30,14 +30,35 @@ CREATE TABLE `assign_books` (
   `return_date` date DEFAULT NULL,
   `created_at` datetime NOT NULL,
   `updated_at` datetime NOT NULL,
+  `returned_on` date DEFAULT NULL,
+  `renewals` int NOT NULL DEFAULT '0',
+  `fine` decimal(12,2) NOT NULL DEFAULT '0.00',
+  `fine_paid` tinyint(1) NOT NULL DEFAULT '0',
   PRIMARY KEY (`id`),
-  KEY `assign_books_book_id` (`book_id`),
-  KEY `assign_books_customer_id` (`customer_id`),
+  KEY `assign_books_customer_returned_idx` (`customer_id`,`returned_on`),
+  KEY `assign_books_book_returned_idx` (`book_id`,`returned_on`),
   CONSTRAINT `assign_books_book_id` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
   CONSTRAINT `assign_books_customer_id` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `authors`
+--
+
+DROP TABLE IF EXISTS `authors`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `authors` (
+  `id` char(36) NOT NULL,
+  `name` varchar(150) NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `authors_name_idx` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `blogs`
 --
@@ -56,6 +77,72 @@ CREATE TABLE `blogs` (
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `book_authors`
+--
+
+DROP TABLE IF EXISTS `book_authors`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `book_authors` (
+  `id` char(36) NOT NULL,
+  `book_id` char(36) NOT NULL,
+  `author_id` char(36) NOT NULL,
+  `position` int NOT NULL DEFAULT '0',
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `book_authors_book_author_idx` (`book_id`,`author_id`),
+  KEY `author_id` (`author_id`),
+  CONSTRAINT `book_authors_ibfk_1` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `book_authors_ibfk_2` FOREIGN KEY (`author_id`) REFERENCES `authors` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `book_publishers`
+--
+
+DROP TABLE IF EXISTS `book_publishers`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `book_publishers` (
+  `id` char(36) NOT NULL,
+  `book_id` char(36) NOT NULL,
+  `publisher_id` char(36) NOT NULL,
+  `position` int NOT NULL DEFAULT '0',
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `book_publishers_book_publisher_idx` (`book_id`,`publisher_id`),
+  KEY `publisher_id` (`publisher_id`),
+  CONSTRAINT `book_publishers_ibfk_1` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `book_publishers_ibfk_2` FOREIGN KEY (`publisher_id`) REFERENCES `publishers` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `book_subjects`
+--
+
+DROP TABLE IF EXISTS `book_subjects`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `book_subjects` (
+  `id` char(36) NOT NULL,
+  `book_id` char(36) NOT NULL,
+  `subject_id` char(36) NOT NULL,
+  `position` int NOT NULL DEFAULT '0',
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `book_subjects_book_subject_idx` (`book_id`,`subject_id`),
+  KEY `subject_id` (`subject_id`),
+  CONSTRAINT `book_subjects_ibfk_1` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `book_subjects_ibfk_2` FOREIGN KEY (`subject_id`) REFERENCES `subjects` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `books`
 --
@@ -68,14 +155,24 @@ CREATE TABLE `books` (
   `category_id` char(36) DEFAULT NULL,
   `title` varchar(150) NOT NULL,
   `book_no` varchar(50) NOT NULL,
-  `author` varchar(50) NOT NULL,
+  `author` varchar(255) NOT NULL,
   `picture_path` varchar(255) NOT NULL,
-  `price` float NOT NULL,
+  `price` decimal(12,2) NOT NULL DEFAULT '0.00',
   `status` int NOT NULL,
   `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `isbn` varchar(20) NOT NULL DEFAULT '',
+  `publisher` varchar(255) NOT NULL DEFAULT '',
+  `published_year` int NOT NULL DEFAULT '0',
+  `description` text,
+  `call_number` varchar(100) NOT NULL DEFAULT '',
+  `call_number_sort` varchar(150) NOT NULL DEFAULT '',
+  `replacement_cost` decimal(12,2) NOT NULL DEFAULT '0.00',
+  `currency` varchar(3) NOT NULL DEFAULT 'USD',
   PRIMARY KEY (`id`),
   KEY `book_categoryi_id` (`category_id`),
+  KEY `books_isbn_idx` (`isbn`),
+  KEY `books_call_number_sort_idx` (`call_number_sort`),
   CONSTRAINT `book_categoryi_id` FOREIGN KEY (`category_id`) REFERENCES `categories` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -93,7 +190,13 @@ CREATE TABLE `categories` (
   `status` int NOT NULL,
   `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-  PRIMARY KEY (`id`)
+  `parent_id` char(36) DEFAULT NULL,
+  `path` varchar(400) NOT NULL DEFAULT '',
+  `depth` int NOT NULL DEFAULT '0',
+  PRIMARY KEY (`id`),
+  KEY `categories_parent_id_fk` (`parent_id`),
+  KEY `categories_path_idx` (`path`),
+  CONSTRAINT `categories_parent_id_fk` FOREIGN KEY (`parent_id`) REFERENCES `categories` (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
@@ -112,7 +215,45 @@ CREATE TABLE `customers` (
   `address` text,
   `created_at` datetime NOT NULL,
   `updated_at` datetime NOT NULL,
-  PRIMARY KEY (`id`)
+  `card_number` varchar(20) NOT NULL DEFAULT '',
+  `joined_on` date DEFAULT NULL,
+  `expires_on` date DEFAULT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'active',
+  `membership_plan_id` char(36) DEFAULT NULL,
+  `photo_path` varchar(255) NOT NULL DEFAULT '',
+  `password_hash` varchar(255) NOT NULL DEFAULT '',
+  `no_email_notices` tinyint(1) NOT NULL DEFAULT '0',
+  `sms_notices` tinyint(1) NOT NULL DEFAULT '0',
+  `sms_number` varchar(16) NOT NULL DEFAULT '',
+  `sms_opted_out_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `customers_card_number_idx` (`card_number`),
+  KEY `customers_membership_plan_id` (`membership_plan_id`),
+  KEY `customers_sms_number_idx` (`sms_number`),
+  CONSTRAINT `customers_membership_plan_id` FOREIGN KEY (`membership_plan_id`) REFERENCES `membership_plans` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `holds`
+--
+
+DROP TABLE IF EXISTS `holds`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `holds` (
+  `id` char(36) NOT NULL,
+  `customer_id` char(36) NOT NULL,
+  `book_id` char(36) NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'waiting',
+  `ready_until` date DEFAULT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `holds_book_status_idx` (`book_id`,`status`,`created_at`),
+  KEY `holds_customer_status_idx` (`customer_id`,`status`),
+  CONSTRAINT `holds_book_id` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `holds_customer_id` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
@@ -129,12 +270,111 @@ CREATE TABLE `inventories` (
   `qty` int NOT NULL,
   `created_at` datetime NOT NULL,
   `updated_at` datetime NOT NULL,
+  `branch` varchar(100) NOT NULL DEFAULT 'Main',
   PRIMARY KEY (`id`),
-  KEY `invent_book_id` (`book_id`),
+  KEY `inventories_book_branch_idx` (`book_id`,`branch`),
   CONSTRAINT `invent_book_id` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `jobs`
+--
+
+DROP TABLE IF EXISTS `jobs`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `jobs` (
+  `id` char(36) NOT NULL,
+  `queue` varchar(50) NOT NULL DEFAULT 'default',
+  `handler` varchar(100) NOT NULL,
+  `args` text NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'queued',
+  `attempts` int NOT NULL DEFAULT '0',
+  `max_attempts` int NOT NULL DEFAULT '5',
+  `run_at` datetime NOT NULL,
+  `unique_key` varchar(255) DEFAULT NULL,
+  `locked_by` varchar(255) NOT NULL DEFAULT '',
+  `locked_at` datetime DEFAULT NULL,
+  `started_at` datetime DEFAULT NULL,
+  `finished_at` datetime DEFAULT NULL,
+  `last_error` text,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `jobs_unique_key_idx` (`unique_key`),
+  KEY `jobs_status_run_at_idx` (`status`,`run_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `membership_plans`
+--
+
+DROP TABLE IF EXISTS `membership_plans`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `membership_plans` (
+  `id` char(36) NOT NULL,
+  `name` varchar(50) NOT NULL,
+  `max_loans` int NOT NULL DEFAULT '5',
+  `loan_days` int NOT NULL DEFAULT '14',
+  `max_renewals` int NOT NULL DEFAULT '2',
+  `fine_per_day` decimal(12,2) NOT NULL DEFAULT '0.00',
+  `is_default` tinyint(1) NOT NULL DEFAULT '0',
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `membership_plans_name_idx` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `notifications`
+--
+
+DROP TABLE IF EXISTS `notifications`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `notifications` (
+  `id` char(36) NOT NULL,
+  `customer_id` char(36) NOT NULL,
+  `kind` varchar(20) NOT NULL,
+  `about_id` char(36) NOT NULL,
+  `occasion` varchar(40) NOT NULL,
+  `channel` varchar(20) NOT NULL DEFAULT 'email',
+  `recipient` varchar(255) NOT NULL DEFAULT '',
+  `subject` varchar(255) NOT NULL DEFAULT '',
+  `status` varchar(20) NOT NULL,
+  `error` text,
+  `attempts` int NOT NULL DEFAULT '0',
+  `sent_at` datetime DEFAULT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `notifications_once_idx` (`kind`,`about_id`,`occasion`,`channel`),
+  KEY `notifications_customer_idx` (`customer_id`,`created_at`),
+  CONSTRAINT `notifications_customer_id` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `publishers`
+--
+
+DROP TABLE IF EXISTS `publishers`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `publishers` (
+  `id` char(36) NOT NULL,
+  `name` varchar(150) NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `publishers_name_idx` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `schema_migration`
 --
@@ -149,6 +389,93 @@ CREATE TABLE `schema_migration` (
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
+--
+-- Table structure for table `stock_movements`
+--
+
+DROP TABLE IF EXISTS `stock_movements`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `stock_movements` (
+  `id` char(36) NOT NULL,
+  `book_id` char(36) NOT NULL,
+  `branch` varchar(100) NOT NULL,
+  `quantity` int NOT NULL,
+  `reason` varchar(50) NOT NULL,
+  `stocktake_id` char(36) DEFAULT NULL,
+  `user_id` char(36) DEFAULT NULL,
+  `note` varchar(255) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `stock_movements_book_branch_idx` (`book_id`,`branch`),
+  KEY `stock_movements_stocktake_id` (`stocktake_id`),
+  CONSTRAINT `stock_movements_book_id` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `stock_movements_stocktake_id` FOREIGN KEY (`stocktake_id`) REFERENCES `stocktakes` (`id`) ON DELETE SET NULL ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `stocktake_counts`
+--
+
+DROP TABLE IF EXISTS `stocktake_counts`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `stocktake_counts` (
+  `id` char(36) NOT NULL,
+  `stocktake_id` char(36) NOT NULL,
+  `book_id` char(36) NOT NULL,
+  `counted` int NOT NULL DEFAULT '0',
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `stocktake_counts_book_idx` (`stocktake_id`,`book_id`),
+  KEY `stocktake_counts_book_id` (`book_id`),
+  CONSTRAINT `stocktake_counts_book_id` FOREIGN KEY (`book_id`) REFERENCES `books` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `stocktake_counts_stocktake_id` FOREIGN KEY (`stocktake_id`) REFERENCES `stocktakes` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `stocktakes`
+--
+
+DROP TABLE IF EXISTS `stocktakes`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `stocktakes` (
+  `id` char(36) NOT NULL,
+  `branch` varchar(100) NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT 'open',
+  `user_id` char(36) DEFAULT NULL,
+  `notes` text,
+  `adjusted` tinyint(1) NOT NULL DEFAULT '0',
+  `closed_at` datetime DEFAULT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `stocktakes_branch_status_idx` (`branch`,`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Table structure for table `subjects`
+--
+
+DROP TABLE IF EXISTS `subjects`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!50503 SET character_set_client = utf8mb4 */;
+CREATE TABLE `subjects` (
+  `id` char(36) NOT NULL,
+  `name` varchar(150) NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `subjects_name_idx` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
 --
 -- Table structure for table `users`
 --
@@ -180,4 +507,4 @@ CREATE TABLE `users` (
 /*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
 /*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
 
--- Dump completed on 2023-06-19 15:53:27
+-- Dump completed on 2026-10-19 10:40:00
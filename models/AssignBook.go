@@ -1,13 +1,18 @@
 package models
 
 import (
+	"database/sql"
 	"encoding/json"
 	"time"
 
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gobuffalo/validate/v3/validators"
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"library/money"
 )
 
 // AssignBook is used by pop to map your assign_books database table to your go code.
@@ -19,6 +24,13 @@ type AssignBook struct {
 	ReturnDate string    `json:"return_date" db:"return_date"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+
+	// ReturnDate is when the book is due back; ReturnedOn when it came
+	// back, with the fine for any days late. See AssignBookLoan.go.
+	ReturnedOn nulls.Time  `json:"returned_on" db:"returned_on" form:"-"`
+	Renewals   int         `json:"renewals" db:"renewals" form:"-"`
+	Fine       money.Money `json:"fine" db:"fine" form:"-"`
+	FinePaid   bool        `json:"fine_paid" db:"fine_paid" form:"-"`
 }
 
 // String is not required by pop and may be deleted
@@ -48,9 +60,48 @@ func (a *AssignBook) Validate(tx *pop.Connection) (*validate.Errors, error) {
 }
 
 // ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
-// This method is not required and may be deleted.
+// Books are only lent to customers whose library card is active and who
+// don't owe too much in fines, up to the number of loans their plan
+// allows, and only while a copy is on the shelf that isn't held for
+// someone else; see AssignBookLoan.go and BookCirculation.go.
 func (a *AssignBook) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
-	return validate.NewErrors(), nil
+	verrs := validate.NewErrors()
+	if a.CustomerID == "" || a.BookID == "" {
+		return verrs, nil
+	}
+	now := time.Now()
+	customer := &Customer{}
+	if err := tx.Find(customer, a.CustomerID); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return verrs, err
+		}
+		verrs.Add("customer_id", "Customer does not exist.")
+		return verrs, nil
+	}
+	refusal, err := customer.BorrowingRefusal(tx, now)
+	if err != nil {
+		return verrs, err
+	}
+	if refusal != "" {
+		verrs.Add("customer_id", refusal)
+	}
+
+	book := &Book{}
+	if err := tx.Find(book, a.BookID); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return verrs, err
+		}
+		verrs.Add("book_id", "Book does not exist.")
+		return verrs, nil
+	}
+	refusal, err = book.LendingRefusal(tx, *customer, now)
+	if err != nil {
+		return verrs, err
+	}
+	if refusal != "" {
+		verrs.Add("book_id", refusal)
+	}
+	return verrs, nil
 }
 
 // ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
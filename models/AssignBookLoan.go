@@ -0,0 +1,182 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// loanDate reads a date column held in a string, which comes back from
+// MySQL as a timestamp such as 2026-10-19T00:00:00Z.
+func loanDate(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", dateColumn(s))
+	return t, errors.WithStack(err)
+}
+
+// dateColumn cuts such a timestamp back to the date MySQL takes.
+func dateColumn(s string) string {
+	if len(s) > 10 {
+		return s[:10]
+	}
+	return s
+}
+
+// BeforeValidate lends books from today, for as long as the customer's
+// plan allows, unless the dates were given.
+func (a *AssignBook) BeforeValidate(tx *pop.Connection) error {
+	if a.AssignDate == "" {
+		a.AssignDate = time.Now().Format("2006-01-02")
+	}
+	if a.ReturnDate != "" || a.CustomerID == "" {
+		return nil
+	}
+	customer := &Customer{}
+	if err := tx.Find(customer, a.CustomerID); err != nil {
+		// left for ValidateCreate to report
+		return nil
+	}
+	plan, err := customer.MembershipPlan(tx)
+	if err != nil {
+		return err
+	}
+	assigned, err := loanDate(a.AssignDate)
+	if err != nil {
+		return nil
+	}
+	a.ReturnDate = assigned.AddDate(0, 0, plan.LoanDays).Format("2006-01-02")
+	return nil
+}
+
+// BeforeSave writes the dates back as MySQL takes them and puts the
+// fine in the library's currency, which loans don't keep a column for.
+func (a *AssignBook) BeforeSave(tx *pop.Connection) error {
+	a.AssignDate = dateColumn(a.AssignDate)
+	a.ReturnDate = dateColumn(a.ReturnDate)
+	a.Fine.Currency = money.DefaultCurrency
+	return nil
+}
+
+// AfterFind does the same for loans read from the database.
+func (a *AssignBook) AfterFind(tx *pop.Connection) error {
+	a.Fine.Currency = money.DefaultCurrency
+	return nil
+}
+
+// BorrowingRefusal explains why the customer may not borrow another
+// book on the day of now: their card isn't in good standing, they owe
+// too much in fines or have as many books out as their plan allows.
+// It returns "" when they may.
+func (c Customer) BorrowingRefusal(tx *pop.Connection, now time.Time) (string, error) {
+	if refusal := c.LoanRefusal(now); refusal != "" {
+		return refusal, nil
+	}
+	if refusal, err := c.fineRefusal(tx, now); err != nil || refusal != "" {
+		return refusal, err
+	}
+	plan, err := c.MembershipPlan(tx)
+	if err != nil {
+		return "", err
+	}
+	open, err := c.OpenLoans(tx)
+	if err != nil {
+		return "", err
+	}
+	if open >= plan.MaxLoans {
+		return fmt.Sprintf("%s already has %d books on loan, the most the %s plan allows.", c.Name, open, plan.Name), nil
+	}
+	return "", nil
+}
+
+// DaysLate counts the days past the due date the book was returned,
+// or is still out on the day of now.
+func (a AssignBook) DaysLate(now time.Time) int {
+	due, err := loanDate(a.ReturnDate)
+	if err != nil {
+		return 0
+	}
+	until := dateOf(now)
+	if a.ReturnedOn.Valid {
+		until = dateOf(a.ReturnedOn.Time)
+	}
+	if days := int(until.Sub(due).Hours() / 24); days > 0 {
+		return days
+	}
+	return 0
+}
+
+// Renew lends the book again for the loan period of the customer's
+// plan, from the day of now, as long as the customer may still borrow,
+// the plan allows another renewal and no other customer is waiting for
+// the book.
+func (a *AssignBook) Renew(tx *pop.Connection, now time.Time) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if a.ReturnedOn.Valid {
+		verrs.Add("return_date", "The book has already been returned.")
+		return verrs, nil
+	}
+	customer := &Customer{}
+	if err := tx.Find(customer, a.CustomerID); err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	if refusal := customer.LoanRefusal(now); refusal != "" {
+		verrs.Add("customer_id", refusal)
+		return verrs, nil
+	}
+	plan, err := customer.MembershipPlan(tx)
+	if err != nil {
+		return verrs, err
+	}
+	if a.Renewals >= plan.MaxRenewals {
+		verrs.Add("renewals", fmt.Sprintf("The loan has been renewed %d times, the most the %s plan allows.", a.Renewals, plan.Name))
+		return verrs, nil
+	}
+	book := &Book{}
+	if err := tx.Find(book, a.BookID); err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	held, err := book.HeldFor(tx, *customer, now)
+	if err != nil {
+		return verrs, err
+	}
+	available, err := book.Available(tx)
+	if err != nil {
+		return verrs, err
+	}
+	if held > available {
+		verrs.Add("book_id", fmt.Sprintf("%s is on hold for another customer.", book.Title))
+		return verrs, nil
+	}
+
+	due := dateOf(now).AddDate(0, 0, plan.LoanDays)
+	if current, err := loanDate(a.ReturnDate); err == nil && current.After(due) {
+		due = current
+	}
+	a.ReturnDate = due.Format("2006-01-02")
+	a.Renewals++
+	return tx.ValidateAndUpdate(a)
+}
+
+// Return checks the book back in on the day of now, charging the fine
+// of the customer's plan for every day it is late.
+func (a *AssignBook) Return(tx *pop.Connection, now time.Time) error {
+	if a.ReturnedOn.Valid {
+		return nil
+	}
+	customer := &Customer{}
+	if err := tx.Find(customer, a.CustomerID); err != nil {
+		return errors.WithStack(err)
+	}
+	plan, err := customer.MembershipPlan(tx)
+	if err != nil {
+		return err
+	}
+	a.ReturnedOn = nulls.NewTime(dateOf(now))
+	a.Fine = plan.Fine(a.DaysLate(now))
+	return errors.WithStack(tx.Update(a))
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_AssignBook_PlanLimits() {
+	plan := ms.createPlan("child", 1, 1, true)
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555"}
+	ms.NoError(ms.DB.Create(customer))
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	lend := func(bookNo string) (*AssignBook, []string) {
+		book := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(100, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(book))
+		loan := &AssignBook{CustomerID: customer.ID.String(), BookID: book.ID.String()}
+		verrs, err := ms.DB.ValidateAndCreate(loan)
+		ms.NoError(err)
+		return loan, verrs.Get("customer_id")
+	}
+
+	loan, refused := lend("A")
+	ms.Empty(refused)
+	today := time.Now().Format("2006-01-02")
+	ms.Equal(today, loan.AssignDate)
+	ms.Equal(time.Now().AddDate(0, 0, plan.LoanDays).Format("2006-01-02"), loan.ReturnDate)
+
+	_, refused = lend("B")
+	ms.Equal([]string{"Ada already has 1 books on loan, the most the child plan allows."}, refused)
+
+	ms.NoError(ms.DB.Find(loan, loan.ID))
+	verrs, err := loan.Renew(ms.DB, time.Now())
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.Equal(1, loan.Renewals)
+	verrs, err = loan.Renew(ms.DB, time.Now())
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get("renewals"))
+
+	// returned three days late
+	due, _ := loanDate(loan.ReturnDate)
+	ms.NoError(loan.Return(ms.DB, due.AddDate(0, 0, 3)))
+	ms.Equal(3, loan.DaysLate(time.Now()))
+	ms.Equal(money.New(75, money.DefaultCurrency), loan.Fine)
+
+	_, refused = lend("C")
+	ms.Empty(refused)
+}
+
+func (ms *ModelSuite) Test_MembershipPlan_Default() {
+	adult := ms.createPlan("adult", 5, 2, true)
+	staff := ms.createPlan("staff", 20, 5, true)
+
+	plan, err := DefaultMembershipPlan(ms.DB)
+	ms.NoError(err)
+	ms.Equal(staff.ID, plan.ID)
+	ms.NoError(ms.DB.Reload(adult))
+	ms.False(adult.IsDefault)
+
+	verrs, err := ms.DB.ValidateAndCreate(&MembershipPlan{Name: "adult", MaxLoans: 0, LoanDays: 14})
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get("name"))
+	ms.NotEmpty(verrs.Get("max_loans"))
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Author is used by pop to map your authors database table to your go code.
+// Books are linked to authors through the book_authors table.
+type Author struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (a Author) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// Authors is not required by pop and may be deleted
+type Authors []Author
+
+// String is not required by pop and may be deleted
+func (a Authors) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// Names returns the names of the authors in order.
+func (a Authors) Names() []string {
+	names := make([]string, len(a))
+	for i, x := range a {
+		names[i] = x.Name
+	}
+	return names
+}
+
+func (a *Author) setName(name string) {
+	a.Name = name
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (a *Author) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: a.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: a.Name, Name: "Name", Max: 150},
+		&validators.FuncValidator{
+			Field:   a.Name,
+			Name:    "Name",
+			Message: "%s is already used by another author",
+			Fn: func() bool {
+				taken, err := tx.Where("name = ? AND id <> ?", a.Name, a.ID).Exists(&Author{})
+				return err == nil && !taken
+			},
+		},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (a *Author) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (a *Author) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
@@ -2,19 +2,20 @@ package models
 
 import (
 	"encoding/json"
-	"io"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gobuffalo/buffalo/binding"
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gobuffalo/validate/v3/validators"
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
+
+	"library/money"
+	"library/upload"
 )
 
 // Book is used by pop to map your books database table to your go code.
@@ -23,15 +24,39 @@ type Book struct {
 	CategoryID  string       `json:"category_id" db:"category_id"`
 	Title       string       `json:"title" db:"title"`
 	BookNo      string       `json:"book_no" db:"book_no"`
+	ISBN        string       `json:"isbn" db:"isbn"`
 	Author      string       `json:"author" db:"author"`
+	Publisher   string       `json:"publisher" db:"publisher"`
+	Year        int          `json:"published_year" db:"published_year"`
+	Description nulls.String `json:"description" db:"description"`
+	UseCover    bool         `json:"-" db:"-" form:"UseCover"`
 	Picture     binding.File `db:"-" form:"picture"`
-	PicturePath string       `json:"picture_path" db:"picture_path"`
-	Price       string       `json:"price" db:"price"`
+	PicturePath string       `json:"picture_path" db:"picture_path" form:"-"`
+	Price       money.Money  `json:"price" db:"price"`
 	Status      int          `json:"status" db:"status"`
 	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
 	Category    *Category    `belongs_to:"categories"`
 	Inventory   Inventory    `has_one:"inventories" fk_id:"book_id"`
+
+	// CallNumber is a Dewey or LC call number and CallNumberSort the key
+	// that puts it in shelf order; see BookCallNumber.go.
+	CallNumber     string `json:"call_number" db:"call_number"`
+	CallNumberSort string `json:"-" db:"call_number_sort" form:"-"`
+
+	// ReplacementCost is charged when the book is lost. Both amounts are
+	// in Currency; see BookMoney.go.
+	ReplacementCost money.Money `json:"replacement_cost" db:"replacement_cost"`
+	Currency        string      `json:"currency" db:"currency"`
+
+	// Authors, publishers and subjects are linked through join tables;
+	// see BookCredits.go. The name lists are what forms and imports set.
+	AuthorNames    []string   `json:"author_names,omitempty" db:"-" form:"AuthorNames"`
+	PublisherNames []string   `json:"publisher_names,omitempty" db:"-" form:"PublisherNames"`
+	SubjectNames   []string   `json:"subject_names,omitempty" db:"-" form:"SubjectNames"`
+	Authors        Authors    `json:"authors,omitempty" db:"-" form:"-"`
+	Publishers     Publishers `json:"publishers,omitempty" db:"-" form:"-"`
+	Subjects       Subjects   `json:"subjects,omitempty" db:"-" form:"-"`
 }
 
 // String is not required by pop and may be deleted
@@ -48,95 +73,92 @@ func (b Books) String() string {
 	jb, _ := json.Marshal(b)
 	return string(jb)
 }
-func (b *Book) Create(tx *pop.Connection) (*validate.Errors, error) {
-	// Create the directory for storing book pictures
-
-	// uuid := uuid.New()
-	// Generate a unique filename for the picture
-	if b.Picture.Valid() {
-		dir := filepath.Join(".", "uploads", "books")
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
-
-		currentTime := time.Now()
-		// Convert the current time to a numerical representation
-		timeNumber := currentTime.Unix()
-		ext := filepath.Ext(b.Picture.Filename)
-		filename := strconv.FormatInt(timeNumber, 10) + ext
-		filePath := filepath.Join(dir, filename)
-
-		// Create the file and copy the picture data
-		file, err := os.Create(filePath)
-		if err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
-		defer file.Close()
-
-		_, err = io.Copy(file, b.Picture)
-		if err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
 
-		// Set the PicturePath field of the Book struct
-		b.PicturePath = "/" + filePath
+// Create stores the uploaded cover, if any, then validates and creates
+// the book. A cover that isn't an acceptable image is a validation error.
+func (b *Book) Create(tx *pop.Connection) (*validate.Errors, error) {
+	if verrs, err := b.savePicture(tx); err != nil || verrs.HasAny() {
+		return verrs, err
 	}
-	// Validate and create the book record in the database
 	verrs, err := tx.ValidateAndCreate(b)
 	if err != nil {
 		return verrs, errors.WithStack(err)
 	}
-
 	return verrs, nil
 }
 
+// Update stores the uploaded cover, if any, then validates and updates
+// the book. The cover it replaces is left in place: it is only safe to
+// remove once the update has committed.
 func (b *Book) Update(tx *pop.Connection) (*validate.Errors, error) {
-	// Create the directory for storing book pictures
-
-	// uuid := uuid.New()
-	// Generate a unique filename for the picture
-	if b.Picture.Valid() {
-		dir := filepath.Join(".", "uploads", "books")
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
-		currentTime := time.Now()
-		// Convert the current time to a numerical representation
-		timeNumber := currentTime.Unix()
-		ext := filepath.Ext(b.Picture.Filename)
-		filename := strconv.FormatInt(timeNumber, 10) + ext
-		filePath := filepath.Join(dir, filename)
-
-		// Create the file and copy the picture data
-		file, err := os.Create(filePath)
-		if err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
-		defer file.Close()
-
-		_, err = io.Copy(file, b.Picture)
-		if err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
-		if b.PicturePath != "" && b.PicturePath != "/"+filePath {
-			picturePath := strings.TrimLeft(b.PicturePath, "/")
-			if err := os.Remove(picturePath); err != nil {
-				return validate.NewErrors(), errors.WithStack(err)
-			}
-		}
-
-		// Set the PicturePath field of the Book struct
-		b.PicturePath = "/" + filePath
+	if verrs, err := b.savePicture(tx); err != nil || verrs.HasAny() {
+		return verrs, err
 	}
-	// Validate and create the book record in the database
 	verrs, err := tx.ValidateAndUpdate(b)
 	if err != nil {
 		return verrs, errors.WithStack(err)
 	}
-
 	return verrs, nil
 }
 
+// savePicture stores the uploaded cover and points PicturePath at it.
+func (b *Book) savePicture(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if !b.Picture.Valid() {
+		return verrs, nil
+	}
+	path, err := saveUpload(tx.Context(), verrs, "picture", "books", b.Picture, CoverUpload)
+	if path != "" {
+		b.PicturePath = path
+	}
+	return verrs, err
+}
+
+// PictureThumbnail returns the path of the cover's thumbnail, or of the
+// cover itself when it was uploaded before thumbnails were made.
+func (b Book) PictureThumbnail() string {
+	return upload.Thumbnail(b.PicturePath)
+}
+
+// NormalizeISBN strips hyphens, spaces and trailing qualifiers such as
+// "(pbk.)" from an ISBN so it can be compared against stored values.
+func NormalizeISBN(s string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		case r == '-' || r == ' ':
+			// separators are not significant
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+// BeforeValidate fills in the names and the credit lines from each
+// other, since forms send names while imports and older API clients only
+// set Author and Publisher. It also normalises the call number and puts
+// the amounts in the book's currency; see BookCredits.go,
+// BookCallNumber.go and BookMoney.go.
+func (b *Book) BeforeValidate(tx *pop.Connection) error {
+	b.applyCredits()
+	b.applyCallNumber()
+	b.applyMoney()
+	return nil
+}
+
+// BeforeSave does the same for books saved without validation.
+func (b *Book) BeforeSave(tx *pop.Connection) error {
+	b.applyCredits()
+	b.applyCallNumber()
+	b.applyMoney()
+	return nil
+}
+
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
 func (b *Book) Validate(tx *pop.Connection) (*validate.Errors, error) {
@@ -144,9 +166,40 @@ func (b *Book) Validate(tx *pop.Connection) (*validate.Errors, error) {
 		&validators.StringIsPresent{Field: b.Title, Name: "Title"},
 		&validators.StringIsPresent{Field: b.CategoryID, Name: "CategoryID"},
 		&validators.StringIsPresent{Field: b.BookNo, Name: "BookNo"},
-		&validators.StringIsPresent{Field: b.Author, Name: "Author"},
-		&validators.StringIsPresent{Field: b.Price, Name: "Price"},
+		&validators.StringIsPresent{Field: b.Author, Name: "AuthorNames", Message: "Author can not be blank."},
+		&validators.FuncValidator{
+			Field:   "Price",
+			Name:    "Price",
+			Message: "%s must be an amount of zero or more.",
+			Fn:      func() bool { return validAmount(b.Price) },
+		},
+		&validators.FuncValidator{
+			Field:   "Replacement cost",
+			Name:    "ReplacementCost",
+			Message: "%s must be an amount of zero or more.",
+			Fn:      func() bool { return validAmount(b.ReplacementCost) },
+		},
+		&validators.FuncValidator{
+			Field:   b.Currency,
+			Name:    "Currency",
+			Message: "%s is not a supported currency",
+			Fn:      func() bool { return money.KnownCurrency(b.Currency) },
+		},
 		// &validators.IntIsPresent{Field: b.Status, Name: "Status"},
+		&validators.FuncValidator{
+			Field:   strconv.Itoa(b.Year),
+			Name:    "Year",
+			Message: "%s is not a valid publication year",
+			Fn: func() bool {
+				return b.Year == 0 || (b.Year >= 1400 && b.Year <= time.Now().Year()+1)
+			},
+		},
+		&validators.FuncValidator{
+			Field:   b.CallNumber,
+			Name:    "CallNumber",
+			Message: "%s is not a Dewey Decimal or Library of Congress call number",
+			Fn:      b.validCallNumber,
+		},
 	), nil
 }
 
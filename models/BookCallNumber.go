@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/callnumber"
+)
+
+// applyCallNumber writes the call number in its usual form and works
+// out its shelf order key. Call numbers that don't parse are kept as
+// typed, without a key, for Validate to report.
+func (b *Book) applyCallNumber() {
+	b.CallNumber = strings.TrimSpace(b.CallNumber)
+	b.CallNumberSort = ""
+	if b.CallNumber == "" {
+		return
+	}
+	if cn, err := callnumber.Parse(b.CallNumber); err == nil {
+		b.CallNumber = cn.String()
+		b.CallNumberSort = cn.SortKey()
+	}
+}
+
+// validCallNumber reports whether the call number is blank or parses.
+func (b *Book) validCallNumber() bool {
+	if b.CallNumber == "" {
+		return true
+	}
+	_, err := callnumber.Parse(b.CallNumber)
+	return err == nil
+}
+
+// ShelfNeighbours returns up to n books shelved right before and right
+// after this one, both in shelf order. Books without a call number
+// aren't on the shelf.
+func (b *Book) ShelfNeighbours(tx *pop.Connection, n int) (Books, Books, error) {
+	before, after := Books{}, Books{}
+	if b.CallNumberSort == "" {
+		return before, after, nil
+	}
+	key := b.CallNumberSort
+
+	err := tx.Where("books.call_number_sort <> '' AND (books.call_number_sort < ? OR (books.call_number_sort = ? AND books.id < ?))", key, key, b.ID).
+		Order("books.call_number_sort desc, books.id desc").Limit(n).All(&before)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+
+	err = tx.Where("books.call_number_sort > ? OR (books.call_number_sort = ? AND books.id > ?)", key, key, b.ID).
+		Order("books.call_number_sort, books.id").Limit(n).All(&after)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return before, after, nil
+}
+
+// ShelfRange limits q to the books shelved from one call number up to
+// and including another, in shelf order. Either end may be blank; a
+// call number that doesn't parse is reported as an error. "823" as the
+// end takes in every book under 823, such as 823.914 ROW.
+func ShelfRange(q *pop.Query, from, to string) (*pop.Query, error) {
+	q = q.Where("books.call_number_sort <> ''")
+	if from != "" {
+		cn, err := callnumber.Parse(from)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q", from)
+		}
+		q = q.Where("books.call_number_sort >= ?", cn.SortKey())
+	}
+	if to != "" {
+		cn, err := callnumber.Parse(to)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q", to)
+		}
+		q = q.Where("books.call_number_sort < ?", cn.SortKey()+"~")
+	}
+	return q.Order("books.call_number_sort, books.id"), nil
+}
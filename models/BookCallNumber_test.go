@@ -0,0 +1,65 @@
+package models
+
+import (
+	"library/marc"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_Book_CallNumber() {
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+
+	shelve := func(bookNo, callNumber string) *Book {
+		book := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(100, "USD"), Status: 1, CallNumber: callNumber}
+		verrs, err := ms.DB.ValidateAndCreate(book)
+		ms.NoError(err)
+		ms.False(verrs.HasAny(), verrs.Error())
+		return book
+	}
+	c := shelve("C", "823.914 row 2001")
+	a := shelve("A", "005.133 PYT")
+	d := shelve("D", "823.914 ROW 2001 v.10")
+	b := shelve("B", "823 AUS")
+	shelve("E", "")
+
+	ms.Equal("823.914 ROW 2001", c.CallNumber)
+
+	before, after, err := c.ShelfNeighbours(ms.DB, 5)
+	ms.NoError(err)
+	ms.Equal([]string{a.BookNo, b.BookNo}, []string{before[0].BookNo, before[1].BookNo})
+	ms.Len(after, 1)
+	ms.Equal(d.BookNo, after[0].BookNo)
+
+	// "823" runs to the end of 823, decimals included
+	q, err := ShelfRange(ms.DB.Q(), "800", "823")
+	ms.NoError(err)
+	books := Books{}
+	ms.NoError(q.All(&books))
+	ms.Equal([]string{b.BookNo, c.BookNo, d.BookNo}, []string{books[0].BookNo, books[1].BookNo, books[2].BookNo})
+
+	q, err = ShelfRange(ms.DB.Q(), "", "823.8")
+	ms.NoError(err)
+	books = Books{}
+	ms.NoError(q.All(&books))
+	ms.Len(books, 2)
+
+	_, err = ShelfRange(ms.DB.Q(), "fiction", "")
+	ms.Error(err)
+
+	invalid := &Book{CategoryID: category.ID.String(), Title: "X", BookNo: "X", Author: "Someone", Price: money.New(100, "USD"), CallNumber: "FIC ROW"}
+	verrs, err := ms.DB.ValidateAndCreate(invalid)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+	ms.NotEmpty(verrs.Get("call_number"))
+}
+
+func (ms *ModelSuite) Test_BookFromMARC_CallNumber() {
+	rec := marcRecord("ocm1", "9780140449136", "Crime and punishment", "Dostoyevsky, Fyodor", "Fiction")
+	rec.DataFields = append(rec.DataFields,
+		marc.DataField{Tag: "050", Subfields: []marc.Subfield{{Code: "a", Value: "PG3326"}, {Code: "b", Value: ".P7 2003"}}},
+		marc.DataField{Tag: "082", Subfields: []marc.Subfield{{Code: "a", Value: "891.73/3"}, {Code: "b", Value: "D724c"}}},
+	)
+	book, _ := BookFromMARC(rec)
+	ms.Equal("891.733 D724C", book.CallNumber)
+}
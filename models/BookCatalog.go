@@ -0,0 +1,116 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+// Slug is the title of the book made fit for a URL, as in
+// "the-left-hand-of-darkness". It is only there for readers and search
+// engines; books are found by id.
+func (b Book) Slug() string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(b.Title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		case r == '\'' || r == '’':
+			// "Don't" is "dont", not "don-t"
+		default:
+			dash = true
+		}
+		if sb.Len() >= 80 {
+			break
+		}
+	}
+	if sb.Len() == 0 {
+		return "book"
+	}
+	return sb.String()
+}
+
+// Availability is how many copies of a book the library has and how
+// many are out, without saying who has them.
+type Availability struct {
+	Copies int
+	OnLoan int
+	// DueBack is the earliest date a copy out is due back.
+	DueBack string
+}
+
+// Available counts the copies on the shelf.
+func (a Availability) Available() int {
+	if a.Copies < a.OnLoan {
+		return 0
+	}
+	return a.Copies - a.OnLoan
+}
+
+// BookAvailability counts the copies of each of the books and those out
+// on loan, as Copies and OnLoan do for one book, keyed by book id.
+func BookAvailability(tx *pop.Connection, books Books) (map[string]Availability, error) {
+	availability := map[string]Availability{}
+	if len(books) == 0 {
+		return availability, nil
+	}
+	args := make([]interface{}, len(books))
+	for i, b := range books {
+		args[i] = b.ID
+		availability[b.ID.String()] = Availability{Copies: 1}
+	}
+	in := "(" + strings.TrimSuffix(strings.Repeat("?,", len(books)), ",") + ")"
+
+	var stock []struct {
+		BookID string `db:"book_id"`
+		Qty    int    `db:"qty"`
+	}
+	if err := tx.RawQuery("SELECT book_id, COALESCE(SUM(qty), 0) AS qty FROM inventories WHERE book_id IN "+in+" GROUP BY book_id", args...).All(&stock); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, s := range stock {
+		a := availability[s.BookID]
+		a.Copies = s.Qty
+		availability[s.BookID] = a
+	}
+
+	var loans []struct {
+		BookID  string `db:"book_id"`
+		OnLoan  int    `db:"on_loan"`
+		DueBack string `db:"due_back"`
+	}
+	if err := tx.RawQuery("SELECT book_id, COUNT(*) AS on_loan, MIN(return_date) AS due_back FROM assign_books WHERE returned_on IS NULL AND book_id IN "+in+" GROUP BY book_id", args...).All(&loans); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, l := range loans {
+		a := availability[l.BookID]
+		a.OnLoan, a.DueBack = l.OnLoan, dateColumn(l.DueBack)
+		availability[l.BookID] = a
+	}
+	return availability, nil
+}
+
+// CatalogVersion tells when what the public catalog shows last changed:
+// the latest change to books, categories, stock and loans, and how many
+// books there are, which changes when one is deleted.
+func CatalogVersion(tx *pop.Connection) (time.Time, int, error) {
+	version := struct {
+		Modified time.Time `db:"modified"`
+		Books    int       `db:"books"`
+	}{}
+	err := tx.RawQuery(`SELECT CAST(GREATEST(
+		COALESCE((SELECT MAX(updated_at) FROM books), TIMESTAMP('1970-01-01')),
+		COALESCE((SELECT MAX(updated_at) FROM categories), TIMESTAMP('1970-01-01')),
+		COALESCE((SELECT MAX(updated_at) FROM inventories), TIMESTAMP('1970-01-01')),
+		COALESCE((SELECT MAX(updated_at) FROM assign_books), TIMESTAMP('1970-01-01'))) AS DATETIME) AS modified,
+		(SELECT COUNT(*) FROM books) AS books`).First(&version)
+	return version.Modified, version.Books, errors.WithStack(err)
+}
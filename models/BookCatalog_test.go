@@ -0,0 +1,50 @@
+package models
+
+import (
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_Book_Slug() {
+	for title, slug := range map[string]string{
+		"The Left Hand of Darkness": "the-left-hand-of-darkness",
+		"Don't Panic!":              "dont-panic",
+		"  1984 ":                   "1984",
+		"Die Blechtrommel: Roman":   "die-blechtrommel-roman",
+		"Ærø – Øst":                 "ærø-øst",
+		"?!":                        "book",
+	} {
+		ms.Equal(slug, Book{Title: title}.Slug(), title)
+	}
+}
+
+func (ms *ModelSuite) Test_BookAvailability() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := func(bookNo string) Book {
+		b := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(100, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(b))
+		return *b
+	}
+	counted, uncounted := book("A"), book("B")
+	ms.NoError(ms.DB.Create(&Inventory{BookID: counted.ID.String(), Qty: 3}))
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555"}
+	ms.NoError(ms.DB.Create(customer))
+	loan := &AssignBook{CustomerID: customer.ID.String(), BookID: counted.ID.String()}
+	verrs, err := ms.DB.ValidateAndCreate(loan)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+
+	availability, err := BookAvailability(ms.DB, Books{counted, uncounted})
+	ms.NoError(err)
+	a := availability[counted.ID.String()]
+	ms.Equal(3, a.Copies)
+	ms.Equal(2, a.Available())
+	ms.Equal(dateColumn(loan.ReturnDate), a.DueBack)
+	ms.Equal(Availability{Copies: 1}, availability[uncounted.ID.String()])
+
+	modified, books, err := CatalogVersion(ms.DB)
+	ms.NoError(err)
+	ms.Equal(2, books)
+	ms.False(modified.IsZero())
+}
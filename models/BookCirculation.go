@@ -0,0 +1,185 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Copies counts the copies of the book in stock. Books that were never
+// counted into the inventory are taken to be a single copy.
+func (b Book) Copies(tx *pop.Connection) (int, error) {
+	stock := struct {
+		Entries int `db:"entries"`
+		Qty     int `db:"qty"`
+	}{}
+	err := tx.RawQuery("SELECT COUNT(*) AS entries, COALESCE(SUM(qty), 0) AS qty FROM inventories WHERE book_id = ?", b.ID).First(&stock)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if stock.Entries == 0 {
+		return 1, nil
+	}
+	return stock.Qty, nil
+}
+
+// OnLoan counts the copies of the book lent and not yet returned.
+func (b Book) OnLoan(tx *pop.Connection) (int, error) {
+	n, err := tx.Where("book_id = ? AND returned_on IS NULL", b.ID).Count(&AssignBook{})
+	return n, errors.WithStack(err)
+}
+
+// Available counts the copies of the book on the shelf.
+func (b Book) Available(tx *pop.Connection) (int, error) {
+	copies, err := b.Copies(tx)
+	if err != nil {
+		return 0, err
+	}
+	out, err := b.OnLoan(tx)
+	if err != nil {
+		return 0, err
+	}
+	if copies < out {
+		return 0, nil
+	}
+	return copies - out, nil
+}
+
+// HeldFor counts the holds on the book ahead of the customer's on the
+// day of now: every active hold of other customers placed before the
+// customer's own, or all of them when the customer has none.
+func (b Book) HeldFor(tx *pop.Connection, customer Customer, now time.Time) (int, error) {
+	own := &Hold{}
+	err := activeHolds(tx.Where("book_id = ? AND customer_id = ?", b.ID, customer.ID), now).First(own)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, errors.WithStack(err)
+	}
+	q := activeHolds(tx.Where("book_id = ? AND customer_id <> ?", b.ID, customer.ID), now)
+	if err == nil {
+		q = q.Where("created_at < ?", own.CreatedAt)
+	}
+	n, err := q.Count(&Hold{})
+	return n, errors.WithStack(err)
+}
+
+// LendingRefusal explains why the book can't be lent to the customer on
+// the day of now: it has been withdrawn, the customer already has it,
+// no copy is on the shelf or the copies there are held for customers
+// ahead of them. It returns "" when it can.
+func (b Book) LendingRefusal(tx *pop.Connection, customer Customer, now time.Time) (string, error) {
+	if b.Status != 1 {
+		return fmt.Sprintf("%s has been withdrawn from lending.", b.Title), nil
+	}
+	has, err := tx.Where("book_id = ? AND customer_id = ? AND returned_on IS NULL", b.ID, customer.ID).Exists(&AssignBook{})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if has {
+		return fmt.Sprintf("%s already has %s on loan.", customer.Name, b.Title), nil
+	}
+	available, err := b.Available(tx)
+	if err != nil {
+		return "", err
+	}
+	if available == 0 {
+		return fmt.Sprintf("Every copy of %s is out on loan.", b.Title), nil
+	}
+	held, err := b.HeldFor(tx, customer, now)
+	if err != nil {
+		return "", err
+	}
+	if held >= available {
+		return fmt.Sprintf("%s is on hold for another customer.", b.Title), nil
+	}
+	return "", nil
+}
+
+// HoldRefusal explains why the customer can't place a hold on the book
+// on the day of now: their card isn't in good standing, the book has
+// been withdrawn, they already have it or a copy is on the shelf for
+// them to borrow. It returns "" when they can.
+func (b Book) HoldRefusal(tx *pop.Connection, customer Customer, now time.Time) (string, error) {
+	if refusal := customer.LoanRefusal(now); refusal != "" {
+		return refusal, nil
+	}
+	has, err := tx.Where("book_id = ? AND customer_id = ? AND returned_on IS NULL", b.ID, customer.ID).Exists(&AssignBook{})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if has {
+		return fmt.Sprintf("%s already has %s on loan.", customer.Name, b.Title), nil
+	}
+	refusal, err := b.LendingRefusal(tx, customer, now)
+	if err != nil {
+		return "", err
+	}
+	if b.Status != 1 {
+		return refusal, nil
+	}
+	if refusal == "" {
+		return fmt.Sprintf("A copy of %s is on the shelf; borrow it at the desk.", b.Title), nil
+	}
+	return "", nil
+}
+
+// AfterCreate fulfils the customer's hold on the book they borrowed.
+func (a *AssignBook) AfterCreate(tx *pop.Connection) error {
+	err := tx.RawQuery("UPDATE holds SET status = ?, updated_at = ? WHERE customer_id = ? AND book_id = ? AND status IN (?, ?)",
+		HoldFulfilled, time.Now(), a.CustomerID, a.BookID, HoldWaiting, HoldReady).Exec()
+	return errors.WithStack(err)
+}
+
+// CheckIn returns the copy of the book lent longest ago on the day of
+// now and puts it aside for the next hold in the queue, if any. It
+// returns nil for the loan when no copy is out.
+func (b Book) CheckIn(tx *pop.Connection, now time.Time) (*AssignBook, *Hold, error) {
+	loan := &AssignBook{}
+	if err := tx.Where("book_id = ? AND returned_on IS NULL", b.ID).Order("assign_date, created_at").First(loan); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, errors.WithStack(err)
+	}
+	hold, err := loan.CheckIn(tx, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	return loan, hold, nil
+}
+
+// CheckIn returns the loan on the day of now and puts the copy aside for
+// the next hold on the book, if any. It returns nil for the hold when the
+// loan was already returned or no hold is waiting.
+func (a *AssignBook) CheckIn(tx *pop.Connection, now time.Time) (*Hold, error) {
+	if a.ReturnedOn.Valid {
+		return nil, nil
+	}
+	if err := a.Return(tx, now); err != nil {
+		return nil, err
+	}
+	return Book{ID: uuid.FromStringOrNil(a.BookID)}.readyNextHold(tx, now)
+}
+
+// readyNextHold puts a copy of the book on the shelf aside, from the day
+// of now, for the hold placed first of those waiting. It returns nil
+// when none is.
+func (b Book) readyNextHold(tx *pop.Connection, now time.Time) (*Hold, error) {
+	hold := &Hold{}
+	if err := tx.Where("book_id = ? AND status = ?", b.ID, HoldWaiting).Order("created_at").First(hold); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	hold.Status = HoldReady
+	hold.ReadyUntil = nulls.NewTime(dateOf(now).AddDate(0, 0, HoldShelfDays))
+	if err := tx.Update(hold); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return hold, nil
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_Book_HoldsAndCheckIn() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := &Book{CategoryID: category.ID.String(), Title: "Dune", BookNo: "B1", Author: "Herbert", Price: money.New(100, "USD"), Status: 1}
+	ms.NoError(ms.DB.Create(book))
+	customer := func(name string) Customer {
+		c := &Customer{Name: name, Email: name + "@example.com", Mobile: "555"}
+		verrs, err := ms.DB.ValidateAndCreate(c)
+		ms.NoError(err)
+		ms.False(verrs.HasAny(), verrs.Error())
+		return *c
+	}
+	ada, bob, cy := customer("ada"), customer("bob"), customer("cy")
+	now := time.Now()
+
+	copies, err := book.Copies(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, copies)
+
+	loan := &AssignBook{CustomerID: ada.ID.String(), BookID: book.ID.String()}
+	verrs, err := ms.DB.ValidateAndCreate(loan)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+
+	refusal, err := book.LendingRefusal(ms.DB, ada, now)
+	ms.NoError(err)
+	ms.Equal("ada already has Dune on loan.", refusal)
+	refusal, err = book.LendingRefusal(ms.DB, bob, now)
+	ms.NoError(err)
+	ms.Equal("Every copy of Dune is out on loan.", refusal)
+
+	for _, c := range []Customer{bob, cy} {
+		verrs, err = ms.DB.ValidateAndCreate(&Hold{CustomerID: c.ID.String(), BookID: book.ID.String()})
+		ms.NoError(err)
+		ms.False(verrs.HasAny(), verrs.Error())
+		time.Sleep(time.Second)
+	}
+	verrs, err = ms.DB.ValidateAndCreate(&Hold{CustomerID: bob.ID.String(), BookID: book.ID.String()})
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get("book_id"))
+
+	returned, hold, err := book.CheckIn(ms.DB, now)
+	ms.NoError(err)
+	ms.Equal(loan.ID, returned.ID)
+	ms.True(returned.ReturnedOn.Valid)
+	ms.Equal(bob.ID.String(), hold.CustomerID)
+	ms.Equal(HoldReady, hold.Status)
+
+	// the copy is put aside for bob, ahead of cy
+	refusal, err = book.LendingRefusal(ms.DB, cy, now)
+	ms.NoError(err)
+	ms.Equal("Dune is on hold for another customer.", refusal)
+	refusal, err = book.LendingRefusal(ms.DB, bob, now)
+	ms.NoError(err)
+	ms.Empty(refusal)
+
+	verrs, err = ms.DB.ValidateAndCreate(&AssignBook{CustomerID: bob.ID.String(), BookID: book.ID.String()})
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.NoError(ms.DB.Reload(hold))
+	ms.Equal(HoldFulfilled, hold.Status)
+
+	returned, hold, err = book.CheckIn(ms.DB, now)
+	ms.NoError(err)
+	ms.NotNil(returned)
+	ms.Equal(cy.ID.String(), hold.CustomerID)
+
+	returned, hold, err = book.CheckIn(ms.DB, now)
+	ms.NoError(err)
+	ms.Nil(returned)
+	ms.Nil(hold)
+}
+
+func (ms *ModelSuite) Test_Customer_Fines() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555"}
+	ms.NoError(ms.DB.Create(customer))
+	lend := func(bookNo string) (*AssignBook, []string) {
+		book := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(100, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(book))
+		loan := &AssignBook{CustomerID: customer.ID.String(), BookID: book.ID.String()}
+		verrs, err := ms.DB.ValidateAndCreate(loan)
+		ms.NoError(err)
+		return loan, verrs.Get("customer_id")
+	}
+
+	loan, refused := lend("A")
+	ms.Empty(refused)
+	due, _ := loanDate(loan.ReturnDate)
+
+	// running up 25 a day while out, 30 days late
+	owed, err := customer.FinesOwed(ms.DB, due.AddDate(0, 0, 30))
+	ms.NoError(err)
+	ms.Equal(int64(750), owed.Cents)
+
+	ms.NoError(loan.Return(ms.DB, due.AddDate(0, 0, 30)))
+	owed, err = customer.FinesOwed(ms.DB, time.Now())
+	ms.NoError(err)
+	ms.Equal(int64(750), owed.Cents)
+
+	_, refused = lend("B")
+	ms.Equal([]string{"Ada owes 7.50 USD in fines, more than the 5.00 USD allowed."}, refused)
+
+	ms.NoError(customer.SettleFines(ms.DB))
+	owed, err = customer.FinesOwed(ms.DB, time.Now())
+	ms.NoError(err)
+	ms.True(owed.IsZero())
+
+	_, refused = lend("C")
+	ms.Empty(refused)
+}
+
+func (ms *ModelSuite) Test_ExpireHolds() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := &Book{CategoryID: category.ID.String(), Title: "Dune", BookNo: "B1", Author: "Herbert", Price: money.New(100, "USD"), Status: 1}
+	ms.NoError(ms.DB.Create(book))
+	ada := &Customer{Name: "ada", Email: "ada@example.com", Mobile: "555"}
+	bob := &Customer{Name: "bob", Email: "bob@example.com", Mobile: "555"}
+	ms.NoError(ms.DB.Create(ada))
+	ms.NoError(ms.DB.Create(bob))
+	now := time.Now()
+
+	// ada didn't come for the copy put aside for her; bob is next
+	stale := &Hold{CustomerID: ada.ID.String(), BookID: book.ID.String(), Status: HoldReady, ReadyUntil: nulls.NewTime(now.AddDate(0, 0, -1))}
+	ms.NoError(ms.DB.Create(stale))
+	waiting := &Hold{CustomerID: bob.ID.String(), BookID: book.ID.String()}
+	ms.NoError(ms.DB.Create(waiting))
+
+	expired, readied, err := ExpireHolds(ms.DB, now)
+	ms.NoError(err)
+	ms.Len(expired, 1)
+	ms.Equal(stale.ID, expired[0].ID)
+	ms.Len(readied, 1)
+	ms.Equal(waiting.ID, readied[0].ID)
+	ms.NoError(ms.DB.Reload(stale))
+	ms.Equal(HoldExpired, stale.Status)
+	ms.NoError(ms.DB.Reload(waiting))
+	ms.Equal(HoldReady, waiting.Status)
+	ms.True(waiting.ReadyUntil.Valid)
+
+	// bob's hold waits until its day is over
+	expired, _, err = ExpireHolds(ms.DB, now)
+	ms.NoError(err)
+	ms.Empty(expired)
+}
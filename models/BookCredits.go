@@ -0,0 +1,235 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// creditLineSize is the width of the books.author and books.publisher
+// columns, which keep the names joined for lists and searches.
+const creditLineSize = 255
+
+// SplitCredits splits a credit line such as "Ann Lee; Bob Ray" into
+// names, dropping blanks and repeats.
+func SplitCredits(s string) []string {
+	return cleanNames(strings.Split(s, ";"))
+}
+
+// CreditLine joins names the way they are stored in books.author and
+// books.publisher.
+func CreditLine(names []string) string {
+	return truncate(strings.Join(names, "; "), creditLineSize)
+}
+
+func cleanNames(names []string) []string {
+	seen := map[string]bool{}
+	clean := []string{}
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		clean = append(clean, truncate(name, 150))
+	}
+	return clean
+}
+
+// AfterSave links the book to its authors, publishers and subjects.
+// Links are only rewritten for the lists that were set, so saving a
+// book loaded without its credits leaves them alone.
+func (b *Book) AfterSave(tx *pop.Connection) error {
+	if b.AuthorNames != nil {
+		if err := saveCredits(tx, b.ID, "book_authors", "author_id", b.AuthorNames, func() named { return &Author{} }); err != nil {
+			return err
+		}
+	}
+	if b.PublisherNames != nil {
+		if err := saveCredits(tx, b.ID, "book_publishers", "publisher_id", b.PublisherNames, func() named { return &Publisher{} }); err != nil {
+			return err
+		}
+	}
+	if b.SubjectNames != nil {
+		if err := saveCredits(tx, b.ID, "book_subjects", "subject_id", b.SubjectNames, func() named { return &Subject{} }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Book) applyCredits() {
+	if b.AuthorNames == nil && b.Author != "" {
+		b.AuthorNames = SplitCredits(b.Author)
+	}
+	if b.AuthorNames != nil {
+		b.AuthorNames = cleanNames(b.AuthorNames)
+		b.Author = CreditLine(b.AuthorNames)
+	}
+	if b.PublisherNames == nil && b.Publisher != "" {
+		b.PublisherNames = SplitCredits(b.Publisher)
+	}
+	if b.PublisherNames != nil {
+		b.PublisherNames = cleanNames(b.PublisherNames)
+		b.Publisher = CreditLine(b.PublisherNames)
+	}
+	if b.SubjectNames != nil {
+		b.SubjectNames = cleanNames(b.SubjectNames)
+	}
+}
+
+// LoadCredits loads the authors, publishers and subjects of the book
+// in the order they are credited.
+func (b *Book) LoadCredits(tx *pop.Connection) error {
+	b.Authors = Authors{}
+	if err := tx.Q().Join("book_authors", "book_authors.author_id = authors.id").
+		Where("book_authors.book_id = ?", b.ID).Order("book_authors.position").All(&b.Authors); err != nil {
+		return errors.WithStack(err)
+	}
+	b.Publishers = Publishers{}
+	if err := tx.Q().Join("book_publishers", "book_publishers.publisher_id = publishers.id").
+		Where("book_publishers.book_id = ?", b.ID).Order("book_publishers.position").All(&b.Publishers); err != nil {
+		return errors.WithStack(err)
+	}
+	b.Subjects = Subjects{}
+	if err := tx.Q().Join("book_subjects", "book_subjects.subject_id = subjects.id").
+		Where("book_subjects.book_id = ?", b.ID).Order("book_subjects.position").All(&b.Subjects); err != nil {
+		return errors.WithStack(err)
+	}
+	b.AuthorNames = b.Authors.Names()
+	b.PublisherNames = b.Publishers.Names()
+	b.SubjectNames = b.Subjects.Names()
+	return nil
+}
+
+// named is an Author, Publisher or Subject.
+type named interface {
+	setName(string)
+}
+
+// saveCredits replaces the links of a book in joinTable with links to
+// the records with the given names, creating records that don't exist
+// yet. Names match case-insensitively, following the column collation.
+func saveCredits(tx *pop.Connection, bookID uuid.UUID, joinTable, column string, names []string, newRecord func() named) error {
+	if err := tx.RawQuery("DELETE FROM "+joinTable+" WHERE book_id = ?", bookID).Exec(); err != nil {
+		return errors.WithStack(err)
+	}
+	now := time.Now()
+	for i, name := range names {
+		id, err := findOrCreateNamed(tx, newRecord(), name)
+		if err != nil {
+			return err
+		}
+		linkID, err := uuid.NewV4()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		err = tx.RawQuery("INSERT INTO "+joinTable+" (id, book_id, "+column+", position, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+			linkID, bookID, id, i, now, now).Exec()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func findOrCreateNamed(tx *pop.Connection, rec named, name string) (uuid.UUID, error) {
+	err := tx.Where("name = ?", name).First(rec)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, errors.WithStack(err)
+	}
+	if err != nil {
+		rec.setName(name)
+		verrs, err := tx.ValidateAndCreate(rec)
+		if err != nil {
+			return uuid.Nil, errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			return uuid.Nil, errors.Errorf("%q: %s", name, strings.ReplaceAll(verrs.Error(), "\n", "; "))
+		}
+	}
+	return recordID(rec), nil
+}
+
+func recordID(rec named) uuid.UUID {
+	switch r := rec.(type) {
+	case *Author:
+		return r.ID
+	case *Publisher:
+		return r.ID
+	case *Subject:
+		return r.ID
+	}
+	return uuid.Nil
+}
+
+// AfterUpdate keeps the credit line of the author's books in step with
+// a renamed author.
+func (a *Author) AfterUpdate(tx *pop.Connection) error {
+	return refreshCreditLines(tx, "author", "authors", "book_authors", "author_id", a.ID)
+}
+
+// AfterUpdate keeps the credit line of the publisher's books in step
+// with a renamed publisher.
+func (p *Publisher) AfterUpdate(tx *pop.Connection) error {
+	return refreshCreditLines(tx, "publisher", "publishers", "book_publishers", "publisher_id", p.ID)
+}
+
+// refreshCreditLines rebuilds books.<line> from the linked names for
+// every book linked to the record id.
+func refreshCreditLines(tx *pop.Connection, line, table, joinTable, column string, id uuid.UUID) error {
+	err := tx.RawQuery("UPDATE books SET "+line+" = COALESCE((SELECT LEFT(GROUP_CONCAT("+table+".name ORDER BY "+joinTable+".position SEPARATOR '; '), ?) "+
+		"FROM "+joinTable+" JOIN "+table+" ON "+table+".id = "+joinTable+"."+column+" WHERE "+joinTable+".book_id = books.id), '') "+
+		"WHERE books.id IN (SELECT book_id FROM "+joinTable+" WHERE "+column+" = ?)", creditLineSize, id).Exec()
+	return errors.WithStack(err)
+}
+
+// LoanStats sums up how often a book has been lent.
+type LoanStats struct {
+	BookID    string `json:"book_id" db:"book_id"`
+	Loans     int    `json:"loans" db:"loans"`
+	Borrowers int    `json:"borrowers" db:"borrowers"`
+	LastLoan  string `json:"last_loan" db:"last_loan"`
+}
+
+// CreditedBooks returns the books linked to an author, publisher or
+// subject, by title, with the loan statistics of each book.
+func CreditedBooks(tx *pop.Connection, rec interface{}) (Books, map[string]LoanStats, error) {
+	joinTable, column, id := "", "", uuid.Nil
+	switch r := rec.(type) {
+	case *Author:
+		joinTable, column, id = "book_authors", "author_id", r.ID
+	case *Publisher:
+		joinTable, column, id = "book_publishers", "publisher_id", r.ID
+	case *Subject:
+		joinTable, column, id = "book_subjects", "subject_id", r.ID
+	default:
+		return nil, nil, errors.Errorf("books can't be credited to %T", rec)
+	}
+
+	books := Books{}
+	err := tx.Q().Join(joinTable, joinTable+".book_id = books.id").
+		Where(joinTable+"."+column+" = ?", id).Order("books.title").Eager("Category").All(&books)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	var stats []LoanStats
+	err = tx.RawQuery("SELECT assign_books.book_id, COUNT(*) AS loans, COUNT(DISTINCT assign_books.customer_id) AS borrowers, "+
+		"COALESCE(CAST(MAX(assign_books.assign_date) AS CHAR), '') AS last_loan FROM assign_books "+
+		"JOIN "+joinTable+" ON "+joinTable+".book_id = assign_books.book_id WHERE "+joinTable+"."+column+" = ? "+
+		"GROUP BY assign_books.book_id", id).All(&stats)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	byBook := map[string]LoanStats{}
+	for _, s := range stats {
+		byBook[s.BookID] = s
+	}
+	return books, byBook, nil
+}
@@ -0,0 +1,62 @@
+package models
+
+import "library/money"
+
+func (ms *ModelSuite) Test_SplitCredits() {
+	ms.Equal([]string{"Ann Lee", "Bob Ray"}, SplitCredits(" Ann  Lee ;Bob Ray; ann lee;;"))
+	ms.Equal([]string{}, SplitCredits(""))
+	ms.Equal("Ann Lee; Bob Ray", CreditLine([]string{"Ann Lee", "Bob Ray"}))
+}
+
+func (ms *ModelSuite) Test_Book_Credits() {
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+
+	book := &Book{CategoryID: category.ID.String(), Title: "Good Omens", BookNo: "B-1", Price: money.New(1000, "USD"), Status: 1,
+		AuthorNames: []string{"Terry Pratchett", "Neil Gaiman"}, SubjectNames: []string{"Fantasy"}}
+	verrs, err := ms.DB.ValidateAndCreate(book)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.Equal("Terry Pratchett; Neil Gaiman", book.Author)
+
+	// an import that only sets the credit line reuses the same author
+	other := &Book{CategoryID: category.ID.String(), Title: "Mort", BookNo: "B-2", Price: money.New(800, "USD"), Status: 1, Author: "terry pratchett"}
+	verrs, err = ms.DB.ValidateAndCreate(other)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+
+	count, err := ms.DB.Count(&Authors{})
+	ms.NoError(err)
+	ms.Equal(2, count)
+
+	loaded := &Book{}
+	ms.NoError(ms.DB.Find(loaded, book.ID))
+	ms.NoError(loaded.LoadCredits(ms.DB))
+	ms.Equal([]string{"Terry Pratchett", "Neil Gaiman"}, loaded.AuthorNames)
+	ms.Equal([]string{"Fantasy"}, loaded.SubjectNames)
+	ms.Empty(loaded.PublisherNames)
+
+	// renaming the author rewrites the credit line of both books
+	author := &Author{}
+	ms.NoError(ms.DB.Where("name = ?", "Terry Pratchett").First(author))
+	author.Name = "Sir Terry Pratchett"
+	verrs, err = ms.DB.ValidateAndUpdate(author)
+	ms.NoError(err)
+	ms.False(verrs.HasAny())
+	ms.NoError(ms.DB.Reload(book))
+	ms.Equal("Sir Terry Pratchett; Neil Gaiman", book.Author)
+	ms.NoError(ms.DB.Reload(other))
+	ms.Equal("Sir Terry Pratchett", other.Author)
+
+	books, stats, err := CreditedBooks(ms.DB, author)
+	ms.NoError(err)
+	ms.Len(books, 2)
+	ms.Empty(stats)
+}
+
+func (ms *ModelSuite) Test_Author_NameTaken() {
+	ms.NoError(ms.DB.Create(&Author{Name: "Ann Lee"}))
+	verrs, err := ms.DB.ValidateAndCreate(&Author{Name: "Ann Lee"})
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+}
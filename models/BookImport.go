@@ -0,0 +1,253 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/marc"
+	"library/money"
+)
+
+// Statuses reported for each record of a catalogue import.
+const (
+	BookImportNew       = "new"
+	BookImportDuplicate = "duplicate"
+	BookImportInvalid   = "invalid"
+	BookImportCreated   = "created"
+)
+
+// BookImportRow is the mapping of one imported record to a Book along
+// with what happened (or will happen) to it.
+type BookImportRow struct {
+	Record   int      `json:"record"`
+	Book     Book     `json:"book"`
+	Subjects []string `json:"subjects"`
+	Status   string   `json:"status"`
+	Message  string   `json:"message"`
+}
+
+// BookImportRows is the per-record report of an import.
+type BookImportRows []BookImportRow
+
+// Count returns how many rows have the given status.
+func (rows BookImportRows) Count(status string) int {
+	n := 0
+	for _, row := range rows {
+		if row.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// BookFromMARC maps the fields of a MARC record onto a new Book:
+//
+//	001       control number, used as BookNo
+//	020 $a $c ISBN and price
+//	100/110/700/710 $a authors
+//	245 $a $b title
+//	264/260 $b publisher
+//	082/050 $a $b call number, Dewey first
+//	650 $a    subjects
+//
+// The subject headings are also returned separately so the caller can
+// map them to a category.
+func BookFromMARC(rec marc.Record) (Book, []string) {
+	book := Book{Status: 1}
+
+	for _, f := range rec.Fields("245") {
+		book.Title = truncate(trimISBD(f.Join("ab")), 150)
+		break
+	}
+	for _, tag := range []string{"100", "110", "700", "710"} {
+		for _, a := range rec.Values(tag, "a") {
+			book.AuthorNames = append(book.AuthorNames, trimISBD(a))
+		}
+	}
+	book.AuthorNames = cleanNames(book.AuthorNames)
+	book.Author = CreditLine(book.AuthorNames)
+	for _, tag := range []string{"264", "260"} {
+		if p := rec.Value(tag, "b"); p != "" {
+			book.PublisherNames = cleanNames([]string{trimISBD(p)})
+			book.Publisher = CreditLine(book.PublisherNames)
+			break
+		}
+	}
+	for _, isbn := range rec.Values("020", "a") {
+		if n := NormalizeISBN(isbn); n != "" {
+			book.ISBN = n
+			break
+		}
+	}
+	for _, tag := range []string{"082", "050"} {
+		for _, f := range rec.Fields(tag) {
+			book.CallNumber = strings.TrimSpace(f.Value("a") + " " + f.Value("b"))
+			if book.validCallNumber() {
+				break
+			}
+			book.CallNumber = ""
+		}
+		if book.CallNumber != "" {
+			break
+		}
+	}
+	price, ok := parsePrice(rec.Value("020", "c"))
+	if !ok {
+		price, ok = parsePrice(rec.Value("365", "b") + " " + rec.Value("365", "c"))
+	}
+	if ok {
+		book.Price = price
+		book.Currency = price.Currency
+	}
+	book.BookNo = rec.Control("001")
+	if book.BookNo == "" {
+		book.BookNo = book.ISBN
+	}
+	book.BookNo = truncate(book.BookNo, 50)
+
+	var subjects []string
+	for _, s := range rec.Values("650", "a") {
+		subjects = append(subjects, trimISBD(s))
+	}
+	book.SubjectNames = cleanNames(subjects)
+	return book, subjects
+}
+
+// PrepareBookImport maps the records, assigns categories and flags
+// invalid records and duplicates of existing books or of earlier
+// records in the same file. Nothing is written to the database.
+//
+// A record is filed under the first of its subject headings that
+// matches a category name, falling back to defaultCategoryID.
+func PrepareBookImport(tx *pop.Connection, records []marc.Record, defaultCategoryID string) (BookImportRows, error) {
+	categories := Categories{}
+	if err := tx.All(&categories); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	byName := map[string]string{}
+	for _, c := range categories {
+		byName[strings.ToLower(c.CategoryName)] = c.ID.String()
+	}
+
+	seenISBN := map[string]int{}
+	seenBookNo := map[string]int{}
+	rows := make(BookImportRows, 0, len(records))
+
+	for i, rec := range records {
+		book, subjects := BookFromMARC(rec)
+		row := BookImportRow{Record: i + 1, Subjects: subjects, Status: BookImportNew}
+
+		book.CategoryID = defaultCategoryID
+		for _, s := range subjects {
+			if id, ok := byName[strings.ToLower(s)]; ok {
+				book.CategoryID = id
+				break
+			}
+		}
+		if book.Price.IsZero() {
+			row.Message = "no price in record, set to 0"
+		}
+		if err := book.BeforeValidate(tx); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		row.Book = book
+
+		verrs, err := book.Validate(tx)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			row.Status = BookImportInvalid
+			row.Message = strings.ReplaceAll(verrs.Error(), "\n", "; ")
+			rows = append(rows, row)
+			continue
+		}
+
+		if n, ok := seenISBN[book.ISBN]; ok && book.ISBN != "" {
+			row.Status = BookImportDuplicate
+			row.Message = "same ISBN as record " + strconv.Itoa(n)
+		} else if n, ok := seenBookNo[book.BookNo]; ok {
+			row.Status = BookImportDuplicate
+			row.Message = "same book no. as record " + strconv.Itoa(n)
+		} else {
+			q := tx.Where("book_no = ?", book.BookNo)
+			if book.ISBN != "" {
+				q = tx.Where("book_no = ? OR isbn = ?", book.BookNo, book.ISBN)
+			}
+			exists, err := q.Exists(&Book{})
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if exists {
+				row.Status = BookImportDuplicate
+				row.Message = "already in the catalogue"
+			}
+		}
+
+		if book.ISBN != "" {
+			seenISBN[book.ISBN] = row.Record
+		}
+		seenBookNo[book.BookNo] = row.Record
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// CommitBookImport creates the books of every new row. It is meant to
+// run inside a single transaction: a database error aborts the whole
+// import, while rows that fail validation are reported and skipped.
+func CommitBookImport(tx *pop.Connection, rows BookImportRows) error {
+	for i := range rows {
+		row := &rows[i]
+		if row.Status != BookImportNew {
+			continue
+		}
+		verrs, err := tx.ValidateAndCreate(&row.Book)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			row.Status = BookImportInvalid
+			row.Message = strings.ReplaceAll(verrs.Error(), "\n", "; ")
+			continue
+		}
+		row.Status = BookImportCreated
+	}
+	return nil
+}
+
+// trimISBD removes the trailing ISBD punctuation cataloguers leave on
+// MARC values, e.g. "Crime and punishment /" or "Dostoyevsky, Fyodor,".
+func trimISBD(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,="))
+}
+
+// parsePrice pulls the first amount out of values like "$12.50",
+// "Rs. 450" or "EUR 9,90 (pbk.)", in the currency the value names, if
+// any. It reports false when there is no amount.
+func parsePrice(s string) (money.Money, bool) {
+	start := strings.IndexAny(s, "0123456789")
+	if start < 0 {
+		return money.Money{}, false
+	}
+	end := start
+	for end < len(s) && strings.ContainsRune("0123456789.,", rune(s[end])) {
+		end++
+	}
+	price, err := money.Parse(strings.TrimRight(s[start:end], ".,"), money.CurrencyIn(s))
+	if err != nil {
+		return money.Money{}, false
+	}
+	return price, true
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"library/marc"
+	"library/money"
+)
+
+func marcRecord(controlNo, isbn, title, author, subject string) marc.Record {
+	return marc.Record{
+		ControlFields: []marc.ControlField{{Tag: "001", Value: controlNo}},
+		DataFields: []marc.DataField{
+			{Tag: "020", Subfields: []marc.Subfield{{Code: "a", Value: isbn}, {Code: "c", Value: "$12.50"}}},
+			{Tag: "100", Subfields: []marc.Subfield{{Code: "a", Value: author}}},
+			{Tag: "245", Subfields: []marc.Subfield{{Code: "a", Value: title}}},
+			{Tag: "650", Subfields: []marc.Subfield{{Code: "a", Value: subject}}},
+		},
+	}
+}
+
+func (ms *ModelSuite) Test_BookFromMARC() {
+	book, subjects := BookFromMARC(marcRecord("ocm1", "978-0-14-044913-6 (pbk.)", "Crime and punishment /", "Dostoyevsky, Fyodor,", "Fiction."))
+
+	ms.Equal("Crime and punishment", book.Title)
+	ms.Equal("Dostoyevsky, Fyodor", book.Author)
+	ms.Equal("9780140449136", book.ISBN)
+	ms.Equal("ocm1", book.BookNo)
+	ms.Equal(money.New(1250, ""), book.Price)
+	ms.Equal([]string{"Fiction."}, subjects)
+}
+
+func (ms *ModelSuite) Test_parsePrice() {
+	for s, want := range map[string]money.Money{
+		"$12.50":          money.New(1250, ""),
+		"Rs. 450":         money.New(45000, ""),
+		"EUR 9,90 (pbk.)": money.New(990, "EUR"),
+		"£1,299.00.":      money.New(129900, "GBP"),
+		"12.50 USD":       money.New(1250, "USD"),
+	} {
+		price, ok := parsePrice(s)
+		ms.True(ok, s)
+		ms.Equal(want, price, s)
+	}
+	_, ok := parsePrice("(pbk.)")
+	ms.False(ok)
+}
+
+func (ms *ModelSuite) Test_BookImport_Duplicates() {
+	fiction := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(fiction))
+	other := &Category{CategoryName: "Other", Status: 1}
+	ms.NoError(ms.DB.Create(other))
+
+	existing := &Book{CategoryID: other.ID.String(), Title: "Existing", BookNo: "B-1", ISBN: "9780316769488", Author: "Someone", Price: money.New(100, "USD"), Status: 1}
+	ms.NoError(ms.DB.Create(existing))
+
+	rows, err := PrepareBookImport(ms.DB, []marc.Record{
+		marcRecord("ocm1", "9780140449136", "Crime and punishment", "Dostoyevsky", "Fiction"),
+		marcRecord("ocm2", "9780140449136", "Crime and punishment", "Dostoyevsky", "Fiction"),
+		marcRecord("ocm3", "0-316-76948-8", "The catcher in the rye", "Salinger", "Novels"),
+		marcRecord("ocm4", "", "", "Nobody", "Novels"),
+	}, other.ID.String())
+	ms.NoError(err)
+	ms.Len(rows, 4)
+
+	ms.Equal(BookImportNew, rows[0].Status)
+	ms.Equal(fiction.ID.String(), rows[0].Book.CategoryID)
+	ms.Equal(BookImportDuplicate, rows[1].Status)
+	ms.Equal(BookImportNew, rows[2].Status)
+	ms.Equal(other.ID.String(), rows[2].Book.CategoryID)
+	ms.Equal(BookImportInvalid, rows[3].Status)
+
+	ms.NoError(CommitBookImport(ms.DB, rows))
+	ms.Equal(2, rows.Count(BookImportCreated))
+
+	count, err := ms.DB.Count("books")
+	ms.NoError(err)
+	ms.Equal(3, count)
+}
+
+func (ms *ModelSuite) Test_BookImport_Unpriced() {
+	other := &Category{CategoryName: "Other", Status: 1}
+	ms.NoError(ms.DB.Create(other))
+
+	unpriced := marcRecord("ocm5", "9780141439518", "Pride and prejudice", "Austen", "Novels")
+	unpriced.DataFields[0].Subfields = unpriced.DataFields[0].Subfields[:1]
+	rows, err := PrepareBookImport(ms.DB, []marc.Record{
+		unpriced,
+		marcRecord("ocm6", "9780141439662", "Sense and sensibility", "Austen", "Novels"),
+	}, other.ID.String())
+	ms.NoError(err)
+
+	for _, row := range rows {
+		ms.Equal(BookImportNew, row.Status, row.Message)
+		ms.Equal(money.DefaultCurrency, row.Book.Currency)
+	}
+	ms.Equal("no price in record, set to 0", rows[0].Message)
+	ms.Equal(int64(1250), rows[1].Book.Price.Cents)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/gobuffalo/pop/v6"
+
+	"library/money"
+)
+
+// applyMoney puts the price and replacement cost in the book's
+// currency, which defaults to money.DefaultCurrency. A book without a
+// replacement cost is replaced at its price.
+func (b *Book) applyMoney() {
+	if b.Currency == "" {
+		b.Currency = b.Price.Currency
+	}
+	if b.Currency == "" {
+		b.Currency = money.DefaultCurrency
+	}
+	b.Price.Currency = b.Currency
+	b.ReplacementCost.Currency = b.Currency
+	if b.ReplacementCost.IsZero() && b.ReplacementCost.Err() == nil {
+		b.ReplacementCost.Cents = b.Price.Cents
+	}
+}
+
+// AfterFind gives the amounts read from the database the book's
+// currency, which is kept in a column of its own.
+func (b *Book) AfterFind(tx *pop.Connection) error {
+	b.Price.Currency = b.Currency
+	b.ReplacementCost.Currency = b.Currency
+	return nil
+}
+
+// validAmount reports whether an amount parsed and isn't negative.
+func validAmount(m money.Money) bool {
+	return m.Err() == nil && !m.IsNegative()
+}
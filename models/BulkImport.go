@@ -0,0 +1,383 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// Actions reported for each row of a bulk import.
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportError  = "error"
+)
+
+// importRecord is a model that can be validated before it is saved.
+// BeforeValidate fills in the defaults, such as a book's currency, that
+// saving it would.
+type importRecord interface {
+	BeforeValidate(tx *pop.Connection) error
+	Validate(tx *pop.Connection) (*validate.Errors, error)
+}
+
+// Importer describes how spreadsheet rows become records of one model.
+type Importer struct {
+	// Kind names the import in URLs and forms, e.g. "books".
+	Kind string
+	// Fields are the target fields a file column can be mapped to.
+	Fields []string
+	// Key is the field used to find an existing record when upserting.
+	Key string
+	// Scope, when set, is a field that identifies a record together
+	// with Key, as the branch does for stock.
+	Scope string
+	// Aliases are extra column headings recognised by AutoMap.
+	Aliases map[string]string
+
+	// build returns the record described by values. When a record with
+	// the same key exists it is loaded and updated in place.
+	build func(tx *pop.Connection, values map[string]string, verrs *validate.Errors) (importRecord, bool, error)
+}
+
+// Importers lists the supported bulk imports by kind.
+var Importers = map[string]*Importer{
+	"books":       bookImporter,
+	"customers":   customerImporter,
+	"inventories": inventoryImporter,
+}
+
+// ImportOptions control how a file is applied.
+type ImportOptions struct {
+	// Mapping maps a target field to a zero based column index.
+	Mapping map[string]int `json:"mapping"`
+	// Upsert updates records whose key already exists instead of
+	// reporting them as errors.
+	Upsert bool `json:"upsert"`
+}
+
+// ImportRow is the outcome of one line of the file.
+type ImportRow struct {
+	Line   int      `json:"line"`
+	Cells  []string `json:"cells"`
+	Action string   `json:"action"`
+	Errors []string `json:"errors"`
+
+	record importRecord
+}
+
+// ImportReport is the per-row result of a dry run or an import.
+type ImportReport struct {
+	Kind string      `json:"kind"`
+	Rows []ImportRow `json:"rows"`
+}
+
+// Count returns how many rows will be (or were) handled with action.
+func (r ImportReport) Count(action string) int {
+	n := 0
+	for _, row := range r.Rows {
+		if row.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// HasErrors reports whether any row failed; an import with errors is
+// never written.
+func (r ImportReport) HasErrors() bool {
+	return r.Count(ImportError) > 0
+}
+
+// AutoMap guesses the mapping from the file's heading row by comparing
+// headings and field names case and punctuation insensitively.
+func (imp *Importer) AutoMap(header []string) map[string]int {
+	mapping := map[string]int{}
+	for i, h := range header {
+		name := importName(h)
+		for _, f := range imp.Fields {
+			if _, done := mapping[f]; done {
+				continue
+			}
+			if importName(f) == name || importName(imp.Aliases[name]) == importName(f) {
+				mapping[f] = i
+			}
+		}
+	}
+	return mapping
+}
+
+// Run validates every data row (the heading row excluded) and, when
+// commit is true and no row has errors, creates or updates the records.
+// Lines are numbered as in the file, counting the heading as line 1.
+// The caller is expected to run it inside a transaction so that a
+// database error aborts the whole import.
+func (imp *Importer) Run(tx *pop.Connection, rows [][]string, opts ImportOptions, commit bool) (ImportReport, error) {
+	report := ImportReport{Kind: imp.Kind}
+	if _, ok := opts.Mapping[imp.Key]; !ok {
+		return report, errors.Errorf("the %s column must be mapped", imp.Key)
+	}
+
+	seen := map[string]int{}
+	for i, cells := range rows {
+		row := ImportRow{Line: i + 2, Cells: cells}
+
+		values := map[string]string{}
+		for field, col := range opts.Mapping {
+			if col >= 0 && col < len(cells) {
+				values[field] = strings.TrimSpace(cells[col])
+			}
+		}
+		if isBlank(values) {
+			continue
+		}
+
+		key := strings.ToLower(values[imp.Key])
+		if imp.Scope != "" && key != "" {
+			key += "\x00" + strings.ToLower(values[imp.Scope])
+		}
+		if n, ok := seen[key]; ok && key != "" {
+			row.Action = ImportError
+			row.Errors = append(row.Errors, fmt.Sprintf("%s %q is repeated from line %d", imp.Key, values[imp.Key], n))
+			report.Rows = append(report.Rows, row)
+			continue
+		}
+		seen[key] = row.Line
+
+		verrs := validate.NewErrors()
+		record, exists, err := imp.build(tx, values, verrs)
+		if err != nil {
+			return report, errors.WithStack(err)
+		}
+		row.record = record
+		row.Action = ImportCreate
+		if exists {
+			row.Action = ImportUpdate
+			if !opts.Upsert {
+				verrs.Add(imp.Key, fmt.Sprintf("%s %q already exists", imp.Key, values[imp.Key]))
+			}
+		}
+
+		if !verrs.HasAny() {
+			if err := record.BeforeValidate(tx); err != nil {
+				return report, errors.WithStack(err)
+			}
+			ve, err := record.Validate(tx)
+			if err != nil {
+				return report, errors.WithStack(err)
+			}
+			verrs.Append(ve)
+		}
+		if verrs.HasAny() {
+			row.Action = ImportError
+			for _, k := range verrs.Keys() {
+				row.Errors = append(row.Errors, verrs.Get(k)...)
+			}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	if !commit || report.HasErrors() {
+		return report, nil
+	}
+	for _, row := range report.Rows {
+		var verrs *validate.Errors
+		var err error
+		if row.Action == ImportUpdate {
+			verrs, err = tx.ValidateAndUpdate(row.record)
+		} else {
+			verrs, err = tx.ValidateAndCreate(row.record)
+		}
+		if err != nil {
+			return report, errors.WithStack(err)
+		}
+		if verrs.HasAny() {
+			return report, errors.Errorf("line %d: %s", row.Line, verrs.Error())
+		}
+	}
+	return report, nil
+}
+
+func isBlank(values map[string]string) bool {
+	for _, v := range values {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func importName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// set assigns v to *dst unless v is blank, so blank cells never wipe
+// out data on existing records.
+func set(dst *string, v string) {
+	if v != "" {
+		*dst = v
+	}
+}
+
+// setMoney is set for amounts, which are read in the currency *dst is
+// already in. Amounts that don't parse are reported in verrs.
+func setMoney(verrs *validate.Errors, dst *money.Money, field, v string) {
+	if v == "" {
+		return
+	}
+	amount, err := money.Parse(v, dst.Currency)
+	if err != nil {
+		verrs.Add(field, fmt.Sprintf("%s %q is not an amount of money", field, v))
+		return
+	}
+	*dst = amount
+}
+
+var bookImporter = &Importer{
+	Kind:   "books",
+	Fields: []string{"BookNo", "Title", "Category", "ISBN", "Author", "Publisher", "Subjects", "CallNumber", "Year", "Price", "ReplacementCost", "Currency", "Status"},
+	Key:    "BookNo",
+	Aliases: map[string]string{
+		"categoryname":  "Category",
+		"number":        "BookNo",
+		"publishedyear": "Year",
+		"authors":       "Author",
+		"publishers":    "Publisher",
+		"subject":       "Subjects",
+		"callno":        "CallNumber",
+		"dewey":         "CallNumber",
+		"replacement":   "ReplacementCost",
+	},
+	build: func(tx *pop.Connection, values map[string]string, verrs *validate.Errors) (importRecord, bool, error) {
+		book := &Book{Status: 1}
+		err := tx.Where("book_no = ?", values["BookNo"]).First(book)
+		exists := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, false, err
+		}
+
+		set(&book.BookNo, values["BookNo"])
+		set(&book.Title, values["Title"])
+		set(&book.Author, values["Author"])
+		set(&book.Publisher, values["Publisher"])
+		if v := values["Subjects"]; v != "" {
+			book.SubjectNames = SplitCredits(v)
+		}
+		set(&book.CallNumber, values["CallNumber"])
+		if v := values["Currency"]; v != "" {
+			book.Currency = strings.ToUpper(v)
+			book.Price.Currency = book.Currency
+			book.ReplacementCost.Currency = book.Currency
+		}
+		setMoney(verrs, &book.Price, "Price", values["Price"])
+		setMoney(verrs, &book.ReplacementCost, "ReplacementCost", values["ReplacementCost"])
+		if v := values["ISBN"]; v != "" {
+			book.ISBN = NormalizeISBN(v)
+		}
+		if v := values["Year"]; v != "" {
+			year, err := strconv.Atoi(v)
+			if err != nil {
+				verrs.Add("Year", fmt.Sprintf("Year %q is not a number", v))
+			}
+			book.Year = year
+		}
+		if v := values["Status"]; v != "" {
+			switch strings.ToLower(v) {
+			case "1", "active", "yes", "true":
+				book.Status = 1
+			case "0", "de-active", "inactive", "no", "false":
+				book.Status = 0
+			default:
+				verrs.Add("Status", fmt.Sprintf("Status %q should be Active or De-Active", v))
+			}
+		}
+		if v := values["Category"]; v != "" {
+			category := &Category{}
+			if err := tx.Where("id = ? OR category_name = ?", v, v).First(category); err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					return nil, false, err
+				}
+				verrs.Add("Category", fmt.Sprintf("Category %q does not exist", v))
+			}
+			book.CategoryID = category.ID.String()
+		}
+		return book, exists, nil
+	},
+}
+
+var customerImporter = &Importer{
+	Kind:    "customers",
+	Fields:  []string{"Email", "Name", "Mobile", "Address"},
+	Key:     "Email",
+	Aliases: map[string]string{"emailaddress": "Email", "phone": "Mobile"},
+	build: func(tx *pop.Connection, values map[string]string, verrs *validate.Errors) (importRecord, bool, error) {
+		email := strings.ToLower(values["Email"])
+		customer := &Customer{}
+		err := tx.Where("LOWER(email) = ?", email).First(customer)
+		exists := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, false, err
+		}
+
+		set(&customer.Email, email)
+		set(&customer.Name, values["Name"])
+		set(&customer.Mobile, values["Mobile"])
+		if v := values["Address"]; v != "" {
+			customer.Address = nulls.NewString(v)
+		}
+		return customer, exists, nil
+	},
+}
+
+var inventoryImporter = &Importer{
+	Kind:    "inventories",
+	Fields:  []string{"BookNo", "Qty", "Branch"},
+	Key:     "BookNo",
+	Scope:   "Branch",
+	Aliases: map[string]string{"quantity": "Qty", "copies": "Qty", "location": "Branch"},
+	build: func(tx *pop.Connection, values map[string]string, verrs *validate.Errors) (importRecord, bool, error) {
+		inventory := &Inventory{}
+		book := &Book{}
+		if err := tx.Where("book_no = ?", values["BookNo"]).First(book); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, false, err
+			}
+			verrs.Add("BookNo", fmt.Sprintf("Book %q does not exist", values["BookNo"]))
+			return inventory, false, nil
+		}
+
+		branch := strings.TrimSpace(values["Branch"])
+		if branch == "" {
+			branch = DefaultBranch
+		}
+		err := tx.Where("book_id = ? AND branch = ?", book.ID, branch).First(inventory)
+		exists := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, false, err
+		}
+		inventory.BookID = book.ID.String()
+		inventory.Branch = branch
+		if v := values["Qty"]; v != "" {
+			qty, err := strconv.Atoi(v)
+			if err != nil || qty < 0 {
+				verrs.Add("Qty", fmt.Sprintf("Qty %q is not a whole number", v))
+			}
+			inventory.Qty = qty
+		}
+		return inventory, exists, nil
+	},
+}
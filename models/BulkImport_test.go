@@ -0,0 +1,106 @@
+package models
+
+import "library/money"
+
+func (ms *ModelSuite) Test_Importer_AutoMap() {
+	mapping := Importers["books"].AutoMap([]string{"Book No", "title", "Category Name", "Published Year", "Notes"})
+	ms.Equal(map[string]int{"BookNo": 0, "Title": 1, "Category": 2, "Year": 3}, mapping)
+}
+
+func (ms *ModelSuite) Test_Importer_Customers() {
+	ms.createPlan("adult", 5, 2, true)
+	existing := &Customer{Name: "Ann", Email: "ann@example.com", Mobile: "1"}
+	ms.NoError(ms.DB.Create(existing))
+
+	imp := Importers["customers"]
+	rows := [][]string{
+		{"ANN@example.com", "Ann Smith", ""},
+		{"bob@example.com", "Bob", "555"},
+		{"bob@example.com", "Bob again", "555"},
+	}
+	opts := ImportOptions{Mapping: map[string]int{"Email": 0, "Name": 1, "Mobile": 2}}
+
+	report, err := imp.Run(ms.DB, rows, opts, true)
+	ms.NoError(err)
+	ms.True(report.HasErrors())
+	ms.Equal(ImportError, report.Rows[0].Action, "existing customers need upsert")
+	ms.Equal(ImportCreate, report.Rows[1].Action)
+	ms.Equal(ImportError, report.Rows[2].Action)
+	ms.Equal(4, report.Rows[2].Line)
+
+	count, err := ms.DB.Count("customers")
+	ms.NoError(err)
+	ms.Equal(1, count, "nothing is written when a row fails")
+
+	opts.Upsert = true
+	report, err = imp.Run(ms.DB, rows[:2], opts, true)
+	ms.NoError(err)
+	ms.False(report.HasErrors())
+	ms.Equal(1, report.Count(ImportUpdate))
+	ms.Equal(1, report.Count(ImportCreate))
+
+	ms.NoError(ms.DB.Reload(existing))
+	ms.Equal("Ann Smith", existing.Name)
+	ms.Equal("1", existing.Mobile, "blank cells keep the current value")
+}
+
+func (ms *ModelSuite) Test_Importer_Customers_cards() {
+	plan := ms.createPlan("adult", 5, 2, true)
+	imp := Importers["customers"]
+
+	// card details aren't columns; new customers get the defaults
+	rows := [][]string{{"cy@example.com", "Cy"}}
+	report, err := imp.Run(ms.DB, rows, ImportOptions{Mapping: map[string]int{"Email": 0, "Name": 1}}, false)
+	ms.NoError(err)
+	ms.Equal(ImportError, report.Rows[0].Action)
+	ms.Equal([]string{"Mobile can not be blank."}, report.Rows[0].Errors)
+
+	rows = [][]string{{"cy@example.com", "Cy", "555"}}
+	report, err = imp.Run(ms.DB, rows, ImportOptions{Mapping: map[string]int{"Email": 0, "Name": 1, "Mobile": 2}}, true)
+	ms.NoError(err)
+	ms.Equal(ImportCreate, report.Rows[0].Action, report.Rows[0].Errors)
+
+	customer := &Customer{}
+	ms.NoError(ms.DB.Where("email = ?", "cy@example.com").First(customer))
+	ms.Equal(CustomerActive, customer.Status)
+	ms.Equal(plan.ID.String(), customer.MembershipPlanID)
+	ms.True(ValidCardNumber(customer.CardNumber))
+}
+
+func (ms *ModelSuite) Test_Importer_Books() {
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+
+	report, err := Importers["books"].Run(ms.DB, [][]string{{"B-1", "Emma", "Fiction", "Jane Austen", "12.50"}}, ImportOptions{
+		Mapping: map[string]int{"BookNo": 0, "Title": 1, "Category": 2, "Author": 3, "Price": 4},
+	}, true)
+	ms.NoError(err)
+	ms.Equal(ImportCreate, report.Rows[0].Action, report.Rows[0].Errors)
+
+	book := &Book{}
+	ms.NoError(ms.DB.Where("book_no = ?", "B-1").First(book))
+	ms.Equal(money.New(1250, money.DefaultCurrency), book.Price)
+}
+
+func (ms *ModelSuite) Test_Importer_Inventories() {
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := &Book{CategoryID: category.ID.String(), Title: "T", BookNo: "B-1", Author: "A", Price: money.New(100, "USD"), Status: 1}
+	ms.NoError(ms.DB.Create(book))
+
+	report, err := Importers["inventories"].Run(ms.DB, [][]string{{"B-1", "4"}, {"B-404", "2"}}, ImportOptions{
+		Mapping: map[string]int{"BookNo": 0, "Qty": 1},
+	}, false)
+	ms.NoError(err)
+	ms.Equal(ImportCreate, report.Rows[0].Action)
+	ms.Equal(ImportError, report.Rows[1].Action)
+	ms.Contains(report.Rows[1].Errors[0], "does not exist")
+
+	report, err = Importers["inventories"].Run(ms.DB, [][]string{{"B-1", "4", "Main"}, {"B-1", "2", "East"}, {"B-1", "1", "east"}}, ImportOptions{
+		Mapping: map[string]int{"BookNo": 0, "Qty": 1, "Branch": 2},
+	}, false)
+	ms.NoError(err)
+	ms.Equal(ImportCreate, report.Rows[0].Action)
+	ms.Equal(ImportCreate, report.Rows[1].Action, "a book is stocked at each branch")
+	ms.Equal(ImportError, report.Rows[2].Action)
+}
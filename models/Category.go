@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gobuffalo/validate/v3/validators"
@@ -12,11 +13,21 @@ import (
 
 // Category is used by pop to map your categories database table to your go code.
 type Category struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	CategoryName string    `json:"category_name" db:"category_name"`
-	Status       int       `json:"status" db:"status"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID           uuid.UUID  `json:"id" db:"id"`
+	CategoryName string     `json:"category_name" db:"category_name"`
+	Status       int        `json:"status" db:"status"`
+	ParentID     nulls.UUID `json:"parent_id" db:"parent_id" form:"ParentID"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Path lists the ids from the top level category down to this one,
+	// as in "/<id>/<id>/", and Depth is the number of ancestors. Both
+	// are kept up to date by BeforeSave; see CategoryTree.go.
+	Path  string `json:"path" db:"path" form:"-"`
+	Depth int    `json:"depth" db:"depth" form:"-"`
+	// Trail is the names from the top level down, as in "Science /
+	// Physics". It is only set by CategoryTree.
+	Trail string `json:"trail,omitempty" db:"-" form:"-"`
 }
 
 type Selectable interface {
@@ -24,7 +35,13 @@ type Selectable interface {
 	SelectLabel() string
 }
 
+// SelectLabel shows the full trail of a category when it was loaded
+// with CategoryTree, so nested categories with the same name can be
+// told apart.
 func (c Category) SelectLabel() string {
+	if c.Trail != "" {
+		return c.Trail
+	}
 	return c.CategoryName
 }
 func (c Category) SelectValue() interface{} {
@@ -49,7 +66,7 @@ func (c Categories) String() string {
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
 func (c *Category) Validate(tx *pop.Connection) (*validate.Errors, error) {
-	return validate.NewErrors(), nil
+	return c.validateParent(tx)
 }
 
 // ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
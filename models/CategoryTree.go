@@ -0,0 +1,179 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// MaxCategoryDepth is how many levels categories can be nested, which
+// keeps the path within its column.
+const MaxCategoryDepth = 10
+
+// validateParent refuses parents that don't exist, moves into the
+// category's own subtree and trees deeper than MaxCategoryDepth.
+func (c *Category) validateParent(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if !c.ParentID.Valid {
+		return verrs, nil
+	}
+	parent := &Category{}
+	if err := tx.Find(parent, c.ParentID.UUID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			verrs.Add("ParentID", "Parent category does not exist.")
+			return verrs, nil
+		}
+		return verrs, errors.WithStack(err)
+	}
+	if parent.ID == c.ID || (c.Path != "" && strings.HasPrefix(parent.Path, c.Path)) {
+		verrs.Add("ParentID", "A category can't be moved into itself or one of its subcategories.")
+		return verrs, nil
+	}
+
+	height := 0
+	if c.Path != "" {
+		deepest := &Category{}
+		if err := tx.Where("path LIKE ?", c.Path+"%").Order("depth desc").First(deepest); err != nil {
+			return verrs, errors.WithStack(err)
+		}
+		height = deepest.Depth - c.Depth
+	}
+	if parent.Depth+1+height >= MaxCategoryDepth {
+		verrs.Add("ParentID", fmt.Sprintf("Categories can't be nested more than %d levels deep.", MaxCategoryDepth))
+	}
+	return verrs, nil
+}
+
+// BeforeSave works out the path and depth of the category from its
+// parent. When a category moves, the paths of its whole subtree are
+// rewritten along with it.
+func (c *Category) BeforeSave(tx *pop.Connection) error {
+	if c.ID == uuid.Nil {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.ID = id
+	}
+
+	path, depth := "/", 0
+	if c.ParentID.Valid {
+		parent := &Category{}
+		if err := tx.Find(parent, c.ParentID.UUID); err != nil {
+			return errors.WithStack(err)
+		}
+		path, depth = parent.Path, parent.Depth+1
+	}
+	path += c.ID.String() + "/"
+
+	if c.Path != "" && c.Path != path {
+		err := tx.RawQuery("UPDATE categories SET path = CONCAT(?, SUBSTRING(path, ?)), depth = depth + ? WHERE path LIKE ? AND id <> ?",
+			path, len(c.Path)+1, depth-c.Depth, c.Path+"%", c.ID).Exec()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	c.Path, c.Depth = path, depth
+	return nil
+}
+
+// AncestorIDs returns the ids of the categories above this one, from
+// the top level down.
+func (c Category) AncestorIDs() []string {
+	ids := strings.Split(strings.Trim(c.Path, "/"), "/")
+	if len(ids) <= 1 {
+		return []string{}
+	}
+	return ids[:len(ids)-1]
+}
+
+// Breadcrumbs returns the categories above this one, from the top
+// level down.
+func (c *Category) Breadcrumbs(tx *pop.Connection) (Categories, error) {
+	crumbs := Categories{}
+	ids := c.AncestorIDs()
+	if len(ids) == 0 {
+		return crumbs, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	if err := tx.Where("id IN (?)", args...).Order("depth").All(&crumbs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return crumbs, nil
+}
+
+// Children returns the categories directly below this one, by name.
+func (c *Category) Children(tx *pop.Connection) (Categories, error) {
+	children := Categories{}
+	if err := tx.Where("parent_id = ?", c.ID).Order("category_name").All(&children); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return children, nil
+}
+
+// CategoryTree returns every category in tree order: each one followed
+// by its subcategories, siblings by name. The Trail of each is set.
+func CategoryTree(tx *pop.Connection) (Categories, error) {
+	all := Categories{}
+	if err := tx.All(&all); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	children := map[string]Categories{}
+	for _, c := range all {
+		parent := ""
+		if c.ParentID.Valid {
+			parent = c.ParentID.UUID.String()
+		}
+		children[parent] = append(children[parent], c)
+	}
+
+	tree := make(Categories, 0, len(all))
+	var walk func(parent, trail string)
+	walk = func(parent, trail string) {
+		level := children[parent]
+		sort.SliceStable(level, func(i, j int) bool {
+			return strings.ToLower(level[i].CategoryName) < strings.ToLower(level[j].CategoryName)
+		})
+		for _, c := range level {
+			c.Trail = c.CategoryName
+			if trail != "" {
+				c.Trail = trail + " / " + c.CategoryName
+			}
+			tree = append(tree, c)
+			walk(c.ID.String(), c.Trail)
+		}
+	}
+	walk("", "")
+	return tree, nil
+}
+
+// SubtreeTotals adds up per-category counts, such as the number of
+// books, so each category also counts what is in its subcategories.
+func (cs Categories) SubtreeTotals(counts map[string]int) map[string]int {
+	totals := map[string]int{}
+	for _, c := range cs {
+		n := counts[c.ID.String()]
+		totals[c.ID.String()] += n
+		for _, id := range c.AncestorIDs() {
+			totals[id] += n
+		}
+	}
+	return totals
+}
+
+// InCategory limits q to the rows whose column holds the given category
+// or one of its subcategories, as in InCategory(q, "books.category_id",
+// id) for all the books in Science, Physics included.
+func InCategory(q *pop.Query, column, categoryID string) *pop.Query {
+	return q.Where(column+" IN (SELECT sub.id FROM categories sub JOIN categories top ON sub.path LIKE CONCAT(top.path, '%') WHERE top.id = ?)", categoryID)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"github.com/gobuffalo/nulls"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) createCategory(name string, parent *Category) *Category {
+	c := &Category{CategoryName: name, Status: 1}
+	if parent != nil {
+		c.ParentID = nulls.NewUUID(parent.ID)
+	}
+	verrs, err := ms.DB.ValidateAndCreate(c)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	return c
+}
+
+func (ms *ModelSuite) Test_Category_Tree() {
+	science := ms.createCategory("Science", nil)
+	physics := ms.createCategory("Physics", science)
+	quantum := ms.createCategory("Quantum", physics)
+	ms.createCategory("Art", nil)
+
+	ms.Equal("/"+science.ID.String()+"/"+physics.ID.String()+"/"+quantum.ID.String()+"/", quantum.Path)
+	ms.Equal(2, quantum.Depth)
+	ms.Equal([]string{science.ID.String(), physics.ID.String()}, quantum.AncestorIDs())
+
+	crumbs, err := quantum.Breadcrumbs(ms.DB)
+	ms.NoError(err)
+	ms.Equal([]string{"Science", "Physics"}, []string{crumbs[0].CategoryName, crumbs[1].CategoryName})
+
+	tree, err := CategoryTree(ms.DB)
+	ms.NoError(err)
+	trails := []string{}
+	for _, c := range tree {
+		trails = append(trails, c.SelectLabel())
+	}
+	ms.Equal([]string{"Art", "Science", "Science / Physics", "Science / Physics / Quantum"}, trails)
+
+	totals := tree.SubtreeTotals(map[string]int{quantum.ID.String(): 2, science.ID.String(): 1})
+	ms.Equal(3, totals[science.ID.String()])
+	ms.Equal(2, totals[physics.ID.String()])
+}
+
+func (ms *ModelSuite) Test_Category_Move() {
+	science := ms.createCategory("Science", nil)
+	physics := ms.createCategory("Physics", science)
+	quantum := ms.createCategory("Quantum", physics)
+
+	// a category can't go below its own subcategory
+	science.ParentID = nulls.NewUUID(quantum.ID)
+	verrs, err := ms.DB.ValidateAndUpdate(science)
+	ms.NoError(err)
+	ms.True(verrs.HasAny())
+
+	// moving physics to the top level takes quantum along
+	ms.NoError(ms.DB.Reload(physics))
+	physics.ParentID = nulls.UUID{}
+	verrs, err = ms.DB.ValidateAndUpdate(physics)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.NoError(ms.DB.Reload(quantum))
+	ms.Equal("/"+physics.ID.String()+"/"+quantum.ID.String()+"/", quantum.Path)
+	ms.Equal(1, quantum.Depth)
+}
+
+func (ms *ModelSuite) Test_InCategory() {
+	science := ms.createCategory("Science", nil)
+	physics := ms.createCategory("Physics", science)
+	art := ms.createCategory("Art", nil)
+	for _, category := range []*Category{science, physics, art} {
+		book := &Book{CategoryID: category.ID.String(), Title: category.CategoryName, BookNo: category.CategoryName, Price: money.New(100, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(book))
+	}
+
+	count, err := InCategory(ms.DB.Q(), "books.category_id", science.ID.String()).Count(&Books{})
+	ms.NoError(err)
+	ms.Equal(2, count)
+	count, err = InCategory(ms.DB.Q(), "books.category_id", physics.ID.String()).Count(&Books{})
+	ms.NoError(err)
+	ms.Equal(1, count)
+}
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// The periods loans are counted by.
+const (
+	ReportDay   = "day"
+	ReportWeek  = "week"
+	ReportMonth = "month"
+)
+
+// ReportPeriods are the periods loans can be counted by.
+var ReportPeriods = []string{ReportDay, ReportWeek, ReportMonth}
+
+// PeriodCount is how many books were lent and returned in a day, week
+// or month.
+type PeriodCount struct {
+	Start   time.Time `json:"start"`
+	Label   string    `json:"label"`
+	Loans   int       `json:"loans"`
+	Returns int       `json:"returns"`
+}
+
+// RankedCount is how many loans a book, category or customer had.
+// Code is the book number or card number.
+type RankedCount struct {
+	ID    string `db:"id"`
+	Name  string `db:"name"`
+	Code  string `db:"code"`
+	Loans int    `db:"loans"`
+}
+
+// OverdueLoan is a book out past its due date, with who has it and the
+// fine it is running up.
+type OverdueLoan struct {
+	AssignBook
+	Title        string      `db:"title"`
+	BookNo       string      `db:"book_no"`
+	CustomerName string      `db:"customer_name"`
+	CardNumber   string      `db:"card_number"`
+	PlanID       string      `db:"membership_plan_id"`
+	Late         int         `db:"-"`
+	Fine         money.Money `db:"-"`
+}
+
+// LoanLength is how long books returned in a range were out for, in
+// days.
+type LoanLength struct {
+	Loans   int
+	Average float64
+	Longest int
+}
+
+type dayCount struct {
+	Day   string `db:"day"`
+	Count int    `db:"n"`
+}
+
+// periodStart is the first day of the period t falls in. Weeks start on
+// Monday.
+func periodStart(t time.Time, period string) time.Time {
+	t = dateOf(t)
+	switch period {
+	case ReportWeek:
+		return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	case ReportMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
+
+// periodLabel names the period starting at t.
+func periodLabel(t time.Time, period string) string {
+	switch period {
+	case ReportWeek:
+		return "week of " + t.Format("2006-01-02")
+	case ReportMonth:
+		return t.Format("January 2006")
+	}
+	return t.Format("Mon 2006-01-02")
+}
+
+func nextPeriod(t time.Time, period string) time.Time {
+	switch period {
+	case ReportWeek:
+		return t.AddDate(0, 0, 7)
+	case ReportMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// LoansByPeriod counts the books lent and returned on the days from and
+// to and those between, by day, week or month. Every period in the range
+// is listed, those without loans too.
+func LoansByPeriod(tx *pop.Connection, from, to time.Time, period string) ([]PeriodCount, error) {
+	if !included(ReportPeriods, period) {
+		return nil, errors.Errorf("%s is not a report period", period)
+	}
+	from, to = dateOf(from), dateOf(to)
+	counts := []PeriodCount{}
+	index := map[string]int{}
+	for start := periodStart(from, period); !start.After(to); start = nextPeriod(start, period) {
+		index[start.Format("2006-01-02")] = len(counts)
+		counts = append(counts, PeriodCount{Start: start, Label: periodLabel(start, period)})
+	}
+
+	count := func(column string, add func(*PeriodCount, int)) error {
+		days := []dayCount{}
+		q := "SELECT " + column + " AS day, COUNT(*) AS n FROM assign_books WHERE " + column + " BETWEEN ? AND ? GROUP BY " + column
+		if err := tx.RawQuery(q, from.Format("2006-01-02"), to.Format("2006-01-02")).All(&days); err != nil {
+			return errors.WithStack(err)
+		}
+		for _, d := range days {
+			day, err := loanDate(d.Day)
+			if err != nil {
+				return err
+			}
+			if i, ok := index[periodStart(day, period).Format("2006-01-02")]; ok {
+				add(&counts[i], d.Count)
+			}
+		}
+		return nil
+	}
+	if err := count("assign_date", func(p *PeriodCount, n int) { p.Loans += n }); err != nil {
+		return nil, err
+	}
+	if err := count("returned_on", func(p *PeriodCount, n int) { p.Returns += n }); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// mostBorrowed ranks what the query groups loans lent from and to by,
+// most loans first, limit of them at most.
+func mostBorrowed(tx *pop.Connection, query string, from, to time.Time, limit int) ([]RankedCount, error) {
+	ranked := []RankedCount{}
+	err := tx.RawQuery(query+" ORDER BY loans DESC, name LIMIT ?", dateOf(from).Format("2006-01-02"), dateOf(to).Format("2006-01-02"), limit).All(&ranked)
+	return ranked, errors.WithStack(err)
+}
+
+// MostBorrowedBooks are the books lent most often from and to.
+func MostBorrowedBooks(tx *pop.Connection, from, to time.Time, limit int) ([]RankedCount, error) {
+	return mostBorrowed(tx, "SELECT books.id, books.title AS name, books.book_no AS code, COUNT(*) AS loans FROM assign_books JOIN books ON books.id = assign_books.book_id WHERE assign_books.assign_date BETWEEN ? AND ? GROUP BY books.id, books.title, books.book_no", from, to, limit)
+}
+
+// MostBorrowedCategories are the categories whose books were lent most
+// often from and to. A book counts toward its own category only, not
+// the ones above it.
+func MostBorrowedCategories(tx *pop.Connection, from, to time.Time, limit int) ([]RankedCount, error) {
+	return mostBorrowed(tx, "SELECT categories.id, categories.category_name AS name, '' AS code, COUNT(*) AS loans FROM assign_books JOIN books ON books.id = assign_books.book_id JOIN categories ON categories.id = books.category_id WHERE assign_books.assign_date BETWEEN ? AND ? GROUP BY categories.id, categories.category_name", from, to, limit)
+}
+
+// MostActiveCustomers are the customers who borrowed most books from
+// and to.
+func MostActiveCustomers(tx *pop.Connection, from, to time.Time, limit int) ([]RankedCount, error) {
+	return mostBorrowed(tx, "SELECT customers.id, customers.name, customers.card_number AS code, COUNT(*) AS loans FROM assign_books JOIN customers ON customers.id = assign_books.customer_id WHERE assign_books.assign_date BETWEEN ? AND ? GROUP BY customers.id, customers.name, customers.card_number", from, to, limit)
+}
+
+// OverdueLoans are the books out past their due date on the day of now,
+// the longest overdue first, with the fines they are running up.
+func OverdueLoans(tx *pop.Connection, now time.Time) ([]OverdueLoan, error) {
+	loans := []OverdueLoan{}
+	if err := tx.RawQuery("SELECT assign_books.*, books.title, books.book_no, customers.name AS customer_name, customers.card_number, customers.membership_plan_id FROM assign_books JOIN books ON books.id = assign_books.book_id JOIN customers ON customers.id = assign_books.customer_id WHERE assign_books.returned_on IS NULL AND assign_books.return_date < ? ORDER BY assign_books.return_date, customers.name", dateOf(now).Format("2006-01-02")).All(&loans); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	plans := map[string]*MembershipPlan{}
+	for i := range loans {
+		loan := &loans[i]
+		loan.Late = loan.DaysLate(now)
+		plan, ok := plans[loan.PlanID]
+		if !ok {
+			var err error
+			if plan, err = (Customer{Name: loan.CustomerName, MembershipPlanID: loan.PlanID}).MembershipPlan(tx); err != nil {
+				return nil, err
+			}
+			plans[loan.PlanID] = plan
+		}
+		loan.Fine = plan.Fine(loan.Late)
+	}
+	return loans, nil
+}
+
+// AverageLoanLength is how long the books returned from and to were out
+// for, on average and at most.
+func AverageLoanLength(tx *pop.Connection, from, to time.Time) (LoanLength, error) {
+	length := LoanLength{}
+	loans := AssignBooks{}
+	if err := tx.Select("assign_date", "returned_on").Where("returned_on BETWEEN ? AND ?", dateOf(from).Format("2006-01-02"), dateOf(to).Format("2006-01-02")).All(&loans); err != nil {
+		return length, errors.WithStack(err)
+	}
+	total := 0
+	for _, loan := range loans {
+		lent, err := loanDate(loan.AssignDate)
+		if err != nil || !loan.ReturnedOn.Valid {
+			continue
+		}
+		days := int(dateOf(loan.ReturnedOn.Time).Sub(lent).Hours() / 24)
+		if days < 0 {
+			continue
+		}
+		length.Loans++
+		total += days
+		if days > length.Longest {
+			length.Longest = days
+		}
+	}
+	if length.Loans > 0 {
+		length.Average = float64(total) / float64(length.Loans)
+	}
+	return length, nil
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_CirculationReports() {
+	ms.createPlan("adult", 5, 2, true)
+	fiction := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(fiction))
+	poetry := &Category{CategoryName: "Poetry", Status: 1}
+	ms.NoError(ms.DB.Create(poetry))
+	book := func(category *Category, bookNo string) *Book {
+		b := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(100, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(b))
+		return b
+	}
+	customer := func(name string) *Customer {
+		c := &Customer{Name: name, Email: name + "@example.com", Mobile: "555"}
+		verrs, err := ms.DB.ValidateAndCreate(c)
+		ms.NoError(err)
+		ms.False(verrs.HasAny(), verrs.Error())
+		return c
+	}
+	day := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02", s)
+		ms.NoError(err)
+		return t
+	}
+	lend := func(c *Customer, b *Book, lent, due, returned string) {
+		loan := &AssignBook{CustomerID: c.ID.String(), BookID: b.ID.String(), AssignDate: lent, ReturnDate: due}
+		if returned != "" {
+			loan.ReturnedOn = nulls.NewTime(day(returned))
+		}
+		ms.NoError(ms.DB.Create(loan))
+	}
+	dune, emma, odes := book(fiction, "Dune"), book(fiction, "Emma"), book(poetry, "Odes")
+	ada, bob := customer("Ada"), customer("Bob")
+
+	lend(ada, dune, "2026-10-01", "2026-10-15", "2026-10-05")
+	lend(bob, dune, "2026-10-06", "2026-10-20", "2026-10-16")
+	lend(ada, emma, "2026-10-06", "2026-10-20", "")
+	lend(ada, odes, "2026-10-14", "2026-10-28", "")
+	lend(bob, odes, "2026-09-20", "2026-10-04", "2026-10-02")
+
+	from, to := day("2026-10-01"), day("2026-10-31")
+
+	weeks, err := LoansByPeriod(ms.DB, from, to, ReportWeek)
+	ms.NoError(err)
+	ms.Len(weeks, 5)
+	ms.Equal("week of 2026-09-28", weeks[0].Label)
+	ms.Equal(1, weeks[0].Loans)
+	ms.Equal(1, weeks[0].Returns)
+	ms.Equal(2, weeks[1].Loans)
+	ms.Equal(1, weeks[1].Returns)
+	ms.Equal(1, weeks[2].Loans)
+	ms.Equal(1, weeks[2].Returns)
+	days, err := LoansByPeriod(ms.DB, from, to, ReportDay)
+	ms.NoError(err)
+	ms.Len(days, 31)
+	ms.Equal(2, days[5].Loans)
+	_, err = LoansByPeriod(ms.DB, from, to, "decade")
+	ms.Error(err)
+
+	books, err := MostBorrowedBooks(ms.DB, from, to, 2)
+	ms.NoError(err)
+	ms.Len(books, 2)
+	ms.Equal("Dune", books[0].Name)
+	ms.Equal(2, books[0].Loans)
+	categories, err := MostBorrowedCategories(ms.DB, from, to, 10)
+	ms.NoError(err)
+	ms.Len(categories, 2)
+	ms.Equal("Fiction", categories[0].Name)
+	ms.Equal(3, categories[0].Loans)
+	customers, err := MostActiveCustomers(ms.DB, from, to, 10)
+	ms.NoError(err)
+	ms.Equal("Ada", customers[0].Name)
+	ms.Equal(3, customers[0].Loans)
+	ms.Equal(ada.CardNumber, customers[0].Code)
+
+	overdue, err := OverdueLoans(ms.DB, day("2026-10-25"))
+	ms.NoError(err)
+	ms.Len(overdue, 1)
+	ms.Equal("Emma", overdue[0].Title)
+	ms.Equal(5, overdue[0].Late)
+	ms.Equal(int64(125), overdue[0].Fine.Cents)
+
+	length, err := AverageLoanLength(ms.DB, from, to)
+	ms.NoError(err)
+	ms.Equal(3, length.Loans)
+	ms.Equal(12, length.Longest)
+	ms.InDelta(8.67, length.Average, 0.01)
+}
@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/gobuffalo/buffalo/binding"
 	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gobuffalo/validate/v3/validators"
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"library/upload"
 )
 
 // Customer is used by pop to map your customers database table to your go code.
@@ -20,6 +24,33 @@ type Customer struct {
 	Address   nulls.String `json:"address" db:"address"`
 	CreatedAt time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+
+	// CardNumber is issued when the customer is created; see
+	// CustomerCard.go.
+	CardNumber       string     `json:"card_number" db:"card_number" form:"-"`
+	MembershipPlanID string     `json:"membership_plan_id" db:"membership_plan_id"`
+	JoinedOn         time.Time  `json:"joined_on" db:"joined_on"`
+	ExpiresOn        nulls.Time `json:"expires_on" db:"expires_on"`
+	Status           string     `json:"status" db:"status"`
+
+	// Photo is printed on the library card. Photos are kept with the
+	// profile pictures, which only signed-in users can see.
+	Photo     binding.File `db:"-" form:"photo"`
+	PhotoPath string       `json:"photo_path" db:"photo_path" form:"-"`
+
+	// PasswordHash lets the customer sign in to the patron portal; see
+	// CustomerPortal.go. Customers without a password can't.
+	PasswordHash         string `json:"-" db:"password_hash" form:"-"`
+	Password             string `json:"-" db:"-"`
+	PasswordConfirmation string `json:"-" db:"-"`
+
+	// Customers are emailed notices unless NoEmailNotices is set, and
+	// sent them by text message when SMSNotices is, until they reply
+	// STOP; see CustomerSMS.go. SMSNumber is Mobile in E.164 form.
+	NoEmailNotices bool       `json:"no_email_notices" db:"no_email_notices"`
+	SMSNotices     bool       `json:"sms_notices" db:"sms_notices"`
+	SMSNumber      string     `json:"sms_number" db:"sms_number" form:"-"`
+	SMSOptedOutAt  nulls.Time `json:"sms_opted_out_at" db:"sms_opted_out_at" form:"-"`
 }
 
 // String is not required by pop and may be deleted
@@ -44,6 +75,33 @@ func (c *Customer) Validate(tx *pop.Connection) (*validate.Errors, error) {
 		&validators.StringIsPresent{Field: c.Name, Name: "Name"},
 		&validators.StringIsPresent{Field: c.Email, Name: "Email"},
 		&validators.StringIsPresent{Field: c.Mobile, Name: "Mobile"},
+		&validators.FuncValidator{
+			Field:   "Membership plan",
+			Name:    "MembershipPlanID",
+			Message: "%s does not exist.",
+			Fn: func() bool {
+				exists, err := tx.Where("id = ?", c.MembershipPlanID).Exists(&MembershipPlan{})
+				return err == nil && exists
+			},
+		},
+		&validators.FuncValidator{
+			Field:   c.Status,
+			Name:    "Status",
+			Message: "%s is not a card status",
+			Fn:      func() bool { return included(CustomerStatuses, c.Status) },
+		},
+		&validators.FuncValidator{
+			Field:   c.Mobile,
+			Name:    "Mobile",
+			Message: "%s is not a number text messages can be sent to.",
+			Fn:      func() bool { return !c.SMSNotices || c.SMSNumber != "" },
+		},
+		&validators.FuncValidator{
+			Field:   "Expiry date",
+			Name:    "ExpiresOn",
+			Message: "%s can not be before the joining date.",
+			Fn:      func() bool { return !c.ExpiresOn.Valid || !c.ExpiresOn.Time.Before(c.JoinedOn) },
+		},
 	), nil
 }
 
@@ -58,3 +116,49 @@ func (c *Customer) ValidateCreate(tx *pop.Connection) (*validate.Errors, error)
 func (c *Customer) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.NewErrors(), nil
 }
+
+// Create stores the uploaded photo, if any, and hashes the portal
+// password given, then validates and creates the customer. A photo that
+// isn't an acceptable image is a validation error.
+func (c *Customer) Create(tx *pop.Connection) (*validate.Errors, error) {
+	if verrs, err := c.hashPassword(); err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+	if verrs, err := c.savePhoto(tx); err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+	verrs, err := tx.ValidateAndCreate(c)
+	return verrs, errors.WithStack(err)
+}
+
+// Update stores the uploaded photo, if any, and hashes a new portal
+// password, then validates and updates the customer. The photo it
+// replaces is left in place until the update has committed.
+func (c *Customer) Update(tx *pop.Connection) (*validate.Errors, error) {
+	if verrs, err := c.hashPassword(); err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+	if verrs, err := c.savePhoto(tx); err != nil || verrs.HasAny() {
+		return verrs, err
+	}
+	verrs, err := tx.ValidateAndUpdate(c)
+	return verrs, errors.WithStack(err)
+}
+
+// savePhoto stores the uploaded photo and points PhotoPath at it.
+func (c *Customer) savePhoto(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if !c.Photo.Valid() {
+		return verrs, nil
+	}
+	path, err := saveUpload(tx.Context(), verrs, "photo", "profiles", c.Photo, ProfileUpload)
+	if path != "" {
+		c.PhotoPath = path
+	}
+	return verrs, err
+}
+
+// PhotoThumbnail returns the path of the photo's thumbnail.
+func (c Customer) PhotoThumbnail() string {
+	return upload.Thumbnail(c.PhotoPath)
+}
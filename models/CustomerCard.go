@@ -0,0 +1,205 @@
+package models
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+// Card statuses. A card that is active but past its expiry date counts
+// as expired without being saved as such; see Standing.
+const (
+	CustomerActive    = "active"
+	CustomerSuspended = "suspended"
+	CustomerExpired   = "expired"
+)
+
+// CustomerStatuses lists the statuses a library card can be given.
+var CustomerStatuses = []string{CustomerActive, CustomerSuspended, CustomerExpired}
+
+// CardPrefix starts every card number issued, as the library's code
+// does on Codabar library cards. It is four digits, set by CARD_PREFIX.
+var CardPrefix = "2000"
+
+// MembershipMonths is how long a membership lasts when it starts or is
+// renewed, set by MEMBERSHIP_MONTHS.
+var MembershipMonths = 12
+
+// applyCard puts customers saved without them on the default plan, as
+// active members from the day of now.
+func (c *Customer) applyCard(tx *pop.Connection, now time.Time) error {
+	if c.MembershipPlanID == "" {
+		plan, err := DefaultMembershipPlan(tx)
+		if err != nil {
+			return err
+		}
+		c.MembershipPlanID = plan.ID.String()
+	}
+	if c.Status == "" {
+		c.Status = CustomerActive
+	}
+	if c.JoinedOn.IsZero() {
+		c.JoinedOn = dateOf(now)
+	}
+	return nil
+}
+
+// BeforeValidate fills in the membership defaults so forms may leave
+// them out, and the number text messages go to.
+func (c *Customer) BeforeValidate(tx *pop.Connection) error {
+	c.applySMSNumber()
+	return c.applyCard(tx, time.Now())
+}
+
+// BeforeSave does the same for customers saved without validation,
+// such as imported ones.
+func (c *Customer) BeforeSave(tx *pop.Connection) error {
+	c.applySMSNumber()
+	return c.applyCard(tx, time.Now())
+}
+
+// MembershipPlan returns the plan the customer is on.
+func (c Customer) MembershipPlan(tx *pop.Connection) (*MembershipPlan, error) {
+	plan := &MembershipPlan{}
+	if err := tx.Find(plan, c.MembershipPlanID); err != nil {
+		return nil, errors.Wrapf(err, "finding the membership plan of %s", c.Name)
+	}
+	return plan, nil
+}
+
+// OpenLoans counts the books the customer has borrowed and not yet
+// returned.
+func (c Customer) OpenLoans(tx *pop.Connection) (int, error) {
+	n, err := tx.Where("customer_id = ? AND returned_on IS NULL", c.ID).Count(&AssignBook{})
+	return n, errors.WithStack(err)
+}
+
+// BeforeCreate issues the customer's library card, running for a
+// membership term from the joining date unless an expiry date was
+// given.
+func (c *Customer) BeforeCreate(tx *pop.Connection) error {
+	if !c.ExpiresOn.Valid {
+		c.ExpiresOn = nulls.NewTime(c.JoinedOn.AddDate(0, MembershipMonths, 0))
+	}
+	if c.CardNumber != "" {
+		return nil
+	}
+	number, err := newCardNumber(tx)
+	if err != nil {
+		return err
+	}
+	c.CardNumber = number
+	return nil
+}
+
+// newCardNumber returns a card number no customer holds yet: the
+// prefix, nine random digits and a check digit, fourteen digits in all
+// as Codabar library barcodes have.
+func newCardNumber(tx *pop.Connection) (string, error) {
+	for tries := 0; tries < 10; tries++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000000))
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		number := fmt.Sprintf("%s%09d", CardPrefix, n.Int64())
+		number += string('0' + luhnDigit(number))
+
+		taken, err := tx.Where("card_number = ?", number).Exists(&Customer{})
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if !taken {
+			return number, nil
+		}
+	}
+	return "", errors.New("could not find a free card number")
+}
+
+// luhnDigit returns the check digit the Luhn algorithm adds to digits.
+func luhnDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i -= 2 {
+		d := int(digits[i]-'0') * 2
+		if d > 9 {
+			d -= 9
+		}
+		sum += d
+		if i > 0 {
+			sum += int(digits[i-1] - '0')
+		}
+	}
+	return byte((10 - sum%10) % 10)
+}
+
+// ValidCardNumber reports whether s is made of digits ending in a
+// correct check digit, catching most misread and mistyped numbers
+// before they are looked up.
+func ValidCardNumber(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s[len(s)-1]-'0' == luhnDigit(s[:len(s)-1])
+}
+
+// Standing returns the status of the card on the day of now, which is
+// expired once an active card's expiry date has gone by.
+func (c Customer) Standing(now time.Time) string {
+	if c.Status == CustomerActive && c.ExpiresOn.Valid && dateOf(c.ExpiresOn.Time).Before(dateOf(now)) {
+		return CustomerExpired
+	}
+	return c.Status
+}
+
+// LoanRefusal explains why the customer may not borrow books on the
+// day of now, or returns "" when they may.
+func (c Customer) LoanRefusal(now time.Time) string {
+	switch c.Standing(now) {
+	case CustomerActive:
+		return ""
+	case CustomerSuspended:
+		return fmt.Sprintf("The library card of %s is suspended.", c.Name)
+	default:
+		if c.ExpiresOn.Valid {
+			return fmt.Sprintf("The membership of %s expired on %s and has to be renewed first.", c.Name, c.ExpiresOn.Time.Format("2006-01-02"))
+		}
+		return fmt.Sprintf("The membership of %s has expired and has to be renewed first.", c.Name)
+	}
+}
+
+// Renew extends the membership by a term, from its expiry date if it
+// hasn't expired yet and from the day of now otherwise. An expired card
+// becomes active again; a suspended one stays suspended.
+func (c *Customer) Renew(now time.Time) {
+	from := dateOf(now)
+	if c.ExpiresOn.Valid && dateOf(c.ExpiresOn.Time).After(from) {
+		from = dateOf(c.ExpiresOn.Time)
+	}
+	c.ExpiresOn = nulls.NewTime(from.AddDate(0, MembershipMonths, 0))
+	if c.Status == CustomerExpired {
+		c.Status = CustomerActive
+	}
+}
+
+// dateOf returns the day of t as the database's date columns hold it.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func included(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
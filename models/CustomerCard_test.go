@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) createPlan(name string, maxLoans, maxRenewals int, isDefault bool) *MembershipPlan {
+	plan := &MembershipPlan{Name: name, MaxLoans: maxLoans, LoanDays: 14, MaxRenewals: maxRenewals, FinePerDay: money.New(25, "USD"), IsDefault: isDefault}
+	verrs, err := ms.DB.ValidateAndCreate(plan)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	return plan
+}
+
+func (ms *ModelSuite) Test_Customer_Card() {
+	plan := ms.createPlan("adult", 5, 2, true)
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555"}
+	verrs, err := ms.DB.ValidateAndCreate(customer)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+
+	ms.Len(customer.CardNumber, 14)
+	ms.True(ValidCardNumber(customer.CardNumber))
+	ms.Equal(CardPrefix, customer.CardNumber[:4])
+	ms.Equal(plan.ID.String(), customer.MembershipPlanID)
+	ms.Equal(CustomerActive, customer.Status)
+	ms.Equal(customer.JoinedOn.AddDate(0, MembershipMonths, 0), customer.ExpiresOn.Time)
+
+	other := &Customer{Name: "Bob", Email: "bob@example.com", Mobile: "556", Status: "lost"}
+	verrs, err = ms.DB.ValidateAndCreate(other)
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get("status"))
+}
+
+func (ms *ModelSuite) Test_ValidCardNumber() {
+	ms.True(ValidCardNumber("79927398713"))
+	ms.False(ValidCardNumber("79927398710"))
+	ms.False(ValidCardNumber("7992739871a"))
+	ms.False(ValidCardNumber(""))
+	ms.Equal(byte(3), luhnDigit("7992739871"))
+}
+
+func (ms *ModelSuite) Test_Customer_Standing() {
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.Local)
+	customer := Customer{Name: "Ada", Status: CustomerActive, ExpiresOn: nulls.NewTime(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))}
+	ms.Equal(CustomerActive, customer.Standing(now))
+	ms.Empty(customer.LoanRefusal(now))
+
+	later := now.AddDate(0, 0, 1)
+	ms.Equal(CustomerExpired, customer.Standing(later))
+	ms.Contains(customer.LoanRefusal(later), "expired on 2026-10-19")
+
+	customer.Status = CustomerSuspended
+	ms.Contains(customer.LoanRefusal(now), "suspended")
+}
+
+func (ms *ModelSuite) Test_Customer_Renew() {
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+	// renewed early, from the expiry date
+	customer := Customer{Status: CustomerActive, ExpiresOn: nulls.NewTime(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC))}
+	customer.Renew(now)
+	ms.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC).AddDate(0, MembershipMonths, 0), customer.ExpiresOn.Time)
+
+	// renewed late, from today
+	customer = Customer{Status: CustomerExpired, ExpiresOn: nulls.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))}
+	customer.Renew(now)
+	ms.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC).AddDate(0, MembershipMonths, 0), customer.ExpiresOn.Time)
+	ms.Equal(CustomerActive, customer.Status)
+
+	customer = Customer{Status: CustomerSuspended}
+	customer.Renew(now)
+	ms.Equal(CustomerSuspended, customer.Status)
+}
+
+func (ms *ModelSuite) Test_AssignBook_RefusesInactiveCards() {
+	ms.createPlan("adult", 5, 2, true)
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555", Status: CustomerSuspended}
+	ms.NoError(ms.DB.Create(customer))
+
+	loan := &AssignBook{CustomerID: customer.ID.String(), BookID: "b", AssignDate: "2026-10-19", ReturnDate: "2026-11-02"}
+	verrs, err := ms.DB.ValidateAndCreate(loan)
+	ms.NoError(err)
+	ms.Contains(verrs.Get("customer_id"), "The library card of Ada is suspended.")
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// FineLimit is the most a customer may owe in fines and still borrow.
+// FINE_LIMIT sets it, in the library's currency.
+var FineLimit = money.New(500, "")
+
+// FinesOwed adds up the unpaid fines of books returned late and the
+// fines books still out are running up on the day of now.
+func (c Customer) FinesOwed(tx *pop.Connection, now time.Time) (money.Money, error) {
+	owed := money.New(0, money.DefaultCurrency)
+	loans := AssignBooks{}
+	if err := tx.Where("customer_id = ? AND (returned_on IS NULL OR (fine > 0 AND fine_paid = false))", c.ID).All(&loans); err != nil {
+		return owed, errors.WithStack(err)
+	}
+	var plan *MembershipPlan
+	for _, loan := range loans {
+		fine := loan.Fine
+		if !loan.ReturnedOn.Valid {
+			days := loan.DaysLate(now)
+			if days == 0 {
+				continue
+			}
+			if plan == nil {
+				var err error
+				if plan, err = c.MembershipPlan(tx); err != nil {
+					return owed, err
+				}
+			}
+			fine = plan.Fine(days)
+		}
+		var err error
+		if owed, err = owed.Add(fine); err != nil {
+			return owed, err
+		}
+	}
+	return owed, nil
+}
+
+// SettleFines records the fines of the books the customer has returned
+// as paid. Fines of books still out are charged when they come back.
+func (c Customer) SettleFines(tx *pop.Connection) error {
+	err := tx.RawQuery("UPDATE assign_books SET fine_paid = true WHERE customer_id = ? AND returned_on IS NOT NULL AND fine > 0 AND fine_paid = false", c.ID).Exec()
+	return errors.WithStack(err)
+}
+
+// fineRefusal explains why fines keep the customer from borrowing on
+// the day of now, or returns "" when they don't.
+func (c Customer) fineRefusal(tx *pop.Connection, now time.Time) (string, error) {
+	owed, err := c.FinesOwed(tx, now)
+	if err != nil {
+		return "", err
+	}
+	if owed.Cents <= FineLimit.Cents {
+		return "", nil
+	}
+	limit := money.New(FineLimit.Cents, owed.Currency)
+	return fmt.Sprintf("%s owes %s in fines, more than the %s allowed.", c.Name, owed, limit), nil
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// ReminderDays is how many days before a book is due its borrower is
+// reminded, or 0 for no reminders. REMINDER_DAYS sets it.
+var ReminderDays = 2
+
+// OverdueNoticeDays are how many days late a book is when its borrower
+// is told: 1, 7, 14 and 28 days by default. OVERDUE_NOTICE_DAYS sets them,
+// separated by commas.
+var OverdueNoticeDays = []int{1, 7, 14, 28}
+
+// Notice is something a customer should be told about a loan or a hold.
+type Notice struct {
+	Kind     string
+	Customer Customer
+	// AboutID is the loan or hold the notice is about.
+	AboutID string
+	// Occasion tells the notices about a loan or hold apart, so each is
+	// sent once: the due date of a reminder, the due date and step of an
+	// overdue notice, the last day a held book waits.
+	Occasion string
+	Title    string
+	BookNo   string
+	// Due is the date the book is due back, or the last day a held book
+	// waits for the customer.
+	Due      string
+	DaysLate int
+	Fine     money.Money
+}
+
+type noticeLoan struct {
+	AssignBook
+	Title  string `db:"title"`
+	BookNo string `db:"book_no"`
+}
+
+type noticeHold struct {
+	Hold
+	Title  string `db:"title"`
+	BookNo string `db:"book_no"`
+}
+
+// overdueStep is the last of OverdueNoticeDays a book days late has
+// reached, or 0 when it has reached none.
+func overdueStep(days int) int {
+	step := 0
+	for _, d := range OverdueNoticeDays {
+		if d <= days && d > step {
+			step = d
+		}
+	}
+	return step
+}
+
+// PendingNotices finds what customers who want notices on the channel
+// should be told on the day of now and haven't been: books due within
+// ReminderDays, books late by one of OverdueNoticeDays and held books
+// put aside for them. A book late past several steps is only told about
+// once, at the last one.
+func PendingNotices(tx *pop.Connection, now time.Time, channel string) ([]Notice, error) {
+	today := dateOf(now)
+	notices := []Notice{}
+	customers := map[string]*Customer{}
+	plans := map[string]*MembershipPlan{}
+	customer := func(id string) (*Customer, error) {
+		if c, ok := customers[id]; ok {
+			return c, nil
+		}
+		c := &Customer{}
+		if err := tx.Find(c, id); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		customers[id] = c
+		return c, nil
+	}
+
+	loans := []noticeLoan{}
+	until := today.AddDate(0, 0, ReminderDays).Format("2006-01-02")
+	if err := tx.RawQuery("SELECT assign_books.*, books.title, books.book_no FROM assign_books JOIN books ON books.id = assign_books.book_id WHERE assign_books.returned_on IS NULL AND assign_books.return_date <= ?", until).All(&loans); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, loan := range loans {
+		due, err := loanDate(loan.ReturnDate)
+		if err != nil {
+			continue
+		}
+		n := Notice{AboutID: loan.ID.String(), Title: loan.Title, BookNo: loan.BookNo, Due: due.Format("2006-01-02")}
+		if days := loan.DaysLate(now); days > 0 {
+			step := overdueStep(days)
+			if step == 0 {
+				continue
+			}
+			n.Kind, n.Occasion, n.DaysLate = NoticeOverdue, fmt.Sprintf("%s+%d", n.Due, step), days
+		} else {
+			if ReminderDays <= 0 || due.Before(today) {
+				continue
+			}
+			n.Kind, n.Occasion = NoticeDueSoon, n.Due
+		}
+		c, err := customer(loan.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		n.Customer = *c
+		if n.Kind == NoticeOverdue {
+			plan, ok := plans[c.MembershipPlanID]
+			if !ok {
+				if plan, err = c.MembershipPlan(tx); err != nil {
+					return nil, err
+				}
+				plans[c.MembershipPlanID] = plan
+			}
+			n.Fine = plan.Fine(n.DaysLate)
+		}
+		notices = append(notices, n)
+	}
+
+	holds := []noticeHold{}
+	if err := tx.RawQuery("SELECT holds.*, books.title, books.book_no FROM holds JOIN books ON books.id = holds.book_id WHERE holds.status = ? AND holds.ready_until >= ?", HoldReady, today.Format("2006-01-02")).All(&holds); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, hold := range holds {
+		c, err := customer(hold.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		notices = append(notices, holdNotice(*c, hold))
+	}
+
+	pending := notices[:0]
+	for _, n := range notices {
+		if !included(n.Customer.Channels(), channel) {
+			continue
+		}
+		sent, err := n.Sent(tx, channel)
+		if err != nil {
+			return nil, err
+		}
+		if !sent {
+			pending = append(pending, n)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Customer.Name < pending[j].Customer.Name
+	})
+	return pending, nil
+}
+
+func holdNotice(c Customer, hold noticeHold) Notice {
+	until := hold.ReadyUntil.Time.Format("2006-01-02")
+	return Notice{
+		Kind:     NoticeHoldReady,
+		Customer: c,
+		AboutID:  hold.ID.String(),
+		Occasion: until,
+		Title:    hold.Title,
+		BookNo:   hold.BookNo,
+		Due:      until,
+	}
+}
+
+// HoldNotice is the notice telling the customer of a ready hold that the
+// book is put aside for them.
+func HoldNotice(tx *pop.Connection, hold Hold) (Notice, error) {
+	c := &Customer{}
+	if err := tx.Find(c, hold.CustomerID); err != nil {
+		return Notice{}, errors.WithStack(err)
+	}
+	book := &Book{}
+	if err := tx.Find(book, hold.BookID); err != nil {
+		return Notice{}, errors.WithStack(err)
+	}
+	return holdNotice(*c, noticeHold{Hold: hold, Title: book.Title, BookNo: book.BookNo}), nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_PendingNotices() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555"}
+	verrs, err := ms.DB.ValidateAndCreate(customer)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	book := func(bookNo string) *Book {
+		b := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(100, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(b))
+		return b
+	}
+	now := time.Now()
+	day := func(days int) string {
+		return now.AddDate(0, 0, days).Format("2006-01-02")
+	}
+	lend := func(b *Book, from, due int) *AssignBook {
+		loan := &AssignBook{CustomerID: customer.ID.String(), BookID: b.ID.String(), AssignDate: day(from), ReturnDate: day(due)}
+		verrs, err := ms.DB.ValidateAndCreate(loan)
+		ms.NoError(err)
+		ms.False(verrs.HasAny(), verrs.Error())
+		return loan
+	}
+
+	soon := lend(book("Soon"), -12, 2)
+	late := lend(book("Late"), -22, -8)
+	lend(book("Later"), 0, 14)
+	hold := &Hold{CustomerID: customer.ID.String(), BookID: book("Held").ID.String(), Status: HoldReady, ReadyUntil: nulls.NewTime(now.AddDate(0, 0, 7))}
+	ms.NoError(ms.DB.Create(hold))
+
+	notices, err := PendingNotices(ms.DB, now, ChannelEmail)
+	ms.NoError(err)
+	ms.Len(notices, 3)
+	kinds := map[string]Notice{}
+	for _, n := range notices {
+		kinds[n.Kind] = n
+	}
+	ms.Equal(soon.ID.String(), kinds[NoticeDueSoon].AboutID)
+	ms.Equal(day(2), kinds[NoticeDueSoon].Occasion)
+	ms.Equal(late.ID.String(), kinds[NoticeOverdue].AboutID)
+	ms.Equal(day(-8)+"+7", kinds[NoticeOverdue].Occasion)
+	ms.Equal(8, kinds[NoticeOverdue].DaysLate)
+	ms.Equal(int64(200), kinds[NoticeOverdue].Fine.Cents)
+	ms.Equal(hold.ID.String(), kinds[NoticeHoldReady].AboutID)
+	ms.Equal("Held", kinds[NoticeHoldReady].Title)
+
+	// a notice that failed to send is tried again, one sent is not
+	note, err := kinds[NoticeDueSoon].Record(ms.DB, ChannelEmail, "ada@example.com", "Soon is due", now, errors.New("connection refused"))
+	ms.NoError(err)
+	ms.Equal(NotificationFailed, note.Status)
+	for _, n := range notices {
+		_, err := n.Record(ms.DB, ChannelEmail, "ada@example.com", n.Title, now, nil)
+		ms.NoError(err)
+	}
+	notices, err = PendingNotices(ms.DB, now, ChannelEmail)
+	ms.NoError(err)
+	ms.Empty(notices)
+	ms.NoError(ms.DB.Reload(note))
+	ms.Equal(NotificationSent, note.Status)
+	ms.Equal(2, note.Attempts)
+	count, err := ms.DB.Count(&Notification{})
+	ms.NoError(err)
+	ms.Equal(3, count)
+
+	// a week on both books are late
+	notices, err = PendingNotices(ms.DB, now.AddDate(0, 0, 7), ChannelEmail)
+	ms.NoError(err)
+	ms.Len(notices, 2)
+	occasions := map[string]string{}
+	for _, n := range notices {
+		ms.Equal(NoticeOverdue, n.Kind)
+		occasions[n.AboutID] = n.Occasion
+	}
+	ms.Equal(day(2)+"+1", occasions[soon.ID.String()])
+	ms.Equal(day(-8)+"+14", occasions[late.ID.String()])
+}
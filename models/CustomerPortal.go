@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password a customer may sign in to
+// the patron portal with.
+var MinPasswordLength = 8
+
+// hashPassword replaces the customer's portal password with the one in
+// Password, when one was given.
+func (c *Customer) hashPassword() (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if c.Password == "" {
+		return verrs, nil
+	}
+	if len(c.Password) < MinPasswordLength {
+		verrs.Add("password", fmt.Sprintf("Password must be at least %d characters long.", MinPasswordLength))
+	}
+	if c.Password != c.PasswordConfirmation {
+		verrs.Add("password_confirmation", "Password does not match confirmation")
+	}
+	if verrs.HasAny() {
+		return verrs, nil
+	}
+	ph, err := bcrypt.GenerateFromPassword([]byte(c.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	c.PasswordHash = string(ph)
+	c.Password, c.PasswordConfirmation = "", ""
+	return verrs, nil
+}
+
+// CheckPassword reports whether password is the customer's portal
+// password.
+func (c Customer) CheckPassword(password string) bool {
+	if c.PasswordHash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(c.PasswordHash), []byte(password)) == nil
+}
+
+// FindPatron finds the customer signing in to the patron portal with
+// their card number or email address and password. It returns nil when
+// none matches.
+func FindPatron(tx *pop.Connection, login, password string) (*Customer, error) {
+	login = strings.TrimSpace(login)
+	q := tx.Where("email = ?", login)
+	if ValidCardNumber(login) {
+		q = tx.Where("card_number = ?", login)
+	}
+	customers := Customers{}
+	if err := q.Where("password_hash <> ''").All(&customers); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	for i := range customers {
+		if customers[i].CheckPassword(password) {
+			return &customers[i], nil
+		}
+	}
+	return nil, nil
+}
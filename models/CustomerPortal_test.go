@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_Customer_Portal() {
+	ms.createPlan("adult", 5, 2, true)
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555", Password: "short", PasswordConfirmation: "short"}
+	verrs, err := customer.Create(ms.DB)
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get("password"))
+
+	customer.Password, customer.PasswordConfirmation = "correct horse", "correct horse"
+	verrs, err = customer.Create(ms.DB)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.NotEmpty(customer.PasswordHash)
+
+	for _, login := range []string{customer.CardNumber, "ada@example.com"} {
+		found, err := FindPatron(ms.DB, login, "correct horse")
+		ms.NoError(err)
+		ms.NotNil(found, login)
+		found, err = FindPatron(ms.DB, login, "wrong")
+		ms.NoError(err)
+		ms.Nil(found, login)
+	}
+
+	// customers without a password can't sign in
+	other := &Customer{Name: "Bob", Email: "bob@example.com", Mobile: "555"}
+	ms.NoError(ms.DB.Create(other))
+	found, err := FindPatron(ms.DB, "bob@example.com", "")
+	ms.NoError(err)
+	ms.Nil(found)
+}
+
+func (ms *ModelSuite) Test_Book_HoldRefusal() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := &Book{CategoryID: category.ID.String(), Title: "Dune", BookNo: "B1", Author: "Herbert", Price: money.New(100, "USD"), Status: 1}
+	ms.NoError(ms.DB.Create(book))
+	ada := &Customer{Name: "ada", Email: "ada@example.com", Mobile: "555"}
+	ms.NoError(ms.DB.Create(ada))
+	bob := &Customer{Name: "bob", Email: "bob@example.com", Mobile: "555"}
+	ms.NoError(ms.DB.Create(bob))
+	now := time.Now()
+
+	refusal, err := book.HoldRefusal(ms.DB, *bob, now)
+	ms.NoError(err)
+	ms.Equal("A copy of Dune is on the shelf; borrow it at the desk.", refusal)
+
+	loan := &AssignBook{CustomerID: ada.ID.String(), BookID: book.ID.String()}
+	verrs, err := ms.DB.ValidateAndCreate(loan)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+
+	refusal, err = book.HoldRefusal(ms.DB, *ada, now)
+	ms.NoError(err)
+	ms.Equal("ada already has Dune on loan.", refusal)
+	refusal, err = book.HoldRefusal(ms.DB, *bob, now)
+	ms.NoError(err)
+	ms.Empty(refusal)
+
+	// ada can't renew a book bob is waiting for
+	verrs, err = ms.DB.ValidateAndCreate(&Hold{CustomerID: bob.ID.String(), BookID: book.ID.String()})
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	verrs, err = loan.Renew(ms.DB, now)
+	ms.NoError(err)
+	ms.Equal([]string{"Dune is on hold for another customer."}, verrs.Get("book_id"))
+}
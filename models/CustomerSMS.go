@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/sms"
+)
+
+// SMSCountryCode is the country code of mobile numbers written without
+// one, as set by SMS_COUNTRY_CODE.
+var SMSCountryCode = "1"
+
+// SMSStopWords opt a customer out of text messages when they reply with
+// one; SMSStartWords opt them back in.
+var (
+	SMSStopWords  = []string{"STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT"}
+	SMSStartWords = []string{"START", "UNSTOP"}
+)
+
+// applySMSNumber keeps SMSNumber in step with Mobile. It is left empty
+// when Mobile isn't a number text messages can be sent to.
+func (c *Customer) applySMSNumber() {
+	c.SMSNumber, _ = sms.Normalize(c.Mobile, SMSCountryCode)
+}
+
+// Channels are the channels the customer wants notices on and can be
+// reached on.
+func (c Customer) Channels() []string {
+	channels := []string{}
+	if !c.NoEmailNotices && strings.TrimSpace(c.Email) != "" {
+		channels = append(channels, ChannelEmail)
+	}
+	if c.SMSNotices && !c.SMSOptedOutAt.Valid && c.SMSNumber != "" {
+		channels = append(channels, ChannelSMS)
+	}
+	return channels
+}
+
+// OptInSMS has the customer sent notices by text message, undoing a
+// STOP they replied with before. Only the customer may ask for it.
+func (c *Customer) OptInSMS() {
+	c.SMSNotices = true
+	c.SMSOptedOutAt.Valid = false
+}
+
+// SMSReply acts on a text message sent to the library from number: a
+// stop word opts the customers with that mobile out of text messages at
+// now, a start word opts those who had opted out back in. It returns
+// the customers it changed, and nothing for other messages.
+func SMSReply(tx *pop.Connection, number, text string, now time.Time) (Customers, error) {
+	customers := Customers{}
+	from, err := sms.Normalize(number, SMSCountryCode)
+	if err != nil {
+		return customers, nil
+	}
+	word := strings.ToUpper(strings.Trim(strings.TrimSpace(text), ".!"))
+	var q *pop.Query
+	switch {
+	case included(SMSStopWords, word):
+		q = tx.Where("sms_number = ? AND sms_opted_out_at IS NULL", from)
+	case included(SMSStartWords, word):
+		q = tx.Where("sms_number = ? AND sms_opted_out_at IS NOT NULL", from)
+	default:
+		return customers, nil
+	}
+	if err := q.All(&customers); err != nil {
+		return customers, errors.WithStack(err)
+	}
+	for i := range customers {
+		c := &customers[i]
+		if included(SMSStopWords, word) {
+			c.SMSOptedOutAt.Time, c.SMSOptedOutAt.Valid = now, true
+		} else {
+			c.OptInSMS()
+		}
+		if err := tx.UpdateColumns(c, "sms_notices", "sms_opted_out_at", "updated_at"); err != nil {
+			return customers, errors.WithStack(err)
+		}
+	}
+	return customers, nil
+}
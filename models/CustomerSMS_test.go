@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+func (ms *ModelSuite) Test_Customer_SMS() {
+	ms.createPlan("adult", 5, 2, true)
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "(555) 123-4567"}
+	verrs, err := ms.DB.ValidateAndCreate(customer)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.Equal("+15551234567", customer.SMSNumber)
+	ms.Equal([]string{ChannelEmail}, customer.Channels())
+
+	customer.SMSNotices = true
+	customer.NoEmailNotices = true
+	verrs, err = ms.DB.ValidateAndUpdate(customer)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.Equal([]string{ChannelSMS}, customer.Channels())
+
+	customer.Mobile = "ask at the desk"
+	verrs, err = ms.DB.ValidateAndUpdate(customer)
+	ms.NoError(err)
+	ms.NotEmpty(verrs.Get("mobile"))
+	customer.Mobile = "555 123 4567"
+
+	// replies from other numbers or with other words change nothing
+	changed, err := SMSReply(ms.DB, "+15559999999", "STOP", time.Now())
+	ms.NoError(err)
+	ms.Empty(changed)
+	changed, err = SMSReply(ms.DB, "+15551234567", "thanks!", time.Now())
+	ms.NoError(err)
+	ms.Empty(changed)
+
+	changed, err = SMSReply(ms.DB, "+1 555 123 4567", "stop", time.Now())
+	ms.NoError(err)
+	ms.Len(changed, 1)
+	ms.NoError(ms.DB.Reload(customer))
+	ms.True(customer.SMSOptedOutAt.Valid)
+	ms.Empty(customer.Channels())
+
+	changed, err = SMSReply(ms.DB, "+15551234567", "Start", time.Now())
+	ms.NoError(err)
+	ms.Len(changed, 1)
+	ms.NoError(ms.DB.Reload(customer))
+	ms.False(customer.SMSOptedOutAt.Valid)
+	ms.Equal([]string{ChannelSMS}, customer.Channels())
+}
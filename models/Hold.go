@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Hold statuses. A hold waits in a queue for its book, is ready when a
+// returned copy has been put aside for the customer, and is fulfilled
+// when the customer borrows it, or expired when they don't come for it
+// in time.
+const (
+	HoldWaiting   = "waiting"
+	HoldReady     = "ready"
+	HoldFulfilled = "fulfilled"
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired"
+)
+
+// HoldStatuses are the statuses a hold can have.
+var HoldStatuses = []string{HoldWaiting, HoldReady, HoldFulfilled, HoldCancelled, HoldExpired}
+
+// HoldShelfDays is how many days a copy put aside for a hold waits for
+// the customer. HOLD_SHELF_DAYS sets it.
+var HoldShelfDays = 7
+
+// Hold is used by pop to map your holds database table to your go code.
+// A customer places a hold to borrow a book that is out; holds on a
+// book are served in the order they were placed.
+type Hold struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CustomerID string     `json:"customer_id" db:"customer_id"`
+	BookID     string     `json:"book_id" db:"book_id"`
+	Status     string     `json:"status" db:"status"`
+	ReadyUntil nulls.Time `json:"ready_until" db:"ready_until"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (h Hold) String() string {
+	jh, _ := json.Marshal(h)
+	return string(jh)
+}
+
+// Holds is not required by pop and may be deleted
+type Holds []Hold
+
+// String is not required by pop and may be deleted
+func (h Holds) String() string {
+	jh, _ := json.Marshal(h)
+	return string(jh)
+}
+
+// activeHolds limits q to holds still to be served on the day of now:
+// those waiting and those put aside whose time hasn't run out.
+func activeHolds(q *pop.Query, now time.Time) *pop.Query {
+	return q.Where("(holds.status = ? OR (holds.status = ? AND holds.ready_until >= ?))", HoldWaiting, HoldReady, dateOf(now).Format("2006-01-02"))
+}
+
+// Active reports whether the hold is still to be served on the day of
+// now.
+func (h Hold) Active(now time.Time) bool {
+	switch h.Status {
+	case HoldWaiting:
+		return true
+	case HoldReady:
+		return !h.ReadyUntil.Valid || !dateOf(h.ReadyUntil.Time).Before(dateOf(now))
+	}
+	return false
+}
+
+// Cancel withdraws a hold that hasn't been fulfilled.
+func (h *Hold) Cancel(tx *pop.Connection) error {
+	if h.Status == HoldFulfilled || h.Status == HoldCancelled || h.Status == HoldExpired {
+		return nil
+	}
+	h.Status = HoldCancelled
+	return errors.WithStack(tx.Update(h))
+}
+
+// ExpireHolds ends the holds whose book waited for the customer until
+// before the day of now, and puts each copy aside for the next hold in
+// its queue, if any. It returns the holds it expired and those it
+// readied.
+func ExpireHolds(tx *pop.Connection, now time.Time) (Holds, Holds, error) {
+	expired, readied := Holds{}, Holds{}
+	if err := tx.Where("status = ? AND ready_until < ?", HoldReady, dateOf(now).Format("2006-01-02")).Order("ready_until, created_at").All(&expired); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	for i := range expired {
+		h := &expired[i]
+		h.Status = HoldExpired
+		if err := tx.Update(h); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		next, err := Book{ID: uuid.FromStringOrNil(h.BookID)}.readyNextHold(tx, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		if next != nil {
+			readied = append(readied, *next)
+		}
+	}
+	return expired, readied, nil
+}
+
+// BeforeValidate places new holds at the back of the queue.
+func (h *Hold) BeforeValidate(tx *pop.Connection) error {
+	if h.Status == "" {
+		h.Status = HoldWaiting
+	}
+	return nil
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (h *Hold) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: h.CustomerID, Name: "CustomerID"},
+		&validators.StringIsPresent{Field: h.BookID, Name: "BookID"},
+		&validators.FuncValidator{
+			Field:   h.Status,
+			Name:    "Status",
+			Message: "%s is not a hold status",
+			Fn:      func() bool { return included(HoldStatuses, h.Status) },
+		},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// A customer holds a book once.
+func (h *Hold) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	held, err := activeHolds(tx.Where("customer_id = ? AND book_id = ?", h.CustomerID, h.BookID), time.Now()).Exists(&Hold{})
+	if err != nil {
+		return verrs, errors.WithStack(err)
+	}
+	if held {
+		verrs.Add("book_id", "The book is already on hold for the customer.")
+	}
+	return verrs, nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (h *Hold) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
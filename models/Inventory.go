@@ -2,19 +2,28 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gobuffalo/validate/v3/validators"
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
 )
 
+// DefaultBranch is the branch stock is kept at unless another is given.
+// Books that were never counted into the inventory are taken to be a
+// single copy there.
+const DefaultBranch = "Main"
+
 // Inventory is used by pop to map your inventories database table to your go code.
+// Each entry is the copies of a book kept at a branch.
 type Inventory struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	BookID    string    `json:"book_id" db:"book_id"`
 	Qty       int       `json:"qty" db:"qty"`
+	Branch    string    `json:"branch" db:"branch"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	Book      *Book     `belongs_to:"books"`
@@ -35,6 +44,36 @@ func (i Inventories) String() string {
 	return string(ji)
 }
 
+// BeforeValidate keeps the entry at DefaultBranch when no branch is
+// given.
+func (i *Inventory) BeforeValidate(tx *pop.Connection) error {
+	i.Branch = strings.TrimSpace(i.Branch)
+	if i.Branch == "" {
+		i.Branch = DefaultBranch
+	}
+	return nil
+}
+
+// Branches lists the branches stock is kept at, DefaultBranch among
+// them, by name.
+func Branches(tx *pop.Connection) ([]string, error) {
+	var rows []struct {
+		Branch string `db:"branch"`
+	}
+	if err := tx.RawQuery("SELECT DISTINCT branch FROM inventories ORDER BY branch").All(&rows); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	branches, main := []string{}, false
+	for _, row := range rows {
+		branches = append(branches, row.Branch)
+		main = main || row.Branch == DefaultBranch
+	}
+	if !main {
+		branches = append([]string{DefaultBranch}, branches...)
+	}
+	return branches, nil
+}
+
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 // This method is not required and may be deleted.
 func (i *Inventory) Validate(tx *pop.Connection) (*validate.Errors, error) {
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// StockValuation is what the copies of a category's books kept at a
+// branch are worth at their prices, in one currency.
+type StockValuation struct {
+	// Category is blank for books without one.
+	Category string      `db:"category"`
+	Branch   string      `db:"branch"`
+	Currency string      `db:"currency"`
+	Titles   int         `db:"titles"`
+	Copies   int         `db:"copies"`
+	Value    money.Money `db:"value"`
+}
+
+// InventoryValuation values the stock by category, branch and currency,
+// in that order. Books that were never counted into the inventory are
+// taken to be a single copy at DefaultBranch.
+func InventoryValuation(tx *pop.Connection) ([]StockValuation, error) {
+	valuation := []StockValuation{}
+	q := `SELECT category, branch, currency, COUNT(DISTINCT book_id) AS titles, SUM(qty) AS copies, COALESCE(SUM(qty * price), 0) AS value
+		FROM (SELECT COALESCE(categories.category_name, '') AS category, stock.branch, COALESCE(NULLIF(books.currency, ''), ?) AS currency,
+			books.id AS book_id, stock.qty, books.price
+			FROM books
+			JOIN (SELECT book_id, branch, SUM(qty) AS qty FROM inventories GROUP BY book_id, branch
+				UNION ALL SELECT id AS book_id, ? AS branch, 1 AS qty FROM books WHERE NOT EXISTS (SELECT 1 FROM inventories WHERE inventories.book_id = books.id)) stock ON stock.book_id = books.id
+			LEFT JOIN categories ON categories.id = books.category_id) stocked
+		GROUP BY category, branch, currency
+		ORDER BY category, branch, currency`
+	if err := tx.RawQuery(q, money.DefaultCurrency, DefaultBranch).All(&valuation); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := range valuation {
+		valuation[i].Value.Currency = valuation[i].Currency
+	}
+	return valuation, nil
+}
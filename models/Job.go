@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Job statuses. A job is queued to run at RunAt, running while a worker
+// has it, and done when it succeeded. One that fails is queued again
+// later, until it has failed MaxAttempts times and is failed for good.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// JobStatuses are the statuses a job can have.
+var JobStatuses = []string{JobQueued, JobRunning, JobDone, JobFailed}
+
+// Job is used by pop to map your jobs database table to your go code.
+// It is a piece of background work for the jobs.Queue worker: which
+// handler to run, with what arguments, and how its runs went.
+type Job struct {
+	ID      uuid.UUID `json:"id" db:"id"`
+	Queue   string    `json:"queue" db:"queue"`
+	Handler string    `json:"handler" db:"handler"`
+	// Args are the handler's worker.Args, as JSON.
+	Args        string    `json:"args" db:"args"`
+	Status      string    `json:"status" db:"status"`
+	Attempts    int       `json:"attempts" db:"attempts"`
+	MaxAttempts int       `json:"max_attempts" db:"max_attempts"`
+	RunAt       time.Time `json:"run_at" db:"run_at"`
+	// UniqueKey keeps a job from being queued twice, such as a
+	// scheduled one by two instances of the app.
+	UniqueKey  nulls.String `json:"unique_key" db:"unique_key"`
+	LockedBy   string       `json:"locked_by" db:"locked_by"`
+	LockedAt   nulls.Time   `json:"locked_at" db:"locked_at"`
+	StartedAt  nulls.Time   `json:"started_at" db:"started_at"`
+	FinishedAt nulls.Time   `json:"finished_at" db:"finished_at"`
+	LastError  nulls.String `json:"last_error" db:"last_error"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (j Job) String() string {
+	jj, _ := json.Marshal(j)
+	return string(jj)
+}
+
+// Jobs is not required by pop and may be deleted
+type Jobs []Job
+
+// String is not required by pop and may be deleted
+func (j Jobs) String() string {
+	jj, _ := json.Marshal(j)
+	return string(jj)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (j *Job) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: j.Handler, Name: "Handler"},
+		&validators.FuncValidator{
+			Field:   j.Status,
+			Name:    "Status",
+			Message: "%s is not a job status",
+			Fn:      func() bool { return included(JobStatuses, j.Status) },
+		},
+	), nil
+}
+
+// Duration is how long the job's last run took, or 0 when it hasn't
+// finished one.
+func (j Job) Duration() time.Duration {
+	if !j.StartedAt.Valid || !j.FinishedAt.Valid {
+		return 0
+	}
+	return j.FinishedAt.Time.Sub(j.StartedAt.Time)
+}
+
+// Retry queues a job that failed for good to run again at now, with
+// all its attempts ahead of it.
+func (j *Job) Retry(tx *pop.Connection, now time.Time) error {
+	if j.Status != JobFailed {
+		return errors.Errorf("only failed jobs are retried, job %s is %s", j.ID, j.Status)
+	}
+	j.Status, j.Attempts, j.RunAt = JobQueued, 0, now
+	j.LockedBy, j.LockedAt = "", nulls.Time{}
+	return errors.WithStack(tx.Update(j))
+}
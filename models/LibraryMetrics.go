@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// LibraryMetrics are the library's figures at a point in time, as shown
+// on the dashboard.
+type LibraryMetrics struct {
+	At time.Time `json:"at"`
+	// Titles counts the books in the catalog, Copies the copies of them
+	// in stock; see Book.Copies.
+	Titles       int `json:"titles"`
+	Copies       int `json:"copies"`
+	OnLoan       int `json:"on_loan"`
+	Overdue      int `json:"overdue"`
+	HoldsWaiting int `json:"holds_waiting"`
+	HoldsReady   int `json:"holds_ready"`
+	// NewCustomers joined in the month so far.
+	NewCustomers int `json:"new_customers"`
+	// FinesOutstanding adds up the unpaid fines of books returned late
+	// and those books still out are running up.
+	FinesOutstanding money.Money   `json:"fines_outstanding"`
+	Loans            []PeriodCount `json:"loans"`
+}
+
+// MetricsLoanDays is how many days of loans the metrics chart.
+const MetricsLoanDays = 30
+
+// CurrentMetrics works out the library's figures on the day of now.
+func CurrentMetrics(tx *pop.Connection, now time.Time) (*LibraryMetrics, error) {
+	m := &LibraryMetrics{At: now}
+	today := dateOf(now)
+	var err error
+
+	if m.Titles, err = tx.Count(&Book{}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	stock := struct {
+		Counted   int `db:"counted"`
+		Uncounted int `db:"uncounted"`
+	}{}
+	if err := tx.RawQuery("SELECT (SELECT COALESCE(SUM(inventories.qty), 0) FROM inventories JOIN books ON books.id = inventories.book_id) AS counted, (SELECT COUNT(*) FROM books WHERE NOT EXISTS (SELECT 1 FROM inventories WHERE inventories.book_id = books.id)) AS uncounted").First(&stock); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.Copies = stock.Counted + stock.Uncounted
+
+	if m.OnLoan, err = tx.Where("returned_on IS NULL").Count(&AssignBook{}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	overdue, err := OverdueLoans(tx, now)
+	if err != nil {
+		return nil, err
+	}
+	m.Overdue = len(overdue)
+	if m.HoldsWaiting, err = tx.Where("status = ?", HoldWaiting).Count(&Hold{}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if m.HoldsReady, err = tx.Where("status = ? AND ready_until >= ?", HoldReady, today.Format("2006-01-02")).Count(&Hold{}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	if m.NewCustomers, err = tx.Where("created_at >= ?", month).Count(&Customer{}); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	unpaid := struct {
+		Fines money.Money `db:"fines"`
+	}{}
+	if err := tx.RawQuery("SELECT COALESCE(SUM(fine), 0) AS fines FROM assign_books WHERE returned_on IS NOT NULL AND fine > 0 AND fine_paid = false").First(&unpaid); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	m.FinesOutstanding = money.New(unpaid.Fines.Cents, money.DefaultCurrency)
+	for _, loan := range overdue {
+		if m.FinesOutstanding, err = m.FinesOutstanding.Add(money.New(loan.Fine.Cents, money.DefaultCurrency)); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.Loans, err = LoansByPeriod(tx, today.AddDate(0, 0, 1-MetricsLoanDays), today, ReportDay); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_CurrentMetrics() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := func(bookNo string) *Book {
+		b := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(100, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(b))
+		return b
+	}
+	dune, emma := book("Dune"), book("Emma")
+	ms.NoError(ms.DB.Create(&Inventory{BookID: dune.ID.String(), Qty: 3}))
+	ada := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555"}
+	verrs, err := ms.DB.ValidateAndCreate(ada)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+
+	now := time.Now()
+	day := func(days int) string {
+		return now.AddDate(0, 0, days).Format("2006-01-02")
+	}
+	// out and 4 days late
+	ms.NoError(ms.DB.Create(&AssignBook{CustomerID: ada.ID.String(), BookID: dune.ID.String(), AssignDate: day(-18), ReturnDate: day(-4)}))
+	// out and not yet due
+	ms.NoError(ms.DB.Create(&AssignBook{CustomerID: ada.ID.String(), BookID: emma.ID.String(), AssignDate: day(-1), ReturnDate: day(13)}))
+	// returned with an unpaid fine
+	ms.NoError(ms.DB.Create(&AssignBook{CustomerID: ada.ID.String(), BookID: emma.ID.String(), AssignDate: day(-30), ReturnDate: day(-16), ReturnedOn: nulls.NewTime(now.AddDate(0, 0, -2)), Fine: money.New(350, "USD")}))
+	ms.NoError(ms.DB.Create(&Hold{CustomerID: ada.ID.String(), BookID: dune.ID.String(), Status: HoldWaiting}))
+
+	m, err := CurrentMetrics(ms.DB, now)
+	ms.NoError(err)
+	ms.Equal(2, m.Titles)
+	ms.Equal(4, m.Copies)
+	ms.Equal(2, m.OnLoan)
+	ms.Equal(1, m.Overdue)
+	ms.Equal(1, m.HoldsWaiting)
+	ms.Equal(0, m.HoldsReady)
+	ms.Equal(1, m.NewCustomers)
+	ms.Equal(int64(350+4*25), m.FinesOutstanding.Cents)
+	ms.Len(m.Loans, MetricsLoanDays)
+	ms.Equal(1, m.Loans[MetricsLoanDays-2].Loans)
+	ms.Equal(1, m.Loans[MetricsLoanDays-3].Returns)
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// MembershipPlan is used by pop to map your membership_plans database table to your go code.
+// Every customer is on a plan, which sets how many books they may have
+// on loan, for how long, how often a loan may be renewed and the fine
+// for each day a book is late.
+type MembershipPlan struct {
+	ID          uuid.UUID   `json:"id" db:"id"`
+	Name        string      `json:"name" db:"name"`
+	MaxLoans    int         `json:"max_loans" db:"max_loans"`
+	LoanDays    int         `json:"loan_days" db:"loan_days"`
+	MaxRenewals int         `json:"max_renewals" db:"max_renewals"`
+	FinePerDay  money.Money `json:"fine_per_day" db:"fine_per_day"`
+	// IsDefault marks the plan customers are put on when none is chosen.
+	// Only one plan is the default.
+	IsDefault bool      `json:"is_default" db:"is_default"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (m MembershipPlan) String() string {
+	jm, _ := json.Marshal(m)
+	return string(jm)
+}
+
+// MembershipPlans is not required by pop and may be deleted
+type MembershipPlans []MembershipPlan
+
+// String is not required by pop and may be deleted
+func (m MembershipPlans) String() string {
+	jm, _ := json.Marshal(m)
+	return string(jm)
+}
+
+// SelectLabel names the plan in the customer form.
+func (m MembershipPlan) SelectLabel() string {
+	return m.Name
+}
+
+func (m MembershipPlan) SelectValue() interface{} {
+	return m.ID.String()
+}
+
+// DefaultMembershipPlan returns the plan customers are put on when none
+// is chosen.
+func DefaultMembershipPlan(tx *pop.Connection) (*MembershipPlan, error) {
+	plan := &MembershipPlan{}
+	if err := tx.Order("is_default desc, name").First(plan); err != nil {
+		return nil, errors.Wrap(err, "finding the default membership plan")
+	}
+	return plan, nil
+}
+
+// Fine returns what a book returned the given number of days late
+// costs on this plan.
+func (m MembershipPlan) Fine(daysLate int) money.Money {
+	if daysLate <= 0 {
+		return money.New(0, m.FinePerDay.Currency)
+	}
+	return m.FinePerDay.Mul(int64(daysLate))
+}
+
+// BeforeSave puts the fine in the library's currency, which plans
+// don't keep a column for.
+func (m *MembershipPlan) BeforeSave(tx *pop.Connection) error {
+	m.FinePerDay.Currency = money.DefaultCurrency
+	return nil
+}
+
+// AfterFind does the same for plans read from the database.
+func (m *MembershipPlan) AfterFind(tx *pop.Connection) error {
+	m.FinePerDay.Currency = money.DefaultCurrency
+	return nil
+}
+
+// AfterSave takes the default away from the other plans when this one
+// becomes it.
+func (m *MembershipPlan) AfterSave(tx *pop.Connection) error {
+	if !m.IsDefault {
+		return nil
+	}
+	err := tx.RawQuery("UPDATE membership_plans SET is_default = false WHERE id <> ?", m.ID).Exec()
+	return errors.WithStack(err)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (m *MembershipPlan) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: m.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: m.Name, Name: "Name", Max: 50},
+		&validators.FuncValidator{
+			Field:   m.Name,
+			Name:    "Name",
+			Message: "%s is already used by another plan",
+			Fn: func() bool {
+				taken, err := tx.Where("name = ? AND id <> ?", m.Name, m.ID).Exists(&MembershipPlan{})
+				return err == nil && !taken
+			},
+		},
+		&validators.IntIsGreaterThan{Field: m.MaxLoans, Name: "MaxLoans", Compared: 0, Message: "Max loans must be at least 1."},
+		&validators.IntIsGreaterThan{Field: m.LoanDays, Name: "LoanDays", Compared: 0, Message: "Loan days must be at least 1."},
+		&validators.IntIsGreaterThan{Field: m.MaxRenewals, Name: "MaxRenewals", Compared: -1, Message: "Max renewals can not be negative."},
+		&validators.FuncValidator{
+			Field:   "Fine per day",
+			Name:    "FinePerDay",
+			Message: "%s must be an amount of zero or more.",
+			Fn:      func() bool { return validAmount(m.FinePerDay) },
+		},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (m *MembershipPlan) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (m *MembershipPlan) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Kinds of notice sent to customers.
+const (
+	// NoticeDueSoon reminds a customer a book is due back soon.
+	NoticeDueSoon = "due_soon"
+	// NoticeOverdue tells a customer a book is late.
+	NoticeOverdue = "overdue"
+	// NoticeHoldReady tells a customer a book they held is put aside
+	// for them.
+	NoticeHoldReady = "hold_ready"
+)
+
+// NoticeKinds are the kinds of notice sent to customers.
+var NoticeKinds = []string{NoticeDueSoon, NoticeOverdue, NoticeHoldReady}
+
+// Channels notices are sent on: email, and text messages to customers
+// who ask for them.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// NoticeChannels are the channels notices are sent on.
+var NoticeChannels = []string{ChannelEmail, ChannelSMS}
+
+// Notification statuses. A notification failed to send is tried again
+// the next time notices are sent.
+const (
+	NotificationSent   = "sent"
+	NotificationFailed = "failed"
+)
+
+// Notification is used by pop to map your notifications database table
+// to your go code. It records a notice sent, or tried, to a customer,
+// so each is sent once: one per kind, loan or hold (AboutID), occasion
+// and channel.
+type Notification struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	CustomerID string       `json:"customer_id" db:"customer_id"`
+	Kind       string       `json:"kind" db:"kind"`
+	AboutID    string       `json:"about_id" db:"about_id"`
+	Occasion   string       `json:"occasion" db:"occasion"`
+	Channel    string       `json:"channel" db:"channel"`
+	Recipient  string       `json:"recipient" db:"recipient"`
+	Subject    string       `json:"subject" db:"subject"`
+	Status     string       `json:"status" db:"status"`
+	Error      nulls.String `json:"error" db:"error"`
+	Attempts   int          `json:"attempts" db:"attempts"`
+	SentAt     nulls.Time   `json:"sent_at" db:"sent_at"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (n Notification) String() string {
+	jn, _ := json.Marshal(n)
+	return string(jn)
+}
+
+// Notifications is not required by pop and may be deleted
+type Notifications []Notification
+
+// String is not required by pop and may be deleted
+func (n Notifications) String() string {
+	jn, _ := json.Marshal(n)
+	return string(jn)
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (n *Notification) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: n.CustomerID, Name: "CustomerID"},
+		&validators.StringIsPresent{Field: n.AboutID, Name: "AboutID"},
+		&validators.StringIsPresent{Field: n.Occasion, Name: "Occasion"},
+		&validators.StringIsPresent{Field: n.Channel, Name: "Channel"},
+		&validators.FuncValidator{
+			Field:   n.Kind,
+			Name:    "Kind",
+			Message: "%s is not a kind of notice",
+			Fn:      func() bool { return included(NoticeKinds, n.Kind) },
+		},
+	), nil
+}
+
+// Record notes that the notice was sent on the channel to recipient at
+// now, or failed to be with sendErr, in the notification kept for it.
+func (n Notice) Record(tx *pop.Connection, channel, recipient, subject string, now time.Time, sendErr error) (*Notification, error) {
+	note := &Notification{}
+	err := tx.Where("kind = ? AND about_id = ? AND occasion = ? AND channel = ?", n.Kind, n.AboutID, n.Occasion, channel).First(note)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithStack(err)
+	}
+	note.CustomerID = n.Customer.ID.String()
+	note.Kind, note.AboutID, note.Occasion, note.Channel = n.Kind, n.AboutID, n.Occasion, channel
+	note.Recipient, note.Subject = recipient, subject
+	if r := []rune(subject); len(r) > 255 {
+		// a text message is its own subject, and may be longer
+		note.Subject = string(r[:254]) + "…"
+	}
+	note.Attempts++
+	if sendErr != nil {
+		note.Status = NotificationFailed
+		note.Error = nulls.NewString(sendErr.Error())
+	} else {
+		note.Status = NotificationSent
+		note.Error = nulls.String{}
+		note.SentAt = nulls.NewTime(now)
+	}
+	verrs, err := tx.ValidateAndSave(note)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if verrs.HasAny() {
+		return nil, errors.New(verrs.Error())
+	}
+	return note, nil
+}
+
+// Sent reports whether the notice has already been sent on the channel.
+func (n Notice) Sent(tx *pop.Connection, channel string) (bool, error) {
+	sent, err := tx.Where("kind = ? AND about_id = ? AND occasion = ? AND channel = ? AND status = ?", n.Kind, n.AboutID, n.Occasion, channel, NotificationSent).Exists(&Notification{})
+	return sent, errors.WithStack(err)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Publisher is used by pop to map your publishers database table to your go code.
+// Books are linked to publishers through the book_publishers table.
+type Publisher struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (p Publisher) String() string {
+	jp, _ := json.Marshal(p)
+	return string(jp)
+}
+
+// Publishers is not required by pop and may be deleted
+type Publishers []Publisher
+
+// String is not required by pop and may be deleted
+func (p Publishers) String() string {
+	jp, _ := json.Marshal(p)
+	return string(jp)
+}
+
+// Names returns the names of the publishers in order.
+func (p Publishers) Names() []string {
+	names := make([]string, len(p))
+	for i, x := range p {
+		names[i] = x.Name
+	}
+	return names
+}
+
+func (p *Publisher) setName(name string) {
+	p.Name = name
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (p *Publisher) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: p.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: p.Name, Name: "Name", Max: 150},
+		&validators.FuncValidator{
+			Field:   p.Name,
+			Name:    "Name",
+			Message: "%s is already used by another publisher",
+			Fn: func() bool {
+				taken, err := tx.Where("name = ? AND id <> ?", p.Name, p.ID).Exists(&Publisher{})
+				return err == nil && !taken
+			},
+		},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (p *Publisher) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (p *Publisher) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"library/money"
+)
+
+// Stocktake statuses. A stocktake is open while staff count the stock
+// of a branch, and closed once its variances have been looked over,
+// with or without the stock adjusted to the counts.
+const (
+	StocktakeOpen   = "open"
+	StocktakeClosed = "closed"
+)
+
+// StocktakeStatuses are the statuses a stocktake can have.
+var StocktakeStatuses = []string{StocktakeOpen, StocktakeClosed}
+
+// MovementStocktake is the reason given to the stock movements a
+// stocktake makes when its counts are applied.
+const MovementStocktake = "stocktake"
+
+// Stocktake is used by pop to map your stocktakes database table to your go code.
+// It is a physical count of the books kept at a branch.
+type Stocktake struct {
+	ID        uuid.UUID    `json:"id" db:"id"`
+	Branch    string       `json:"branch" db:"branch"`
+	Status    string       `json:"status" db:"status"`
+	UserID    nulls.UUID   `json:"user_id" db:"user_id"`
+	Notes     nulls.String `json:"notes" db:"notes"`
+	Adjusted  bool         `json:"adjusted" db:"adjusted"`
+	ClosedAt  nulls.Time   `json:"closed_at" db:"closed_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (s Stocktake) String() string {
+	js, _ := json.Marshal(s)
+	return string(js)
+}
+
+// Stocktakes is not required by pop and may be deleted
+type Stocktakes []Stocktake
+
+// String is not required by pop and may be deleted
+func (s Stocktakes) String() string {
+	js, _ := json.Marshal(s)
+	return string(js)
+}
+
+// BeforeValidate counts the stock of DefaultBranch unless another
+// branch is given.
+func (s *Stocktake) BeforeValidate(tx *pop.Connection) error {
+	s.Branch = strings.TrimSpace(s.Branch)
+	if s.Branch == "" {
+		s.Branch = DefaultBranch
+	}
+	if s.Status == "" {
+		s.Status = StocktakeOpen
+	}
+	return nil
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (s *Stocktake) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: s.Branch, Name: "Branch"},
+		&validators.StringLengthInRange{Field: s.Branch, Name: "Branch", Max: 100},
+		&validators.StringInclusion{Field: s.Status, Name: "Status", List: StocktakeStatuses},
+	), nil
+}
+
+// Open reports whether counts can still be recorded.
+func (s Stocktake) Open() bool {
+	return s.Status == StocktakeOpen
+}
+
+// StocktakeCount is how many copies of a book were found in a
+// stocktake.
+type StocktakeCount struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	StocktakeID string    `json:"stocktake_id" db:"stocktake_id"`
+	BookID      string    `json:"book_id" db:"book_id"`
+	Counted     int       `json:"counted" db:"counted"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// StocktakeCounts is not required by pop and may be deleted
+type StocktakeCounts []StocktakeCount
+
+// CountedBook is a count with the title and number of the book.
+type CountedBook struct {
+	StocktakeCount
+	Title  string `db:"title"`
+	BookNo string `db:"book_no"`
+}
+
+// StockMovement records a change made to the stock of a book at a
+// branch, and why.
+type StockMovement struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	BookID      string     `json:"book_id" db:"book_id"`
+	Branch      string     `json:"branch" db:"branch"`
+	Quantity    int        `json:"quantity" db:"quantity"`
+	Reason      string     `json:"reason" db:"reason"`
+	StocktakeID nulls.UUID `json:"stocktake_id" db:"stocktake_id"`
+	UserID      nulls.UUID `json:"user_id" db:"user_id"`
+	Note        string     `json:"note" db:"note"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// StockMovements is not required by pop and may be deleted
+type StockMovements []StockMovement
+
+// Record counts n more copies of the book, or sets the count to n when
+// add is false, and returns the book's count. Counts never go below
+// zero.
+func (s *Stocktake) Record(tx *pop.Connection, bookID string, n int, add bool) (int, *validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if !s.Open() {
+		verrs.Add("status", "The stocktake is closed.")
+		return 0, verrs, nil
+	}
+	if !add && n < 0 {
+		verrs.Add("counted", "The count can't be below zero.")
+		return 0, verrs, nil
+	}
+
+	// Scanners at the same shelf may count the same book at once, so
+	// the count is added to where it is kept rather than read and
+	// written back.
+	set := "counted = ?"
+	if add {
+		set = "counted = GREATEST(counted + ?, 0)"
+	}
+	updated, err := tx.RawQuery("UPDATE stocktake_counts SET "+set+", updated_at = ? WHERE stocktake_id = ? AND book_id = ?", n, time.Now(), s.ID, bookID).ExecWithCount()
+	if err != nil {
+		return 0, verrs, errors.WithStack(err)
+	}
+	count := &StocktakeCount{}
+	if updated == 0 {
+		err := tx.Where("stocktake_id = ? AND book_id = ?", s.ID, bookID).First(count)
+		if errors.Is(err, sql.ErrNoRows) {
+			count = &StocktakeCount{StocktakeID: s.ID.String(), BookID: bookID}
+			if n > 0 {
+				count.Counted = n
+			}
+			return count.Counted, verrs, errors.WithStack(tx.Create(count))
+		}
+		return count.Counted, verrs, errors.WithStack(err)
+	}
+	err = tx.Where("stocktake_id = ? AND book_id = ?", s.ID, bookID).First(count)
+	return count.Counted, verrs, errors.WithStack(err)
+}
+
+// Counts lists the books counted so far, the latest counted first.
+func (s Stocktake) Counts(tx *pop.Connection) ([]CountedBook, error) {
+	counts := []CountedBook{}
+	err := tx.RawQuery("SELECT stocktake_counts.*, books.title, books.book_no FROM stocktake_counts JOIN books ON books.id = stocktake_counts.book_id WHERE stocktake_counts.stocktake_id = ? ORDER BY stocktake_counts.updated_at DESC, books.title", s.ID).All(&counts)
+	return counts, errors.WithStack(err)
+}
+
+// StockVariance compares the copies of a book counted at a branch to
+// those it should have.
+type StockVariance struct {
+	BookID   string      `db:"book_id"`
+	Title    string      `db:"title"`
+	BookNo   string      `db:"book_no"`
+	Currency string      `db:"currency"`
+	Price    money.Money `db:"price"`
+	Expected int         `db:"expected"`
+	OnLoan   int         `db:"on_loan"`
+	Counted  int         `db:"counted"`
+	// Variance is the copies found over those expected, or missing
+	// when negative, allowing for the copies out on loan.
+	Variance int         `db:"-"`
+	Value    money.Money `db:"-"`
+}
+
+// Variances compares the counts to the stock the branch should have, for
+// every book stocked there or counted, by title. Loans aren't kept by
+// branch, so the copies of a book out on loan, up to the branch's stock
+// of it, are taken to be out from every branch that stocks it.
+func (s Stocktake) Variances(tx *pop.Connection) ([]StockVariance, error) {
+	variances := []StockVariance{}
+	q := `SELECT books.id AS book_id, books.title, books.book_no, books.currency, books.price,
+		CASE WHEN stock.book_id IS NOT NULL THEN stock.qty WHEN ? AND NOT EXISTS (SELECT 1 FROM inventories WHERE inventories.book_id = books.id) THEN 1 ELSE 0 END AS expected,
+		COALESCE(loans.n, 0) AS on_loan, COALESCE(stocktake_counts.counted, 0) AS counted
+		FROM books
+		LEFT JOIN (SELECT book_id, SUM(qty) AS qty FROM inventories WHERE branch = ? GROUP BY book_id) stock ON stock.book_id = books.id
+		LEFT JOIN (SELECT book_id, COUNT(*) AS n FROM assign_books WHERE returned_on IS NULL GROUP BY book_id) loans ON loans.book_id = books.id
+		LEFT JOIN stocktake_counts ON stocktake_counts.book_id = books.id AND stocktake_counts.stocktake_id = ?
+		WHERE stock.book_id IS NOT NULL OR stocktake_counts.id IS NOT NULL OR (? AND NOT EXISTS (SELECT 1 FROM inventories WHERE inventories.book_id = books.id))
+		ORDER BY books.title, books.book_no`
+	main := s.Branch == DefaultBranch
+	if err := tx.RawQuery(q, main, s.Branch, s.ID, main).All(&variances); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i := range variances {
+		v := &variances[i]
+		if v.Currency == "" {
+			v.Currency = money.DefaultCurrency
+		}
+		v.Price.Currency = v.Currency
+		out := v.OnLoan
+		if out > v.Expected {
+			out = v.Expected
+		}
+		v.Variance = v.Counted + out - v.Expected
+		v.Value = v.Price.Mul(int64(v.Variance))
+	}
+	return variances, nil
+}
+
+// Close ends the stocktake on behalf of the user. The stock of each of
+// the books in adjust whose count differs from what was expected is set
+// to the count, with the copies out on loan, and the change recorded as
+// a stock movement. It returns how many books were adjusted.
+func (s *Stocktake) Close(tx *pop.Connection, userID nulls.UUID, adjust []string, now time.Time) (int, *validate.Errors, error) {
+	verrs := validate.NewErrors()
+	// Closing claims the stocktake, so that it can't be applied twice.
+	closed, err := tx.RawQuery("UPDATE stocktakes SET status = ?, closed_at = ?, updated_at = ? WHERE id = ? AND status = ?", StocktakeClosed, now, now, s.ID, StocktakeOpen).ExecWithCount()
+	if err != nil {
+		return 0, verrs, errors.WithStack(err)
+	}
+	if closed == 0 {
+		verrs.Add("status", "The stocktake is closed.")
+		return 0, verrs, nil
+	}
+	s.Status, s.ClosedAt = StocktakeClosed, nulls.NewTime(now)
+
+	variances, err := s.Variances(tx)
+	if err != nil {
+		return 0, verrs, err
+	}
+	wanted := map[string]bool{}
+	for _, id := range adjust {
+		wanted[id] = true
+	}
+	adjusted := 0
+	for _, v := range variances {
+		if v.Variance == 0 || !wanted[v.BookID] {
+			continue
+		}
+		if err := setStock(tx, v.BookID, s.Branch, v.Expected+v.Variance); err != nil {
+			return adjusted, verrs, err
+		}
+		movement := &StockMovement{
+			BookID:      v.BookID,
+			Branch:      s.Branch,
+			Quantity:    v.Variance,
+			Reason:      MovementStocktake,
+			StocktakeID: nulls.NewUUID(s.ID),
+			UserID:      userID,
+			Note:        fmt.Sprintf("Counted %d with %d on loan, %d expected", v.Counted, v.OnLoan, v.Expected),
+		}
+		if err := tx.Create(movement); err != nil {
+			return adjusted, verrs, errors.WithStack(err)
+		}
+		adjusted++
+	}
+	if adjusted > 0 {
+		s.Adjusted = true
+		if err := tx.UpdateColumns(s, "adjusted"); err != nil {
+			return adjusted, verrs, errors.WithStack(err)
+		}
+	}
+	return adjusted, verrs, nil
+}
+
+// setStock makes qty the copies of the book kept at the branch, in its
+// first inventory entry there. A book that was never counted into the
+// inventory was taken to be a single copy at DefaultBranch, which is
+// kept when its stock is set at another branch.
+func setStock(tx *pop.Connection, bookID, branch string, qty int) error {
+	entries := Inventories{}
+	if err := tx.Where("book_id = ?", bookID).Order("created_at, id").All(&entries); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(entries) == 0 && branch != DefaultBranch {
+		if err := tx.Create(&Inventory{BookID: bookID, Qty: 1, Branch: DefaultBranch}); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	var kept *Inventory
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Branch != branch {
+			continue
+		}
+		if kept == nil {
+			kept = entry
+			continue
+		}
+		if err := tx.Destroy(entry); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if kept == nil {
+		return errors.WithStack(tx.Create(&Inventory{BookID: bookID, Qty: qty, Branch: branch}))
+	}
+	kept.Qty = qty
+	return errors.WithStack(tx.UpdateColumns(kept, "qty", "updated_at"))
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+
+	"library/money"
+)
+
+func (ms *ModelSuite) Test_Stocktake() {
+	ms.createPlan("adult", 5, 2, true)
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := func(bookNo string, cents int64) *Book {
+		b := &Book{CategoryID: category.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: money.New(cents, "USD"), Status: 1}
+		ms.NoError(ms.DB.Create(b))
+		return b
+	}
+	stock := func(b *Book, branch string, qty int) {
+		ms.NoError(ms.DB.Create(&Inventory{BookID: b.ID.String(), Branch: branch, Qty: qty}))
+	}
+	dune, emma, odes, uncounted := book("Dune", 1000), book("Emma", 500), book("Odes", 200), book("Zed", 300)
+	stock(dune, DefaultBranch, 3)
+	stock(emma, DefaultBranch, 2)
+	stock(odes, "East", 1)
+
+	customer := &Customer{Name: "Ada", Email: "ada@example.com", Mobile: "555"}
+	verrs, err := ms.DB.ValidateAndCreate(customer)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.NoError(ms.DB.Create(&AssignBook{CustomerID: customer.ID.String(), BookID: emma.ID.String(), AssignDate: "2026-10-01", ReturnDate: "2026-10-15"}))
+
+	take := &Stocktake{}
+	verrs, err = ms.DB.ValidateAndCreate(take)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.Equal(DefaultBranch, take.Branch)
+	ms.True(take.Open())
+
+	record := func(b *Book, n int, add bool) int {
+		counted, verrs, err := take.Record(ms.DB, b.ID.String(), n, add)
+		ms.NoError(err)
+		ms.False(verrs.HasAny(), verrs.Error())
+		return counted
+	}
+	ms.Equal(1, record(dune, 1, true))
+	ms.Equal(2, record(dune, 1, true))
+	ms.Equal(1, record(emma, 1, true))
+	ms.Equal(2, record(odes, 2, false))
+	ms.Equal(0, record(odes, -5, true), "counts don't go below zero")
+	ms.Equal(1, record(odes, 1, false))
+
+	counts, err := take.Counts(ms.DB)
+	ms.NoError(err)
+	ms.Len(counts, 3)
+
+	variances, err := take.Variances(ms.DB)
+	ms.NoError(err)
+	ms.Len(variances, 4)
+	byBook := map[string]StockVariance{}
+	for _, v := range variances {
+		byBook[v.BookNo] = v
+	}
+	ms.Equal(-1, byBook["Dune"].Variance)
+	ms.Equal("-10.00 USD", byBook["Dune"].Value.String())
+	ms.Equal(0, byBook["Emma"].Variance, "the copy out on loan isn't missing")
+	ms.Equal(1, byBook["Emma"].OnLoan)
+	ms.Equal(0, byBook["Odes"].Expected, "Odes is kept at another branch")
+	ms.Equal(1, byBook["Odes"].Variance)
+	ms.Equal(1, byBook["Zed"].Expected, "a book never counted is a copy at the main branch")
+	ms.Equal(-1, byBook["Zed"].Variance)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	adjusted, verrs, err := take.Close(ms.DB, nulls.UUID{}, []string{dune.ID.String(), odes.ID.String()}, now)
+	ms.NoError(err)
+	ms.False(verrs.HasAny(), verrs.Error())
+	ms.Equal(2, adjusted)
+	ms.NoError(ms.DB.Reload(take))
+	ms.Equal(StocktakeClosed, take.Status)
+	ms.True(take.Adjusted)
+
+	copies, err := dune.Copies(ms.DB)
+	ms.NoError(err)
+	ms.Equal(2, copies)
+	copies, err = odes.Copies(ms.DB)
+	ms.NoError(err)
+	ms.Equal(2, copies, "a copy of Odes was found at the main branch")
+	copies, err = uncounted.Copies(ms.DB)
+	ms.NoError(err)
+	ms.Equal(1, copies, "Zed wasn't adjusted")
+
+	movements := StockMovements{}
+	ms.NoError(ms.DB.Where("stocktake_id = ?", take.ID).Order("quantity").All(&movements))
+	ms.Len(movements, 2)
+	ms.Equal(-1, movements[0].Quantity)
+	ms.Equal(MovementStocktake, movements[0].Reason)
+	ms.Equal(1, movements[1].Quantity)
+
+	_, verrs, err = take.Record(ms.DB, dune.ID.String(), 1, true)
+	ms.NoError(err)
+	ms.True(verrs.HasAny(), "a closed stocktake takes no more counts")
+	_, verrs, err = take.Close(ms.DB, nulls.UUID{}, nil, now)
+	ms.NoError(err)
+	ms.True(verrs.HasAny(), "a stocktake closes once")
+}
+
+func (ms *ModelSuite) Test_InventoryValuation() {
+	fiction := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(fiction))
+	book := func(bookNo string, price money.Money) *Book {
+		b := &Book{CategoryID: fiction.ID.String(), Title: bookNo, BookNo: bookNo, Author: "Someone", Price: price, Status: 1}
+		ms.NoError(ms.DB.Create(b))
+		return b
+	}
+	dune, emma := book("Dune", money.New(1000, "USD")), book("Emma", money.New(250, "USD"))
+	book("Odes", money.New(400, "EUR"))
+	ms.NoError(ms.DB.Create(&Inventory{BookID: dune.ID.String(), Branch: DefaultBranch, Qty: 2}))
+	ms.NoError(ms.DB.Create(&Inventory{BookID: dune.ID.String(), Branch: "East", Qty: 1}))
+	ms.NoError(ms.DB.Create(&Inventory{BookID: emma.ID.String(), Branch: DefaultBranch, Qty: 4}))
+
+	valuation, err := InventoryValuation(ms.DB)
+	ms.NoError(err)
+	ms.Len(valuation, 3)
+	ms.Equal("East", valuation[0].Branch)
+	ms.Equal(1, valuation[0].Copies)
+	ms.Equal("10.00 USD", valuation[0].Value.String())
+	ms.Equal(DefaultBranch, valuation[1].Branch)
+	ms.Equal("EUR", valuation[1].Currency)
+	ms.Equal(1, valuation[1].Copies, "a book never counted is a copy at the main branch")
+	ms.Equal(DefaultBranch, valuation[2].Branch)
+	ms.Equal("USD", valuation[2].Currency)
+	ms.Equal(2, valuation[2].Titles)
+	ms.Equal(6, valuation[2].Copies)
+	ms.Equal("30.00 USD", valuation[2].Value.String())
+}
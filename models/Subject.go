@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Subject is used by pop to map your subjects database table to your go code.
+// Books are linked to subjects through the book_subjects table.
+type Subject struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// String is not required by pop and may be deleted
+func (s Subject) String() string {
+	js, _ := json.Marshal(s)
+	return string(js)
+}
+
+// Subjects is not required by pop and may be deleted
+type Subjects []Subject
+
+// String is not required by pop and may be deleted
+func (s Subjects) String() string {
+	js, _ := json.Marshal(s)
+	return string(js)
+}
+
+// Names returns the names of the subjects in order.
+func (s Subjects) Names() []string {
+	names := make([]string, len(s))
+	for i, x := range s {
+		names[i] = x.Name
+	}
+	return names
+}
+
+func (s *Subject) setName(name string) {
+	s.Name = name
+}
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+// This method is not required and may be deleted.
+func (s *Subject) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: s.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: s.Name, Name: "Name", Max: 150},
+		&validators.FuncValidator{
+			Field:   s.Name,
+			Name:    "Name",
+			Message: "%s is already used by another subject",
+			Fn: func() bool {
+				taken, err := tx.Where("name = ? AND id <> ?", s.Name, s.ID).Exists(&Subject{})
+				return err == nil && !taken
+			},
+		},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+// This method is not required and may be deleted.
+func (s *Subject) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+// This method is not required and may be deleted.
+func (s *Subject) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
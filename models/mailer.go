@@ -0,0 +1,37 @@
+package models
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gobuffalo/envy"
+
+	"library/mailer"
+)
+
+// Mailer sends the notices the library emails its customers. MAILER
+// picks how; see MailSender.
+var Mailer = MailSender(envy.Get("MAILER", "log"))
+
+// MailFrom is who the library's email comes from, as set by MAIL_FROM.
+var MailFrom = envy.Get("MAIL_FROM", "Library <library@localhost>")
+
+// MailSender returns the sender named kind: "smtp" for the server
+// SMTP_HOST:SMTP_PORT, signed in to as SMTP_USER with SMTP_PASSWORD,
+// "file" for .eml files in MAIL_DIR, or "log" to write messages to the
+// log instead of sending them.
+func MailSender(kind string) mailer.Sender {
+	switch strings.ToLower(kind) {
+	case "smtp":
+		return mailer.SMTP{
+			Host:     envy.Get("SMTP_HOST", "localhost"),
+			Port:     envy.Get("SMTP_PORT", "25"),
+			Username: envy.Get("SMTP_USER", ""),
+			Password: envy.Get("SMTP_PASSWORD", ""),
+		}
+	case "file":
+		return mailer.File{Dir: envy.Get("MAIL_DIR", "tmp/mail")}
+	}
+	return mailer.Log{Logger: log.New(os.Stderr, "[mail] ", log.LstdFlags)}
+}
@@ -2,9 +2,13 @@ package models
 
 import (
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/gobuffalo/envy"
 	"github.com/gobuffalo/pop/v6"
+
+	"library/money"
 )
 
 // DB is a connection to your database to be used
@@ -19,4 +23,28 @@ func init() {
 		log.Fatal(err)
 	}
 	pop.Debug = env == "development"
+	money.DefaultCurrency = envy.Get("CURRENCY", money.DefaultCurrency)
+	CardPrefix = envy.Get("CARD_PREFIX", CardPrefix)
+	SMSCountryCode = envy.Get("SMS_COUNTRY_CODE", SMSCountryCode)
+	if months, err := strconv.Atoi(envy.Get("MEMBERSHIP_MONTHS", "")); err == nil && months > 0 {
+		MembershipMonths = months
+	}
+	if days, err := strconv.Atoi(envy.Get("HOLD_SHELF_DAYS", "")); err == nil && days > 0 {
+		HoldShelfDays = days
+	}
+	if limit, err := money.Parse(envy.Get("FINE_LIMIT", ""), ""); err == nil && !limit.IsNegative() {
+		FineLimit = limit
+	}
+	if days, err := strconv.Atoi(envy.Get("REMINDER_DAYS", "")); err == nil && days >= 0 {
+		ReminderDays = days
+	}
+	if steps := envy.Get("OVERDUE_NOTICE_DAYS", ""); steps != "" {
+		days := []int{}
+		for _, s := range strings.Split(steps, ",") {
+			if d, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && d > 0 {
+				days = append(days, d)
+			}
+		}
+		OverdueNoticeDays = days
+	}
 }
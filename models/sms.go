@@ -0,0 +1,30 @@
+package models
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gobuffalo/envy"
+
+	"library/sms"
+)
+
+// SMS sends the notices the library texts its customers. SMS_GATEWAY
+// picks how; see SMSSender.
+var SMS = SMSSender(envy.Get("SMS_GATEWAY", "log"))
+
+// SMSSender returns the sender named kind: "http" for the gateway at
+// SMS_GATEWAY_URL, authorized with SMS_GATEWAY_TOKEN and sending as
+// SMS_FROM, or "log" to write messages to the log instead of sending
+// them.
+func SMSSender(kind string) sms.Sender {
+	if strings.ToLower(kind) == "http" {
+		return sms.HTTP{
+			URL:   envy.Get("SMS_GATEWAY_URL", ""),
+			Token: envy.Get("SMS_GATEWAY_TOKEN", ""),
+			From:  envy.Get("SMS_FROM", ""),
+		}
+	}
+	return sms.Log{Logger: log.New(os.Stderr, "[sms] ", log.LstdFlags)}
+}
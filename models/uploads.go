@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/pkg/errors"
+
+	"library/upload"
+)
+
+// Uploads is where book covers, profile pictures and customer photos
+// are kept. They are served by actions.ServeUploads. UPLOADS_STORAGE
+// picks the storage; see UploadStorage. Profile pictures and photos are
+// private: only signed-in users see them.
+var Uploads = upload.Store{
+	Storage: UploadStorage(UploadsStorageKind()),
+	URL:     "/uploads",
+	Secret:  []byte(envy.Get("UPLOADS_SECRET", envy.Get("SESSION_SECRET", ""))),
+	Private: []string{"profiles"},
+}
+
+// UploadsStorageKind is the kind of storage uploads are kept in,
+// "local" or "s3", as set by UPLOADS_STORAGE.
+func UploadsStorageKind() string {
+	return strings.ToLower(envy.Get("UPLOADS_STORAGE", "local"))
+}
+
+// UploadStorage returns the storage named kind: "local" for the
+// directory UPLOADS_DIR, or "s3" for the bucket S3_BUCKET at
+// S3_ENDPOINT, signed with S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY.
+// Set S3_PATH_STYLE for MinIO and other stand-ins.
+func UploadStorage(kind string) upload.Storage {
+	if strings.ToLower(kind) == "s3" {
+		return upload.S3{
+			Endpoint:  envy.Get("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:    envy.Get("S3_REGION", "us-east-1"),
+			Bucket:    envy.Get("S3_BUCKET", ""),
+			AccessKey: envy.Get("S3_ACCESS_KEY_ID", ""),
+			SecretKey: envy.Get("S3_SECRET_ACCESS_KEY", ""),
+			PathStyle: envy.Get("S3_PATH_STYLE", "") == "true",
+		}
+	}
+	return upload.Local{Dir: envy.Get("UPLOADS_DIR", "uploads")}
+}
+
+// ImageCache keeps the resized copies of uploaded images that
+// actions.ServeImage makes. Each instance may keep its own.
+var ImageCache = upload.Local{Dir: envy.Get("IMAGE_CACHE_DIR", "tmp/images")}
+
+// ImageSizes are the widths and heights images can be resized to.
+var ImageSizes = []int{32, 40, 48, 64, 80, 96, 100, 120, 128, 160, 200, 240, 320, 400, 480, 640, 800}
+
+var (
+	// CoverUpload limits the covers uploaded for books.
+	CoverUpload = upload.Options{MaxBytes: 5 << 20, Types: upload.Images, Thumbnail: 160}
+	// ProfileUpload limits the pictures users upload for their profile.
+	ProfileUpload = upload.Options{MaxBytes: 2 << 20, Types: upload.Images, Thumbnail: 64}
+)
+
+// saveUpload stores the upload in folder and returns its path. A file
+// refused for what it is shows up in verrs under field.
+func saveUpload(ctx context.Context, verrs *validate.Errors, field, folder string, f io.Reader, opts upload.Options) (string, error) {
+	file, err := Uploads.Save(ctx, folder, f, opts)
+	if upload.IsRejected(err) {
+		verrs.Add(field, err.Error())
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return file.Path, nil
+}
+
+// UploadInUse reports whether a book, user or customer still points to
+// the upload at path. Equal uploads share a file, so a file replaced on
+// one record may still be another's.
+func UploadInUse(tx *pop.Connection, path string) (bool, error) {
+	inUse, err := tx.Where("picture_path = ?", path).Exists(&Book{})
+	if err != nil || inUse {
+		return inUse, errors.WithStack(err)
+	}
+	inUse, err = tx.Where("profile_path = ?", path).Exists(&User{})
+	if err != nil || inUse {
+		return inUse, errors.WithStack(err)
+	}
+	inUse, err = tx.Where("photo_path = ?", path).Exists(&Customer{})
+	return inUse, errors.WithStack(err)
+}
+
+// RemoveUpload deletes the upload at path, its thumbnail and the
+// resized copies made of it.
+func RemoveUpload(ctx context.Context, path string) error {
+	if err := Uploads.Remove(ctx, path); err != nil {
+		return err
+	}
+	key, ok := Uploads.Key(path)
+	if !ok {
+		return nil
+	}
+	copies, err := ImageCache.List(ctx, strings.TrimSuffix(key, filepath.Ext(key))+"/")
+	if err != nil {
+		return err
+	}
+	for _, copy := range copies {
+		if err := ImageCache.Delete(ctx, copy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
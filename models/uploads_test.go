@@ -0,0 +1,22 @@
+package models
+
+import "library/money"
+
+func (ms *ModelSuite) Test_UploadInUse() {
+	category := &Category{CategoryName: "Fiction", Status: 1}
+	ms.NoError(ms.DB.Create(category))
+	book := &Book{CategoryID: category.ID.String(), Title: "T", BookNo: "B-1", Author: "A", Price: money.New(100, "USD"), Status: 1,
+		PicturePath: "/uploads/books/0123456789abcdef0123456789abcdef.jpg"}
+	ms.NoError(ms.DB.Create(book))
+
+	inUse, err := UploadInUse(ms.DB, book.PicturePath)
+	ms.NoError(err)
+	ms.True(inUse)
+
+	inUse, err = UploadInUse(ms.DB, "/uploads/books/other.jpg")
+	ms.NoError(err)
+	ms.False(inUse)
+
+	ms.Equal("/uploads/books/0123456789abcdef0123456789abcdef_thumb.jpg", book.PictureThumbnail())
+	ms.Equal("/uploads/books/1697700000.jpg", Book{PicturePath: "/uploads/books/1697700000.jpg"}.PictureThumbnail())
+}
@@ -2,10 +2,6 @@ package models
 
 import (
 	"encoding/json"
-	"io"
-	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +12,8 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
+
+	"library/upload"
 )
 
 // User is a generated model from buffalo-auth, it serves as the base for username/password authentication.
@@ -31,7 +29,7 @@ type User struct {
 	Password             string       `json:"-" db:"-"`
 	PasswordConfirmation string       `json:"-" db:"-"`
 	Profile              binding.File `db:"-" form:"profile"`
-	ProfilePath          string       `json:"profile_path" db:"profile_path"`
+	ProfilePath          string       `json:"profile_path" db:"profile_path" form:"-"`
 }
 
 // Create wraps up the pattern of encrypting the password and
@@ -46,38 +44,17 @@ func (u *User) Create(tx *pop.Connection) (*validate.Errors, error) {
 	return tx.ValidateAndCreate(u)
 }
 
+// Update stores the uploaded profile picture, if any, then validates
+// and updates the user. The picture it replaces is left in place until
+// the update has committed.
 func (u *User) Update(tx *pop.Connection) (*validate.Errors, error) {
-	// if !u.Profile.Valid() {
-
-	// }
 	if u.Profile.Valid() {
-		dir := filepath.Join(".", "uploads")
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
-		currentTime := time.Now()
-		// Convert the current time to a numerical representation
-		timeNumber := currentTime.Unix()
-		ext := filepath.Ext(u.Profile.Filename)
-		filename := strconv.FormatInt(timeNumber, 10) + ext
-		filePath := filepath.Join(dir, filename)
-
-		f, err := os.Create(filepath.Join(dir, u.Profile.Filename))
-		if err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
+		verrs := validate.NewErrors()
+		path, err := saveUpload(tx.Context(), verrs, "profile", "profiles", u.Profile, ProfileUpload)
+		if err != nil || verrs.HasAny() {
+			return verrs, err
 		}
-		defer f.Close()
-		_, err = io.Copy(f, u.Profile)
-		if err != nil {
-			return validate.NewErrors(), errors.WithStack(err)
-		}
-		if u.ProfilePath != "" && u.ProfilePath != "/"+filePath {
-			ProfilePath := strings.TrimLeft(u.ProfilePath, "/")
-			if err := os.Remove(ProfilePath); err != nil {
-				return validate.NewErrors(), errors.WithStack(err)
-			}
-		}
-		u.ProfilePath = "/" + filepath.Join(dir, u.Profile.Filename)
+		u.ProfilePath = path
 	}
 	ph, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -87,6 +64,13 @@ func (u *User) Update(tx *pop.Connection) (*validate.Errors, error) {
 	return tx.ValidateAndUpdate(u)
 }
 
+// ProfileThumbnail returns the path of the profile picture's thumbnail,
+// or of the picture itself when it was uploaded before thumbnails were
+// made.
+func (u User) ProfileThumbnail() string {
+	return upload.Thumbnail(u.ProfilePath)
+}
+
 // String is not required by pop and may be deleted
 func (u User) String() string {
 	ju, _ := json.Marshal(u)
@@ -0,0 +1,157 @@
+package money
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// currency describes how a currency is written.
+type currency struct {
+	Symbol string
+	// Digits is how many decimals are shown. Amounts are always kept in
+	// hundredths; currencies without minor units are rounded for show.
+	Digits int
+}
+
+var currencies = map[string]currency{
+	"USD": {"$", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+	"INR": {"₹", 2},
+	"CAD": {"CA$", 2},
+	"AUD": {"A$", 2},
+	"CHF": {"CHF", 2},
+	"BRL": {"R$", 2},
+	"MXN": {"MX$", 2},
+	"PKR": {"Rs", 2},
+	"BDT": {"৳", 2},
+	"NGN": {"₦", 2},
+	"KES": {"KSh", 2},
+	"ZAR": {"R", 2},
+}
+
+// Currencies returns the codes of the currencies amounts can be in.
+func Currencies() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// KnownCurrency reports whether code is one of Currencies.
+func KnownCurrency(code string) bool {
+	_, ok := currencies[code]
+	return ok
+}
+
+// locale describes how amounts are written in a language.
+type locale struct {
+	Decimal, Group string
+	// After puts the symbol after the number, separated by a space.
+	After bool
+	// Space separates a symbol written before the number. Symbols
+	// ending in a letter, such as "CHF", are always separated.
+	Space bool
+}
+
+var locales = map[string]locale{
+	"en":    {Decimal: ".", Group: ","},
+	"ja":    {Decimal: ".", Group: ","},
+	"de":    {Decimal: ",", Group: ".", After: true},
+	"es":    {Decimal: ",", Group: ".", After: true},
+	"it":    {Decimal: ",", Group: ".", After: true},
+	"fr":    {Decimal: ",", Group: "\u202f", After: true},
+	"nl":    {Decimal: ",", Group: ".", Space: true},
+	"pt":    {Decimal: ",", Group: ".", Space: true},
+	"de-ch": {Decimal: ".", Group: "'", Space: true},
+}
+
+// Format writes the amount for the first of the languages (such as
+// "en-US" or "de") that has known conventions, as in "$1,234.50" or
+// "1.234,50 €". English conventions are used when none match.
+func (m Money) Format(languages ...string) string {
+	loc := locales["en"]
+	for _, lang := range languages {
+		lang = strings.ToLower(lang)
+		if l, ok := locales[lang]; ok {
+			loc = l
+			break
+		}
+		if l, ok := locales[strings.SplitN(lang, "-", 2)[0]]; ok {
+			loc = l
+			break
+		}
+	}
+
+	code := m.Currency
+	if code == "" {
+		code = DefaultCurrency
+	}
+	cur, ok := currencies[code]
+	if !ok {
+		cur = currency{Symbol: code, Digits: 2}
+	}
+
+	cents, sign := m.Cents, ""
+	if cents < 0 {
+		cents, sign = -cents, "-"
+	}
+	units, fraction := cents/100, cents%100
+	if cur.Digits == 0 && fraction >= 50 {
+		units++
+	}
+	number := group(strconv.FormatInt(units, 10), loc.Group)
+	if cur.Digits > 0 {
+		number += loc.Decimal + strconv.FormatInt(fraction+100, 10)[1:]
+	}
+
+	symbol := []rune(cur.Symbol)
+	switch {
+	case loc.After:
+		return sign + number + "\u00a0" + cur.Symbol
+	case loc.Space || unicode.IsLetter(symbol[len(symbol)-1]):
+		return sign + cur.Symbol + "\u00a0" + number
+	}
+	return sign + cur.Symbol + number
+}
+
+// group puts sep between every three digits.
+func group(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// CurrencyIn returns the currency named in text such as "EUR 12.00" or
+// "£7.99", or "" when it names none or only "$", which several
+// currencies share.
+func CurrencyIn(s string) string {
+	for _, word := range strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if KnownCurrency(word) {
+			return word
+		}
+	}
+	for code, cur := range currencies {
+		if cur.Symbol != "$" && len([]rune(cur.Symbol)) == 1 && !unicode.IsLetter([]rune(cur.Symbol)[0]) && strings.Contains(s, cur.Symbol) {
+			return code
+		}
+	}
+	return ""
+}
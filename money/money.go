@@ -0,0 +1,259 @@
+// Package money holds amounts of money as whole hundredths of a
+// currency unit, so prices, replacement costs and fees add up exactly.
+// Amounts are stored in DECIMAL(12,2) columns, with the currency in a
+// column of its own.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCurrency is used for amounts that don't name their currency.
+var DefaultCurrency = "USD"
+
+// Money is an amount in a currency.
+type Money struct {
+	// Cents is the amount in hundredths of the currency's main unit,
+	// whatever the currency calls them.
+	Cents    int64
+	Currency string
+
+	// invalid keeps text that didn't parse, for Err to report.
+	invalid string
+}
+
+// New returns cents hundredths of currency.
+func New(cents int64, currency string) Money {
+	return Money{Cents: cents, Currency: currency}
+}
+
+// Parse reads an amount such as "12.50", "$1,234.5", "1.234,50 €" or
+// "12,50". When both a comma and a period appear, whichever comes last
+// is the decimal separator; a lone comma is one when at most two digits
+// follow it. Amounts with more than two decimals are rounded half away
+// from zero.
+func Parse(s, currency string) (Money, error) {
+	m := Money{Currency: currency}
+	var digits strings.Builder
+	negative := false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			digits.WriteRune(r)
+		case r == '-' && digits.Len() == 0:
+			negative = true
+		case r == ' ' || r == '\u00a0' || r == '\u202f' || r == '\'':
+			// group separators
+		case strings.ContainsRune("$€£¥₹", r) || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z'):
+			// currency symbols and codes
+		default:
+			return m, errors.Errorf("%q is not an amount of money", s)
+		}
+	}
+	n := digits.String()
+	if n == "" {
+		return m, errors.Errorf("%q is not an amount of money", s)
+	}
+
+	decimal := -1
+	lastDot, lastComma := strings.LastIndex(n, "."), strings.LastIndex(n, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		decimal = lastDot
+		if lastComma > lastDot {
+			decimal = lastComma
+		}
+	case lastDot >= 0 && strings.Count(n, ".") == 1:
+		decimal = lastDot
+	case lastComma >= 0 && strings.Count(n, ",") == 1 && len(n)-lastComma-1 <= 2:
+		decimal = lastComma
+	}
+
+	whole, fraction := n, ""
+	if decimal >= 0 {
+		whole, fraction = n[:decimal], n[decimal+1:]
+	}
+	// separators in the whole part have to group thousands
+	groups := strings.FieldsFunc(whole, func(r rune) bool { return r == '.' || r == ',' })
+	for i, g := range groups {
+		if i > 0 && len(g) != 3 {
+			return m, errors.Errorf("%q is not an amount of money", s)
+		}
+	}
+	whole = strings.Join(groups, "")
+	if strings.ContainsAny(fraction, ".,") {
+		return m, errors.Errorf("%q is not an amount of money", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > 1e15 {
+		return m, errors.Errorf("%q is not an amount of money", s)
+	}
+	fraction += "000"
+	cents, _ := strconv.ParseInt(fraction[:2], 10, 64)
+	if fraction[2] >= '5' {
+		cents++
+	}
+	m.Cents = units*100 + cents
+	if negative {
+		m.Cents = -m.Cents
+	}
+	return m, nil
+}
+
+// Err reports text that was bound to the amount but didn't parse.
+func (m Money) Err() error {
+	if m.invalid != "" {
+		return errors.Errorf("%q is not an amount of money", m.invalid)
+	}
+	return nil
+}
+
+// IsZero reports whether the amount is nothing.
+func (m Money) IsZero() bool {
+	return m.Cents == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Cents < 0
+}
+
+// Add returns m + o. Amounts without a currency take the other's;
+// amounts in different currencies can't be added.
+func (m Money) Add(o Money) (Money, error) {
+	currency, err := common(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Cents: m.Cents + o.Cents, Currency: currency}, nil
+}
+
+// Sub returns m - o, with the same rules as Add.
+func (m Money) Sub(o Money) (Money, error) {
+	currency, err := common(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Cents: m.Cents - o.Cents, Currency: currency}, nil
+}
+
+// Mul returns m times n, as for a daily fee over n days.
+func (m Money) Mul(n int64) Money {
+	return Money{Cents: m.Cents * n, Currency: m.Currency}
+}
+
+func common(a, b Money) (string, error) {
+	switch {
+	case a.Currency == "" || a.Currency == b.Currency:
+		return b.Currency, nil
+	case b.Currency == "":
+		return a.Currency, nil
+	}
+	return "", errors.Errorf("can't combine %s and %s", a.Currency, b.Currency)
+}
+
+// Decimal returns the amount as in "1234.50", the way it is stored.
+func (m Money) Decimal() string {
+	cents := m.Cents
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// String returns the amount with its currency code, as in "12.50 USD".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// Value stores the amount in a DECIMAL column.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// Scan reads the amount from a DECIMAL or FLOAT column. The currency is
+// left for the model to fill in from its own column.
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*m = Money{Currency: m.Currency}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', 2, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', 2, 32)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	default:
+		return errors.Errorf("can't scan %T into money", src)
+	}
+	parsed, err := Parse(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// MarshalJSON writes the amount as a decimal string, as the price was
+// written before it had a type of its own.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Decimal())
+}
+
+// UnmarshalJSON reads an amount written as a string or a number.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" || s == "" {
+		*m = Money{Currency: m.Currency}
+		return nil
+	}
+	parsed, err := Parse(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// UnmarshalText binds an amount typed in a form. Text that doesn't
+// parse is kept for Err, so it shows up as a validation error rather
+// than failing the whole request.
+func (m *Money) UnmarshalText(b []byte) error {
+	parsed, err := Parse(string(b), m.Currency)
+	if err != nil {
+		*m = Money{Currency: m.Currency, invalid: string(b)}
+		return nil
+	}
+	*m = parsed
+	return nil
+}
+
+// TagValue is what form helpers put in an input for the amount: the
+// text as typed when it didn't parse, so it can be corrected, and the
+// plain decimal otherwise.
+func (m Money) TagValue() string {
+	if m.invalid != "" {
+		return m.invalid
+	}
+	return m.Decimal()
+}
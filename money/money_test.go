@@ -0,0 +1,104 @@
+package money
+
+import "testing"
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		in    string
+		cents int64
+	}{
+		{"12.50", 1250},
+		{"12.5", 1250},
+		{"$1,234.5", 123450},
+		{"1.234,50 €", 123450},
+		{"12,50", 1250},
+		{"1,234", 123400},
+		{"12.995", 1300},
+		{"-3.20", -320},
+		{"0", 0},
+		{"USD 7", 700},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.in, "USD")
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if m.Cents != tt.cents {
+			t.Errorf("Parse(%q) = %d cents, want %d", tt.in, m.Cents, tt.cents)
+		}
+	}
+	for _, in := range []string{"", "abc", "1.2.3,4,5", "12#"} {
+		if _, err := Parse(in, "USD"); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
+
+func Test_Arithmetic(t *testing.T) {
+	a, b := New(1050, "USD"), New(25, "USD")
+	sum, err := a.Add(b)
+	if err != nil || sum.Decimal() != "10.75" {
+		t.Errorf("Add = %v, %v", sum, err)
+	}
+	diff, _ := b.Sub(a)
+	if diff.String() != "-10.25 USD" {
+		t.Errorf("Sub = %v", diff)
+	}
+	if a.Mul(3).Cents != 3150 {
+		t.Errorf("Mul = %v", a.Mul(3))
+	}
+	if _, err := a.Add(New(1, "EUR")); err == nil {
+		t.Error("added USD and EUR")
+	}
+	if total, _ := (Money{}).Add(a); total.Currency != "USD" {
+		t.Errorf("zero value didn't take the currency: %v", total)
+	}
+}
+
+func Test_Format(t *testing.T) {
+	tests := []struct {
+		m         Money
+		languages []string
+		want      string
+	}{
+		{New(123450, "USD"), []string{"en-US"}, "$1,234.50"},
+		{New(123450, "EUR"), []string{"de-DE", "en"}, "1.234,50\u00a0€"},
+		{New(123450, "EUR"), []string{"fr"}, "1\u202f234,50\u00a0€"},
+		{New(123450, "CHF"), []string{"xx", "de-CH"}, "CHF\u00a01'234.50"},
+		{New(123450, "JPY"), []string{"ja"}, "¥1,235"},
+		{New(-500, "GBP"), nil, "-£5.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Format(tt.languages...); got != tt.want {
+			t.Errorf("%v.Format(%v) = %q, want %q", tt.m, tt.languages, got, tt.want)
+		}
+	}
+}
+
+func Test_ScanValue(t *testing.T) {
+	m := Money{Currency: "EUR"}
+	if err := m.Scan([]byte("19.90")); err != nil || m.Cents != 1990 || m.Currency != "EUR" {
+		t.Errorf("Scan = %v, %v", m, err)
+	}
+	// FLOAT columns hold 12.99 as 12.98999977
+	if err := m.Scan(float64(float32(12.99))); err != nil || m.Cents != 1299 {
+		t.Errorf("Scan(float) = %v, %v", m, err)
+	}
+	if v, _ := New(5, "EUR").Value(); v != "0.05" {
+		t.Errorf("Value = %v", v)
+	}
+
+	var bound Money
+	if err := bound.UnmarshalText([]byte("ten")); err != nil || bound.Err() == nil {
+		t.Errorf("UnmarshalText didn't keep the bad text: %v", err)
+	}
+}
+
+func Test_CurrencyIn(t *testing.T) {
+	for in, want := range map[string]string{"EUR 12.00": "EUR", "£7.99 (pbk.)": "GBP", "12.50 usd": "USD", "$12.50": "", "12.50": ""} {
+		if got := CurrencyIn(in); got != want {
+			t.Errorf("CurrencyIn(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Image is an image stored once in the document and drawn with
+// Page.Image as often as needed.
+type Image struct {
+	Width, Height int
+	obj           int
+}
+
+// AddJPEG embeds JPEG data as is.
+func (d *Document) AddJPEG(data []byte) (*Image, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	colorSpace := "/DeviceRGB"
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		colorSpace = "/DeviceGray"
+	case color.CMYKModel:
+		colorSpace = "/DeviceCMYK"
+	}
+	img := &Image{Width: cfg.Width, Height: cfg.Height, obj: d.reserve()}
+	d.stream(img.obj, "/Type /XObject /Subtype /Image /Width "+strconv.Itoa(cfg.Width)+" /Height "+strconv.Itoa(cfg.Height)+
+		" /ColorSpace "+colorSpace+" /BitsPerComponent 8 /Filter /DCTDecode", data)
+	return img, d.err
+}
+
+// AddImage embeds any decoded image as compressed RGB.
+func (d *Document) AddImage(src image.Image) (*Image, error) {
+	b := src.Bounds()
+	var raw bytes.Buffer
+	zw := zlib.NewWriter(&raw)
+	row := make([]byte, 0, b.Dx()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := src.At(x, y).RGBA()
+			// flatten transparency onto white
+			r = (r*a + 0xffff*(0xffff-a)) / 0xffff
+			g = (g*a + 0xffff*(0xffff-a)) / 0xffff
+			bl = (bl*a + 0xffff*(0xffff-a)) / 0xffff
+			row = append(row, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	img := &Image{Width: b.Dx(), Height: b.Dy(), obj: d.reserve()}
+	d.stream(img.obj, "/Type /XObject /Subtype /Image /Width "+strconv.Itoa(img.Width)+" /Height "+strconv.Itoa(img.Height)+
+		" /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", raw.Bytes())
+	return img, d.err
+}
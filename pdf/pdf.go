@@ -0,0 +1,298 @@
+// Package pdf writes simple PDF documents: text in the standard
+// Helvetica fonts, lines, rectangles and images. Pages are written as
+// soon as they are finished so long documents can be streamed.
+//
+// Coordinates are in points (1/72 inch) measured from the top left
+// corner of the page.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Common page sizes in points.
+const (
+	A4Width      = 595.28
+	A4Height     = 841.89
+	LetterWidth  = 612
+	LetterHeight = 792
+	MM           = 72 / 25.4
+)
+
+// fixed object numbers
+const (
+	catalogObj = 1
+	pagesObj   = 2
+	fontObj    = 3
+	boldObj    = 4
+	firstFree  = 5
+)
+
+// Document is a PDF being written to an io.Writer.
+type Document struct {
+	Width, Height float64
+
+	w       *bufio.Writer
+	written int64
+	offsets map[int]int64
+	next    int
+	pages   []int
+	page    *Page
+	err     error
+}
+
+// New starts a document whose pages are width x height points.
+func New(w io.Writer, width, height float64) *Document {
+	d := &Document{
+		Width:   width,
+		Height:  height,
+		w:       bufio.NewWriter(w),
+		offsets: map[int]int64{},
+		next:    firstFree,
+	}
+	d.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return d
+}
+
+func (d *Document) printf(format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(d.w, format, args...)
+	d.written += int64(n)
+	d.err = err
+}
+
+func (d *Document) write(b []byte) {
+	if d.err != nil {
+		return
+	}
+	n, err := d.w.Write(b)
+	d.written += int64(n)
+	d.err = err
+}
+
+func (d *Document) reserve() int {
+	n := d.next
+	d.next++
+	return n
+}
+
+func (d *Document) beginObj(n int) {
+	d.offsets[n] = d.written
+	d.printf("%d 0 obj\n", n)
+}
+
+func (d *Document) stream(n int, dict string, data []byte) {
+	d.beginObj(n)
+	d.printf("<< %s /Length %d >>\nstream\n", dict, len(data))
+	d.write(data)
+	d.printf("\nendstream\nendobj\n")
+}
+
+// Flush writes buffered output, including finished pages, to the
+// underlying writer.
+func (d *Document) Flush() error {
+	if d.err != nil {
+		return d.err
+	}
+	d.err = d.w.Flush()
+	return d.err
+}
+
+// AddPage finishes the current page, if any, and starts a new one.
+func (d *Document) AddPage() *Page {
+	if d.page != nil {
+		d.page.finish()
+	}
+	d.page = &Page{doc: d, images: map[string]int{}}
+	return d.page
+}
+
+// Close finishes the last page and writes the page tree, catalog and
+// cross reference table.
+func (d *Document) Close() error {
+	if d.page != nil {
+		d.page.finish()
+		d.page = nil
+	}
+	if len(d.pages) == 0 {
+		d.AddPage().finish()
+	}
+
+	d.beginObj(fontObj)
+	d.printf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\nendobj\n")
+	d.beginObj(boldObj)
+	d.printf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>\nendobj\n")
+
+	kids := make([]string, len(d.pages))
+	for i, p := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", p)
+	}
+	d.beginObj(pagesObj)
+	d.printf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>\nendobj\n",
+		strings.Join(kids, " "), len(d.pages), d.Width, d.Height)
+	d.beginObj(catalogObj)
+	d.printf("<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", pagesObj)
+
+	xref := d.written
+	d.printf("xref\n0 %d\n0000000000 65535 f \n", d.next)
+	for n := 1; n < d.next; n++ {
+		d.printf("%010d 00000 n \n", d.offsets[n])
+	}
+	d.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", d.next, catalogObj, xref)
+	if d.err != nil {
+		return errors.WithStack(d.err)
+	}
+	return errors.WithStack(d.w.Flush())
+}
+
+// Page collects the drawing operations of one page.
+type Page struct {
+	doc    *Document
+	buf    bytes.Buffer
+	images map[string]int
+	done   bool
+}
+
+func (p *Page) op(format string, args ...interface{}) {
+	fmt.Fprintf(&p.buf, format, args...)
+	p.buf.WriteByte('\n')
+}
+
+func (p *Page) y(y float64) float64 {
+	return p.doc.Height - y
+}
+
+// Text draws s with its baseline starting at x, y.
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	p.op("BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET", font, size, x, p.y(y), encode(s))
+}
+
+// TextRight draws s so that it ends at x.
+func (p *Page) TextRight(x, y, size float64, bold bool, s string) {
+	p.Text(x-TextWidth(s, size, bold), y, size, bold, s)
+}
+
+// TextCenter draws s centred on x.
+func (p *Page) TextCenter(x, y, size float64, bold bool, s string) {
+	p.Text(x-TextWidth(s, size, bold)/2, y, size, bold, s)
+}
+
+// Line draws a line of the given width.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	p.op("%.2f w %.2f %.2f m %.2f %.2f l S", width, x1, p.y(y1), x2, p.y(y2))
+}
+
+// Rect draws the outline of a rectangle, or fills it.
+func (p *Page) Rect(x, y, w, h float64, fill bool) {
+	paint := "S"
+	if fill {
+		paint = "f"
+	}
+	p.op("%.2f %.2f %.2f %.2f re %s", x, p.y(y+h), w, h, paint)
+}
+
+// Bars draws a barcode's modules, true for a bar, from x, each module
+// wide and h high. Runs of bars are filled as one rectangle.
+func (p *Page) Bars(x, y, module, h float64, modules []bool) {
+	drawn := false
+	for i := 0; i < len(modules); {
+		run := 1
+		for i+run < len(modules) && modules[i+run] == modules[i] {
+			run++
+		}
+		if modules[i] {
+			p.op("%.3f %.2f %.3f %.2f re", x+float64(i)*module, p.y(y+h), float64(run)*module, h)
+			drawn = true
+		}
+		i += run
+	}
+	if drawn {
+		p.op("f")
+	}
+}
+
+// Gray sets the stroke and fill colour to a gray level between 0
+// (black) and 1 (white).
+func (p *Page) Gray(g float64) {
+	p.op("%.3f G %.3f g", g, g)
+}
+
+// Image draws img scaled into the box at x, y of size w x h.
+func (p *Page) Image(img *Image, x, y, w, h float64) {
+	name := fmt.Sprintf("Im%d", img.obj)
+	p.images[name] = img.obj
+	p.op("q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q", w, h, x, p.y(y+h), name)
+}
+
+func (p *Page) finish() {
+	if p.done {
+		return
+	}
+	p.done = true
+	d := p.doc
+
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write(p.buf.Bytes())
+	zw.Close()
+	contentObj := d.reserve()
+	d.stream(contentObj, "/Filter /FlateDecode", content.Bytes())
+
+	var xobjects []string
+	for name, obj := range p.images {
+		xobjects = append(xobjects, fmt.Sprintf("/%s %d 0 R", name, obj))
+	}
+	pageObj := d.reserve()
+	d.beginObj(pageObj)
+	d.printf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> /XObject << %s >> >> >>\nendobj\n",
+		pagesObj, contentObj, fontObj, boldObj, strings.Join(xobjects, " "))
+	d.pages = append(d.pages, pageObj)
+}
+
+// encode converts s to WinAnsi and escapes it for a PDF string.
+// Characters the standard fonts can't show are replaced with "?".
+func encode(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c := winAnsi(r)
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func winAnsi(r rune) byte {
+	switch {
+	case r >= 32 && r < 127:
+		return byte(r)
+	case r >= 160 && r <= 255:
+		return byte(r)
+	case r == '\t' || r == '\n' || r == '\r':
+		return ' '
+	case r == '‘' || r == '’':
+		return '\''
+	case r == '“' || r == '”':
+		return '"'
+	case r == '–' || r == '—':
+		return '-'
+	}
+	return '?'
+}
@@ -0,0 +1,71 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_Document_XRef(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := New(buf, A4Width, A4Height)
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.Set(1, 1, color.White)
+	im, err := d.AddImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := d.AddPage()
+	p.Text(10, 20, 12, true, "Hello (world)")
+	p.Image(im, 10, 30, 20, 20)
+	d.AddPage().Rect(10, 10, 50, 50, false)
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "/Count 2") {
+		t.Error("expected two pages")
+	}
+	// every xref entry must point at the start of its object
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(out)
+	start, _ := strconv.Atoi(m[1])
+	lines := strings.Split(out[start:], "\n")
+	n, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for obj := 1; obj < n; obj++ {
+		off, _ := strconv.Atoi(strings.Fields(lines[2+obj])[0])
+		if want := strconv.Itoa(obj) + " 0 obj"; !strings.HasPrefix(out[off:], want) {
+			t.Errorf("xref for object %d points at %q", obj, out[off:off+10])
+		}
+	}
+}
+
+func Test_encode(t *testing.T) {
+	if got := encode(`a(b)\ “ok” 漢`); got != `a\(b\)\\ "ok" ?` {
+		t.Errorf("got %q", got)
+	}
+}
+
+func Test_Fit(t *testing.T) {
+	if got := Fit("short", 100, 10, false); got != "short" {
+		t.Errorf("got %q", got)
+	}
+	got := Fit("a much longer piece of text", 50, 10, false)
+	if !strings.HasSuffix(got, "...") || TextWidth(got, 10, false) > 50 {
+		t.Errorf("got %q", got)
+	}
+}
+
+func Test_Bars(t *testing.T) {
+	d := New(&bytes.Buffer{}, A4Width, A4Height)
+	p := d.AddPage()
+	p.Bars(10, 20, 1.5, 30, []bool{true, true, false, true, false, false})
+	want := "10.000 791.89 3.000 30.00 re\n14.500 791.89 1.500 30.00 re\nf\n"
+	if got := p.buf.String(); got != want {
+		t.Errorf("got %q", got)
+	}
+}
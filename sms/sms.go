@@ -0,0 +1,161 @@
+// Package sms sends text messages, such as the notices the library
+// sends customers who would rather not be emailed. Where messages go is
+// up to a Sender: an HTTP gateway, the log in development, or a Fake in
+// tests. Numbers are in E.164 form, as in +442079460958; Normalize puts
+// them in it.
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Message is a text message.
+type Message struct {
+	// To is the number the message is sent to, in E.164 form.
+	To   string
+	Text string
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Normalize writes a phone number in E.164 form: a plus and up to 15
+// digits, country code first. Numbers written with a plus or a leading
+// 00 keep their country code; other numbers are taken to be national
+// ones in countryCode, with any leading 0 trunk prefix dropped. Spaces,
+// dots, dashes, slashes and brackets are ignored, as is the (0) of
+// numbers such as +44 (0)20 7946 0958.
+func Normalize(number, countryCode string) (string, error) {
+	s := strings.TrimSpace(number)
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		international, s = true, s[1:]
+	case strings.HasPrefix(s, "00"):
+		international, s = true, s[2:]
+	}
+	if international {
+		s = strings.Replace(s, "(0)", "", 1)
+	}
+
+	var digits strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" .-/()", r):
+		default:
+			return "", errors.Errorf("%q is not a phone number", number)
+		}
+	}
+	d := digits.String()
+	if !international {
+		cc := strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+		if cc == "" {
+			return "", errors.Errorf("%q has no country code", number)
+		}
+		d = cc + strings.TrimPrefix(d, "0")
+	}
+	if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+		return "", errors.Errorf("%q is not a phone number", number)
+	}
+	return "+" + d, nil
+}
+
+// HTTP sends messages through a gateway that takes them as JSON posted
+// to URL, {"from": From, "to": "+44…", "text": "…"}, with Token as a
+// bearer token. Any 2xx response means the gateway took the message.
+type HTTP struct {
+	URL   string
+	Token string
+	// From is the sender the gateway shows, a number or a name.
+	From   string
+	Client *http.Client
+}
+
+// Send posts m to the gateway.
+func (h HTTP) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(map[string]string{"from": h.From, "to": m.To, "text": m.Text})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending a text message")
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		reply, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return errors.Errorf("SMS gateway answered %s: %s", res.Status, strings.TrimSpace(string(reply)))
+	}
+	return nil
+}
+
+// Log writes each message to a logger instead of sending it.
+type Log struct {
+	Logger *log.Logger
+}
+
+// Send logs m.
+func (l Log) Send(ctx context.Context, m Message) error {
+	logger := l.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("text to %s: %s", m.To, m.Text)
+	return nil
+}
+
+// Fake keeps the messages it is sent, for tests to look at. When Err is
+// set it fails to send them instead.
+type Fake struct {
+	Err error
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// Send keeps m, or fails with f.Err.
+func (f *Fake) Send(ctx context.Context, m Message) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	if !strings.HasPrefix(m.To, "+") {
+		return fmt.Errorf("%q is not in E.164 form", m.To)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+// Sent returns the messages sent so far.
+func (f *Fake) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
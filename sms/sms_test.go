@@ -0,0 +1,88 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Normalize(t *testing.T) {
+	tests := []struct {
+		number, cc, want string
+	}{
+		{"+44 20 7946 0958", "1", "+442079460958"},
+		{"+44 (0)20 7946 0958", "1", "+442079460958"},
+		{"0044 20 7946 0958", "1", "+442079460958"},
+		{"020 7946 0958", "44", "+442079460958"},
+		{"(555) 123-4567", "1", "+15551234567"},
+		{"555.123.4567", "+1", "+15551234567"},
+		{"5551234567", "", ""},
+		{"555-CALL-NOW", "1", ""},
+		{"12", "1", ""},
+		{"+1234567890123456", "1", ""},
+		{"", "1", ""},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.number, tt.cc)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Normalize(%q, %q) = %q, want an error", tt.number, tt.cc, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q, %q) = %q, %v, want %q", tt.number, tt.cc, got, err, tt.want)
+		}
+	}
+}
+
+func Test_HTTP(t *testing.T) {
+	var got map[string]string
+	status := http.StatusAccepted
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "who are you?", http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer gateway.Close()
+
+	h := HTTP{URL: gateway.URL, Token: "secret", From: "Library"}
+	if err := h.Send(context.Background(), Message{To: "+15551234567", Text: "Emma is due back"}); err != nil {
+		t.Fatal(err)
+	}
+	if got["from"] != "Library" || got["to"] != "+15551234567" || got["text"] != "Emma is due back" {
+		t.Errorf("gateway got %v", got)
+	}
+
+	status = http.StatusTooManyRequests
+	err := h.Send(context.Background(), Message{To: "+15551234567", Text: "Emma is due back"})
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Send = %v, want the gateway's refusal", err)
+	}
+	h.Token = "wrong"
+	if err := h.Send(context.Background(), Message{To: "+15551234567", Text: "Hi"}); err == nil || !strings.Contains(err.Error(), "who are you?") {
+		t.Errorf("Send = %v, want the gateway's refusal", err)
+	}
+}
+
+func Test_Fake(t *testing.T) {
+	f := &Fake{}
+	if err := f.Send(context.Background(), Message{To: "+15551234567", Text: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Send(context.Background(), Message{To: "555 123 4567", Text: "Hi"}); err == nil {
+		t.Error("sent to a number not in E.164 form")
+	}
+	if sent := f.Sent(); len(sent) != 1 || sent[0].To != "+15551234567" {
+		t.Errorf("Sent = %v", sent)
+	}
+}
@@ -0,0 +1,109 @@
+package spreadsheet
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"library/pdf"
+)
+
+// layout of exported PDF tables, in points
+const (
+	pdfMargin   = 36
+	pdfFontSize = 8
+	pdfRowH     = 13
+	pdfPadding  = 3
+)
+
+type pdfWriter struct {
+	doc    *pdf.Document
+	title  string
+	header []string
+	widths []float64
+	page   *pdf.Page
+	pageNo int
+	y      float64
+	date   string
+}
+
+// NewPDFWriter returns a Writer producing a landscape A4 table. The
+// title, the header row and the page number are repeated on every
+// page, and each page is written out as soon as it is full.
+func NewPDFWriter(w io.Writer, title string) Writer {
+	return &pdfWriter{
+		doc:   pdf.New(w, pdf.A4Height, pdf.A4Width),
+		title: title,
+		date:  time.Now().Format("2006-01-02 15:04"),
+	}
+}
+
+func (p *pdfWriter) Write(row []string) error {
+	if p.header == nil {
+		p.header = append([]string{}, row...)
+		p.widths = p.columnWidths()
+		return nil
+	}
+	if p.page == nil || p.y+pdfRowH > p.doc.Height-pdfMargin-pdfRowH {
+		p.newPage()
+	}
+	p.row(row, false)
+	return nil
+}
+
+// columnWidths shares the printable width between the columns, giving
+// each a share proportional to its heading but at least a minimum.
+func (p *pdfWriter) columnWidths() []float64 {
+	total := p.doc.Width - 2*pdfMargin
+	weights := make([]float64, len(p.header))
+	sum := 0.0
+	for i, h := range p.header {
+		weights[i] = pdf.TextWidth(h, pdfFontSize, true) + 40
+		sum += weights[i]
+	}
+	for i := range weights {
+		weights[i] = weights[i] / sum * total
+	}
+	return weights
+}
+
+func (p *pdfWriter) newPage() {
+	p.page = p.doc.AddPage()
+	p.pageNo++
+	p.page.Text(pdfMargin, pdfMargin, 12, true, p.title)
+	p.page.TextRight(p.doc.Width-pdfMargin, pdfMargin, pdfFontSize, false, p.date)
+	p.page.TextRight(p.doc.Width-pdfMargin, p.doc.Height-pdfMargin/2, pdfFontSize, false, "Page "+strconv.Itoa(p.pageNo))
+	p.y = pdfMargin + 10
+	p.row(p.header, true)
+}
+
+func (p *pdfWriter) row(cells []string, bold bool) {
+	if bold {
+		p.page.Gray(0.9)
+		p.page.Rect(pdfMargin, p.y, p.doc.Width-2*pdfMargin, pdfRowH, true)
+		p.page.Gray(0)
+	}
+	x := float64(pdfMargin)
+	for i, w := range p.widths {
+		if i < len(cells) {
+			text := pdf.Fit(cells[i], w-2*pdfPadding, pdfFontSize, bold)
+			p.page.Text(x+pdfPadding, p.y+pdfRowH-4, pdfFontSize, bold, text)
+		}
+		x += w
+	}
+	p.y += pdfRowH
+	p.page.Gray(0.75)
+	p.page.Line(pdfMargin, p.y, p.doc.Width-pdfMargin, p.y, 0.5)
+	p.page.Gray(0)
+}
+
+func (p *pdfWriter) Flush() error {
+	return p.doc.Flush()
+}
+
+func (p *pdfWriter) Close() error {
+	if p.page == nil {
+		p.newPage()
+	}
+	return p.doc.Close()
+}
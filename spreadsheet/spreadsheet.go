@@ -0,0 +1,86 @@
+// Package spreadsheet reads and writes the tabular files staff use to
+// move data in and out of the library: CSV and Excel (XLSX), plus
+// printable PDF tables for exports.
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Supported formats.
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+)
+
+// FormatOf returns the format for a file name, or "" when the
+// extension isn't supported.
+func FormatOf(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".txt":
+		return CSV
+	case ".xlsx":
+		return XLSX
+	}
+	return ""
+}
+
+// Read returns all rows of a CSV file or of the first sheet of an XLSX
+// workbook. Rows are padded so they all have the same number of cells.
+func Read(format string, r io.ReaderAt, size int64) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch format {
+	case CSV:
+		rows, err = ReadCSV(io.NewSectionReader(r, 0, size))
+	case XLSX:
+		rows, err = ReadXLSX(r, size)
+	default:
+		return nil, errors.Errorf("spreadsheet: unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pad(rows), nil
+}
+
+// ReadCSV reads a comma separated file, ignoring a UTF-8 byte order
+// mark as written by Excel.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+
+	cr := csv.NewReader(bytes.NewReader(b))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return rows, nil
+}
+
+func pad(rows [][]string) [][]string {
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+	return rows
+}
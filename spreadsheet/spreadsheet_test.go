@@ -0,0 +1,156 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func buildXLSX(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func Test_ReadXLSX(t *testing.T) {
+	r := buildXLSX(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Books" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Type="worksheet" Target="worksheets/data.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>Title</t></si><si><t>Price</t></si><si><r><t>Crime and </t></r><r><t>Punishment</t></r></si></sst>`,
+		"xl/worksheets/data.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+			<row r="3"><c r="A3" t="s"><v>2</v></c><c r="B3" t="b"><v>1</v></c><c r="C3"><v>12.5</v></c></row>
+			<row r="4"><c r="A4" t="inlineStr"><is><t>Inline</t></is></c></row>
+		</sheetData></worksheet>`,
+	})
+
+	rows, err := Read(XLSX, r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Title", "", "Price"},
+		{"", "", ""},
+		{"Crime and Punishment", "TRUE", "12.5"},
+		{"Inline", "", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %q", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if strings.Join(rows[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("row %d = %q, want %q", i, rows[i], want[i])
+		}
+	}
+}
+
+func Test_ReadCSV(t *testing.T) {
+	r := strings.NewReader("\xef\xbb\xbfName,Email\nAnn,ann@example.com,extra\n")
+	rows, err := Read(CSV, r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0][0] != "Name" || len(rows[0]) != 3 || rows[1][2] != "extra" {
+		t.Errorf("unexpected rows %q", rows)
+	}
+}
+
+func Test_FormatOf(t *testing.T) {
+	for name, want := range map[string]string{"a.CSV": CSV, "b.xlsx": XLSX, "c.xls": ""} {
+		if got := FormatOf(name); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_XLSXWriter_RoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(XLSX, buf, "Books: all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := [][]string{
+		{"Book No", "Title", "Price"},
+		{"00123", "Crime & <Punishment>", "12.5"},
+		{"B-2", "", "7"},
+	}
+	for _, row := range in {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := Read(XLSX, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		if strings.Join(rows[i], "|") != strings.Join(in[i], "|") {
+			t.Errorf("row %d = %q, want %q", i, rows[i], in[i])
+		}
+	}
+	if isNumber("00123") || !isNumber("12.5") {
+		t.Error("book numbers with leading zeros must stay text")
+	}
+}
+
+func Test_CSVWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, _ := NewWriter(CSV, buf, "")
+	w.Write([]string{"Name", "Email"})
+	w.Write([]string{"Ann, Jr", "ann@example.com"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "\xef\xbb\xbfName,Email\n\"Ann, Jr\",ann@example.com\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	rows, err := ReadCSV(buf)
+	if err != nil || len(rows) != 2 || rows[0][0] != "Name" {
+		t.Errorf("csv does not read back: %q %v", rows, err)
+	}
+}
+
+func Test_PDFWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, _ := NewWriter(PDF, buf, "Books")
+	w.Write([]string{"Title", "Author"})
+	for i := 0; i < 100; i++ {
+		w.Write([]string{"A rather long title that will not fit in its column at all", "Dostoyevsky"})
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatal("not a complete PDF")
+	}
+	if !strings.Contains(out, "/Count 3") {
+		t.Error("100 rows should fill 3 landscape pages")
+	}
+}
+
+func Test_columnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want || columnIndex(got+"1") != i {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// PDF is an export-only format: a paginated, printable table.
+const PDF = "pdf"
+
+// Writer writes a table one row at a time. The first row written is
+// the header. Flush pushes finished output to the underlying writer so
+// long exports can be streamed, and Close completes the file.
+type Writer interface {
+	Write(row []string) error
+	Flush() error
+	Close() error
+}
+
+// NewWriter returns a Writer for format. The title names the XLSX sheet
+// and heads every page of a PDF.
+func NewWriter(format string, w io.Writer, title string) (Writer, error) {
+	switch format {
+	case CSV:
+		return NewCSVWriter(w), nil
+	case XLSX:
+		return NewXLSXWriter(w, title)
+	case PDF:
+		return NewPDFWriter(w, title), nil
+	}
+	return nil, errors.Errorf("spreadsheet: unsupported format %q", format)
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	switch format {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case PDF:
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	started bool
+	out     io.Writer
+}
+
+// NewCSVWriter returns a Writer producing comma separated values. A
+// UTF-8 byte order mark is written first so Excel detects the encoding.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w), out: w}
+}
+
+func (c *csvWriter) Write(row []string) error {
+	if !c.started {
+		c.started = true
+		if _, err := io.WriteString(c.out, "\xef\xbb\xbf"); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return errors.WithStack(c.w.Write(row))
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return errors.WithStack(c.w.Error())
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
@@ -0,0 +1,145 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border/></borders><cellStyleXfs count="1"><xf/></cellStyleXfs><cellXfs count="2"><xf fontId="0"/><xf fontId="1" applyFont="1"/></cellXfs></styleSheet>`},
+}
+
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	line  int
+}
+
+// NewXLSXWriter returns a Writer producing a single sheet workbook.
+// Rows go straight into the compressed worksheet, so memory use does
+// not grow with the number of rows. The header row is bold.
+func NewXLSXWriter(w io.Writer, title string) (Writer, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		if err := writeZipPart(zw, part.name, part.body); err != nil {
+			return nil, err
+		}
+	}
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(sheetName(title)))
+	err := writeZipPart(zw, "xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`+name.String()+`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+	if err != nil {
+		return nil, err
+	}
+
+	sw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	x := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(sw)}
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return x, nil
+}
+
+func writeZipPart(zw *zip.Writer, name, body string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = io.WriteString(w, body)
+	return errors.WithStack(err)
+}
+
+// sheetName makes a title acceptable as an Excel sheet name.
+func sheetName(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, title)
+	if r := []rune(title); len(r) > 31 {
+		title = string(r[:31])
+	}
+	if strings.TrimSpace(title) == "" {
+		return "Sheet1"
+	}
+	return title
+}
+
+func (x *xlsxWriter) Write(row []string) error {
+	x.line++
+	w := x.sheet
+	w.WriteString(`<row r="` + strconv.Itoa(x.line) + `">`)
+	for i, v := range row {
+		ref := columnName(i) + strconv.Itoa(x.line)
+		style := ""
+		if x.line == 1 {
+			style = ` s="1"`
+		}
+		if x.line > 1 && isNumber(v) {
+			w.WriteString(`<c r="` + ref + `"` + style + `><v>` + v + `</v></c>`)
+			continue
+		}
+		w.WriteString(`<c r="` + ref + `"` + style + ` t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(w, []byte(v))
+		w.WriteString(`</t></is></c>`)
+	}
+	_, err := w.WriteString(`</row>`)
+	return errors.WithStack(err)
+}
+
+func (x *xlsxWriter) Flush() error {
+	if err := x.sheet.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(x.zw.Flush())
+}
+
+func (x *xlsxWriter) Close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(x.zw.Close())
+}
+
+// isNumber reports whether v should be stored as a number. Values with
+// leading zeros, such as book numbers, stay text so they survive.
+func isNumber(v string) bool {
+	if v == "" || len(v) > 15 {
+		return false
+	}
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return false
+	}
+	digits := strings.TrimPrefix(v, "-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return false
+	}
+	return !strings.ContainsAny(v, "eEinfINFxX+") && !strings.HasPrefix(digits, ".") && !strings.HasSuffix(v, ".")
+}
+
+// columnName is the inverse of columnIndex: 0 is "A", 26 is "AA".
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,267 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/pkg/errors"
+)
+
+// MaxPixels is the largest image accepted, however small the file: a
+// few kilobytes can decode into gigabytes.
+const MaxPixels = 40000000
+
+// cleanImage decodes the image and encodes it again, which leaves out
+// EXIF and any other metadata. A JPEG's EXIF orientation is applied
+// first so photos taken sideways still show upright. It also returns a
+// thumbnail fitted into size, when size isn't 0.
+func cleanImage(data []byte, contentType string, size int) ([]byte, []byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, rejected("The image can't be read.")
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, nil, rejected("The image is larger than %d megapixels.", MaxPixels/1000000)
+	}
+
+	var clean bytes.Buffer
+	var img image.Image
+	switch contentType {
+	case "image/gif":
+		// every frame is kept, so animations still play
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, rejected("The image can't be read.")
+		}
+		if err := gif.EncodeAll(&clean, g); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		img = g.Image[0]
+	default:
+		if img, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return nil, nil, rejected("The image can't be read.")
+		}
+		if contentType == "image/jpeg" {
+			img = orient(toRGBA(img), exifOrientation(data))
+		}
+		if err := encode(&clean, img, contentType); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if size == 0 {
+		return clean.Bytes(), nil, nil
+	}
+	var thumbnail bytes.Buffer
+	if err := encode(&thumbnail, Fit(img, size, size), contentType); err != nil {
+		return nil, nil, err
+	}
+	return clean.Bytes(), thumbnail.Bytes(), nil
+}
+
+func encode(w *bytes.Buffer, img image.Image, contentType string) error {
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	case "image/gif":
+		err = gif.Encode(w, img, nil)
+	case "image/webp":
+		err = EncodeWebP(w, img)
+	default:
+		err = png.Encode(w, img)
+	}
+	return errors.WithStack(err)
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// Fit scales img down to fit within width by height, keeping its
+// aspect ratio, by averaging the pixels each new pixel covers. Images
+// that already fit are returned as they are.
+func Fit(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= width && h <= height {
+		return img
+	}
+	if w*height > h*width {
+		height = max1(h * width / w)
+	} else {
+		width = max1(w * height / h)
+	}
+	src := toRGBA(img)
+	return scale(src, src.Rect, width, height)
+}
+
+// Fill scales and crops img to exactly width by height, keeping its
+// aspect ratio by cutting off the edges that don't fit, as a cover
+// thumbnail in a grid wants.
+func Fill(img image.Image, width, height int) image.Image {
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	crop := src.Rect
+	if w*height > h*width {
+		cw := max1(h * width / height)
+		crop.Min.X = (w - cw) / 2
+		crop.Max.X = crop.Min.X + cw
+	} else {
+		ch := max1(w * height / width)
+		crop.Min.Y = (h - ch) / 2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+	return scale(src, crop, width, height)
+}
+
+// scale resizes the part r of src to width by height, averaging the
+// pixels each new pixel covers when shrinking and repeating them when
+// growing.
+func scale(src *image.RGBA, r image.Rectangle, width, height int) *image.RGBA {
+	w, h := r.Dx(), r.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * h / height
+		y1 := (y + 1) * h / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * w / width
+			x1 := (x + 1) * w / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum [4]int
+			n := 0
+			for sy := r.Min.Y + y0; sy < r.Min.Y+y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := r.Min.X + x0; sx < r.Min.X+x1; sx++ {
+					for i := range sum {
+						sum[i] += int(row[sx*4+i])
+					}
+					n++
+				}
+			}
+			off := y*dst.Stride + x*4
+			for i := range sum {
+				dst.Pix[off+i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// orient turns and flips img as EXIF orientation o says the camera
+// held it.
+func orient(img *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return img
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // flipped left to right
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // flipped top to bottom
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // turned left, so turn right
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // turned right, so turn left
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], img.Pix[y*img.Stride+x*4:y*img.Stride+x*4+4])
+		}
+	}
+	return dst
+}
+
+// exifOrientation reads the orientation tag from a JPEG's EXIF data,
+// returning 1 (upright) when there is none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // image data starts
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
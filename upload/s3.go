@@ -0,0 +1,325 @@
+package upload
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// S3 keeps uploads in a bucket of Amazon S3 or a service speaking its
+// API, such as MinIO. Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	// Endpoint is the service's base URL, as in "https://s3.amazonaws.com"
+	// or "http://localhost:9000".
+	Endpoint string
+	Region   string
+	Bucket   string
+	// AccessKey and SecretKey are the credentials requests are signed
+	// with.
+	AccessKey string
+	SecretKey string
+	// PathStyle puts the bucket in the path rather than the host name,
+	// as MinIO and most stand-ins need.
+	PathStyle bool
+	// Client sends the requests; http.DefaultClient when nil.
+	Client *http.Client
+
+	// now is time.Now, replaced in tests.
+	now func() time.Time
+}
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// objectURL returns the URL of key, or of the bucket when key is "".
+func (s S3) objectURL(key string) *url.URL {
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil || u.Host == "" {
+		u = &url.URL{Scheme: "https", Host: s.Endpoint}
+	}
+	if s.PathStyle {
+		u.Path = "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u
+}
+
+func (s S3) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s S3) clock() time.Time {
+	if s.now != nil {
+		return s.now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// do signs and sends a request, turning S3's error responses into
+// errors. Callers close the body of the response returned.
+func (s S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, s.clock())
+	res, err := s.client().Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotExist
+	}
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		var e struct {
+			Code    string
+			Message string
+		}
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+			return nil, errors.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, e.Code, e.Message)
+		}
+		return nil, errors.Errorf("s3 %s %s: %s", req.Method, req.URL.Path, res.Status)
+	}
+	return res, nil
+}
+
+func (s S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := s.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (s S3) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, Info{}, errors.WithStack(err)
+	}
+	res, err := s.do(req, emptyHash)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return res.Body, s3Info(res), nil
+}
+
+func (s S3) Stat(ctx context.Context, key string) (Info, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(key).String(), nil)
+	if err != nil {
+		return Info{}, errors.WithStack(err)
+	}
+	res, err := s.do(req, emptyHash)
+	if err != nil {
+		return Info{}, err
+	}
+	res.Body.Close()
+	return s3Info(res), nil
+}
+
+func s3Info(res *http.Response) Info {
+	info := Info{Size: res.ContentLength, ContentType: res.Header.Get("Content-Type")}
+	info.ModTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	return info
+}
+
+func (s S3) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	res, err := s.do(req, emptyHash)
+	if errors.Is(err, ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// List pages through ListObjectsV2.
+func (s S3) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	token := ""
+	for {
+		u := s.objectURL("")
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res, err := s.do(req, emptyHash)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, c := range page.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Presign returns a URL anyone can GET key with until it expires,
+// which S3 limits to a week.
+func (s S3) Presign(key string, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return "", errors.Errorf("presigned URLs expire after a second to a week, not %s", expires)
+	}
+	now := s.clock()
+	u := s.objectURL(key)
+	q := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.AccessKey + "/" + s.scope(now)},
+		"X-Amz-Date":          {now.Format(amzDate)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u.RawQuery = q.Encode()
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: http.Header{}}
+	signature := s.signature(now, s.canonicalRequest(req, []string{"host"}, unsignedPayload))
+	return u.String() + "&X-Amz-Signature=" + signature, nil
+}
+
+const (
+	amzDate   = "20060102T150405Z"
+	emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// sign adds the headers that authenticate req, whose payload hashes to
+// payloadHash, or is UNSIGNED-PAYLOAD when uploads are streamed.
+func (s S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.Format(amzDate))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	headers := []string{"host"}
+	for name := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers = append(headers, name)
+		}
+	}
+	sort.Strings(headers)
+
+	signature := s.signature(now, s.canonicalRequest(req, headers, payloadHash))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, s.scope(now), strings.Join(headers, ";"), signature))
+}
+
+func (s S3) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+func (s S3) canonicalRequest(req *http.Request, headers []string, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	b.WriteString(awsEscape(req.URL.Path, false) + "\n")
+
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := []string{}
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, awsEscape(name, true)+"="+awsEscape(v, true))
+		}
+	}
+	b.WriteString(strings.Join(params, "&") + "\n")
+
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+		}
+		b.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	b.WriteString("\n" + strings.Join(headers, ";") + "\n")
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+func (s S3) signature(now time.Time, canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(amzDate) + "\n" + s.scope(now) + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.Format("20060102"))
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape percent-encodes s the way Signature Version 4 wants:
+// everything but unreserved characters, and slashes too in query
+// strings.
+func awsEscape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,200 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotExist is returned for keys a Storage holds nothing under.
+var ErrNotExist = errors.New("upload does not exist")
+
+// Storage keeps the content of uploads under keys such as
+// "books/3f2a….jpg". Every app instance has to see the same storage,
+// so only Local storage on a shared disk or S3 suit more than one.
+type Storage interface {
+	// Put stores size bytes read from r under key.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens what is stored under key. Callers close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Stat describes what is stored under key without reading it.
+	Stat(ctx context.Context, key string) (Info, error)
+	// Delete removes key. Deleting a key that holds nothing isn't an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys starting with prefix, in order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Presigner is a Storage that can hand out URLs that read a key
+// directly from it, valid for a while, as S3 can.
+type Presigner interface {
+	Presign(key string, expires time.Duration) (string, error)
+}
+
+// Info describes a stored upload.
+type Info struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Local keeps uploads in a directory.
+type Local struct {
+	Dir string
+}
+
+func (l Local) file(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", errors.Errorf("invalid upload key %q", key)
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(clean)), nil
+}
+
+// Put writes to a temporary file first so a half-written upload is
+// never served.
+func (l Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	dst, err := l.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), dst))
+}
+
+func (l Local) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, info, err
+	}
+	name, _ := l.file(key)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, info, localError(err)
+	}
+	return f, info, nil
+}
+
+func (l Local) Stat(ctx context.Context, key string) (Info, error) {
+	name, err := l.file(key)
+	if err != nil {
+		return Info{}, err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return Info{}, localError(err)
+	}
+	if fi.IsDir() {
+		return Info{}, ErrNotExist
+	}
+	return Info{Size: fi.Size(), ContentType: mime.TypeByExtension(path.Ext(key)), ModTime: fi.ModTime()}, nil
+}
+
+func (l Local) Delete(ctx context.Context, key string) error {
+	name, err := l.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// List leaves out the temporary files of uploads being written. Only
+// the directory the prefix points into is walked.
+func (l Local) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	start := l.Dir
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		start = filepath.Join(l.Dir, filepath.FromSlash(prefix[:i]))
+	}
+	err := filepath.WalkDir(start, func(name string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && name == start {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.Dir, name)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func localError(err error) error {
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	return errors.WithStack(err)
+}
+
+// Copy puts every upload in from that to lacks into to, as when moving
+// uploads from a local directory to S3, and reports each key copied.
+// Files already in to are left alone, so an interrupted copy can be run
+// again.
+func Copy(ctx context.Context, from, to Storage, copied func(key string)) error {
+	keys, err := from.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := to.Stat(ctx, key); err == nil {
+			continue
+		} else if !errors.Is(err, ErrNotExist) {
+			return err
+		}
+		r, info, err := from.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		err = to.Put(ctx, key, r, info.Size, info.ContentType)
+		r.Close()
+		if err != nil {
+			return errors.Wrapf(err, "copying %s", key)
+		}
+		if copied != nil {
+			copied(key)
+		}
+	}
+	return nil
+}
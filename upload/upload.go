@@ -0,0 +1,296 @@
+// Package upload stores files users upload, such as book covers and
+// profile pictures. Files are checked by their content rather than the
+// name or type the browser sent, images are re-encoded so no EXIF data
+// (camera, location) is kept, and each file is named after a hash of
+// its content so two uploads never overwrite each other. Where uploads
+// are kept is up to a Storage: a local directory or an S3 bucket.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Images are the content types of the images browsers can show and
+// this package can clean.
+var Images = []string{"image/jpeg", "image/png", "image/gif"}
+
+var extensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+// Options limit what an upload may be.
+type Options struct {
+	// MaxBytes is the largest file accepted.
+	MaxBytes int64
+	// Types are the content types accepted, as sniffed from the content.
+	Types []string
+	// Thumbnail is the size of the square a thumbnail of an image is
+	// fitted into, or 0 for no thumbnail.
+	Thumbnail int
+}
+
+// Error is an upload refused for what it is, such as being too large
+// or of the wrong type, as opposed to failing to store it. Its message
+// is meant for the user.
+type Error struct {
+	msg string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func rejected(format string, args ...interface{}) error {
+	return &Error{msg: fmt.Sprintf(format, args...)}
+}
+
+// IsRejected reports whether err is an Error.
+func IsRejected(err error) bool {
+	var e *Error
+	return errors.As(err, &e)
+}
+
+// File is a stored upload.
+type File struct {
+	// Path is where the file is served, as in
+	// "/uploads/books/3f2a….jpg".
+	Path string
+	// Thumbnail is where its thumbnail is served, if it has one.
+	Thumbnail   string
+	ContentType string
+	Size        int64
+}
+
+// Store checks and keeps uploads in a Storage, serving them at a URL
+// path.
+type Store struct {
+	Storage Storage
+	// URL is the path uploads are served at, such as "/uploads".
+	URL string
+	// Secret signs the URLs of private uploads; see SignedURL.
+	Secret []byte
+	// Private are the folders whose uploads are only served at signed
+	// URLs.
+	Private []string
+}
+
+// Save checks the upload in r against opts and stores it in folder,
+// such as "books". Saving the same content twice gives the same File.
+func (s Store) Save(ctx context.Context, folder string, r io.Reader, opts Options) (*File, error) {
+	data, err := io.ReadAll(io.LimitReader(r, opts.MaxBytes+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(data) == 0 {
+		return nil, rejected("The file is empty.")
+	}
+	if int64(len(data)) > opts.MaxBytes {
+		return nil, rejected("The file is larger than %s.", byteSize(opts.MaxBytes))
+	}
+
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !allowed(contentType, opts.Types) {
+		return nil, rejected("%s files are not allowed; upload %s.", contentType, strings.Join(opts.Types, ", "))
+	}
+
+	var thumbnail []byte
+	if isImage(contentType) {
+		if data, thumbnail, err = cleanImage(data, contentType, opts.Thumbnail); err != nil {
+			return nil, err
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	key := path.Join(folder, hex.EncodeToString(sum[:16])+extensions[contentType])
+	if err := s.put(ctx, key, data, contentType); err != nil {
+		return nil, err
+	}
+	file := &File{Path: s.Path(key), ContentType: contentType, Size: int64(len(data))}
+	if thumbnail != nil {
+		file.Thumbnail = ThumbnailPath(file.Path)
+		if err := s.put(ctx, ThumbnailPath(key), thumbnail, contentType); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// put stores data under key unless something is already there, which,
+// keys being hashes, is the same data.
+func (s Store) put(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := s.Storage.Stat(ctx, key)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	return s.Storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+// Open opens the upload served at p. Callers close it.
+func (s Store) Open(ctx context.Context, p string) (io.ReadCloser, Info, error) {
+	key, ok := s.Key(p)
+	if !ok {
+		return nil, Info{}, ErrNotExist
+	}
+	return s.Storage.Get(ctx, key)
+}
+
+// Remove deletes the upload served at p along with its thumbnail.
+// Paths outside the store and uploads already gone are ignored.
+func (s Store) Remove(ctx context.Context, p string) error {
+	for _, p := range []string{p, ThumbnailPath(p)} {
+		key, ok := s.Key(p)
+		if !ok {
+			continue
+		}
+		if err := s.Storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Key returns the storage key of the upload served at p, and false
+// when p isn't a path within the store.
+func (s Store) Key(p string) (string, bool) {
+	prefix := strings.TrimSuffix(s.URL, "/") + "/"
+	if !strings.HasPrefix(p, prefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(p, prefix)), "/")
+	if key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+// Path returns where the upload stored under key is served.
+func (s Store) Path(key string) string {
+	return strings.TrimSuffix(s.URL, "/") + "/" + key
+}
+
+// IsPrivate reports whether the upload served at p is in one of the
+// Private folders.
+func (s Store) IsPrivate(p string) bool {
+	key, ok := s.Key(p)
+	if !ok {
+		return false
+	}
+	for _, folder := range s.Private {
+		if strings.HasPrefix(key, strings.TrimSuffix(folder, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SignedURL returns a URL the upload served at p can be read at until
+// expires has passed, for uploads in Private folders. Storage that can
+// presign URLs, such as S3, serves the upload itself; otherwise the URL
+// is p with an expiry and a signature that Verify checks.
+func (s Store) SignedURL(p string, expires time.Duration) (string, error) {
+	key, ok := s.Key(p)
+	if !ok {
+		return "", errors.Errorf("%s is not an upload", p)
+	}
+	if presigner, ok := s.Storage.(Presigner); ok {
+		return presigner.Presign(key, expires)
+	}
+	deadline := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	return s.Path(key) + "?" + url.Values{"expires": {deadline}, "signature": {s.sign(key, deadline)}}.Encode(), nil
+}
+
+// Verify reports whether query holds an unexpired signature SignedURL
+// made for the upload served at p.
+func (s Store) Verify(p string, query url.Values) bool {
+	key, ok := s.Key(p)
+	if !ok || len(s.Secret) == 0 {
+		return false
+	}
+	deadline, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > deadline {
+		return false
+	}
+	return hmac.Equal([]byte(query.Get("signature")), []byte(s.sign(key, query.Get("expires"))))
+}
+
+func (s Store) sign(key, deadline string) string {
+	h := hmac.New(sha256.New, s.Secret)
+	h.Write([]byte(key + "\n" + deadline))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ThumbnailPath returns where the thumbnail of the image at p is, as in
+// "/uploads/books/3f2a…_thumb.jpg".
+func ThumbnailPath(p string) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + "_thumb" + ext
+}
+
+// Thumbnail returns the path of the thumbnail of the image at p, or p
+// itself when p isn't named by this package, like files stored before
+// it was used. Only use it for folders saved with a Thumbnail size.
+func Thumbnail(p string) string {
+	if !hashName(strings.TrimSuffix(path.Base(p), path.Ext(p))) {
+		return p
+	}
+	return ThumbnailPath(p)
+}
+
+// Immutable reports whether the upload at p, or its thumbnail, is named
+// after its content, so whatever is served at p never changes.
+func Immutable(p string) bool {
+	name := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	return hashName(strings.TrimSuffix(name, "_thumb"))
+}
+
+func hashName(name string) bool {
+	return len(name) == 32 && strings.Trim(name, "0123456789abcdef") == ""
+}
+
+func allowed(contentType string, types []string) bool {
+	for _, t := range types {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func isImage(contentType string) bool {
+	return allowed(contentType, Images)
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%g MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%g KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,222 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	return img
+}
+
+func pngBytes(t *testing.T, img image.Image) []byte {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// jpegWithOrientation encodes img with an EXIF segment holding the
+// orientation tag.
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	data := b.Bytes()
+	return append(append(append([]byte{}, data[:2]...), append(app1, segment...)...), data[2:]...)
+}
+
+var ctx = context.Background()
+
+func Test_Save(t *testing.T) {
+	store := Store{Storage: Local{Dir: t.TempDir()}, URL: "/uploads"}
+	opts := Options{MaxBytes: 1 << 20, Types: Images, Thumbnail: 16}
+	data := pngBytes(t, testImage(40, 20))
+
+	file, err := store.Save(ctx, "books", bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(file.Path, "/uploads/books/") || !strings.HasSuffix(file.Path, ".png") {
+		t.Errorf("Path = %q", file.Path)
+	}
+	if file.ContentType != "image/png" || file.Thumbnail != ThumbnailPath(file.Path) {
+		t.Errorf("file = %+v", file)
+	}
+
+	again, err := store.Save(ctx, "books", bytes.NewReader(data), opts)
+	if err != nil || again.Path != file.Path {
+		t.Errorf("same content saved as %q, want %q (%v)", again.Path, file.Path, err)
+	}
+
+	f, _, err := store.Open(ctx, file.Thumbnail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil || config.Width != 16 || config.Height != 8 {
+		t.Errorf("thumbnail is %dx%d (%v), want 16x8", config.Width, config.Height, err)
+	}
+
+	if err := store.Remove(ctx, file.Path); err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := store.Storage.List(ctx, "books/")
+	if len(keys) != 0 {
+		t.Errorf("%v left after Remove", keys)
+	}
+}
+
+func Test_Save_Rejects(t *testing.T) {
+	store := Store{Storage: Local{Dir: t.TempDir()}, URL: "/uploads"}
+	opts := Options{MaxBytes: 1 << 10, Types: Images}
+	for name, data := range map[string][]byte{
+		"empty":      {},
+		"too large":  append(pngBytes(t, testImage(4, 4)), make([]byte, 2<<10)...),
+		"not image":  []byte("<html><script>alert(1)</script></html>"),
+		"truncated":  pngBytes(t, testImage(4, 4))[:40],
+		"pdf posing": []byte("%PDF-1.4\n"),
+	} {
+		_, err := store.Save(ctx, "books", bytes.NewReader(data), opts)
+		if !IsRejected(err) {
+			t.Errorf("%s: err = %v, want a rejection", name, err)
+		}
+	}
+}
+
+func Test_Save_StripsEXIF(t *testing.T) {
+	store := Store{Storage: Local{Dir: t.TempDir()}, URL: "/uploads"}
+	data := jpegWithOrientation(t, testImage(30, 10), 6)
+	if exifOrientation(data) != 6 {
+		t.Fatalf("orientation = %d, want 6", exifOrientation(data))
+	}
+
+	file, err := store.Save(ctx, "profiles", bytes.NewReader(data), Options{MaxBytes: 1 << 20, Types: Images})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := store.Open(ctx, file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	clean, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(clean, []byte("Exif")) {
+		t.Error("EXIF data was kept")
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(clean))
+	if err != nil || config.Width != 10 || config.Height != 30 {
+		t.Errorf("stored image is %dx%d (%v), want it turned upright to 10x30", config.Width, config.Height, err)
+	}
+}
+
+func Test_Store_Key(t *testing.T) {
+	store := Store{URL: "/uploads"}
+	for p, want := range map[string]string{
+		"/uploads/books/a.jpg":        "books/a.jpg",
+		"/uploads/../config/database": "config/database",
+		"/assets/a.jpg":               "",
+		"/uploads/":                   "",
+	} {
+		got, ok := store.Key(p)
+		if got != want || ok != (want != "") {
+			t.Errorf("Key(%q) = %q, %v; want %q", p, got, ok, want)
+		}
+	}
+}
+
+func Test_Store_SignedURL(t *testing.T) {
+	store := Store{Storage: Local{Dir: t.TempDir()}, URL: "/uploads", Secret: []byte("secret"), Private: []string{"cards"}}
+	if !store.IsPrivate("/uploads/cards/a.pdf") || store.IsPrivate("/uploads/books/a.jpg") {
+		t.Error("IsPrivate doesn't go by folder")
+	}
+
+	signed, err := store.SignedURL("/uploads/cards/a.pdf", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	if u.Path != "/uploads/cards/a.pdf" || !store.Verify(u.Path, u.Query()) {
+		t.Errorf("Verify refused %s", signed)
+	}
+	if store.Verify("/uploads/cards/b.pdf", u.Query()) {
+		t.Error("Verify accepted a signature for another upload")
+	}
+	if (Store{URL: "/uploads", Secret: []byte("other")}).Verify(u.Path, u.Query()) {
+		t.Error("Verify accepted a signature made with another secret")
+	}
+
+	expired, _ := store.SignedURL("/uploads/cards/a.pdf", -time.Minute)
+	u, _ = url.Parse(expired)
+	if store.Verify(u.Path, u.Query()) {
+		t.Error("Verify accepted an expired signature")
+	}
+}
+
+func Test_Fit(t *testing.T) {
+	for _, c := range []struct{ w, h, bw, bh, ww, wh int }{
+		{400, 200, 100, 100, 100, 50},
+		{200, 400, 100, 100, 50, 100},
+		{50, 20, 100, 100, 50, 20},
+		{1000, 1, 10, 10, 10, 1},
+	} {
+		b := Fit(testImage(c.w, c.h), c.bw, c.bh).Bounds()
+		if b.Dx() != c.ww || b.Dy() != c.wh {
+			t.Errorf("Fit(%dx%d, %dx%d) = %dx%d, want %dx%d", c.w, c.h, c.bw, c.bh, b.Dx(), b.Dy(), c.ww, c.wh)
+		}
+	}
+}
+
+func Test_Thumbnail(t *testing.T) {
+	if got := Thumbnail("/uploads/books/0123456789abcdef0123456789abcdef.png"); got != "/uploads/books/0123456789abcdef0123456789abcdef_thumb.png" {
+		t.Errorf("Thumbnail of a stored image = %q", got)
+	}
+	if got := Thumbnail("/uploads/books/1697700000.jpg"); got != "/uploads/books/1697700000.jpg" {
+		t.Errorf("Thumbnail of an older upload = %q", got)
+	}
+}
+
+func Test_Immutable(t *testing.T) {
+	for p, want := range map[string]bool{
+		"/uploads/books/0123456789abcdef0123456789abcdef.png":       true,
+		"/uploads/books/0123456789abcdef0123456789abcdef_thumb.png": true,
+		"/uploads/books/1697700000.jpg":                             false,
+		"/uploads/books/0123456789abcdef0123456789abcdeg.png":       false,
+	} {
+		if got := Immutable(p); got != want {
+			t.Errorf("Immutable(%q) = %v", p, got)
+		}
+	}
+}
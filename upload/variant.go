@@ -0,0 +1,135 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Fits are the ways a Variant can be sized.
+const (
+	// FitContain scales the image to fit within the box.
+	FitContain = "contain"
+	// FitCover scales and crops the image to fill the box.
+	FitCover = "cover"
+)
+
+// Variant describes a resized copy of an uploaded image.
+type Variant struct {
+	// Width and Height bound the copy; 0 leaves that side free. Images
+	// are never enlarged beyond their size, except to fill a cover box.
+	Width, Height int
+	Fit           string
+	// Format is "jpeg", "png" or "webp".
+	Format string
+}
+
+// ParseVariant reads a Variant from request params such as w=80, h=100,
+// fit=cover and format=jpeg, allowing only the given sizes so the cache
+// of variants can't be filled with every size imaginable.
+func ParseVariant(w, h, fit, format string, sizes []int) (Variant, error) {
+	v := Variant{Fit: fit, Format: format}
+	var err error
+	if v.Width, err = variantSize(w, sizes); err != nil {
+		return v, err
+	}
+	if v.Height, err = variantSize(h, sizes); err != nil {
+		return v, err
+	}
+	if v.Width == 0 && v.Height == 0 {
+		return v, rejected("Give a width, a height or both.")
+	}
+
+	switch v.Fit {
+	case "":
+		v.Fit = FitContain
+	case FitContain:
+	case FitCover:
+		if v.Width == 0 || v.Height == 0 {
+			return v, rejected("fit=cover needs both a width and a height.")
+		}
+	default:
+		return v, rejected("fit is %q or %q, not %q.", FitContain, FitCover, fit)
+	}
+
+	switch v.Format {
+	case "", "jpg", "jpeg":
+		v.Format = "jpeg"
+	case "png", "webp":
+	default:
+		return v, rejected("format is jpeg, png or webp, not %q.", format)
+	}
+	return v, nil
+}
+
+func variantSize(s string, sizes []int) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		for _, size := range sizes {
+			if n == size {
+				return n, nil
+			}
+		}
+	}
+	return 0, rejected("%q is not one of the image sizes allowed: %v.", s, sizes)
+}
+
+// Name names the variant's file among an image's other variants, as in
+// "80x100-cover.jpg".
+func (v Variant) Name() string {
+	return fmt.Sprintf("%dx%d-%s%s", v.Width, v.Height, v.Fit, extensions[v.ContentType()])
+}
+
+// ContentType is the type of the variant's file.
+func (v Variant) ContentType() string {
+	return "image/" + v.Format
+}
+
+// Make reads an image from r and returns the variant of it.
+func (v Variant) Make(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, rejected("The file is not an image.")
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, rejected("The image is larger than %d megapixels.", MaxPixels/1000000)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, rejected("The image can't be read.")
+	}
+	// images stored before uploads were cleaned may still be sideways
+	if format == "jpeg" {
+		img = orient(toRGBA(img), exifOrientation(data))
+	}
+
+	width, height := v.Width, v.Height
+	if width == 0 {
+		width = img.Bounds().Dx()
+	}
+	if height == 0 {
+		height = img.Bounds().Dy()
+	}
+	if v.Fit == FitCover {
+		img = Fill(img, width, height)
+	} else {
+		img = Fit(img, width, height)
+	}
+
+	var b bytes.Buffer
+	if err := encode(&b, img, v.ContentType()); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package upload
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+var sizes = []int{40, 80, 100}
+
+func Test_ParseVariant(t *testing.T) {
+	v, err := ParseVariant("80", "100", "cover", "webp", sizes)
+	if err != nil || v != (Variant{Width: 80, Height: 100, Fit: FitCover, Format: "webp"}) {
+		t.Errorf("ParseVariant = %+v, %v", v, err)
+	}
+	if v.Name() != "80x100-cover.webp" || v.ContentType() != "image/webp" {
+		t.Errorf("Name = %q, ContentType = %q", v.Name(), v.ContentType())
+	}
+
+	for _, bad := range [][4]string{
+		{"", "", "", ""},
+		{"81", "", "", ""},
+		{"80", "", "cover", ""},
+		{"80", "", "stretch", ""},
+		{"80", "", "", "bmp"},
+		{"-80", "", "", ""},
+	} {
+		if _, err := ParseVariant(bad[0], bad[1], bad[2], bad[3], sizes); !IsRejected(err) {
+			t.Errorf("ParseVariant%q: err = %v, want a rejection", bad, err)
+		}
+	}
+}
+
+func Test_Variant_Make(t *testing.T) {
+	src := pngBytes(t, testImage(200, 100))
+	for _, c := range []struct {
+		v    Variant
+		w, h int
+	}{
+		{Variant{Width: 80, Height: 100, Fit: FitCover, Format: "png"}, 80, 100},
+		{Variant{Width: 80, Height: 100, Fit: FitContain, Format: "png"}, 80, 40},
+		{Variant{Height: 40, Fit: FitContain, Format: "jpeg"}, 80, 40},
+		{Variant{Width: 400, Fit: FitContain, Format: "jpeg"}, 200, 100},
+	} {
+		data, err := c.v.Make(bytes.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || config.Width != c.w || config.Height != c.h || "image/"+format != c.v.ContentType() {
+			t.Errorf("%+v made a %dx%d %s (%v), want %dx%d", c.v, config.Width, config.Height, format, err, c.w, c.h)
+		}
+	}
+
+	data, err := (Variant{Width: 40, Fit: FitContain, Format: "webp"}).Make(bytes.NewReader(src))
+	if err != nil || len(data) < 16 || string(data[:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8L" {
+		t.Errorf("a WebP variant wasn't made: %v", err)
+	}
+
+	if _, err := (Variant{Width: 40, Fit: FitContain, Format: "png"}).Make(bytes.NewReader([]byte("not an image"))); !IsRejected(err) {
+		t.Errorf("Make of a non-image: %v", err)
+	}
+}
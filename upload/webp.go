@@ -0,0 +1,414 @@
+package upload
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// maxWebPSide is the widest and tallest a WebP image can be.
+const maxWebPSide = 1 << 14
+
+// EncodeWebP writes img to w as a lossless WebP image. There is no WebP
+// encoder in the standard library, so this is a small one: the pixels
+// have the subtract green transform applied and are prefix coded, with
+// runs that repeat the pixel to the left or above copied rather than
+// spelled out. That is all covers and pictures need to come out smaller
+// than a PNG.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > maxWebPSide || height > maxWebPSide {
+		return errors.Errorf("a %dx%d image can't be a WebP", width, height)
+	}
+
+	rgba := toRGBA(img)
+	argb := make([]uint32, width*height)
+	alpha := false
+	for i := range argb {
+		o := rgba.PixOffset(i%width, i/width)
+		p := rgba.Pix[o : o+4 : o+4]
+		// undo the alpha premultiplication of image.RGBA
+		r, g, bl, a := uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		if a != 0xff {
+			alpha = true
+			if a != 0 {
+				r, g, bl = (r*0xff+a/2)/a, (g*0xff+a/2)/a, (bl*0xff+a/2)/a
+			} else {
+				r, g, bl = 0, 0, 0
+			}
+		}
+		// the subtract green transform
+		argb[i] = a<<24 | ((r-g)&0xff)<<16 | g<<8 | (bl-g)&0xff
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+	// one transform, subtract green, then no more
+	bw.write(1, 1)
+	bw.write(2, 2)
+	bw.write(0, 1)
+	// no color cache and a single group of prefix codes
+	bw.write(0, 1)
+	bw.write(0, 1)
+	writeWebPPixels(bw, argb, width)
+	data := bw.bytes()
+
+	size := len(data)
+	if size%2 == 1 {
+		data = append(data, 0)
+	}
+	header := make([]byte, 20)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+len(data)))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(size))
+	if _, err := w.Write(header); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := w.Write(data)
+	return errors.WithStack(err)
+}
+
+// The alphabets of the five prefix codes: green with the lengths of
+// copies after the 256 literals, red, blue, alpha and the distances of
+// copies.
+const (
+	webpGreen = iota
+	webpRed
+	webpBlue
+	webpAlpha
+	webpDistance
+)
+
+var webpAlphabets = [5]int{256 + 24, 256, 256, 256, 40}
+
+// The distance codes of copies from the pixel above and the pixel to
+// the left, from the spec's table of nearby pixels.
+const (
+	webpAbove = 1
+	webpLeft  = 2
+)
+
+// maxWebPCopy is the longest a copy can be.
+const maxWebPCopy = 4096
+
+// webpSymbol is a pixel or a copy: a pixel's ARGB, or the length and
+// distance code of a copy.
+type webpSymbol struct {
+	argb     uint32
+	length   int
+	distance int
+}
+
+// writeWebPPixels writes the prefix codes and the pixels coded with them.
+func writeWebPPixels(bw *bitWriter, argb []uint32, width int) {
+	var symbols []webpSymbol
+	for i := 0; i < len(argb); {
+		length, distance := 0, 0
+		if i >= 1 {
+			length, distance = webpRun(argb, i, 1), webpLeft
+		}
+		if i >= width {
+			if n := webpRun(argb, i, width); n > length {
+				length, distance = n, webpAbove
+			}
+		}
+		if length >= 3 {
+			symbols = append(symbols, webpSymbol{length: length, distance: distance})
+			i += length
+			continue
+		}
+		symbols = append(symbols, webpSymbol{argb: argb[i]})
+		i++
+	}
+
+	var counts [5][]int
+	for k := range counts {
+		counts[k] = make([]int, webpAlphabets[k])
+	}
+	for _, s := range symbols {
+		if s.length == 0 {
+			counts[webpGreen][s.argb>>8&0xff]++
+			counts[webpRed][s.argb>>16&0xff]++
+			counts[webpBlue][s.argb&0xff]++
+			counts[webpAlpha][s.argb>>24]++
+			continue
+		}
+		code, _, _ := webpPrefix(s.length)
+		counts[webpGreen][256+code]++
+		code, _, _ = webpPrefix(s.distance)
+		counts[webpDistance][code]++
+	}
+	var codes [5]prefixCode
+	for k := range codes {
+		codes[k] = newPrefixCode(counts[k], 15)
+		codes[k].writeTo(bw)
+	}
+
+	for _, s := range symbols {
+		if s.length == 0 {
+			codes[webpGreen].put(bw, int(s.argb>>8&0xff))
+			codes[webpRed].put(bw, int(s.argb>>16&0xff))
+			codes[webpBlue].put(bw, int(s.argb&0xff))
+			codes[webpAlpha].put(bw, int(s.argb>>24))
+			continue
+		}
+		code, extra, n := webpPrefix(s.length)
+		codes[webpGreen].put(bw, 256+code)
+		bw.write(extra, n)
+		code, extra, n = webpPrefix(s.distance)
+		codes[webpDistance].put(bw, code)
+		bw.write(extra, n)
+	}
+}
+
+// webpRun is how many pixels from i repeat those distance before them.
+func webpRun(argb []uint32, i, distance int) int {
+	n := 0
+	for i+n < len(argb) && n < maxWebPCopy && argb[i+n] == argb[i+n-distance] {
+		n++
+	}
+	return n
+}
+
+// webpPrefix splits a copy's length or distance code into the prefix
+// that is prefix coded and the extra bits written after it.
+func webpPrefix(v int) (code int, extra uint32, bits uint) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	high := uint(0)
+	for d>>(high+1) != 0 {
+		high++
+	}
+	second := d >> (high - 1) & 1
+	bits = high - 1
+	return int(2*high) + second, uint32(d) & (1<<bits - 1), bits
+}
+
+// prefixCode is a canonical prefix code, as in DEFLATE, with the codes
+// bit-reversed to be written least significant bit first.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+	// single is the only symbol used, if there is just one; it is
+	// written as no bits at all.
+	single int
+}
+
+// newPrefixCode builds a prefix code for symbols seen counts times, none
+// longer than limit bits.
+func newPrefixCode(counts []int, limit int) prefixCode {
+	pc := prefixCode{lengths: make([]int, len(counts)), codes: make([]uint32, len(counts)), single: -1}
+	used := []int{}
+	for s, n := range counts {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+	switch len(used) {
+	case 0:
+		pc.single = 0
+		return pc
+	case 1:
+		pc.single = used[0]
+		return pc
+	}
+
+	// raise the rarest counts until the tree is shallow enough
+	for least := 1; ; least *= 2 {
+		weights := make([]int, len(counts))
+		for _, s := range used {
+			weights[s] = counts[s]
+			if weights[s] < least {
+				weights[s] = least
+			}
+		}
+		if huffmanLengths(weights, used, pc.lengths) <= limit {
+			break
+		}
+	}
+
+	var perLength [16]uint32
+	for _, l := range pc.lengths {
+		perLength[l]++
+	}
+	perLength[0] = 0
+	var next [16]uint32
+	code := uint32(0)
+	for l := 1; l < 16; l++ {
+		code = (code + perLength[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range pc.lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		for i := 0; i < l; i++ {
+			pc.codes[s] = pc.codes[s]<<1 | c>>i&1
+		}
+	}
+	return pc
+}
+
+// huffmanLengths sets the code lengths of the symbols used from their
+// weights and returns the longest.
+func huffmanLengths(weights []int, used []int, lengths []int) int {
+	type node struct {
+		weight      int
+		symbol      int
+		left, right *node
+	}
+	nodes := make([]*node, 0, len(used))
+	for _, s := range used {
+		nodes = append(nodes, &node{weight: weights[s], symbol: s})
+	}
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].weight < nodes[j].weight })
+		joined := &node{weight: nodes[0].weight + nodes[1].weight, symbol: -1, left: nodes[0], right: nodes[1]}
+		nodes = append([]*node{joined}, nodes[2:]...)
+	}
+
+	longest := 0
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if n.symbol >= 0 {
+			lengths[n.symbol] = depth
+			if depth > longest {
+				longest = depth
+			}
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(nodes[0], 0)
+	return longest
+}
+
+// put writes symbol s.
+func (pc prefixCode) put(bw *bitWriter, s int) {
+	if pc.single >= 0 {
+		return
+	}
+	bw.write(pc.codes[s], uint(pc.lengths[s]))
+}
+
+// codeLengthOrder is the order the lengths of the code length code are
+// written in.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writeTo writes the code: a single symbol as a simple code, any other
+// as its code lengths, themselves prefix coded with runs of zeros
+// shortened.
+func (pc prefixCode) writeTo(bw *bitWriter) {
+	if pc.single >= 0 {
+		bw.write(1, 1)
+		bw.write(0, 1)
+		if pc.single < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(pc.single), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(pc.single), 8)
+		}
+		return
+	}
+
+	// code lengths, with 17 and 18 for 3 to 10 and 11 to 138 zeros
+	type token struct {
+		symbol int
+		extra  uint32
+		bits   uint
+	}
+	tokens := []token{}
+	for i := 0; i < len(pc.lengths); {
+		if pc.lengths[i] != 0 {
+			tokens = append(tokens, token{symbol: pc.lengths[i]})
+			i++
+			continue
+		}
+		zeros := 0
+		for i+zeros < len(pc.lengths) && pc.lengths[i+zeros] == 0 && zeros < 138 {
+			zeros++
+		}
+		switch {
+		case zeros >= 11:
+			tokens = append(tokens, token{symbol: 18, extra: uint32(zeros - 11), bits: 7})
+		case zeros >= 3:
+			tokens = append(tokens, token{symbol: 17, extra: uint32(zeros - 3), bits: 3})
+		default:
+			zeros = 1
+			tokens = append(tokens, token{})
+		}
+		i += zeros
+	}
+
+	counts := make([]int, 19)
+	for _, t := range tokens {
+		counts[t.symbol]++
+	}
+	lengthCode := newPrefixCode(counts, 7)
+	lengthLengths := lengthCode.lengths
+	if lengthCode.single >= 0 {
+		// a code of one symbol is read as no bits, whatever its length
+		lengthLengths = make([]int, 19)
+		lengthLengths[lengthCode.single] = 1
+	}
+	n := len(codeLengthOrder)
+	for n > 4 && lengthLengths[codeLengthOrder[n-1]] == 0 {
+		n--
+	}
+
+	bw.write(0, 1)
+	bw.write(uint32(n-4), 4)
+	for _, s := range codeLengthOrder[:n] {
+		bw.write(uint32(lengthLengths[s]), 3)
+	}
+	// the lengths of the whole alphabet follow
+	bw.write(0, 1)
+	for _, t := range tokens {
+		lengthCode.put(bw, t.symbol)
+		bw.write(t.extra, t.bits)
+	}
+}
+
+// bitWriter writes bits least significant first.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	count uint
+}
+
+func (bw *bitWriter) write(v uint32, bits uint) {
+	bw.acc |= uint64(v&(1<<bits-1)) << bw.count
+	bw.count += bits
+	for bw.count >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.count -= 8
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	if bw.count > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.count = 0, 0
+	}
+	return bw.buf
+}
@@ -0,0 +1,54 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func Test_EncodeWebP(t *testing.T) {
+	var b bytes.Buffer
+	if err := EncodeWebP(&b, testImage(123, 45)); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if len(data) < 25 || string(data[:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8L" {
+		t.Fatalf("not a lossless WebP: % x", data[:16])
+	}
+	if size := binary.LittleEndian.Uint32(data[4:]); int(size) != len(data)-8 {
+		t.Errorf("RIFF size %d, file of %d bytes", size, len(data))
+	}
+	if data[20] != 0x2f {
+		t.Errorf("signature %#x", data[20])
+	}
+	bits := binary.LittleEndian.Uint32(data[21:])
+	if w, h := bits&(1<<14-1)+1, bits>>14&(1<<14-1)+1; w != 123 || h != 45 {
+		t.Errorf("header says %dx%d", w, h)
+	}
+
+	if err := EncodeWebP(&b, testImage(1<<14+1, 1)); err == nil {
+		t.Error("encoded an image too wide for WebP")
+	}
+}
+
+func Test_webpPrefix(t *testing.T) {
+	for _, c := range []struct {
+		v     int
+		code  int
+		extra uint32
+		bits  uint
+	}{
+		{1, 0, 0, 0},
+		{4, 3, 0, 0},
+		{5, 4, 0, 1},
+		{6, 4, 1, 1},
+		{7, 5, 0, 1},
+		{9, 6, 0, 2},
+		{4096, 23, 1023, 10},
+	} {
+		code, extra, bits := webpPrefix(c.v)
+		if code != c.code || extra != c.extra || bits != c.bits {
+			t.Errorf("webpPrefix(%d) = %d, %d, %d, want %d, %d, %d", c.v, code, extra, bits, c.code, c.extra, c.bits)
+		}
+	}
+}